@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/lib/tool"
+	"github.com/snowmerak/ttobot/pkg/markdown"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+	"github.com/snowmerak/ttobot/pkg/ollama"
+	"github.com/snowmerak/ttobot/pkg/openai"
+	"github.com/snowmerak/ttobot/pkg/servers"
+)
+
+// appOptions holds the command-line settings of a chat session
+type appOptions struct {
+	// dryRun records the calls of tools that are not read-only instead of running them
+	dryRun bool
+
+	// verbose prints the tool calls and results of each run
+	verbose bool
+
+	// showThinking prints the model's reasoning, dimmed, while streaming
+	showThinking bool
+
+	// noColor turns ANSI colors off, as NO_COLOR does
+	noColor bool
+
+	// scripted sends all output but the answer to standard error and runs
+	// destructive tools without asking, unless confirmDestructive is set
+	scripted           bool
+	confirmDestructive bool
+
+	// serving answers API clients: all output goes to standard error and,
+	// as there is no one to ask, destructive tools that need confirmation
+	// are blocked
+	serving bool
+
+	// signals stops turns on SIGINT (nil: signals are not handled)
+	signals *signalHandler
+
+	// source is the config file as loaded, for /profile (zero: profiles
+	// cannot be switched)
+	source configSource
+
+	// quiet hides the progress of the servers and the model check at startup
+	quiet bool
+
+	// noStats hides the stats line printed after each answer
+	noStats bool
+}
+
+// app is a chat session: the connected servers, the model provider and
+// the conversation
+type app struct {
+	config *mcpConfig.ConfigFile
+	agent  mcpConfig.AgentSettings
+
+	mcpClient    *mcp.Client
+	provider     llm.ChatProvider
+	toolHandler  llm.ToolHandler
+	conversation *llm.Conversation
+	opts         llm.Opts
+	verbose      bool
+	showThinking bool
+
+	// renderMarkdown renders answers for the terminal, with ANSI styles
+	// when color is set; off when standard output is not a terminal, so
+	// piped answers stay plain markdown
+	renderMarkdown bool
+	color          bool
+
+	// tools are the tools offered to the model; with agent.max_tools, each
+	// turn offers only the most relevant of them
+	tools []tool.Tool
+
+	// lastRun is the latest agent run, shown by /trace (nil: none yet)
+	lastRun *llm.AgentResult
+
+	// stats totals the turns of the session, shown by /stats
+	stats sessionStats
+
+	// attachments are the files /attach added to the next question
+	attachments []attachment
+
+	// The conversation is saved to sessionPath in sessionsDir after every
+	// answer (empty: not saved)
+	sessionsDir string
+	sessionPath string
+
+	// signals stops turns on SIGINT (nil: signals are not handled)
+	signals *signalHandler
+
+	// options are the command-line settings the session started with
+	options appOptions
+
+	dryRunCalls *tool.CallRecorder // nil unless dry running
+	toolCache   *tool.Cache        // nil unless agent.tool_cache is set
+
+	in  *bufio.Reader
+	out io.Writer
+
+	// pendingRead delivers the line of a read that readLine gave up on
+	// (nil: none); readLock serializes the readers of in
+	readLock    sync.Mutex
+	pendingRead chan lineRead
+}
+
+// newApp connects to the configured servers and creates the model provider
+func newApp(ctx context.Context, configFile *mcpConfig.ConfigFile, opts appOptions) (_ *app, err error) {
+	a := &app{
+		config:       configFile,
+		agent:        configFile.Agent.Settings(),
+		verbose:      opts.verbose,
+		showThinking: opts.showThinking,
+		signals:      opts.signals,
+		options:      opts,
+
+		renderMarkdown: stdoutIsTerminal() && !opts.scripted && !opts.serving,
+		color:          stdoutIsTerminal() && !opts.noColor && os.Getenv("NO_COLOR") == "",
+		in:             bufio.NewReader(os.Stdin),
+		out:            os.Stdout,
+	}
+	if opts.scripted || opts.serving {
+		a.out = os.Stderr
+	}
+
+	// Create and connect MCP client
+	a.mcpClient = mcp.NewClient("ttobot", "1.0.0")
+	if a.agent.ToolCache {
+		a.toolCache = tool.NewCache(a.agent.ToolCacheTTL, a.agent.ToolCacheEntries)
+		a.mcpClient.SetToolCache(a.toolCache)
+	}
+	// Servers connected before a failure must not outlive ttobot
+	defer func() {
+		if err != nil {
+			a.close()
+		}
+	}()
+	// The model is checked while the servers start; the prompt appears
+	// only once both have settled
+	progress := newStartupProgress(a.out, opts.quiet)
+	defer progress.close()
+	modelStep := progress.begin("model")
+	modelChecked := make(chan string, 1)
+	go func() {
+		modelChecked <- checkStartupModel(ctx, configFile, progress, modelStep)
+	}()
+
+	// The servers outlive a cancelled ctx: close stops them gracefully
+	if err := a.connectServers(context.WithoutCancel(ctx), configFile.Servers, progress); err != nil {
+		return nil, fmt.Errorf("failed to connect to MCP servers: %w", err)
+	}
+	if err := connectBuiltins(context.WithoutCancel(ctx), a.mcpClient, configFile, progress); err != nil {
+		return nil, err
+	}
+	hint := <-modelChecked
+	progress.close()
+	if hint != "" && !opts.quiet {
+		fmt.Fprintf(a.out, "💡 %s\n", hint)
+	}
+
+	if disabled := configFile.DisabledServers(); len(disabled) > 0 {
+		names := make([]string, len(disabled))
+		for i, config := range disabled {
+			names[i] = config.Name
+		}
+		fmt.Fprintf(a.out, "⏸️  Disabled servers: %s\n", strings.Join(names, ", "))
+	}
+
+	if err := a.loadTools(ctx); err != nil {
+		return nil, err
+	}
+	a.conversation = llm.NewConversation(llm.BuildSystemPrompt(a.tools, a.mcpClient.Instructions(), configFile.SystemPrompt))
+	a.startSession()
+	return a, nil
+}
+
+// loadTools offers the tools of the connected servers to a new model
+// provider and sets the agent options, following the config file
+func (a *app) loadTools(ctx context.Context) error {
+	opts := a.options
+	tools, err := a.mcpClient.Tools(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tools: %w", err)
+	}
+
+	if maxTools := a.agent.MaxTools; maxTools > 0 && len(tools) > maxTools {
+		slog.Info("Tools: Offering only the most relevant tools for each question (agent.max_tools)", "tools", len(tools), "max_tools", maxTools)
+	}
+
+	// In a dry run only read-only tools run, so there is nothing to confirm.
+	// Otherwise ask before running destructive tools, except in scripts that
+	// did not ask for it. Without a terminal to ask on, agent.confirm_fallback
+	// answers.
+	confirm := (a.agent.ConfirmDestructive && !opts.scripted) || opts.confirmDestructive
+	if opts.dryRun {
+		if a.dryRunCalls == nil {
+			a.dryRunCalls = &tool.CallRecorder{}
+		}
+		tools = tool.DryRun(tools, a.dryRunCalls)
+		slog.Info("Tools: Dry run, only read-only tools will run")
+	} else if confirm {
+		var confirmer tool.Confirmer = tool.NewLineConfirmer(a.readLine, a.out)
+		if opts.serving || !stdinIsTerminal() {
+			confirmer = a.confirmFallback(opts.serving)
+		}
+		tools = tool.RequireConfirmation(tools, confirmer)
+	}
+
+	provider, toolHandler, err := newProvider(a.config, tools)
+	if err != nil {
+		return fmt.Errorf("failed to create %s client: %w", a.config.Provider, err)
+	}
+	a.tools = tools
+	a.provider = provider
+	a.toolHandler = toolHandler
+	a.opts = llm.Opts{
+		MaxIterations: a.agent.MaxIterations,
+		ToolBudget: llm.ToolBudget{
+			MaxCalls:        a.agent.MaxToolCalls,
+			MaxCallsPerTool: a.agent.MaxToolCallsPerTool,
+			MaxDuration:     a.agent.MaxToolDuration,
+		},
+	}
+	return nil
+}
+
+// turnOpts returns the agent options for a turn answering question
+func (a *app) turnOpts(question string) llm.Opts {
+	opts := a.opts
+	opts.ToolNames = a.selectTools(question)
+	return opts
+}
+
+// selectTools names the tools most relevant to a question when more than
+// agent.max_tools are connected (nil: all of them are offered)
+func (a *app) selectTools(question string) []string {
+	maxTools := a.agent.MaxTools
+	if maxTools <= 0 || len(a.tools) <= maxTools {
+		return nil
+	}
+
+	selected := tool.SelectTools(a.tools, question, maxTools, nil)
+	names := make([]string, len(selected))
+	for i, t := range selected {
+		names[i] = t.Name
+	}
+	slog.Debug("Tools: Offering the most relevant tools (agent.max_tools)", "tools", names)
+	return names
+}
+
+// ask runs the agent loop on a question until the model answers and prints
+// the answer. With verbose set, the tool calls and results are printed too.
+func (a *app) ask(ctx context.Context, question string) error {
+	a.conversation.AddUser(question)
+
+	started := time.Now()
+	result, err := llm.ChatWithTools(ctx, a.provider, a.toolHandler, a.conversation, a.turnOpts(question))
+	a.lastRun = result
+	a.saveSession()
+	if err != nil {
+		a.stats.record(result, time.Since(started))
+		if advice := errorAdvice(err, a.config); advice != "" {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", advice)
+		}
+		return err
+	}
+
+	if a.verbose {
+		a.printTrace()
+	} else if calls := result.Usage.ToolCalls; calls > 0 {
+		fmt.Fprintf(a.out, "🔧 %d tool calls\n", calls)
+	}
+
+	fmt.Fprintf(a.out, "\n%s\n", a.renderAnswer(strings.TrimSpace(result.Response.Message.Content)))
+	if result.Incomplete {
+		fmt.Fprintf(a.out, "⚠️  Stopped after %d model calls; this summarizes the findings so far.\n", result.Iterations)
+	}
+	a.printTurnStats(result, time.Since(started))
+	return nil
+}
+
+// confirmFallback returns the confirmer answering for the user when no one
+// can be asked, following agent.confirm_fallback
+func (a *app) confirmFallback(serving bool) tool.Confirmer {
+	reason := "Standard input is not a terminal"
+	if serving {
+		reason = "Serving the API"
+	}
+	if a.agent.ConfirmApprove {
+		slog.Warn("Tools: " + reason + ", destructive tools will run without asking (agent.confirm_fallback)")
+		return tool.AutoApprove
+	}
+	slog.Info("Tools: " + reason + ", destructive tools will be blocked (agent.confirm_fallback)")
+	return tool.AutoDeny
+}
+
+// renderAnswer renders an answer's markdown for the terminal, ending it
+// with a newline; it is kept as is when rendering is off
+func (a *app) renderAnswer(answer string) string {
+	if !a.renderMarkdown {
+		return answer + "\n"
+	}
+	return markdown.Render(answer, a.markdownOptions())
+}
+
+// markdownOptions returns the rendering options for the terminal's
+// current width
+func (a *app) markdownOptions() markdown.Options {
+	return markdown.Options{Width: terminalWidth(), Color: a.color}
+}
+
+// traceResultChars caps each tool result printed by printTrace
+const traceResultChars = 500
+
+// printTrace prints the tool calls and results of the latest run
+func (a *app) printTrace() {
+	if a.lastRun == nil {
+		fmt.Fprintln(a.out, "Nothing has run yet")
+		return
+	}
+
+	steps := 0
+	for _, message := range a.lastRun.Messages {
+		switch message.Role {
+		case llm.RoleAssistant:
+			if len(message.ToolCalls) == 0 {
+				continue
+			}
+			if content := strings.TrimSpace(message.Content); content != "" {
+				fmt.Fprintf(a.out, "💭 %s\n", content)
+			}
+			for _, call := range message.ToolCalls {
+				fmt.Fprintf(a.out, "🔧 %s %v\n", call.Name, call.Arguments)
+				steps++
+			}
+		case llm.RoleTool:
+			content := truncateRunes(strings.TrimSpace(message.Content), traceResultChars)
+			fmt.Fprintf(a.out, "📄 %s\n%s\n", message.ToolName, indent(content, "   "))
+		}
+	}
+	if steps == 0 {
+		fmt.Fprintln(a.out, "No tools were called in the last run")
+	}
+}
+
+// indent prefixes every line of s
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// finish saves the conversation and prints what the session left behind:
+// the totals of a session of several turns, dry-run calls and cache hits
+func (a *app) finish() {
+	a.saveSession()
+
+	if a.stats.turns > 1 {
+		a.printSessionStats()
+	}
+
+	if a.dryRunCalls != nil {
+		if calls := a.dryRunCalls.Calls(); len(calls) > 0 {
+			fmt.Fprintf(a.out, "🧪 Dry run, %d tool calls not executed:\n%s", len(calls), a.dryRunCalls.Snapshot())
+		}
+	}
+
+	if a.toolCache != nil {
+		if stats := a.toolCache.Stats(); stats.Hits+stats.Misses > 0 {
+			fmt.Fprintf(a.out, "📦 Tool cache: %d hits, %d misses\n", stats.Hits, stats.Misses)
+		}
+	}
+}
+
+// connectServers connects the enabled servers at once, showing each on
+// progress as it settles. Every server that fails is reported.
+func (a *app) connectServers(ctx context.Context, configs []mcpConfig.Config, progress *startupProgress) error {
+	errs := make([]error, len(configs))
+	var wg sync.WaitGroup
+	for i, config := range configs {
+		if !config.IsEnabled() {
+			slog.Info("MCP: Skipping disabled server", "server", config.Name)
+			continue
+		}
+		step := progress.begin(config.Name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.mcpClient.ConnectFromConfig(ctx, config); err != nil {
+				errs[i] = fmt.Errorf("failed to connect to server %s: %w", config.Name, err)
+				progress.finish(step, stepFailed, connectFailure(err))
+				return
+			}
+			progress.finish(step, stepDone, toolCount(ctx, a.mcpClient, config.Name))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// toolCount describes the tools a connected server offers, such as 9 tools
+func toolCount(ctx context.Context, client *mcp.Client, serverID string) string {
+	server, err := client.Server(ctx, serverID)
+	if err != nil {
+		return "connected"
+	}
+	count := fmt.Sprintf("%d tools", server.Tools)
+	if server.FilteredTools > 0 {
+		count += fmt.Sprintf(", %d hidden", server.FilteredTools)
+	}
+	return count
+}
+
+// checkStartupModel checks the model server and the model on progress and
+// returns advice when something is wrong. A failed check does not stop
+// the session: the model can still be switched with /model.
+func checkStartupModel(ctx context.Context, configFile *mcpConfig.ConfigFile, progress *startupProgress, step *progressStep) string {
+	if progress == nil {
+		return ""
+	}
+	// A failure is shown alone, without the checks that passed
+	state, hint := stepDone, ""
+	var details, failures []string
+	for _, check := range checkModel(ctx, configFile) {
+		switch check.Status {
+		case checkFail:
+			state = stepFailed
+			failures = append(failures, check.Detail)
+		case checkWarn:
+			state = max(state, stepWarning)
+		}
+		if check.Detail != "" && check.Status != checkSkip {
+			details = append(details, check.Detail)
+		}
+		if hint == "" {
+			hint = check.Hint
+		}
+	}
+	if len(failures) > 0 {
+		details = failures
+	}
+	progress.finish(step, state, strings.Join(details, ", "))
+	return hint
+}
+
+// connectBuiltins runs the builtin servers in-process, registered under
+// their short prefixes, showing each on progress (nil: none)
+func connectBuiltins(ctx context.Context, client *mcp.Client, configFile *mcpConfig.ConfigFile, progress *startupProgress) error {
+	for _, name := range configFile.BuiltinServers {
+		prefix, _ := mcpConfig.BuiltinPrefix(name)
+		step := progress.begin(prefix)
+		server, err := servers.New(name, configFile.BuiltinRoot)
+		if err != nil {
+			progress.finish(step, stepFailed, err.Error())
+			return fmt.Errorf("failed to create builtin server %s: %w", name, err)
+		}
+		if err := client.ConnectInProcess(ctx, prefix, server); err != nil {
+			progress.finish(step, stepFailed, connectFailure(err))
+			return fmt.Errorf("failed to connect to builtin server %s: %w", name, err)
+		}
+		progress.finish(step, stepDone, toolCount(ctx, client, prefix))
+		slog.Info("MCP: Running builtin server in-process", "server", name, "prefix", prefix)
+	}
+	return nil
+}
+
+// newProvider creates the configured model provider with the tools set
+func newProvider(configFile *mcpConfig.ConfigFile, tools []tool.Tool) (llm.ChatProvider, llm.ToolHandler, error) {
+	agent := configFile.Agent.Settings()
+	switch configFile.Provider {
+	case mcpConfig.ProviderOpenAI:
+		client, err := openai.NewClient(openai.ClientOptions{
+			BaseURL:            configFile.OpenAI.BaseURL,
+			APIKey:             configFile.OpenAI.APIKey(),
+			Model:              configFile.OpenAI.Model,
+			RequestTimeout:     agent.RequestTimeout,
+			MaxToolResultChars: agent.MaxToolResultChars,
+			Prompts:            llm.Prompts(configFile.Prompts),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		client.SetTools(tools)
+		return client, client, nil
+	default:
+		client, err := ollama.NewClient(ollama.ClientOptions{
+			URL:                configFile.Ollama.URL,
+			Model:              configFile.Ollama.Model,
+			RequestTimeout:     configFile.Ollama.RequestTimeout(agent.RequestTimeout),
+			MaxToolResultChars: agent.MaxToolResultChars,
+			BearerToken:        configFile.Ollama.AuthToken(),
+			Prompts:            llm.Prompts(configFile.Prompts),
+			Options:            configFile.Ollama.Options,
+			KeepAlive:          configFile.Ollama.KeepAliveDuration(),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		client.SetTools(tools)
+		provider := ollama.NewProvider(client)
+		return provider, provider, nil
+	}
+}
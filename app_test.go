@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// offeredRecorder answers every request at once, recording the tools each
+// one offered the model
+type offeredRecorder struct {
+	tools   []tool.Tool
+	offered [][]string
+}
+
+func (p *offeredRecorder) Chat(ctx context.Context, messages []llm.Message, opts llm.Opts) (*llm.Response, error) {
+	var names []string
+	for _, t := range opts.OfferedTools(p.tools) {
+		names = append(names, t.Name)
+	}
+	p.offered = append(p.offered, names)
+	return &llm.Response{
+		Message: llm.Message{Role: llm.RoleAssistant, Content: "Done."},
+		Done:    true,
+		Usage:   llm.Usage{Requests: 1},
+	}, nil
+}
+
+func (p *offeredRecorder) ChatStream(ctx context.Context, messages []llm.Message, callback func(llm.Response) error, opts llm.Opts) error {
+	resp, err := p.Chat(ctx, messages, opts)
+	if err != nil {
+		return err
+	}
+	return callback(*resp)
+}
+
+func TestMaxToolsSelectsPerQuestion(t *testing.T) {
+	newTool := func(name, description string) tool.Tool {
+		return tool.Tool{Name: name, Description: description}
+	}
+	tools := []tool.Tool{
+		newTool("fs:read_file", "Reads a file"),
+		newTool("fs:write_file", "Writes a file"),
+		newTool("fs:list_directory", "Lists the entries of a directory"),
+		newTool("git:git_log", "Shows the commit log"),
+		newTool("github:search_issues", "Searches the issues of a GitHub repository"),
+	}
+	provider := &offeredRecorder{tools: tools}
+	a := &app{
+		config:       &mcpConfig.ConfigFile{},
+		agent:        mcpConfig.AgentSettings{MaxTools: 2},
+		provider:     provider,
+		conversation: llm.NewConversation(""),
+		tools:        tools,
+		options:      appOptions{noStats: true},
+		out:          io.Discard,
+	}
+
+	questions := []struct {
+		question string
+		want     []string
+	}{
+		// Nothing to rank by: the first tools are offered
+		{"", []string{"fs:read_file", "fs:write_file"}},
+		{"Which GitHub issues mention a login bug?", []string{"fs:read_file", "github:search_issues"}},
+		{"Show commit log from last week", []string{"fs:read_file", "git:git_log"}},
+		{"List directory entries, then read a file", []string{"fs:read_file", "fs:list_directory"}},
+	}
+	for i, q := range questions {
+		var err error
+		if i%2 == 0 {
+			err = a.ask(context.Background(), q.question)
+		} else {
+			err = a.askStreaming(context.Background(), q.question)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := provider.offered[len(provider.offered)-1]; !slices.Equal(got, q.want) {
+			t.Errorf("%q offered %v, want %v", q.question, got, q.want)
+		}
+	}
+
+	// Without max_tools every tool is offered
+	a.agent.MaxTools = 0
+	if err := a.ask(context.Background(), "Which GitHub issues mention a login bug?"); err != nil {
+		t.Fatal(err)
+	}
+	if got := provider.offered[len(provider.offered)-1]; len(got) != len(tools) {
+		t.Errorf("offered %v, want all tools", got)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// maxAttachBytes caps a file attached with /attach; the rest is dropped
+// with a notice
+const maxAttachBytes = 256 << 10
+
+// errBinaryFile is returned for a file to attach that is not text
+var errBinaryFile = errors.New("the file looks like binary data, not text")
+
+// attachment is a file attached to the next question with /attach
+type attachment struct {
+	path string
+	text string
+
+	// size is the full size of the file and read the bytes of it text
+	// holds, fewer when it exceeds maxAttachBytes
+	size int64
+	read int64
+
+	// encoding names the encoding the text was converted from (empty:
+	// UTF-8)
+	encoding string
+}
+
+// truncated reports whether text holds only part of the file
+func (t attachment) truncated() bool {
+	return t.read < t.size
+}
+
+// readAttachment reads the first limit bytes of a text file, converting
+// UTF-16 with a byte order mark and Latin-1 to UTF-8
+func readAttachment(path string, limit int) (attachment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, int64(limit)))
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	text, encoding, err := decodeText(data, int64(len(data)) < info.Size())
+	if err != nil {
+		return attachment{}, fmt.Errorf("cannot attach %s: %w", path, err)
+	}
+	read := int64(len(data))
+	return attachment{path: path, text: text, size: max(info.Size(), read), read: read, encoding: encoding}, nil
+}
+
+// decodeText converts data to UTF-8 and names the encoding it was in
+// (empty: UTF-8). A byte order mark selects UTF-8 or UTF-16; other data
+// that is not valid UTF-8 is read as Latin-1. Data with NUL bytes is
+// binary. cut reports that data was cut off, possibly mid-character.
+func decodeText(data []byte, cut bool) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false), "UTF-16LE", nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true), "UTF-16BE", nil
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", "", errBinaryFile
+	}
+
+	if cut {
+		// Do not end on a rune cut in half
+		for i := 1; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data), "", nil
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes), "Latin-1", nil
+}
+
+// decodeUTF16 converts UTF-16 to UTF-8, dropping a trailing odd byte
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// writeAttached appends text to a question as a block delimited with its
+// kind and source, noting when only shown of size bytes are included
+func writeAttached(b *bytes.Buffer, kind, source, text string, shown, size int64) {
+	fmt.Fprintf(b, "\n\n--- attached %s (%s) ---\n", kind, source)
+	b.WriteString(text)
+	if text != "" && text[len(text)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	if shown < size {
+		fmt.Fprintf(b, "[truncated: only the first %d of %d bytes are shown]\n", shown, size)
+	}
+	fmt.Fprintf(b, "--- end of attached %s ---", kind)
+}
+
+// withAttachments appends the pending attachments to the question and
+// clears them
+func (a *app) withAttachments(question string) string {
+	if len(a.attachments) == 0 {
+		return question
+	}
+	var b bytes.Buffer
+	b.WriteString(question)
+	for _, t := range a.attachments {
+		writeAttached(&b, "file", t.path, t.text, t.read, t.size)
+	}
+	a.attachments = nil
+	return b.String()
+}
+
+// attachCommand attaches a file to the next question, or lists the
+// pending attachments
+func (a *app) attachCommand(path string) error {
+	if path == "" {
+		a.printAttachments()
+		return nil
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	for _, t := range a.attachments {
+		if t.path == path {
+			return fmt.Errorf("%s is attached already", path)
+		}
+	}
+
+	t, err := readAttachment(path, maxAttachBytes)
+	if err != nil {
+		return err
+	}
+	a.attachments = append(a.attachments, t)
+	fmt.Fprintf(a.out, "📎 Attached %s\n", describeAttachment(t))
+	return nil
+}
+
+// detachCommand removes attachment N, as listed by /attach, or all of them
+func (a *app) detachCommand(arg string) error {
+	if len(a.attachments) == 0 {
+		fmt.Fprintln(a.out, "Nothing is attached")
+		return nil
+	}
+	if arg == "" || arg == "all" {
+		a.attachments = nil
+		fmt.Fprintln(a.out, "📎 Removed all attachments")
+		return nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(a.attachments) {
+		return fmt.Errorf("no attachment %s; /attach lists them", arg)
+	}
+	removed := a.attachments[n-1]
+	a.attachments = append(a.attachments[:n-1], a.attachments[n:]...)
+	fmt.Fprintf(a.out, "📎 Removed %s\n", removed.path)
+	return nil
+}
+
+// printAttachments lists the pending attachments and estimates the size
+// of the prompt with them
+func (a *app) printAttachments() {
+	if len(a.attachments) == 0 {
+		fmt.Fprintln(a.out, "Nothing is attached; /attach <path> attaches a file to the next question")
+		return
+	}
+	tokens := 0
+	for i, t := range a.attachments {
+		fmt.Fprintf(a.out, "%d. %s\n", i+1, describeAttachment(t))
+		tokens += llm.EstimateTokens(t.text)
+	}
+	fmt.Fprintf(a.out, "📏 ~%s tokens attached; ~%s with the conversation so far\n",
+		llm.FormatTokens(tokens), llm.FormatTokens(tokens+a.conversation.EstimateTokens()))
+}
+
+// describeAttachment names an attachment with its size, estimated tokens
+// and, when converted or cut, its encoding or the part included
+func describeAttachment(t attachment) string {
+	description := fmt.Sprintf("%s (%s, ~%s tokens", t.path, formatBytes(t.size), llm.FormatTokens(llm.EstimateTokens(t.text)))
+	if t.encoding != "" {
+		description += ", from " + t.encoding
+	}
+	if t.truncated() {
+		description += fmt.Sprintf(", first %s only", formatBytes(t.read))
+	}
+	return description + ")"
+}
+
+// formatBytes renders a size such as 512 B, 1.5 KB or 2.0 MB
+func formatBytes(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/lib/tool"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+)
+
+// replCommand is a slash command of the interactive session
+type replCommand struct {
+	name  string
+	usage string // arguments shown by /help
+	help  string
+	run   func(a *app, ctx context.Context, arg string) error
+}
+
+// replCommands lists the slash commands in the order /help shows them;
+// /exit and /quit are handled by runREPL
+func replCommands() []replCommand {
+	return []replCommand{
+		{"/help", "", "show this list", func(a *app, ctx context.Context, arg string) error {
+			a.printHelp()
+			return nil
+		}},
+		{"/tools", "", "list the tools offered to the model, by server", func(a *app, ctx context.Context, arg string) error {
+			a.printTools()
+			return nil
+		}},
+		{"/servers", "", "show the state and tool count of each server", (*app).printServers},
+		{"/paste", "", "read a question over several lines, up to a line with only .", (*app).pasteCommand},
+		{"/attach", "[path]", "attach a file to the next question, or list the attachments", func(a *app, ctx context.Context, arg string) error {
+			return a.attachCommand(arg)
+		}},
+		{"/detach", "[N]", "remove attachment N, or all of them", func(a *app, ctx context.Context, arg string) error {
+			return a.detachCommand(arg)
+		}},
+		{"/model", "[name]", "show the model, or switch to a model or Ollama profile", (*app).modelCommand},
+		{"/profile", "[name]", "list the profiles, or switch to one", (*app).profileCommand},
+		{"/config", "", "show the profiles and the effective configuration", func(a *app, ctx context.Context, arg string) error {
+			return a.printConfig()
+		}},
+		{"/system", "[text]", "show the system prompt, or replace the text appended to it", (*app).systemCommand},
+		{"/history", "", "list the messages of the conversation", func(a *app, ctx context.Context, arg string) error {
+			a.printHistory()
+			return nil
+		}},
+		{"/reset", "", "start a new conversation, keeping the system prompt", func(a *app, ctx context.Context, arg string) error {
+			a.resetConversation()
+			return nil
+		}},
+		{"/save", "<path>", "save the conversation to a file", func(a *app, ctx context.Context, arg string) error {
+			return a.saveCommand(arg)
+		}},
+		{"/export", "<path>", "write the conversation as Markdown to share", func(a *app, ctx context.Context, arg string) error {
+			return a.exportCommand(arg)
+		}},
+		{"/stats", "", "show the totals of the session and the calls to each tool", func(a *app, ctx context.Context, arg string) error {
+			a.statsCommand()
+			return nil
+		}},
+		{"/trace", "", "show the tool calls and results of the last answer", func(a *app, ctx context.Context, arg string) error {
+			a.printTrace()
+			return nil
+		}},
+		{"/resume", "[N]", "list recent sessions, or continue session N", func(a *app, ctx context.Context, arg string) error {
+			return a.resumeCommand(arg)
+		}},
+		{"/exit", "", "end the session", nil},
+	}
+}
+
+// findCommand returns the slash command called name
+func findCommand(name string) (replCommand, bool) {
+	for _, command := range replCommands() {
+		if command.name == name {
+			return command, true
+		}
+	}
+	return replCommand{}, false
+}
+
+// commandNames lists the names of the slash commands
+func commandNames() string {
+	commands := replCommands()
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		names[i] = command.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// printHelp lists the slash commands
+func (a *app) printHelp() {
+	for _, command := range replCommands() {
+		fmt.Fprintf(a.out, "%-18s %s\n", strings.TrimSpace(command.name+" "+command.usage), command.help)
+	}
+	fmt.Fprintln(a.out, `A question over several lines starts and ends with """, or its lines end with Alt-Enter`)
+}
+
+// toolDescriptionChars caps the descriptions printed by /tools
+const toolDescriptionChars = 80
+
+// printTools lists the offered tools grouped by server, with the first
+// line of each description
+func (a *app) printTools() {
+	if len(a.tools) == 0 {
+		fmt.Fprintln(a.out, "No tools are offered")
+		return
+	}
+
+	byServer := make(map[string][]tool.Tool)
+	var servers []string
+	for _, t := range a.tools {
+		if _, ok := byServer[t.Server]; !ok {
+			servers = append(servers, t.Server)
+		}
+		byServer[t.Server] = append(byServer[t.Server], t)
+	}
+	sort.Strings(servers)
+
+	for _, server := range servers {
+		tools := byServer[server]
+		name := server
+		if name == "" {
+			name = "(local)"
+		}
+		fmt.Fprintf(a.out, "📦 %s (%d tools)\n", name, len(tools))
+		for _, t := range tools {
+			toolName := t.OriginalName
+			if toolName == "" {
+				toolName = t.Name
+			}
+			description, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+			fmt.Fprintf(a.out, "   %-24s %s\n", toolName, truncateRunes(description, toolDescriptionChars))
+		}
+	}
+}
+
+// serverPingTimeout bounds the health check of each server in /servers
+const serverPingTimeout = 5 * time.Second
+
+// printServers shows each configured server: connected with its tool count
+// and ping time, unresponsive, not connected, or disabled
+func (a *app) printServers(ctx context.Context, arg string) error {
+	statuses, err := a.mcpClient.Status(ctx, a.config.Servers, serverPingTimeout)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		tools := fmt.Sprintf("%d tools", status.Tools)
+		if status.FilteredTools > 0 {
+			tools += fmt.Sprintf(", %d filtered", status.FilteredTools)
+		}
+		switch status.State {
+		case mcp.StatusDisabled:
+			fmt.Fprintf(a.out, "⏸️  %s: disabled\n", status.Name)
+		case mcp.StatusNotConnected:
+			fmt.Fprintf(a.out, "❌ %s: not connected\n", status.Name)
+		case mcp.StatusUnresponsive:
+			fmt.Fprintf(a.out, "⚠️  %s: connected, %s, not responding: %s\n", status.Name, tools, status.Error)
+		default:
+			fmt.Fprintf(a.out, "✅ %s: connected, %s, ping %s\n", status.Name, tools, status.Ping.Round(time.Microsecond))
+		}
+	}
+	return nil
+}
+
+// currentModel returns the model of the configured provider
+func currentModel(configFile *mcpConfig.ConfigFile) string {
+	if configFile.Provider == mcpConfig.ProviderOpenAI {
+		return configFile.OpenAI.Model
+	}
+	return configFile.Ollama.Model
+}
+
+// modelCheckTimeout bounds the check that a model exists in /model
+const modelCheckTimeout = 30 * time.Second
+
+// modelCommand shows the model or switches to another one. The name of an
+// Ollama profile switches to that profile. The provider is replaced only
+// once its server confirms it has the model.
+func (a *app) modelCommand(ctx context.Context, name string) error {
+	if name == "" {
+		fmt.Fprintf(a.out, "🤖 %s (%s)\n", currentModel(a.config), a.config.Provider)
+		if profiles := a.config.OllamaProfileNames(); len(profiles) > 0 {
+			fmt.Fprintf(a.out, "Ollama profiles: %s\n", strings.Join(profiles, ", "))
+		}
+		return nil
+	}
+
+	configFile := *a.config
+	overrides := mcpConfig.Overrides{Model: name}
+	if _, ok := configFile.OllamaProfiles[name]; ok && configFile.Provider != mcpConfig.ProviderOpenAI {
+		overrides = mcpConfig.Overrides{Profile: name}
+	}
+	if err := configFile.ApplyOverrides(overrides); err != nil {
+		return err
+	}
+
+	provider, toolHandler, err := newProvider(&configFile, a.tools)
+	if err != nil {
+		return fmt.Errorf("failed to create %s client: %w", configFile.Provider, err)
+	}
+	if checker, ok := provider.(llm.ModelChecker); ok {
+		checkCtx, cancel := context.WithTimeout(ctx, modelCheckTimeout)
+		defer cancel()
+		if err := checker.CheckModel(checkCtx); err != nil {
+			if advice := errorAdvice(err, &configFile); advice != "" {
+				fmt.Fprintf(a.out, "💡 %s\n", advice)
+			}
+			return fmt.Errorf("not switching to %s: %w", currentModel(&configFile), err)
+		}
+	}
+
+	a.config = &configFile
+	a.provider = provider
+	a.toolHandler = toolHandler
+	fmt.Fprintf(a.out, "🤖 Switched to %s\n", currentModel(a.config))
+	return nil
+}
+
+// profileCommand lists the profiles or switches to one. The servers the
+// new profile does not use are stopped and the ones it adds are started;
+// the conversation is kept, with the new system prompt.
+func (a *app) profileCommand(ctx context.Context, name string) error {
+	if name == "" {
+		a.printProfiles()
+		return nil
+	}
+	if a.options.source.file == nil {
+		return fmt.Errorf("profiles cannot be switched in this session")
+	}
+
+	configFile, err := a.options.source.withProfile(name)
+	if err != nil {
+		return err
+	}
+	// The servers outlive a cancelled ctx, as when they were first connected
+	diff := mcpConfig.DiffConfig(*a.config, *configFile)
+	if err := a.mcpClient.ApplyConfigDiff(context.WithoutCancel(ctx), diff); err != nil {
+		fmt.Fprintf(a.out, "⚠️  %v\n", err)
+	}
+
+	a.config = configFile
+	a.agent = configFile.Agent.Settings()
+	if err := a.loadTools(ctx); err != nil {
+		return err
+	}
+	a.conversation.SetSystemPrompt(llm.BuildSystemPrompt(a.tools, a.mcpClient.Instructions(), configFile.SystemPrompt))
+	fmt.Fprintf(a.out, "🎛️  Switched to profile %s: %s, %d tools\n", name, currentModel(a.config), len(a.tools))
+	return nil
+}
+
+// printProfiles lists the profiles with their servers, marking the active one
+func (a *app) printProfiles() {
+	names := a.config.NamedProfileNames()
+	if len(names) == 0 {
+		fmt.Fprintln(a.out, "No profiles are configured")
+		return
+	}
+
+	for _, name := range names {
+		marker := "  "
+		if name == a.config.ActiveProfile {
+			marker = "▶ "
+		}
+		servers := "all servers"
+		if profile := a.config.Profiles[name]; len(profile.Servers) > 0 {
+			servers = strings.Join(profile.Servers, ", ")
+		}
+		fmt.Fprintf(a.out, "%s%-16s %s\n", marker, name, servers)
+	}
+}
+
+// printConfig shows the profiles and the effective configuration, secrets
+// redacted, as -print-config does
+func (a *app) printConfig() error {
+	if len(a.config.Profiles) > 0 {
+		a.printProfiles()
+		fmt.Fprintln(a.out)
+	}
+	data, err := mcpConfig.MarshalConfigYAML(a.config.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = a.out.Write(data)
+	return err
+}
+
+// systemCommand shows the system prompt or replaces the text appended to
+// the generated one, as system_prompt does
+func (a *app) systemCommand(ctx context.Context, text string) error {
+	if text == "" {
+		fmt.Fprintln(a.out, a.conversation.SystemPrompt())
+		return nil
+	}
+	a.config.SystemPrompt = text
+	a.config.SystemPromptFile = ""
+	a.conversation.SetSystemPrompt(llm.BuildSystemPrompt(a.tools, a.mcpClient.Instructions(), text))
+	fmt.Fprintln(a.out, "📝 System prompt updated")
+	return nil
+}
+
+// historyChars caps each message printed by /history
+const historyChars = 120
+
+// printHistory lists the messages of the conversation, one line each
+func (a *app) printHistory() {
+	history := a.conversation.History()
+	if len(history) == 0 {
+		fmt.Fprintln(a.out, "The conversation is empty")
+		return
+	}
+
+	for i, message := range history {
+		content := strings.Join(strings.Fields(message.Content), " ")
+		switch {
+		case message.Role == llm.RoleTool:
+			content = message.ToolName + ": " + content
+		case len(message.ToolCalls) > 0:
+			names := make([]string, len(message.ToolCalls))
+			for j, call := range message.ToolCalls {
+				names[j] = call.Name
+			}
+			content = strings.TrimSpace(content + " 🔧 " + strings.Join(names, ", "))
+		}
+		if message.Interrupted {
+			content += " (interrupted)"
+		}
+		fmt.Fprintf(a.out, "%3d. %-9s %s\n", i+1, message.Role, truncateRunes(content, historyChars))
+	}
+}
+
+// resetConversation clears the history and starts a new session file, so
+// the previous session can still be resumed
+func (a *app) resetConversation() {
+	a.saveSession()
+	a.conversation.Reset()
+	a.lastRun = nil
+	a.startSession()
+	fmt.Fprintln(a.out, "🧹 Started a new conversation")
+}
+
+// saveCommand saves the conversation to path
+func (a *app) saveCommand(path string) error {
+	if path == "" {
+		return fmt.Errorf("usage: /save <path>")
+	}
+	if err := a.conversation.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "💾 Saved %d messages to %s\n", len(a.conversation.History()), path)
+	return nil
+}
+
+// exportCommand writes the conversation to path as Markdown
+func (a *app) exportCommand(path string) error {
+	if path == "" {
+		return fmt.Errorf("usage: /export <path>")
+	}
+	written, err := a.exportConversation(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "📝 Exported %d messages to %s\n", len(a.conversation.History()), path)
+	if images := len(written) - 1; images > 0 {
+		fmt.Fprintf(a.out, "   and %d images next to it\n", images)
+	}
+	return nil
+}
+
+// exportConversation writes the conversation to path as Markdown, with the
+// model and servers in the header, and returns the files written
+func (a *app) exportConversation(path string) ([]string, error) {
+	servers := []string{}
+	for _, config := range a.config.EnabledServers() {
+		servers = append(servers, config.Name)
+	}
+	return a.conversation.ExportMarkdown(path, llm.ExportOptions{
+		Fields: []llm.ExportField{
+			{Name: "Model", Value: fmt.Sprintf("%s (%s)", currentModel(a.config), a.config.Provider)},
+			{Name: "Servers", Value: strings.Join(servers, ", ")},
+			{Name: "Tools", Value: fmt.Sprint(len(a.tools))},
+		},
+	})
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+	"github.com/snowmerak/ttobot/pkg/ollama"
+	"github.com/snowmerak/ttobot/pkg/openai"
+)
+
+// doctorTimeout bounds each check of the model server
+const doctorTimeout = 10 * time.Second
+
+// errChecksFailed is returned when a check of ttobot doctor failed; the
+// report is printed already
+var errChecksFailed = errors.New("some checks failed")
+
+// Results of a doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// doctorCheck is the result of one check of ttobot doctor
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	// Hint suggests how to fix a failed check
+	Hint string `json:"hint,omitempty"`
+}
+
+// doctorReport is the result of all checks; OK is false if any failed
+type doctorReport struct {
+	OK     bool          `json:"ok"`
+	Checks []doctorCheck `json:"checks"`
+}
+
+// add records checks, clearing OK when one failed
+func (r *doctorReport) add(checks ...doctorCheck) {
+	for _, check := range checks {
+		if check.Status == checkFail {
+			r.OK = false
+		}
+		r.Checks = append(r.Checks, check)
+	}
+}
+
+// runDoctor checks the setup and reports what is wrong with hints on how
+// to fix it: ttobot doctor [-json]
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configFlag := flags.String("config", "", "config file to use instead of "+mcpConfig.ConfigEnvVar+" and the default paths")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	var flagOverrides mcpConfig.Overrides
+	overrideFlags(flags, &flagOverrides)
+	var logOpts logOptions
+	logFlags(flags, &logOpts)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ./ttobot doctor [-json] [flags]")
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// The report covers what would be logged as warnings
+	closeLog, err := setupLogging(logOpts, false, slog.LevelError)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	ctx, _ := handleSignals(context.Background(), os.Stderr)
+	report := diagnose(ctx, *configFlag, flagOverrides)
+	if code := exitCode(context.Cause(ctx)); code != 0 {
+		closeLog()
+		os.Exit(code)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(os.Stdout, report)
+	}
+	if !report.OK {
+		return errChecksFailed
+	}
+	return nil
+}
+
+// diagnose checks the config file, the model server and model, and every
+// enabled server
+func diagnose(ctx context.Context, path string, flagOverrides mcpConfig.Overrides) doctorReport {
+	report := doctorReport{OK: true}
+
+	configCheck := doctorCheck{Name: "Config file", Status: checkPass}
+	source, err := loadConfigSource(path, flagOverrides)
+	var configFile *mcpConfig.ConfigFile
+	if err == nil {
+		configFile, err = source.withProfile("")
+	}
+	switch {
+	case err != nil:
+		configCheck.Status = checkFail
+		configCheck.Detail = err.Error()
+		configCheck.Hint = "Fix the config file, or create a starter one with 'ttobot init'."
+	case source.path == "":
+		configCheck.Status = checkWarn
+		configCheck.Detail = "no config file found, using the memory server"
+		configCheck.Hint = "Create mcp.yaml with 'ttobot init', or point " + mcpConfig.ConfigEnvVar + " or -config at one."
+	default:
+		configCheck.Detail = source.path + " is valid"
+		if configFile.ActiveProfile != "" {
+			configCheck.Detail += ", profile " + configFile.ActiveProfile
+		}
+	}
+	report.add(configCheck)
+	if configFile == nil {
+		report.add(
+			doctorCheck{Name: "Model server", Status: checkSkip, Detail: "no valid config"},
+			doctorCheck{Name: "Model", Status: checkSkip, Detail: "no valid config"},
+		)
+		return report
+	}
+
+	report.add(checkModel(ctx, configFile)...)
+	report.add(checkServers(ctx, configFile.Servers)...)
+	report.add(checkBuiltins(ctx, configFile)...)
+	return report
+}
+
+// checkModel checks that the model server is reachable and serves the
+// configured model
+func checkModel(ctx context.Context, configFile *mcpConfig.ConfigFile) []doctorCheck {
+	server := doctorCheck{Name: "Model server", Status: checkPass}
+	model := doctorCheck{Name: "Model", Status: checkPass}
+	fail := func(check *doctorCheck, err error) {
+		check.Status = checkFail
+		check.Detail = err.Error()
+		check.Hint = errorAdvice(err, configFile)
+	}
+
+	provider, _, err := newProvider(configFile, nil)
+	if err != nil {
+		fail(&server, fmt.Errorf("failed to create %s client: %w", configFile.Provider, err))
+		model.Status, model.Detail = checkSkip, "no client"
+		return []doctorCheck{server, model}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	switch provider := provider.(type) {
+	case *ollama.Provider:
+		client := provider.Client()
+		url, name := configFile.Ollama.URL, configFile.Ollama.Model
+		version, err := client.Version(ctx)
+		if err != nil {
+			fail(&server, err)
+			model.Status, model.Detail = checkSkip, "Ollama is not reachable"
+			break
+		}
+		server.Detail = fmt.Sprintf("Ollama %s at %s", version, url)
+
+		tools, err := client.SupportsTools(ctx)
+		switch {
+		case err != nil:
+			fail(&model, err)
+		case !tools:
+			model.Status = checkWarn
+			model.Detail = name + " is installed but does not support tools"
+			model.Hint = "Use a model with tool support, such as qwen3 or llama3.1, or ttobot answers without its tools."
+		default:
+			model.Detail = name + " is installed and supports tools"
+		}
+
+	case *openai.Client:
+		url, name := configFile.OpenAI.BaseURL, configFile.OpenAI.Model
+		server.Detail = url
+		err := provider.CheckModel(ctx)
+		switch {
+		case errors.Is(err, llm.ErrModelNotFound):
+			fail(&model, err)
+		case err != nil:
+			fail(&server, err)
+			model.Status, model.Detail = checkSkip, "the server is not reachable"
+		default:
+			model.Detail = name + " is served; OpenAI-compatible servers do not report tool support"
+		}
+	}
+	return []doctorCheck{server, model}
+}
+
+// checkServers checks every enabled server at once, each with its own
+// client so that one failing server does not hide the others. The checks
+// are returned in server order.
+func checkServers(ctx context.Context, configs []mcpConfig.Config) []doctorCheck {
+	results := make([][]doctorCheck, len(configs))
+	var wg sync.WaitGroup
+	for i, config := range configs {
+		if !config.IsEnabled() {
+			results[i] = []doctorCheck{{Name: "Server " + config.Name, Status: checkSkip, Detail: "disabled"}}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkServer(ctx, config)
+		}()
+	}
+	wg.Wait()
+
+	var checks []doctorCheck
+	for _, result := range results {
+		checks = append(checks, result...)
+	}
+	return checks
+}
+
+// checkServer checks that a server's command can be run, that it starts
+// and answers initialize within its connect timeout, and that it lists
+// its tools
+func checkServer(ctx context.Context, config mcpConfig.Config) []doctorCheck {
+	prefix := "Server " + config.Name + ": "
+	var checks []doctorCheck
+	skipRest := func(from int, reason string) []doctorCheck {
+		for _, name := range []string{"command", "start", "tools"}[from:] {
+			checks = append(checks, doctorCheck{Name: prefix + name, Status: checkSkip, Detail: reason})
+		}
+		return checks
+	}
+
+	if config.TransportType() == mcpConfig.TransportStdio {
+		if err := config.CheckCommand(); err != nil {
+			checks = append(checks, doctorCheck{
+				Name:   prefix + "command",
+				Status: checkFail,
+				Detail: err.Error(),
+				Hint:   "Install the command or fix command in the config file.",
+			})
+			return skipRest(1, "the command cannot be run")
+		}
+		checks = append(checks, doctorCheck{Name: prefix + "command", Status: checkPass, Detail: config.Command})
+	}
+
+	// A server without connect_timeout gets doctorTimeout rather than
+	// hanging the report
+	connectTimeout, _ := config.Timeouts()
+	if connectTimeout <= 0 {
+		connectTimeout = doctorTimeout
+	}
+	client := mcp.NewClient("ttobot", "1.0.0")
+	client.SetDefaultTimeouts(doctorTimeout, 0)
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := client.Close(closeCtx); err != nil {
+			slog.Warn("Doctor: Failed to disconnect the server", "server", config.Name, "error", err)
+		}
+	}()
+
+	started := time.Now()
+	// As in a chat, the server outlives a cancelled ctx until it is closed
+	if err := client.ConnectFromConfig(context.WithoutCancel(ctx), config); err != nil {
+		hint := fmt.Sprintf("Run the command by hand to see why it fails, or raise connect_timeout (%s) if it starts slowly.", connectTimeout)
+		if config.TransportType() != mcpConfig.TransportStdio {
+			hint = "Check the url and that the server is running."
+		}
+		checks = append(checks, doctorCheck{Name: prefix + "start", Status: checkFail, Detail: err.Error(), Hint: hint})
+		return skipRest(2, "the server did not start")
+	}
+	checks = append(checks, doctorCheck{
+		Name:   prefix + "start",
+		Status: checkPass,
+		Detail: "answered initialize in " + time.Since(started).Round(time.Millisecond).String(),
+	})
+
+	servers, err := client.ListServers(ctx)
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   prefix + "tools",
+			Status: checkFail,
+			Detail: err.Error(),
+			Hint:   "The server started but failed to list its tools; check its log.",
+		})
+	}
+	tools := doctorCheck{Name: prefix + "tools", Status: checkPass}
+	for _, server := range servers {
+		tools.Detail = fmt.Sprintf("%d tools", server.Tools)
+		if server.FilteredTools > 0 {
+			tools.Detail += fmt.Sprintf(", %d hidden by allowed_tools or blocked_tools", server.FilteredTools)
+		}
+		if server.Tools == 0 {
+			tools.Status = checkWarn
+			tools.Hint = "The server offers no tools; check allowed_tools and blocked_tools."
+		}
+	}
+	return append(checks, tools)
+}
+
+// checkBuiltins checks that each builtin server runs and lists its tools
+func checkBuiltins(ctx context.Context, configFile *mcpConfig.ConfigFile) []doctorCheck {
+	var checks []doctorCheck
+	for _, name := range configFile.BuiltinServers {
+		check := doctorCheck{Name: "Builtin server " + name, Status: checkPass}
+		client := mcp.NewClient("ttobot", "1.0.0")
+		one := *configFile
+		one.BuiltinServers = []string{name}
+		servers, err := func() ([]mcp.ServerInfo, error) {
+			if err := connectBuiltins(ctx, client, &one, nil); err != nil {
+				return nil, err
+			}
+			return client.ListServers(ctx)
+		}()
+		if err != nil {
+			check.Status = checkFail
+			check.Detail = err.Error()
+			check.Hint = "Check that builtin_root is a directory ttobot can read."
+		}
+		for _, server := range servers {
+			check.Detail = fmt.Sprintf("%d tools as %s", server.Tools, server.ID)
+		}
+		client.Close(ctx)
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// printDoctorReport writes a line per check, with the hint of failed ones,
+// and a summary
+func printDoctorReport(w io.Writer, report doctorReport) {
+	icons := map[string]string{checkPass: "✅", checkWarn: "⚠️ ", checkFail: "❌", checkSkip: "⏭️ "}
+	failed := 0
+	for _, check := range report.Checks {
+		line := icons[check.Status] + " " + check.Name
+		if check.Detail != "" {
+			// Details such as a server's stderr may span lines
+			line += ": " + strings.ReplaceAll(check.Detail, "\n", "\n   ")
+		}
+		fmt.Fprintln(w, line)
+		if check.Hint != "" && check.Status != checkPass {
+			fmt.Fprintf(w, "   💡 %s\n", check.Hint)
+		}
+		if check.Status == checkFail {
+			failed++
+		}
+	}
+
+	fmt.Fprintln(w)
+	if failed == 0 {
+		fmt.Fprintln(w, "All checks passed")
+	} else {
+		fmt.Fprintf(w, "%d of %d checks failed\n", failed, len(report.Checks))
+	}
+}
@@ -0,0 +1,347 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// DefaultMaxIterations is the default number of model calls in one agent run
+const DefaultMaxIterations = 10
+
+// ErrMaxIterations is returned when the model keeps calling tools past the
+// iteration cap and no summary could be obtained either
+var ErrMaxIterations = errors.New("maximum agent iterations reached")
+
+// AgentResult is the outcome of ChatWithTools and StreamWithTools
+type AgentResult struct {
+	// Response is the final model response
+	Response *Response
+
+	// Messages are the messages appended to the conversation during the run:
+	// assistant turns with tool calls, tool results, and the final answer
+	Messages []Message
+
+	// Usage totals the metrics of every request and tool call in the run
+	Usage Usage
+
+	// Iterations is the number of model calls made
+	Iterations int
+
+	// Incomplete is set when the iteration cap was reached: Response then
+	// holds a summary of the findings so far rather than a finished answer,
+	// and Messages holds everything that was attempted
+	Incomplete bool
+}
+
+// ToolTrace is a tool call made during an agent run and its result
+type ToolTrace struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Result    string         `json:"result"`
+}
+
+// ToolCalls lists the tool calls of the run in order, each with the result
+// fed back to the model
+func (r *AgentResult) ToolCalls() []ToolTrace {
+	traces := []ToolTrace{}
+	if r == nil {
+		return traces
+	}
+
+	// Tool messages answer the calls of the preceding assistant message in order
+	answered := 0
+	for _, message := range r.Messages {
+		switch message.Role {
+		case RoleAssistant:
+			for _, call := range message.ToolCalls {
+				traces = append(traces, ToolTrace{Name: call.Name, Arguments: call.Arguments})
+			}
+		case RoleTool:
+			if answered < len(traces) {
+				traces[answered].Result = message.Content
+				answered++
+			}
+		}
+	}
+	return traces
+}
+
+// ChatWithTools runs the agent loop on a conversation: it sends the history to
+// the provider, executes any tool calls, feeds the results back, and repeats
+// until the model answers without calling tools. Every message produced is
+// appended to the conversation, and usage is accumulated on it.
+//
+// An answer cut off by the NumPredict limit is not treated as final: the
+// partial answer is sent back as the last assistant message so the model
+// continues it, unless StopOnLength is set.
+//
+// A tool call repeated with the same arguments more than MaxRepeatedToolCalls
+// times is not executed again; the model is told to change approach instead.
+// If it repeats the call once more, the run ends with a *ToolLoopError.
+//
+// Calls beyond the ToolBudget are not executed either: the model is told the
+// budget is spent, and once no call may run at all, later requests are sent
+// without tools so the model answers with what it has.
+//
+// When the model is still calling tools after MaxIterations calls, one more
+// request is sent without tools, asking it to summarize its findings; that
+// summary is returned as the answer with Incomplete set.
+//
+// If the provider reports an *InterruptedError, the text generated so far is
+// appended to the conversation as an Interrupted assistant message before the
+// error is returned.
+func ChatWithTools(ctx context.Context, provider ChatProvider, tools ToolHandler, conversation *Conversation, o Opts) (*AgentResult, error) {
+	chat := func(messages []Message, o Opts) (*Response, error) {
+		return provider.Chat(ctx, messages, o)
+	}
+	return runAgent(ctx, tools, conversation, o, chat, nil)
+}
+
+// runAgent is the loop shared by ChatWithTools and StreamWithTools. chat
+// performs one model call; emit, when set, receives tool and done events.
+func runAgent(ctx context.Context, tools ToolHandler, conversation *Conversation, o Opts, chat func([]Message, Opts) (*Response, error), emit func(StreamEvent) error) (*AgentResult, error) {
+	maxIterations := o.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+
+	result := &AgentResult{}
+
+	budget := o.ToolBudget
+	if budget.IsZero() {
+		budget = conversation.ToolBudget()
+	}
+	run := &toolRun{
+		tools:        tools,
+		conversation: conversation,
+		loops:        newLoopDetector(o.MaxRepeatedToolCalls),
+		budget:       newBudgetTracker(budget),
+	}
+	if source, ok := tools.(PromptSource); ok {
+		run.prompts = source.Prompts()
+	}
+	var pending *Message // Answer cut off by the length limit, to be continued
+
+	for result.Iterations < maxIterations {
+		result.Iterations++
+
+		messages := conversation.Messages()
+		if pending != nil {
+			messages = append(messages, *pending)
+		}
+
+		// Once the budget is spent the model must answer without tools
+		callOpts := o
+		if run.budget.Exhausted() {
+			callOpts.NoTools = true
+		}
+
+		resp, err := chat(messages, callOpts)
+		if err != nil {
+			var interrupted *InterruptedError
+			if errors.As(err, &interrupted) {
+				r := run.keepInterrupted(interrupted.Partial, pending)
+				if r != nil {
+					result.Messages = append(result.Messages, *r)
+				}
+			}
+			return result, err
+		}
+		result.Usage.Add(resp.Usage)
+		conversation.AddUsage(resp.Usage)
+		result.Response = resp
+
+		if pending != nil {
+			resp.Message.Content = pending.Content + resp.Message.Content
+			pending = nil
+		}
+
+		if len(resp.Message.ToolCalls) == 0 {
+			if resp.DoneReason == DoneReasonLength && !o.StopOnLength {
+				slog.Info("Agent: Answer hit the length limit, continuing")
+				partial := resp.Message
+				pending = &partial
+				continue
+			}
+
+			conversation.Append(resp.Message)
+			result.Messages = append(result.Messages, resp.Message)
+			if emit != nil {
+				if err := emit(StreamEvent{Kind: EventDone, Response: resp}); err != nil {
+					return result, err
+				}
+			}
+			return result, nil
+		}
+
+		if emit != nil {
+			for i := range resp.Message.ToolCalls {
+				if err := emit(StreamEvent{Kind: EventToolCall, ToolCall: &resp.Message.ToolCalls[i]}); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		continuation, toolUsage, err := run.handle(ctx, resp)
+		result.Usage.Add(toolUsage)
+		conversation.AddUsage(toolUsage)
+		if err != nil {
+			return result, err
+		}
+		conversation.Append(continuation...)
+		result.Messages = append(result.Messages, continuation...)
+
+		if emit != nil {
+			for i, message := range continuation[1:] {
+				event := StreamEvent{Kind: EventToolResult, ToolCall: &resp.Message.ToolCalls[i], Content: message.Content}
+				if err := emit(event); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	// Keep a cut-off answer rather than losing it
+	if pending != nil {
+		conversation.Append(*pending)
+		result.Messages = append(result.Messages, *pending)
+	}
+
+	slog.Warn("Agent: Iteration limit reached, asking for a summary", "iterations", result.Iterations)
+	return result, run.summarize(chat, o, maxIterations, result, emit)
+}
+
+// keepInterrupted records the text generated before a cancellation in the
+// conversation, marked as interrupted so the user can ask to continue.
+// Unfinished tool calls are dropped since they were never executed.
+func (r *toolRun) keepInterrupted(partial Message, pending *Message) *Message {
+	if pending != nil {
+		partial.Content = pending.Content + partial.Content
+	}
+	if partial.Content == "" {
+		return nil
+	}
+	partial.ToolCalls = nil
+	partial.Interrupted = true
+	r.conversation.Append(partial)
+	return &partial
+}
+
+// summarize asks the model to wrap up after the iteration cap, recording the
+// summary as the incomplete answer of the run
+func (r *toolRun) summarize(chat func([]Message, Opts) (*Response, error), o Opts, maxIterations int, result *AgentResult, emit func(StreamEvent) error) error {
+	nudge := SystemMessage(r.prompts.render("iteration_limit", PromptData{Count: maxIterations}))
+	messages := append(r.conversation.Messages(), nudge)
+
+	summaryOpts := o
+	summaryOpts.NoTools = true
+	resp, err := chat(messages, summaryOpts)
+	if err != nil {
+		return fmt.Errorf("%w (%d): summary failed: %w", ErrMaxIterations, maxIterations, err)
+	}
+
+	result.Iterations++
+	result.Usage.Add(resp.Usage)
+	r.conversation.AddUsage(resp.Usage)
+	result.Response = resp
+	result.Incomplete = true
+
+	r.conversation.Append(resp.Message)
+	result.Messages = append(result.Messages, resp.Message)
+	if emit != nil {
+		return emit(StreamEvent{Kind: EventDone, Response: resp})
+	}
+	return nil
+}
+
+// toolRun holds the per-run state for executing tool calls
+type toolRun struct {
+	tools        ToolHandler
+	conversation *Conversation
+	loops        *loopDetector
+	budget       *budgetTracker
+	prompts      Prompts
+}
+
+// handle executes the calls in a response. Calls caught repeating are
+// answered with a warning and calls over budget with a notice instead of
+// being executed; the rest run as one batch so the handler can run them in
+// parallel. It returns the continuation messages, in the order of the calls,
+// and the usage of the executed calls.
+func (r *toolRun) handle(ctx context.Context, resp *Response) ([]Message, Usage, error) {
+	var usage Usage
+	calls := resp.Message.ToolCalls
+
+	// Answers holds the warning or notice of each call not run, by position
+	answers := make([]*Message, len(calls))
+	var batch []ToolCall
+	for i, call := range calls {
+		verdict, count := r.loops.Observe(call)
+		switch verdict {
+		case loopAbort:
+			slog.Warn("Agent: Aborting, tool call repeated", "tool", call.Name, "count", count)
+			transcript := append(slices.Clone(r.conversation.History()), resp.Message)
+			return nil, usage, &ToolLoopError{Call: call, Repeats: count, Transcript: transcript}
+		case loopWarn:
+			slog.Warn("Agent: Tool call repeated, asking the model to change approach", "tool", call.Name, "count", count)
+			warning := loopWarning(r.prompts, call, count)
+			answers[i] = &warning
+			continue
+		}
+
+		// The call is counted when accepted so the batch stays within the
+		// call limits; its time is added once it has run
+		if reason := r.budget.Check(call.Name); reason != "" {
+			slog.Warn("Agent: Skipping tool call, tool budget spent", "tool", call.Name, "reason", reason)
+			notice := budgetNotice(r.prompts, call, reason)
+			answers[i] = &notice
+			continue
+		}
+		r.budget.Record(call.Name)
+		batch = append(batch, call)
+	}
+
+	var results []Message
+	if len(batch) > 0 {
+		batchResp := *resp
+		batchResp.Message.ToolCalls = batch
+		started := time.Now()
+		var err error
+		results, err = r.tools.HandleToolCalls(ctx, &batchResp)
+		if err != nil {
+			return nil, usage, err
+		}
+		elapsed := time.Since(started)
+		if len(results) != len(batch)+1 {
+			return nil, usage, fmt.Errorf("tool handler returned %d results for %d calls", len(results)-1, len(batch))
+		}
+		results = results[1:]
+
+		usage.ToolCalls += len(batch)
+		for _, result := range results {
+			// Handlers that do not time their calls are charged the batch
+			duration := result.Duration
+			if duration <= 0 {
+				duration = elapsed
+			}
+			r.budget.Spend(duration)
+			usage.ToolDuration += duration
+		}
+	}
+
+	continuation := make([]Message, 0, len(calls)+1)
+	continuation = append(continuation, resp.Message)
+	for _, answer := range answers {
+		if answer != nil {
+			continuation = append(continuation, *answer)
+			continue
+		}
+		continuation = append(continuation, results[0])
+		results = results[1:]
+	}
+	return continuation, usage, nil
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// scriptedProvider answers chat requests with canned responses in order
+type scriptedProvider struct {
+	responses []Response
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, messages []Message, opts Opts) (*Response, error) {
+	if len(p.responses) == 0 {
+		return nil, fmt.Errorf("no more responses")
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return &resp, nil
+}
+
+func (p *scriptedProvider) ChatStream(ctx context.Context, messages []Message, callback func(Response) error, opts Opts) error {
+	resp, err := p.Chat(ctx, messages, opts)
+	if err != nil {
+		return err
+	}
+	return callback(*resp)
+}
+
+// countingHandler wraps a ToolHandler, recording the size of each batch
+type countingHandler struct {
+	ToolHandler
+	lock    sync.Mutex
+	batches []int
+}
+
+func (h *countingHandler) HandleToolCalls(ctx context.Context, response *Response) ([]Message, error) {
+	h.lock.Lock()
+	h.batches = append(h.batches, len(response.Message.ToolCalls))
+	h.lock.Unlock()
+	return h.ToolHandler.HandleToolCalls(ctx, response)
+}
+
+func toolCalls(names ...string) Response {
+	resp := Response{Message: Message{Role: RoleAssistant}}
+	for i, name := range names {
+		resp.Message.ToolCalls = append(resp.Message.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call-%d", i),
+			Name:      name,
+			Arguments: map[string]any{"n": i},
+		})
+	}
+	return resp
+}
+
+func TestChatWithToolsRunsCallsInParallel(t *testing.T) {
+	// Each call waits until all of them are running, which only happens if
+	// they run as one batch
+	const n = 3
+	var started sync.WaitGroup
+	started.Add(n)
+	waiting := tool.MustFromFunc("wait", "Waits for the others", func(ctx context.Context) (string, error) {
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return "together", nil
+		case <-time.After(5 * time.Second):
+			return "", fmt.Errorf("ran alone")
+		}
+	})
+
+	runner := NewToolRunner(ToolRunnerOptions{Parallelism: n})
+	runner.SetTools([]tool.Tool{waiting})
+	handler := &countingHandler{ToolHandler: runner}
+	provider := &scriptedProvider{responses: []Response{
+		toolCalls("wait", "wait", "wait"),
+		{Message: Message{Role: RoleAssistant, Content: "done"}},
+	}}
+
+	result, err := ChatWithTools(context.Background(), provider, handler, NewConversation(""), Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.batches) != 1 || handler.batches[0] != n {
+		t.Fatalf("got batches %v, want one of %d calls", handler.batches, n)
+	}
+	if result.Usage.ToolCalls != n {
+		t.Errorf("usage counted %d tool calls, want %d", result.Usage.ToolCalls, n)
+	}
+	for _, message := range result.Messages[1 : n+1] {
+		if !strings.Contains(message.Content, "together") {
+			t.Errorf("call %s: %q", message.ToolCallID, message.Content)
+		}
+	}
+}
+
+type echoArgs struct {
+	N int `json:"n"`
+}
+
+func TestChatWithToolsKeepsOrderAroundSkippedCalls(t *testing.T) {
+	echo := tool.MustFromFunc("echo", "Echoes n", func(ctx context.Context, args *echoArgs) (string, error) {
+		return fmt.Sprintf("echo %d", args.N), nil
+	})
+	other := tool.MustFromFunc("other", "Echoes n", func(ctx context.Context, args *echoArgs) (string, error) {
+		return fmt.Sprintf("other %d", args.N), nil
+	})
+
+	runner := NewToolRunner(ToolRunnerOptions{Parallelism: 4})
+	runner.SetTools([]tool.Tool{echo, other})
+	handler := &countingHandler{ToolHandler: runner}
+	provider := &scriptedProvider{responses: []Response{
+		toolCalls("echo", "other", "echo", "other", "echo"),
+		{Message: Message{Role: RoleAssistant, Content: "done"}},
+	}}
+
+	// The third and fifth calls exceed the limit of one echo call
+	budget := ToolBudget{MaxCallsPerTool: map[string]int{"echo": 1}}
+	result, err := ChatWithTools(context.Background(), provider, handler, NewConversation(""), Opts{ToolBudget: budget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.batches) != 1 || handler.batches[0] != 3 {
+		t.Fatalf("got batches %v, want one of 3 calls", handler.batches)
+	}
+
+	want := []string{"echo 0", "other 1", "calls to echo used", "other 3", "calls to echo used"}
+	results := result.Messages[1:6]
+	for i, message := range results {
+		if message.ToolCallID != fmt.Sprintf("call-%d", i) {
+			t.Errorf("message %d answers %s", i, message.ToolCallID)
+		}
+		if !strings.Contains(message.Content, want[i]) {
+			t.Errorf("message %d = %q, want it to contain %q", i, message.Content, want[i])
+		}
+	}
+	if result.Usage.ToolCalls != 3 {
+		t.Errorf("usage counted %d tool calls, want 3", result.Usage.ToolCalls)
+	}
+}
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+)
+
+// ToolBudget limits the tool calls of one agent run. Zero values mean no limit.
+type ToolBudget struct {
+	// MaxCalls caps the total number of tool calls
+	MaxCalls int
+
+	// MaxCallsPerTool caps the calls of individual tools, keyed by tool name
+	MaxCallsPerTool map[string]int
+
+	// MaxDuration caps the cumulative wall time spent executing tools
+	MaxDuration time.Duration
+}
+
+// IsZero reports whether the budget sets no limits
+func (b ToolBudget) IsZero() bool {
+	return b.MaxCalls == 0 && len(b.MaxCallsPerTool) == 0 && b.MaxDuration == 0
+}
+
+// budgetTracker accounts tool calls against a budget
+type budgetTracker struct {
+	budget  ToolBudget
+	calls   int
+	perTool map[string]int
+	elapsed time.Duration
+}
+
+// newBudgetTracker starts accounting against a budget
+func newBudgetTracker(budget ToolBudget) *budgetTracker {
+	return &budgetTracker{budget: budget, perTool: make(map[string]int)}
+}
+
+// Check returns why a call to the named tool is over budget, or an empty
+// string if it may run
+func (t *budgetTracker) Check(name string) string {
+	if t.budget.MaxCalls > 0 && t.calls >= t.budget.MaxCalls {
+		return fmt.Sprintf("%d of %d tool calls used", t.calls, t.budget.MaxCalls)
+	}
+	if t.budget.MaxDuration > 0 && t.elapsed >= t.budget.MaxDuration {
+		return fmt.Sprintf("%s of %s tool time used", formatSeconds(t.elapsed), formatSeconds(t.budget.MaxDuration))
+	}
+	if limit, ok := t.budget.MaxCallsPerTool[name]; ok && limit > 0 && t.perTool[name] >= limit {
+		return fmt.Sprintf("%d of %d calls to %s used", t.perTool[name], limit, name)
+	}
+	return ""
+}
+
+// Record accounts for a call about to run
+func (t *budgetTracker) Record(name string) {
+	t.calls++
+	t.perTool[name]++
+}
+
+// Spend adds the time of an executed call
+func (t *budgetTracker) Spend(elapsed time.Duration) {
+	t.elapsed += elapsed
+}
+
+// Exhausted reports whether no further tool call of any kind may run
+func (t *budgetTracker) Exhausted() bool {
+	return (t.budget.MaxCalls > 0 && t.calls >= t.budget.MaxCalls) ||
+		(t.budget.MaxDuration > 0 && t.elapsed >= t.budget.MaxDuration)
+}
+
+// budgetNotice is the synthetic result returned for a call over budget
+func budgetNotice(prompts Prompts, call ToolCall, reason string) Message {
+	return Message{
+		Role:       RoleTool,
+		ToolName:   call.Name,
+		ToolCallID: call.ID,
+		Content:    prompts.render("budget_exhausted", PromptData{ToolName: call.Name, Reason: reason}),
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+// SystemMessage creates a system message
+func SystemMessage(content string) Message {
+	return Message{Role: RoleSystem, Content: content}
+}
+
+// UserMessage creates a user message with optional image attachments
+func UserMessage(content string, images ...[]byte) Message {
+	return Message{Role: RoleUser, Content: content, Images: images}
+}
+
+// Conversation holds the message history of a chat session
+type Conversation struct {
+	systemPrompt string
+	messages     []Message
+	usage        Usage
+
+	model   string
+	options map[string]any
+
+	toolBudget ToolBudget
+}
+
+// NewConversation creates an empty conversation with a system prompt
+func NewConversation(systemPrompt string) *Conversation {
+	return &Conversation{systemPrompt: systemPrompt}
+}
+
+// SystemPrompt returns the system prompt
+func (c *Conversation) SystemPrompt() string {
+	return c.systemPrompt
+}
+
+// SetSystemPrompt replaces the system prompt
+func (c *Conversation) SetSystemPrompt(prompt string) {
+	c.systemPrompt = prompt
+}
+
+// Model returns the model the conversation was held with
+func (c *Conversation) Model() string {
+	return c.model
+}
+
+// SetModel records the model the conversation is held with
+func (c *Conversation) SetModel(model string) {
+	c.model = model
+}
+
+// Options returns the model options recorded for the conversation
+func (c *Conversation) Options() map[string]any {
+	return c.options
+}
+
+// SetOptions records the model options, such as temperature, used for the conversation
+func (c *Conversation) SetOptions(options map[string]any) {
+	c.options = options
+}
+
+// ToolBudget returns the tool budget applied to agent runs that set none
+func (c *Conversation) ToolBudget() ToolBudget {
+	return c.toolBudget
+}
+
+// SetToolBudget sets the tool budget applied to agent runs that set none
+func (c *Conversation) SetToolBudget(budget ToolBudget) {
+	c.toolBudget = budget
+}
+
+// AddUser appends a user message with optional image attachments
+func (c *Conversation) AddUser(content string, images ...[]byte) {
+	c.messages = append(c.messages, UserMessage(content, images...))
+}
+
+// AddUserWithImageFiles appends a user message with images loaded from files
+func (c *Conversation) AddUserWithImageFiles(content string, paths ...string) error {
+	images := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		image, err := LoadImage(path)
+		if err != nil {
+			return err
+		}
+		images = append(images, image)
+	}
+	c.AddUser(content, images...)
+	return nil
+}
+
+// Append appends messages, such as a response and its tool results, verbatim
+func (c *Conversation) Append(messages ...Message) {
+	c.messages = append(c.messages, messages...)
+}
+
+// History returns the messages after the system prompt
+func (c *Conversation) History() []Message {
+	return c.messages
+}
+
+// Messages returns the full message list to send, starting with the system prompt
+func (c *Conversation) Messages() []Message {
+	messages := make([]Message, 0, len(c.messages)+1)
+	if c.systemPrompt != "" {
+		messages = append(messages, SystemMessage(c.systemPrompt))
+	}
+	return append(messages, c.messages...)
+}
+
+// Reset clears the history but keeps the system prompt
+func (c *Conversation) Reset() {
+	c.messages = nil
+}
+
+// AddUsage accumulates the metrics of a request made for this conversation
+func (c *Conversation) AddUsage(usage Usage) {
+	c.usage.Add(usage)
+}
+
+// Usage returns the totals accumulated over the conversation
+func (c *Conversation) Usage() Usage {
+	return c.usage
+}
+
+// EstimateTokens estimates the prompt size of the conversation at four
+// characters per token, without images or tool definitions
+func (c *Conversation) EstimateTokens() int {
+	tokens := 0
+	for _, message := range c.Messages() {
+		tokens += EstimateTokens(message.Content)
+	}
+	return tokens
+}
+
+// EstimateTokens estimates the tokens of text at four characters per token
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes for failed requests. Providers wrap one of these when they
+// recognize the cause, so callers can use errors.Is to pick a remedy.
+var (
+	// ErrConnection means the model server could not be reached
+	ErrConnection = errors.New("cannot reach model server")
+
+	// ErrModelNotFound means the model is not available on the server
+	ErrModelNotFound = errors.New("model not found")
+
+	// ErrContextTooLarge means the request exceeds the model's context or the server's size limit
+	ErrContextTooLarge = errors.New("request too large for model context")
+
+	// ErrUnauthorized means the server rejected the credentials
+	ErrUnauthorized = errors.New("not authorized by model server")
+
+	// ErrTimeout means the request ran out of time
+	ErrTimeout = errors.New("request timed out")
+)
+
+// ErrInterrupted is returned when a streaming request is cancelled mid-generation
+var ErrInterrupted = errors.New("generation interrupted")
+
+// InterruptedError carries what was generated before a stream was cancelled
+type InterruptedError struct {
+	// Partial is the assistant message assembled up to the cancellation,
+	// including any tool calls received so far
+	Partial Message
+
+	// Cause is the context error that stopped the stream
+	Cause error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%s after %d bytes: %v", ErrInterrupted, len(e.Partial.Content), e.Cause)
+}
+
+// Is allows errors.Is(err, ErrInterrupted)
+func (e *InterruptedError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+// Unwrap allows errors.Is(err, context.Canceled)
+func (e *InterruptedError) Unwrap() error {
+	return e.Cause
+}
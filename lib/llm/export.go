@@ -0,0 +1,268 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultExportResultChars caps each tool result in a Markdown export
+const DefaultExportResultChars = 2000
+
+// ExportField is a setting listed in the header of a Markdown export
+type ExportField struct {
+	Name  string
+	Value string
+}
+
+// ExportOptions configures a Markdown export
+type ExportOptions struct {
+	// Fields are listed in the header in order, such as the model
+	Fields []ExportField
+
+	// MaxResultChars caps each tool result (zero: DefaultExportResultChars,
+	// negative: no cap)
+	MaxResultChars int
+
+	// Time dates the export (zero: now)
+	Time time.Time
+}
+
+// ExportMarkdown writes the conversation to path as Markdown: a header with
+// the settings and the system prompt, a section per turn with the tool
+// calls as collapsible blocks, and the usage totals. Images are written
+// next to path and linked. It returns the paths of the files written.
+func (c *Conversation) ExportMarkdown(path string, opts ExportOptions) ([]string, error) {
+	e := &exporter{
+		opts:   opts,
+		stem:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		dir:    filepath.Dir(path),
+		images: make(map[string][]byte),
+	}
+	if e.opts.MaxResultChars == 0 {
+		e.opts.MaxResultChars = DefaultExportResultChars
+	}
+	if e.opts.Time.IsZero() {
+		e.opts.Time = time.Now()
+	}
+
+	e.header(c.systemPrompt)
+	for _, message := range c.messages {
+		e.message(message)
+	}
+	e.answerPending()
+	e.footer(c.usage)
+
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	written := []string{path}
+	for _, name := range e.imageNames {
+		imagePath := filepath.Join(e.dir, name)
+		if err := os.WriteFile(imagePath, e.images[name], 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write image %s: %w", imagePath, err)
+		}
+		written = append(written, imagePath)
+	}
+	if err := os.WriteFile(path, []byte(e.b.String()), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write export %s: %w", path, err)
+	}
+	return written, nil
+}
+
+// exporter builds a Markdown export
+type exporter struct {
+	b    strings.Builder
+	opts ExportOptions
+
+	// stem and dir name the sibling files images are written to
+	stem string
+	dir  string
+
+	images     map[string][]byte
+	imageNames []string
+
+	// section is the role of the open section; tool messages belong to the
+	// assistant's
+	section string
+
+	// pending are the tool calls not answered yet, in order
+	pending []ToolCall
+}
+
+// header writes the title, the settings and the system prompt
+func (e *exporter) header(systemPrompt string) {
+	e.b.WriteString("# Conversation\n\n")
+	fmt.Fprintf(&e.b, "- **Exported:** %s\n", e.opts.Time.Format("2006-01-02 15:04:05 MST"))
+	for _, field := range e.opts.Fields {
+		fmt.Fprintf(&e.b, "- **%s:** %s\n", field.Name, field.Value)
+	}
+	e.b.WriteString("\n")
+
+	if systemPrompt != "" {
+		e.b.WriteString("<details>\n<summary>System prompt</summary>\n\n")
+		e.b.WriteString(fenced("text", systemPrompt))
+		e.b.WriteString("\n</details>\n\n")
+	}
+}
+
+// message writes one message, opening a section when the speaker changes
+func (e *exporter) message(message Message) {
+	if message.Role == RoleTool {
+		e.toolResult(message)
+		return
+	}
+	e.answerPending()
+
+	if message.Role != e.section {
+		e.section = message.Role
+		fmt.Fprintf(&e.b, "## %s\n\n", sectionTitle(message.Role))
+	}
+	if message.Thinking != "" {
+		e.b.WriteString("<details>\n<summary>Reasoning</summary>\n\n")
+		e.b.WriteString(strings.TrimSpace(message.Thinking))
+		e.b.WriteString("\n\n</details>\n\n")
+	}
+	if content := strings.TrimSpace(message.Content); content != "" {
+		e.b.WriteString(content)
+		e.b.WriteString("\n\n")
+	}
+	if message.Interrupted {
+		e.b.WriteString("*(interrupted)*\n\n")
+	}
+	e.writeImages(message.Images)
+	e.pending = append(e.pending, message.ToolCalls...)
+}
+
+// toolResult writes the block of the call a tool message answers
+func (e *exporter) toolResult(message Message) {
+	call := ToolCall{Name: message.ToolName}
+	if len(e.pending) > 0 {
+		call = e.pending[0]
+		e.pending = e.pending[1:]
+	}
+	e.toolCall(call, &message)
+}
+
+// answerPending writes the calls that got no result, such as those of an
+// interrupted turn
+func (e *exporter) answerPending() {
+	for _, call := range e.pending {
+		e.toolCall(call, nil)
+	}
+	e.pending = nil
+}
+
+// toolCall writes a collapsible block with a call's arguments and result
+// (nil: none)
+func (e *exporter) toolCall(call ToolCall, result *Message) {
+	summary := "🔧 " + call.Name
+	if result == nil {
+		summary += " (no result)"
+	} else if result.Duration > 0 {
+		summary += " (" + formatSeconds(result.Duration) + ")"
+	}
+	fmt.Fprintf(&e.b, "<details>\n<summary>%s</summary>\n\n", escapeHTML(summary))
+
+	if len(call.Arguments) > 0 {
+		arguments, err := json.MarshalIndent(call.Arguments, "", "  ")
+		if err != nil {
+			arguments = []byte(fmt.Sprint(call.Arguments))
+		}
+		e.b.WriteString("Arguments:\n\n")
+		e.b.WriteString(fenced("json", string(arguments)))
+		e.b.WriteString("\n")
+	}
+	if result != nil {
+		e.b.WriteString("Result:\n\n")
+		e.b.WriteString(fenced("text", truncateExport(result.Content, e.opts.MaxResultChars)))
+		e.b.WriteString("\n")
+		e.writeImages(result.Images)
+	}
+	e.b.WriteString("</details>\n\n")
+}
+
+// writeImages links the images, keeping them to be written next to the
+// export
+func (e *exporter) writeImages(images [][]byte) {
+	for _, image := range images {
+		name := fmt.Sprintf("%s-%d%s", e.stem, len(e.imageNames)+1, imageExt(image))
+		e.images[name] = image
+		e.imageNames = append(e.imageNames, name)
+		fmt.Fprintf(&e.b, "![%s](%s)\n\n", name, name)
+	}
+}
+
+// footer writes the usage totals
+func (e *exporter) footer(usage Usage) {
+	e.b.WriteString("---\n\n")
+	fmt.Fprintf(&e.b, "**Usage:** %d requests, %s\n", usage.Requests, usage)
+}
+
+// sectionTitle returns the heading of a role's section
+func sectionTitle(role string) string {
+	switch role {
+	case RoleUser:
+		return "User"
+	case RoleAssistant:
+		return "Assistant"
+	case RoleSystem:
+		return "System"
+	}
+	return role
+}
+
+// fenced returns text as a fenced code block, the fence longer than any
+// backtick run in the text
+func fenced(lang, text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
+
+// truncateExport shortens a tool result to limit runes, noting how much
+// was left out; limit < 0 means unlimited
+func truncateExport(s string, limit int) string {
+	if limit < 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return strings.TrimRight(string(runes[:limit]), "\n") + fmt.Sprintf("\n… %d more characters", len(runes)-limit)
+}
+
+// escapeHTML escapes the characters HTML gives meaning to in a summary
+func escapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// imageExt returns the file extension of an image by its content
+func imageExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	}
+	return ".bin"
+}
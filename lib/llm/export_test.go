@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngImage is enough of a PNG for content sniffing
+var pngImage = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+
+// exportConversation is a session with thinking, parallel tool calls, an
+// image result, a long result and an interrupted turn with an unanswered call
+func exportConversation() *Conversation {
+	c := NewConversation("You are a helpful assistant.\nUse ```code``` fences for code.")
+	c.AddUser("What is in go.mod, and what does the logo look like?")
+	c.Append(
+		Message{
+			Role:     RoleAssistant,
+			Thinking: "I should read go.mod and render the logo.",
+			ToolCalls: []ToolCall{
+				{Name: "filesystem:read_file", Arguments: map[string]any{"path": "go.mod"}},
+				{Name: "image:render", Arguments: map[string]any{"path": "logo.svg", "size": 64}},
+			},
+		},
+		Message{Role: RoleTool, ToolName: "filesystem:read_file", Content: "module example.com/demo\n\ngo 1.24\n", Duration: 120 * time.Millisecond},
+		Message{Role: RoleTool, ToolName: "image:render", Content: "Rendered <logo.svg>", Duration: 1500 * time.Millisecond, Images: [][]byte{pngImage}},
+		Message{Role: RoleAssistant, Content: "The module is `example.com/demo` and the logo is attached."},
+	)
+	c.AddUser("Show me the whole log.")
+	c.Append(
+		Message{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "shell:tail", Arguments: map[string]any{"file": "app.log"}}}},
+		Message{Role: RoleTool, ToolName: "shell:tail", Content: strings.Repeat("log line\n", 10)},
+		Message{Role: RoleAssistant, Content: "The log repeats one", Interrupted: true, ToolCalls: []ToolCall{{Name: "shell:grep"}}},
+	)
+	c.AddUsage(Usage{
+		Requests:         3,
+		PromptTokens:     2345,
+		CompletionTokens: 210,
+		TotalDuration:    4200 * time.Millisecond,
+		LoadDuration:     900 * time.Millisecond,
+		ToolCalls:        3,
+		ToolDuration:     1620 * time.Millisecond,
+	})
+	return c
+}
+
+func TestExportMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.md")
+	written, err := exportConversation().ExportMarkdown(path, ExportOptions{
+		Fields: []ExportField{
+			{Name: "Model", Value: "qwen3:8b"},
+			{Name: "Servers", Value: "filesystem, image, shell"},
+		},
+		MaxResultChars: 42,
+		Time:           time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := filepath.Join(dir, "session-1.png")
+	if want := []string{path, image}; !reflect.DeepEqual(written, want) {
+		t.Errorf("written = %v, want %v", written, want)
+	}
+	if data, err := os.ReadFile(image); err != nil || !bytes.Equal(data, pngImage) {
+		t.Errorf("image file = %v, %v; want the tool's image", data, err)
+	}
+
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "export", string(markdown))
+}
+
+func TestExportMarkdownEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "empty.md")
+	written, err := NewConversation("").ExportMarkdown(path, ExportOptions{
+		Time: time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 {
+		t.Errorf("written = %v, want only the export", written)
+	}
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "export_empty", string(markdown))
+}
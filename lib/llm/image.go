@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// LoadImage reads an image file for attaching to a message
+func LoadImage(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", path, err)
+	}
+	image, err := ImageFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %s: %w", path, err)
+	}
+	return image, nil
+}
+
+// ImageFromBytes validates raw bytes as an image for attaching to a message
+func ImageFromBytes(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+	if mimeType := http.DetectContentType(data); !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("unsupported content type %s", mimeType)
+	}
+	return data, nil
+}
@@ -0,0 +1,137 @@
+// Package llm defines provider-agnostic chat types and the agent loop built on
+// them. Backends such as pkg/ollama implement ChatProvider.
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Done reasons reported in Response.DoneReason. Providers report
+// DoneReasonStop both for a natural end and for a matched stop sequence.
+const (
+	DoneReasonStop   = "stop"
+	DoneReasonLength = "length"
+)
+
+// Message is one turn of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// Thinking holds the model's reasoning, kept apart from the answer
+	Thinking string `json:"thinking,omitempty"`
+
+	// Images are raw image files attached to the message
+	Images [][]byte `json:"images,omitempty"`
+
+	// ToolCalls are the calls requested by an assistant message
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolName and ToolCallID identify the call a tool message answers
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// Duration is the time the call a tool message answers took to run
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Interrupted marks an assistant message cut short by cancellation
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// ToolCall is a tool invocation requested by the model
+type ToolCall struct {
+	// ID identifies the call for providers that match results by ID
+	ID        string         `json:"id,omitempty"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// Response is a complete model response, or one chunk of a streamed one
+type Response struct {
+	Message Message
+
+	// Done is set on the final chunk of a stream
+	Done bool
+
+	// DoneReason tells why generation ended, e.g. DoneReasonLength
+	DoneReason string
+
+	// Usage holds the metrics of the request, reported with the final chunk
+	Usage Usage
+}
+
+// Opts holds per-call overrides for chat requests
+type Opts struct {
+	// Timeout overrides the provider's request timeout for this call
+	Timeout time.Duration
+
+	// Think toggles native reasoning for models that support it (nil: model default)
+	Think *bool
+
+	// OnThinking receives reasoning text as it is separated from the content
+	OnThinking func(thinking string)
+
+	// NoTools sends the request without any tool definitions
+	NoTools bool
+
+	// ToolNames limits the tool definitions sent to the named tools (nil:
+	// all tools)
+	ToolNames []string
+
+	// MaxIterations caps the model calls in ChatWithTools (default: DefaultMaxIterations)
+	MaxIterations int
+
+	// Stop ends generation when the model emits any of these sequences
+	Stop []string
+
+	// NumPredict caps the number of generated tokens (zero: model default)
+	NumPredict int
+
+	// StopOnLength makes ChatWithTools return an answer cut off by NumPredict
+	// instead of asking the model to continue it
+	StopOnLength bool
+
+	// MaxRepeatedToolCalls is how often ChatWithTools lets the model make the
+	// same tool call before intervening (default: DefaultMaxRepeatedToolCalls,
+	// negative: no limit)
+	MaxRepeatedToolCalls int
+
+	// ToolBudget limits the tool calls of a ChatWithTools run (zero: the
+	// conversation's budget, if any)
+	ToolBudget ToolBudget
+}
+
+// ChatProvider is a chat model backend
+type ChatProvider interface {
+	// Chat sends the messages and returns the complete response
+	Chat(ctx context.Context, messages []Message, opts Opts) (*Response, error)
+
+	// ChatStream sends the messages and delivers the response in chunks
+	ChatStream(ctx context.Context, messages []Message, callback func(Response) error, opts Opts) error
+}
+
+// ToolHandler executes the tool calls in a response.
+//
+// HandleToolCalls returns the messages needed to continue the conversation:
+// the assistant message that issued the calls, followed by one tool message
+// per call in the same order.
+type ToolHandler interface {
+	HandleToolCalls(ctx context.Context, response *Response) ([]Message, error)
+}
+
+// ModelChecker is implemented by providers that can verify, without a chat
+// request, that their model is served
+type ModelChecker interface {
+	// CheckModel returns an error wrapping ErrModelNotFound when the model
+	// is not available
+	CheckModel(ctx context.Context) error
+}
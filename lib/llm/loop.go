@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxRepeatedToolCalls is how often the same tool call may be made in
+// one agent run before it is treated as a loop
+const DefaultMaxRepeatedToolCalls = 2
+
+// ErrToolLoop is returned when the model keeps repeating a tool call after
+// being told to stop
+var ErrToolLoop = errors.New("model is stuck repeating a tool call")
+
+// ToolLoopError reports a tool-call loop with the transcript that led to it
+type ToolLoopError struct {
+	// Call is the repeated call
+	Call ToolCall
+
+	// Repeats is how often the call was made
+	Repeats int
+
+	// Transcript holds the conversation history at the time of the abort,
+	// ending with the assistant message that repeated the call
+	Transcript []Message
+}
+
+func (e *ToolLoopError) Error() string {
+	return fmt.Sprintf("%s: %s called %d times with the same arguments", ErrToolLoop, e.Call.Name, e.Repeats)
+}
+
+// Is allows errors.Is(err, ErrToolLoop)
+func (e *ToolLoopError) Is(target error) bool {
+	return target == ErrToolLoop
+}
+
+// loopDetector counts identical tool calls within an agent run
+type loopDetector struct {
+	limit  int
+	counts map[string]int
+	warned map[string]bool
+}
+
+// newLoopDetector creates a detector allowing limit repeats (zero: the
+// default, negative: detection off)
+func newLoopDetector(limit int) *loopDetector {
+	if limit == 0 {
+		limit = DefaultMaxRepeatedToolCalls
+	}
+	return &loopDetector{
+		limit:  limit,
+		counts: make(map[string]int),
+		warned: make(map[string]bool),
+	}
+}
+
+// loopVerdict tells how to treat a tool call
+type loopVerdict int
+
+const (
+	loopNone  loopVerdict = iota // Execute the call
+	loopWarn                     // Skip the call and tell the model to stop repeating it
+	loopAbort                    // The model ignored the warning
+)
+
+// Observe records a call and returns how to treat it
+func (d *loopDetector) Observe(call ToolCall) (loopVerdict, int) {
+	if d.limit < 0 {
+		return loopNone, 0
+	}
+
+	key := callSignature(call)
+	d.counts[key]++
+	count := d.counts[key]
+
+	switch {
+	case count <= d.limit:
+		return loopNone, count
+	case d.warned[key]:
+		return loopAbort, count
+	default:
+		d.warned[key] = true
+		return loopWarn, count
+	}
+}
+
+// callSignature hashes the tool name and canonical arguments. String values
+// are whitespace-normalized so near-duplicates match.
+func callSignature(call ToolCall) string {
+	// encoding/json sorts map keys, which makes the encoding canonical
+	arguments, _ := json.Marshal(normalizeWhitespace(call.Arguments))
+	sum := sha256.Sum256([]byte(call.Name + "\x00" + string(arguments)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeWhitespace collapses runs of whitespace in every string value
+func normalizeWhitespace(v any) any {
+	switch v := v.(type) {
+	case string:
+		return strings.Join(strings.Fields(v), " ")
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = normalizeWhitespace(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = normalizeWhitespace(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// loopWarning is the synthetic result returned instead of repeating a call
+func loopWarning(prompts Prompts, call ToolCall, count int) Message {
+	return Message{
+		Role:       RoleTool,
+		ToolName:   call.Name,
+		ToolCallID: call.ID,
+		Content:    prompts.render("loop_warning", PromptData{ToolName: call.Name, Count: count}),
+	}
+}
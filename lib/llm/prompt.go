@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// DefaultSystemPromptTokens is the default size budget for generated system prompts
+const DefaultSystemPromptTokens = 1500
+
+// defaultPreamble opens every generated system prompt
+const defaultPreamble = "You are a helpful assistant with access to tools. Use a tool when it helps answer the user's question, and answer directly when it doesn't."
+
+// SystemPromptBuilder generates a system prompt from the connected tools
+type SystemPromptBuilder struct {
+	// Preamble opens the prompt (default: a generic assistant description)
+	Preamble string
+
+	// MaxTokens bounds the prompt size, estimated at four characters per
+	// token; tool descriptions are shortened first to fit (default:
+	// DefaultSystemPromptTokens)
+	MaxTokens int
+}
+
+// BuildSystemPrompt generates a system prompt listing tools grouped by
+// category (their first tag, else their server),
+// the instructions provided by servers, and the user's extra text
+func BuildSystemPrompt(tools []tool.Tool, serverInstructions []string, extra string) string {
+	return SystemPromptBuilder{}.Build(tools, serverInstructions, extra)
+}
+
+// promptTool is a tool entry prepared for rendering
+type promptTool struct {
+	name        string
+	description string
+	destructive bool
+}
+
+// promptGroup is a category of tools prepared for rendering
+type promptGroup struct {
+	name  string
+	tools []promptTool
+}
+
+// Build generates the system prompt
+func (b SystemPromptBuilder) Build(tools []tool.Tool, serverInstructions []string, extra string) string {
+	preamble := b.Preamble
+	if preamble == "" {
+		preamble = defaultPreamble
+	}
+	maxChars := b.MaxTokens * 4
+	if maxChars <= 0 {
+		maxChars = DefaultSystemPromptTokens * 4
+	}
+
+	groups, byServer := groupTools(tools)
+
+	// Shorten descriptions evenly when the full prompt would exceed the budget
+	render := func(descLimit int) string {
+		return renderSystemPrompt(preamble, groups, byServer, serverInstructions, extra, descLimit)
+	}
+	prompt := render(-1)
+	if len(prompt) <= maxChars || len(tools) == 0 {
+		return prompt
+	}
+
+	withoutDescriptions := render(0)
+	available := maxChars - len(withoutDescriptions)
+	if available <= 0 {
+		return withoutDescriptions
+	}
+	// The separators and ellipses make the first guess a little long
+	limit := available / len(tools)
+	prompt = render(limit)
+	for len(prompt) > maxChars && limit > 0 {
+		limit--
+		prompt = render(limit)
+	}
+	return prompt
+}
+
+// groupTools partitions tools by category and reports whether they are
+// grouped by server, as no tool has a category tag. Tools listed under their own server drop the
+// server prefix from their names.
+func groupTools(tools []tool.Tool) ([]promptGroup, bool) {
+	byServer := true
+	var groups []promptGroup
+	for _, category := range tool.Categorize(tools) {
+		group := promptGroup{name: category.Name}
+		for _, t := range category.Tools {
+			name := t.Name
+			if server, short, ok := strings.Cut(t.Name, ":"); ok && server == category.Name {
+				name = short
+			}
+			if slices.ContainsFunc(t.Tags, func(tag string) bool { return !tool.IsHintTag(tag) }) {
+				byServer = false
+			}
+			description, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+			group.tools = append(group.tools, promptTool{
+				name:        name,
+				description: description,
+				destructive: t.Destructive,
+			})
+		}
+		groups = append(groups, group)
+	}
+	return groups, byServer
+}
+
+// renderSystemPrompt formats the prompt; descLimit < 0 means unlimited
+func renderSystemPrompt(preamble string, groups []promptGroup, byServer bool, serverInstructions []string, extra string, descLimit int) string {
+	var sb strings.Builder
+	sb.WriteString(preamble)
+	sb.WriteString("\n")
+
+	hasDestructive := false
+	if len(groups) > 0 {
+		if byServer {
+			sb.WriteString("\nAvailable tools by server:\n")
+		} else {
+			sb.WriteString("\nAvailable tools by category:\n")
+		}
+		for _, group := range groups {
+			fmt.Fprintf(&sb, "[%s]\n", group.name)
+			for _, t := range group.tools {
+				sb.WriteString("- ")
+				sb.WriteString(t.name)
+				if t.destructive {
+					sb.WriteString(" (destructive)")
+					hasDestructive = true
+				}
+				if description := truncateText(t.description, descLimit); description != "" {
+					sb.WriteString(": ")
+					sb.WriteString(description)
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	if hasDestructive {
+		sb.WriteString("\nTools marked (destructive) can modify or delete data. Only call them when the user clearly asked for that change.\n")
+	}
+
+	if len(serverInstructions) > 0 {
+		sb.WriteString("\nServer instructions:\n")
+		for _, instructions := range serverInstructions {
+			fmt.Fprintf(&sb, "- %s\n", strings.TrimSpace(instructions))
+		}
+	}
+
+	if extra = strings.TrimSpace(extra); extra != "" {
+		sb.WriteString("\n")
+		sb.WriteString(extra)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// truncateText shortens s to at most limit runes; limit < 0 means unlimited
+func truncateText(s string, limit int) string {
+	if limit < 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	if limit <= 1 {
+		return ""
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name.golden, rewriting the file instead
+// when the test runs with -update
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// promptTools is a set of tools from two servers, one of them destructive
+func promptTools() []tool.Tool {
+	return []tool.Tool{
+		{Name: "filesystem:read_file", Server: "filesystem", Description: "Read the complete contents of a file.\nUse this to inspect a single file."},
+		{Name: "filesystem:write_file", Server: "filesystem", Description: "Create a new file or overwrite an existing one.", Destructive: true, Tags: []string{tool.TagDestructive}},
+		{Name: "filesystem:list_directory", Server: "filesystem"},
+		{Name: "memory:search_nodes", Server: "memory", Description: "Search the knowledge graph for nodes matching a query.", Tags: []string{tool.TagReadOnly}},
+	}
+}
+
+func TestBuildSystemPrompt(t *testing.T) {
+	tagged := promptTools()
+	tagged[0].Tags = []string{"files"}
+	tagged[1].Tags = []string{"files", tool.TagDestructive}
+	tagged[3].Tags = []string{"knowledge", tool.TagReadOnly}
+
+	long := promptTools()
+	for i := range long {
+		long[i].Description = strings.Repeat("A rather long description of what this tool does. ", 10)
+	}
+
+	tests := []struct {
+		name   string
+		prompt string
+	}{
+		{"no_tools", BuildSystemPrompt(nil, nil, "")},
+		{"by_server", BuildSystemPrompt(promptTools(), nil, "")},
+		{"by_category", BuildSystemPrompt(tagged, nil, "")},
+		{"instructions_and_extra", BuildSystemPrompt(promptTools(),
+			[]string{"Paths are relative to the workspace.\n", "  Prefer search_nodes over reading the whole graph."},
+			"\nAnswer in Korean.\n")},
+		{"custom_preamble", SystemPromptBuilder{Preamble: "You are a coding assistant."}.Build(promptTools(), nil, "")},
+		{"truncated", SystemPromptBuilder{MaxTokens: 150}.Build(long, nil, "")},
+		{"over_budget", SystemPromptBuilder{MaxTokens: 10}.Build(long, nil, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			golden(t, "system_prompt_"+tt.name, tt.prompt)
+		})
+	}
+}
+
+func TestBuildSystemPromptFitsBudget(t *testing.T) {
+	tools := promptTools()
+	for i := range tools {
+		tools[i].Description = strings.Repeat("word ", 200)
+	}
+	for _, maxTokens := range []int{120, 200, 400} {
+		prompt := SystemPromptBuilder{MaxTokens: maxTokens}.Build(tools, nil, "")
+		if len(prompt) > maxTokens*4 {
+			t.Errorf("MaxTokens %d: prompt is %d characters, want at most %d", maxTokens, len(prompt), maxTokens*4)
+		}
+		for _, name := range []string{"read_file", "write_file", "list_directory", "search_nodes"} {
+			if !strings.Contains(prompt, "- "+name) {
+				t.Errorf("MaxTokens %d: prompt lost %s", maxTokens, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Prompts holds the text/template sources used to phrase tool results and
+// notices fed back to the model. Empty fields use the defaults.
+type Prompts struct {
+	// ToolResult wraps a successful result ({{.ToolName}}, {{.Result}}, {{.Elapsed}})
+	ToolResult string
+
+	// ToolError reports a failed call ({{.ToolName}}, {{.Error}}, {{.Elapsed}})
+	ToolError string
+
+	// Truncation renders a shortened result ({{.ToolName}}, {{.Head}}, {{.Tail}},
+	// {{.Omitted}}, {{.Total}}, {{.Summary}})
+	Truncation string
+
+	// BudgetExhausted answers a call over budget ({{.ToolName}}, {{.Reason}})
+	BudgetExhausted string
+
+	// LoopWarning answers a repeated call ({{.ToolName}}, {{.Count}})
+	LoopWarning string
+
+	// IterationLimit asks for a final summary when the agent runs out of
+	// iterations ({{.Count}})
+	IterationLimit string
+}
+
+// Default prompt templates
+const (
+	DefaultToolResultPrompt = "{{.Result}}"
+
+	DefaultToolErrorPrompt = "Tool execution failed: {{.Error}}"
+
+	DefaultTruncationPrompt = "{{.Head}}\n…[{{.Omitted}} omitted{{if .Summary}}; summary: {{.Summary}}{{end}}]…\n{{.Tail}}\n\n" +
+		"[Note: this tool result was truncated from {{.Total}}; the middle is missing. If you need it, call the tool again with a narrower query.]"
+
+	DefaultBudgetExhaustedPrompt = "This call to {{.ToolName}} was not executed: the tool budget is spent ({{.Reason}}). " +
+		"Do not call it again; answer with the information you already have."
+
+	DefaultLoopWarningPrompt = "This call to {{.ToolName}} was not executed: it has now been made {{.Count}} times with the same arguments, and the earlier results above still apply. " +
+		"Do not repeat it. Try a different approach, or answer with the information you already have."
+
+	DefaultIterationLimitPrompt = "You have reached the limit of {{.Count}} steps and cannot call any more tools. " +
+		"Summarize what you found so far, say what remains unfinished, and answer as well as you can with the information you have."
+)
+
+// PromptData holds the fields available to prompt templates
+type PromptData struct {
+	ToolName string
+	Result   string
+	Error    string
+	Elapsed  time.Duration
+	Reason   string
+	Count    int
+	Head     string
+	Tail     string
+	Omitted  string
+	Total    string
+	Summary  string
+}
+
+// PromptSource is implemented by tool handlers that carry custom prompts.
+// The agent loop phrases its own notices with them.
+type PromptSource interface {
+	Prompts() Prompts
+}
+
+// Validate parses every template and reports the first one that is invalid
+func (p Prompts) Validate() error {
+	for _, prompt := range p.fields() {
+		if prompt.text == "" {
+			continue
+		}
+		if _, err := parsePrompt(prompt.text); err != nil {
+			return fmt.Errorf("invalid %s prompt: %w", prompt.name, err)
+		}
+	}
+	return nil
+}
+
+// promptField names one template for error messages
+type promptField struct {
+	name     string
+	text     string
+	fallback string
+}
+
+// fields lists the templates with their defaults
+func (p Prompts) fields() []promptField {
+	return []promptField{
+		{"tool_result", p.ToolResult, DefaultToolResultPrompt},
+		{"tool_error", p.ToolError, DefaultToolErrorPrompt},
+		{"truncation", p.Truncation, DefaultTruncationPrompt},
+		{"budget_exhausted", p.BudgetExhausted, DefaultBudgetExhaustedPrompt},
+		{"loop_warning", p.LoopWarning, DefaultLoopWarningPrompt},
+		{"iteration_limit", p.IterationLimit, DefaultIterationLimitPrompt},
+	}
+}
+
+// render executes a template, falling back to the default when the custom
+// one fails so a bad template never breaks a run
+func (f promptField) render(data PromptData) string {
+	if f.text != "" {
+		out, err := executePrompt(f.text, data)
+		if err == nil {
+			return out
+		}
+		slog.Warn("Prompts: Template failed, using the default", "prompt", f.name, "error", err)
+	}
+	out, err := executePrompt(f.fallback, data)
+	if err != nil {
+		// The defaults are constants; this only happens on a programming error
+		panic(err)
+	}
+	return out
+}
+
+// render executes the named template of p
+func (p Prompts) render(name string, data PromptData) string {
+	for _, field := range p.fields() {
+		if field.name == name {
+			return field.render(data)
+		}
+	}
+	panic("unknown prompt " + name)
+}
+
+// parsedPrompts caches parsed templates by source text
+var parsedPrompts sync.Map
+
+// parsePrompt parses a template, reusing earlier parses
+func parsePrompt(text string) (*template.Template, error) {
+	if tmpl, ok := parsedPrompts.Load(text); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	parsedPrompts.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// executePrompt renders a template with data
+func executePrompt(text string, data PromptData) (string, error) {
+	tmpl, err := parsePrompt(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
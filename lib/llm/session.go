@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// SessionVersion is the version of the session document written by Save
+const SessionVersion = 1
+
+// sessionExt is the file extension of saved sessions
+const sessionExt = ".json"
+
+// sessionDocument is the on-disk form of a conversation. Fields may be added
+// in later versions; unknown fields are ignored when loading.
+type sessionDocument struct {
+	Version      int            `json:"version"`
+	SavedAt      time.Time      `json:"saved_at"`
+	Model        string         `json:"model,omitempty"`
+	Options      map[string]any `json:"options,omitempty"`
+	SystemPrompt string         `json:"system_prompt"`
+	Messages     []Message      `json:"messages"`
+	Usage        Usage          `json:"usage"`
+}
+
+// Save writes the conversation to path as a versioned JSON document,
+// replacing any existing file atomically
+func (c *Conversation) Save(path string) error {
+	doc := sessionDocument{
+		Version:      SessionVersion,
+		SavedAt:      time.Now(),
+		Model:        c.model,
+		Options:      c.options,
+		SystemPrompt: c.systemPrompt,
+		Messages:     c.messages,
+		Usage:        c.usage,
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn session
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save session %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save session %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save session %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load replaces the conversation with the one saved at path
+func (c *Conversation) Load(path string) error {
+	doc, err := readSession(path)
+	if err != nil {
+		return err
+	}
+
+	c.systemPrompt = doc.SystemPrompt
+	c.messages = doc.Messages
+	c.usage = doc.Usage
+	c.model = doc.Model
+	c.options = doc.Options
+	return nil
+}
+
+// LoadConversation reads a conversation saved with Save
+func LoadConversation(path string) (*Conversation, error) {
+	c := &Conversation{}
+	if err := c.Load(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readSession reads and version-checks a session document
+func readSession(path string) (*sessionDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+
+	var doc sessionDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+
+	switch {
+	case doc.Version == 0:
+		return nil, fmt.Errorf("%s is not a saved session (missing version)", path)
+	case doc.Version > SessionVersion:
+		return nil, fmt.Errorf("session %s has version %d, newer than the supported version %d", path, doc.Version, SessionVersion)
+	}
+	return &doc, nil
+}
+
+// MissingTools returns the names of tools called in the history that are not
+// among the available tools, in order of first use. The history stays usable:
+// past calls keep their recorded results, and the model simply cannot call
+// the missing tools again.
+func (c *Conversation) MissingTools(available []tool.Tool) []string {
+	known := make(map[string]bool, len(available))
+	for _, t := range available {
+		known[t.Function.Name] = true
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, m := range c.messages {
+		for _, call := range m.ToolCalls {
+			if !known[call.Name] && !seen[call.Name] {
+				seen[call.Name] = true
+				missing = append(missing, call.Name)
+			}
+		}
+	}
+	return missing
+}
+
+// DefaultSessionsDir returns the directory sessions are saved in by
+// convention: "ttobot/sessions" under the user's config directory
+func DefaultSessionsDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(configDir, "ttobot", "sessions"), nil
+}
+
+// SessionPath returns the file path of the named session in dir
+func SessionPath(dir, name string) string {
+	return filepath.Join(dir, name+sessionExt)
+}
+
+// SessionInfo describes a saved session
+type SessionInfo struct {
+	Name     string
+	Path     string
+	SavedAt  time.Time
+	Model    string
+	Messages int
+
+	// FirstMessage is the first line of the first user message, to tell
+	// sessions apart
+	FirstMessage string
+}
+
+// ListSessions returns the sessions saved in dir, newest first. Files that
+// are not readable sessions, such as corrupted ones or ones written by a
+// newer version, are skipped with a warning; a missing dir yields no
+// sessions.
+func ListSessions(dir string) ([]SessionInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list sessions in %s: %w", dir, err)
+	}
+
+	var sessions []SessionInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), sessionExt) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		doc, err := readSession(path)
+		if err != nil {
+			slog.Warn("Session: Skipping an unreadable session", "error", err)
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			Name:         strings.TrimSuffix(entry.Name(), sessionExt),
+			Path:         path,
+			SavedAt:      doc.SavedAt,
+			Model:        doc.Model,
+			Messages:     len(doc.Messages),
+			FirstMessage: firstUserMessage(doc.Messages),
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].SavedAt.After(sessions[j].SavedAt)
+	})
+	return sessions, nil
+}
+
+// firstUserMessage returns the first line of the first user message
+func firstUserMessage(messages []Message) string {
+	for _, m := range messages {
+		if m.Role == RoleUser {
+			line, _, _ := strings.Cut(strings.TrimSpace(m.Content), "\n")
+			return line
+		}
+	}
+	return ""
+}
+
+// PruneSessions deletes sessions in dir beyond the newest keep ones and those
+// saved longer than maxAge ago. A zero keep or maxAge disables that limit.
+// It returns the paths of the deleted sessions.
+func PruneSessions(dir string, keep int, maxAge time.Duration) ([]string, error) {
+	sessions, err := ListSessions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i, session := range sessions {
+		tooMany := keep > 0 && i >= keep
+		tooOld := maxAge > 0 && time.Since(session.SavedAt) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(session.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove session %s: %w", session.Path, err)
+		}
+		removed = append(removed, session.Path)
+	}
+	return removed, nil
+}
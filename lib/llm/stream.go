@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// EventKind tells what a StreamEvent reports
+type EventKind string
+
+const (
+	// EventToken carries a piece of the answer or of the reasoning
+	EventToken EventKind = "token"
+
+	// EventToolCall is sent before a tool call is executed
+	EventToolCall EventKind = "tool_call"
+
+	// EventToolResult is sent after a tool call finished, with its result
+	EventToolResult EventKind = "tool_result"
+
+	// EventDone is sent once with the final response
+	EventDone EventKind = "done"
+)
+
+// StreamEvent is delivered to the StreamWithTools callback
+type StreamEvent struct {
+	Kind EventKind
+
+	// Content is the token text for EventToken and the result for EventToolResult
+	Content string
+
+	// Thinking is reasoning text for EventToken
+	Thinking string
+
+	// ToolCall is the call for EventToolCall and EventToolResult
+	ToolCall *ToolCall
+
+	// Response is the final response for EventDone
+	Response *Response
+}
+
+// StreamWithTools runs the agent loop like ChatWithTools, but streams every
+// model call: tokens are delivered as they arrive, each tool call is
+// announced before it runs and reported when it finishes, and the follow-up
+// completion is streamed in turn until the model answers.
+func StreamWithTools(ctx context.Context, provider ChatProvider, tools ToolHandler, conversation *Conversation, callback func(StreamEvent) error, o Opts) (*AgentResult, error) {
+	chat := func(messages []Message, o Opts) (*Response, error) {
+		var acc responseAccumulator
+		err := provider.ChatStream(ctx, messages, func(chunk Response) error {
+			acc.Add(chunk)
+			if chunk.Message.Content == "" && chunk.Message.Thinking == "" {
+				return nil
+			}
+			return callback(StreamEvent{
+				Kind:     EventToken,
+				Content:  chunk.Message.Content,
+				Thinking: chunk.Message.Thinking,
+			})
+		}, o)
+		if err != nil {
+			return nil, err
+		}
+		return acc.Response(), nil
+	}
+	return runAgent(ctx, tools, conversation, o, chat, callback)
+}
+
+// responseAccumulator assembles streamed chunks into a complete response
+type responseAccumulator struct {
+	content    strings.Builder
+	thinking   strings.Builder
+	toolCalls  []ToolCall
+	doneReason string
+	usage      Usage
+}
+
+// Add records a chunk
+func (a *responseAccumulator) Add(chunk Response) {
+	a.content.WriteString(chunk.Message.Content)
+	a.thinking.WriteString(chunk.Message.Thinking)
+	a.toolCalls = append(a.toolCalls, chunk.Message.ToolCalls...)
+	if chunk.Done {
+		a.doneReason = chunk.DoneReason
+		a.usage.Add(chunk.Usage)
+	}
+}
+
+// Message returns the assistant message assembled so far
+func (a *responseAccumulator) Message() Message {
+	return Message{
+		Role:      RoleAssistant,
+		Content:   a.content.String(),
+		Thinking:  a.thinking.String(),
+		ToolCalls: a.toolCalls,
+	}
+}
+
+// Response returns the assembled response
+func (a *responseAccumulator) Response() *Response {
+	return &Response{
+		Message:    a.Message(),
+		Done:       true,
+		DoneReason: a.doneReason,
+		Usage:      a.usage,
+	}
+}
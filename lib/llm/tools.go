@@ -0,0 +1,237 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// OfferedTools returns the tools a request with these options offers the
+// model: none with NoTools, else those named in ToolNames when it is set
+func (o Opts) OfferedTools(tools []tool.Tool) []tool.Tool {
+	if o.NoTools {
+		return nil
+	}
+	if o.ToolNames == nil {
+		return tools
+	}
+
+	offered := make([]tool.Tool, 0, len(o.ToolNames))
+	for _, t := range tools {
+		if slices.Contains(o.ToolNames, t.Name) {
+			offered = append(offered, t)
+		}
+	}
+	return offered
+}
+
+// ToolRunnerOptions configures a ToolRunner
+type ToolRunnerOptions struct {
+	// ArgumentValidation controls how tool-call arguments are checked before
+	// execution (default: ValidationLenient)
+	ArgumentValidation ValidationMode
+
+	// MaxResultChars caps each tool result fed back to the model, keeping
+	// the head and tail (zero: DefaultMaxToolResultChars, negative: no cap)
+	MaxResultChars int
+
+	// Summarizer, when set, replaces the omitted middle of a truncated tool
+	// result with a short summary produced by an extra model call
+	Summarizer ChatProvider
+
+	// Prompts phrase tool results and notices (default: the Default*Prompt templates)
+	Prompts Prompts
+
+	// Parallelism bounds the tool calls of one response that run at once
+	// (default: 1, one after another); results keep the order of the calls
+	Parallelism int
+}
+
+// ToolRunner executes the tool calls made by a model. It implements
+// ToolHandler and is shared by every provider.
+type ToolRunner struct {
+	tools *tool.Registry
+
+	validation     ValidationMode
+	maxResultChars int
+	summarizer     ChatProvider
+	parallelism    int
+
+	prompts     Prompts
+	promptsLock sync.RWMutex
+}
+
+// NewToolRunner creates a runner with no tools
+func NewToolRunner(opt ToolRunnerOptions) *ToolRunner {
+	maxResultChars := opt.MaxResultChars
+	if maxResultChars == 0 {
+		maxResultChars = DefaultMaxToolResultChars
+	}
+
+	return &ToolRunner{
+		tools:          tool.NewRegistry(tool.RenameDuplicates),
+		validation:     opt.ArgumentValidation,
+		maxResultChars: maxResultChars,
+		summarizer:     opt.Summarizer,
+		parallelism:    max(opt.Parallelism, 1),
+		prompts:        opt.Prompts,
+	}
+}
+
+// SetTools replaces the available tools. Tools with a name already in use
+// are renamed with a numeric suffix.
+func (r *ToolRunner) SetTools(tools []tool.Tool) {
+	// RenameDuplicates never fails
+	_ = r.tools.Set(tools)
+}
+
+// Tools returns the available tools. The slice must not be modified.
+func (r *ToolRunner) Tools() []tool.Tool {
+	return r.tools.Snapshot()
+}
+
+// Registry returns the registry holding the available tools
+func (r *ToolRunner) Registry() *tool.Registry {
+	return r.tools
+}
+
+// SetSummarizer sets the provider used to summarize truncated results
+func (r *ToolRunner) SetSummarizer(summarizer ChatProvider) {
+	r.summarizer = summarizer
+}
+
+// Prompts returns the prompt templates; it implements PromptSource
+func (r *ToolRunner) Prompts() Prompts {
+	r.promptsLock.RLock()
+	defer r.promptsLock.RUnlock()
+	return r.prompts
+}
+
+// SetPrompts replaces the prompt templates
+func (r *ToolRunner) SetPrompts(prompts Prompts) {
+	r.promptsLock.Lock()
+	defer r.promptsLock.Unlock()
+	r.prompts = prompts
+}
+
+// Execute runs a single tool call and returns its result. A result with
+// IsError set is returned without an error; the tool ran and reported a
+// failure the model should see. An executor returning no result and no
+// error yields an empty result.
+func (r *ToolRunner) Execute(ctx context.Context, call ToolCall) (*tool.Result, error) {
+	// Find the tool by name, or by its unprefixed name if that is unambiguous
+	targetTool, ok := r.tools.Get(call.Name)
+	if !ok {
+		return nil, fmt.Errorf("tool %s not found", call.Name)
+	}
+
+	slog.Debug("Tool execution: Executing tool call", "tool", call.Name, "arguments", call.Arguments)
+
+	// Reject bad arguments before they reach the tool so the model can retry
+	if r.validation != ValidationOff {
+		problems := targetTool.Function.Parameters.ValidateArgumentsWith(call.Arguments, r.validation.validationOptions())
+		for _, problem := range problems {
+			if problem.Warning {
+				slog.Warn("Tool execution: Questionable arguments", "tool", call.Name, "problem", problem.Error())
+			} else {
+				slog.Warn("Tool execution: Invalid arguments", "tool", call.Name, "problem", problem.Error())
+			}
+		}
+		if tool.HasErrors(problems) {
+			return nil, fmt.Errorf("invalid arguments for tool %s: %s", call.Name, tool.FormatForModel(problems))
+		}
+	}
+
+	// Execute the tool using its executor
+	result, err := targetTool.Execute(ctx, call.Arguments)
+	if err != nil {
+		slog.Debug("Tool execution: Execution failed", "tool", call.Name, "error", err)
+		return nil, fmt.Errorf("tool execution failed: %w", err)
+	}
+	if result == nil {
+		result = &tool.Result{}
+	}
+
+	slog.Debug("Tool execution: Result", "tool", call.Name, "result", result.Text())
+	return result, nil
+}
+
+// HandleToolCalls implements ToolHandler. Failed calls are reported to the
+// model as tool results rather than returned as errors.
+func (r *ToolRunner) HandleToolCalls(ctx context.Context, response *Response) ([]Message, error) {
+	if len(response.Message.ToolCalls) == 0 {
+		return nil, nil
+	}
+
+	slog.Debug("Tool handling: Processing tool calls", "calls", len(response.Message.ToolCalls))
+
+	newMessages := make([]Message, 0, len(response.Message.ToolCalls)+1)
+	newMessages = append(newMessages, response.Message)
+
+	// Each call is run through Execute for its lookup and validation
+	calls := make([]tool.Call, len(response.Message.ToolCalls))
+	for i, call := range response.Message.ToolCalls {
+		calls[i] = tool.Call{Tool: &tool.Tool{
+			Name: call.Name,
+			Executor: tool.ExecFunc(func(ctx context.Context, _ map[string]any) (*tool.Result, error) {
+				return r.Execute(ctx, call)
+			}),
+		}}
+	}
+	batch := tool.ExecuteBatch(ctx, calls, tool.BatchOpts{Parallelism: r.parallelism})
+
+	prompts := r.Prompts()
+	for i, call := range response.Message.ToolCalls {
+		result, err := batch[i].Result, batch[i].Err
+		data := PromptData{ToolName: call.Name, Elapsed: batch[i].Duration}
+		var content string
+		switch {
+		case err != nil:
+			slog.Warn("Tool handling: Tool call failed", "tool", call.Name, "error", err)
+			data.Error = err.Error()
+			content = prompts.render("tool_error", data)
+		case result.IsError:
+			slog.Warn("Tool handling: Tool reported an error", "tool", call.Name, "error", firstLine(result.Text()))
+			data.Error = result.Text()
+			content = prompts.render("tool_error", data)
+		default:
+			data.Result = result.Text()
+			content = prompts.render("tool_result", data)
+		}
+		content = r.truncateResult(ctx, call.Name, content)
+
+		message := Message{
+			Role:       RoleTool,
+			Content:    content,
+			ToolName:   call.Name,
+			ToolCallID: call.ID,
+			Duration:   batch[i].Duration,
+		}
+		// Images returned by the tool are attached so vision models can see them
+		for _, image := range result.Binary("image/") {
+			message.Images = append(message.Images, image.Data)
+		}
+		newMessages = append(newMessages, message)
+	}
+
+	slog.Debug("Tool handling: Created tool result messages", "messages", len(newMessages)-1)
+	return newMessages, nil
+}
+
+// firstLine returns the first non-empty line of s, shortened for logs
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > 200 {
+				line = line[:200] + "…"
+			}
+			return line
+		}
+	}
+	return ""
+}
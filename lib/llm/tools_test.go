@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// resultTool returns a tool answering every call with result and err
+func resultTool(name string, result *tool.Result, err error) tool.Tool {
+	return tool.Tool{
+		Name:     name,
+		Function: tool.ToolFunction{Name: name, Parameters: tool.ParameterSchema{Type: "object"}},
+		Executor: tool.ExecFunc(func(ctx context.Context, _ map[string]any) (*tool.Result, error) {
+			return result, err
+		}),
+	}
+}
+
+func TestToolRunnerResultParts(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	jpeg := []byte{0xff, 0xd8, 0xff}
+	runner := NewToolRunner(ToolRunnerOptions{})
+	runner.SetTools([]tool.Tool{
+		resultTool("text", tool.TextResult("plain"), nil),
+		resultTool("json", &tool.Result{Parts: []tool.Part{tool.JSONPart{Data: json.RawMessage(`{"n":1}`)}}}, nil),
+		resultTool("media", &tool.Result{Parts: []tool.Part{
+			tool.TextPart{Text: "two images: "},
+			tool.BinaryPart{MIMEType: "image/png", Data: png},
+			tool.BinaryPart{MIMEType: "audio/wav", Data: []byte{1}},
+			tool.BinaryPart{MIMEType: "image/jpeg", Data: jpeg},
+		}}, nil),
+		resultTool("reported", tool.ErrorResult("disk full"), nil),
+		resultTool("failed", nil, errors.New("connection lost")),
+		resultTool("nothing", nil, nil),
+		// A tool written against the old string signature
+		{
+			Name:     "legacy",
+			Function: tool.ToolFunction{Name: "legacy", Parameters: tool.ParameterSchema{Type: "object"}},
+			Executor: tool.TextAdapter{Executor: tool.TextExecutorFunc(func(ctx context.Context, _ map[string]any) (string, error) {
+				return "adapted", nil
+			})},
+		},
+	})
+	response := &Response{Message: toolCalls("text", "json", "media", "reported", "failed", "nothing", "legacy").Message}
+	messages, err := runner.HandleToolCalls(context.Background(), response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		content string
+		images  [][]byte
+	}{
+		{content: "plain"},
+		{content: `{"n":1}`},
+		{content: "two images: [image/png, 8 bytes][audio/wav, 1 bytes][image/jpeg, 3 bytes]", images: [][]byte{png, jpeg}},
+		{content: "Tool execution failed: disk full"},
+		{content: "Tool execution failed: tool execution failed: connection lost"},
+		{content: ""},
+		{content: "adapted"},
+	}
+	if len(messages) != len(tests)+1 {
+		t.Fatalf("got %d messages, want %d", len(messages), len(tests)+1)
+	}
+	for i, tt := range tests {
+		message := messages[i+1]
+		if message.Content != tt.content {
+			t.Errorf("%s content = %q, want %q", message.ToolName, message.Content, tt.content)
+		}
+		if !reflect.DeepEqual(message.Images, tt.images) {
+			t.Errorf("%s images = %v, want %v", message.ToolName, message.Images, tt.images)
+		}
+	}
+}
+
+func TestToolRunnerExecuteWithoutResult(t *testing.T) {
+	runner := NewToolRunner(ToolRunnerOptions{})
+	runner.SetTools([]tool.Tool{resultTool("nothing", nil, nil)})
+
+	result, err := runner.Execute(context.Background(), ToolCall{Name: "nothing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.IsError || result.Text() != "" {
+		t.Errorf("got %+v, want an empty result", result)
+	}
+}
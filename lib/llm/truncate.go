@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// DefaultMaxToolResultChars is the default cap on a single tool result
+const DefaultMaxToolResultChars = 12 * 1024
+
+// maxSummaryInputChars bounds how much omitted text is sent for summarization
+const maxSummaryInputChars = 64 * 1024
+
+// truncatedResult holds the pieces of a tool result that exceeded the cap
+type truncatedResult struct {
+	head    string
+	omitted string
+	tail    string
+	total   int
+}
+
+// splitOversized keeps the head and tail of s within limit characters.
+// It returns false when s fits.
+func splitOversized(s string, limit int) (truncatedResult, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return truncatedResult{}, false
+	}
+
+	headLen := limit * 2 / 3
+	tailLen := limit - headLen
+
+	// Avoid cutting multi-byte characters in half
+	for headLen > 0 && !isRuneStart(s[headLen]) {
+		headLen--
+	}
+	tailStart := len(s) - tailLen
+	for tailStart < len(s) && !isRuneStart(s[tailStart]) {
+		tailStart++
+	}
+
+	return truncatedResult{
+		head:    s[:headLen],
+		omitted: s[headLen:tailStart],
+		tail:    s[tailStart:],
+		total:   len(s),
+	}, true
+}
+
+// isRuneStart reports whether b begins a UTF-8 sequence
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// render joins the pieces with an omission marker and a note for the model
+func (t truncatedResult) render(prompts Prompts, toolName string, summary string) string {
+	return prompts.render("truncation", PromptData{
+		ToolName: toolName,
+		Head:     t.head,
+		Tail:     t.tail,
+		Omitted:  formatKB(len(t.omitted)),
+		Total:    formatKB(t.total),
+		Summary:  summary,
+	})
+}
+
+// formatKB renders a byte count in kilobytes
+func formatKB(n int) string {
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
+
+// truncateResult applies the configured cap to a tool result, optionally
+// summarizing the omitted middle with a quick model call
+func (r *ToolRunner) truncateResult(ctx context.Context, toolName string, result string) string {
+	truncated, ok := splitOversized(result, r.maxResultChars)
+	if !ok {
+		return result
+	}
+
+	slog.Info("Tool handling: Truncating result", "tool", toolName, "chars", len(result), "limit", r.maxResultChars)
+
+	var summary string
+	if r.summarizer != nil {
+		var err error
+		summary, err = summarizeOmitted(ctx, r.summarizer, toolName, truncated.omitted)
+		if err != nil {
+			slog.Warn("Tool handling: Summarizing omitted output failed", "error", err)
+		}
+	}
+
+	return truncated.render(r.Prompts(), toolName, summary)
+}
+
+// summarizeOmitted asks the model for a short summary of omitted tool output
+func summarizeOmitted(ctx context.Context, provider ChatProvider, toolName string, omitted string) (string, error) {
+	if len(omitted) > maxSummaryInputChars {
+		omitted = omitted[:maxSummaryInputChars]
+	}
+
+	resp, err := provider.Chat(ctx, []Message{
+		SystemMessage("Summarize the following excerpt of tool output in at most three sentences. Keep file names, identifiers, and numbers that look important."),
+		UserMessage(fmt.Sprintf("Excerpt from %s:\n\n%s", toolName, omitted)),
+	}, Opts{NoTools: true})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(resp.Message.Content), " "), nil
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Usage collects token counts and timings reported by the model
+type Usage struct {
+	// Requests is the number of chat requests made
+	Requests int `json:"requests"`
+
+	// PromptTokens is the number of prompt tokens evaluated
+	PromptTokens int `json:"prompt_tokens"`
+
+	// CompletionTokens is the number of tokens generated
+	CompletionTokens int `json:"completion_tokens"`
+
+	// TotalDuration is the time the server spent on the requests
+	TotalDuration time.Duration `json:"total_duration"`
+
+	// LoadDuration is the part of TotalDuration spent loading the model
+	LoadDuration time.Duration `json:"load_duration"`
+
+	// ToolCalls is the number of tool calls executed
+	ToolCalls int `json:"tool_calls,omitempty"`
+
+	// ToolDuration is the wall time spent executing tools
+	ToolDuration time.Duration `json:"tool_duration,omitempty"`
+}
+
+// Add accumulates other into u
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalDuration += other.TotalDuration
+	u.LoadDuration += other.LoadDuration
+	u.ToolCalls += other.ToolCalls
+	u.ToolDuration += other.ToolDuration
+}
+
+// String renders the usage as "prompt 1.2k tok, completion 345 tok, 8.3s (model load 2.1s)",
+// followed by ", 3 tool calls in 1.2s" when tools ran
+func (u Usage) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "prompt %s tok, completion %s tok, %s", FormatTokens(u.PromptTokens), FormatTokens(u.CompletionTokens), formatSeconds(u.TotalDuration))
+	if u.LoadDuration >= 100*time.Millisecond {
+		fmt.Fprintf(&sb, " (model load %s)", formatSeconds(u.LoadDuration))
+	}
+	if u.ToolCalls > 0 {
+		fmt.Fprintf(&sb, ", %d tool calls in %s", u.ToolCalls, formatSeconds(u.ToolDuration))
+	}
+	return sb.String()
+}
+
+// FormatTokens abbreviates token counts of a thousand or more, such as 1.2k
+func FormatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+// formatSeconds renders a duration in seconds with one decimal
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
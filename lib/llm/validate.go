@@ -0,0 +1,27 @@
+package llm
+
+import (
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// ValidationMode controls how strictly tool-call arguments are checked
+// against the tool's parameter schema before execution
+type ValidationMode int
+
+const (
+	// ValidationLenient checks required arguments, basic types, and enums,
+	// and logs arguments not declared in the schema
+	ValidationLenient ValidationMode = iota
+	// ValidationStrict additionally rejects arguments not declared in the schema
+	ValidationStrict
+	// ValidationOff passes arguments to the tool unchecked
+	ValidationOff
+)
+
+// validationOptions returns the schema validation options for the mode
+func (m ValidationMode) validationOptions() tool.ValidationOptions {
+	if m == ValidationStrict {
+		return tool.ValidationOptions{Unknown: tool.UnknownReject}
+	}
+	return tool.ValidationOptions{Unknown: tool.UnknownWarn}
+}
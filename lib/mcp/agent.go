@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Agent limits
+const (
+	// MaxAgentIterations is the highest accepted agent.max_iterations
+	MaxAgentIterations = 50
+
+	// DefaultAgentRequestTimeout bounds model requests unless configured
+	DefaultAgentRequestTimeout = 5 * time.Minute
+)
+
+// Answers of agent.confirm_fallback
+const (
+	ConfirmDeny    = "deny"
+	ConfirmApprove = "approve"
+)
+
+// AgentConfig holds the agent loop settings
+type AgentConfig struct {
+	// MaxIterations caps the model calls in one agent run, between 1 and
+	// MaxAgentIterations (zero: the built-in default)
+	MaxIterations int `json:"max_iterations,omitempty" yaml:"max_iterations,omitempty"`
+
+	// MaxToolResultChars caps each tool result fed back to the model
+	// (zero: the built-in default)
+	MaxToolResultChars int `json:"max_tool_result_chars,omitempty" yaml:"max_tool_result_chars,omitempty"`
+
+	// ToolBudget limits the tool calls of one agent run (zero: no limit)
+	ToolBudget ToolBudgetConfig `json:"tool_budget,omitempty" yaml:"tool_budget,omitempty"`
+
+	// ToolCache reuses the results of read-only tools (unset: no caching)
+	ToolCache ToolCacheConfig `json:"tool_cache,omitempty" yaml:"tool_cache,omitempty"`
+
+	// ConfirmDestructive asks before running tools that modify data
+	ConfirmDestructive bool `json:"confirm_destructive,omitempty" yaml:"confirm_destructive,omitempty"`
+
+	// ConfirmFallback answers for the user when ConfirmDestructive is set
+	// but no one can be asked, as in ttobot serve or with standard input
+	// not a terminal: ConfirmDeny (default) or ConfirmApprove
+	ConfirmFallback string `json:"confirm_fallback,omitempty" yaml:"confirm_fallback,omitempty"`
+
+	// MaxTools caps the tools offered to the model; when more are
+	// connected, the most relevant to the request are chosen (zero: all)
+	MaxTools int `json:"max_tools,omitempty" yaml:"max_tools,omitempty"`
+
+	// RequestTimeout bounds each model request unless the provider section
+	// sets its own (default: DefaultAgentRequestTimeout)
+	RequestTimeout string `json:"request_timeout,omitempty" yaml:"request_timeout,omitempty"`
+}
+
+// ToolBudgetConfig limits the tool calls of one agent run
+type ToolBudgetConfig struct {
+	// MaxCalls caps the total number of tool calls
+	MaxCalls int `json:"max_calls,omitempty" yaml:"max_calls,omitempty"`
+
+	// MaxCallsPerTool caps the calls of individual tools, keyed by the
+	// prefixed tool name such as "filesystem:write_file"
+	MaxCallsPerTool map[string]int `json:"max_calls_per_tool,omitempty" yaml:"max_calls_per_tool,omitempty"`
+
+	// MaxDuration caps the cumulative time spent executing tools
+	MaxDuration string `json:"max_duration,omitempty" yaml:"max_duration,omitempty"`
+}
+
+// ToolCacheConfig configures the cache of tool results. Setting either
+// field turns the cache on.
+type ToolCacheConfig struct {
+	// TTL is how long a result is reused (empty: until a tool of the same
+	// server modifies something)
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	// MaxEntries bounds the cached results (zero: tool.DefaultCacheEntries)
+	MaxEntries int `json:"max_entries,omitempty" yaml:"max_entries,omitempty"`
+}
+
+// Enabled reports whether the cache is configured
+func (c ToolCacheConfig) Enabled() bool {
+	return c.TTL != "" || c.MaxEntries != 0
+}
+
+// AgentSettings holds the parsed agent settings with defaults applied,
+// for the agent loop and the confirmation of destructive tools
+type AgentSettings struct {
+	// MaxIterations caps the model calls in one run (zero: the built-in default)
+	MaxIterations int
+
+	// MaxToolResultChars caps each tool result (zero: the built-in default)
+	MaxToolResultChars int
+
+	// Tool budget of one run; zero values set no limit
+	MaxToolCalls        int
+	MaxToolCallsPerTool map[string]int
+	MaxToolDuration     time.Duration
+
+	// Tool result cache; ToolCacheTTL zero means no expiry
+	ToolCache        bool
+	ToolCacheTTL     time.Duration
+	ToolCacheEntries int
+
+	ConfirmDestructive bool
+	RequestTimeout     time.Duration
+
+	// ConfirmApprove approves destructive tools when no one can be asked
+	// (false: they are blocked)
+	ConfirmApprove bool
+
+	// MaxTools caps the tools offered to the model (zero: all)
+	MaxTools int
+}
+
+// Settings returns the parsed settings. It assumes a validated config.
+func (a AgentConfig) Settings() AgentSettings {
+	settings := AgentSettings{
+		MaxIterations:       a.MaxIterations,
+		MaxToolResultChars:  a.MaxToolResultChars,
+		MaxToolCalls:        a.ToolBudget.MaxCalls,
+		MaxToolCallsPerTool: a.ToolBudget.MaxCallsPerTool,
+		ConfirmDestructive:  a.ConfirmDestructive,
+		ConfirmApprove:      a.ConfirmFallback == ConfirmApprove,
+		RequestTimeout:      DefaultAgentRequestTimeout,
+		MaxTools:            a.MaxTools,
+		ToolCache:           a.ToolCache.Enabled(),
+		ToolCacheEntries:    a.ToolCache.MaxEntries,
+	}
+	if d, err := time.ParseDuration(a.ToolCache.TTL); err == nil {
+		settings.ToolCacheTTL = d
+	}
+	if d, err := time.ParseDuration(a.ToolBudget.MaxDuration); err == nil {
+		settings.MaxToolDuration = d
+	}
+	if d, err := time.ParseDuration(a.RequestTimeout); err == nil && d > 0 {
+		settings.RequestTimeout = d
+	}
+	return settings
+}
+
+// validate checks the ranges of the agent settings
+func (a AgentConfig) validate() error {
+	if a.MaxIterations < 0 || a.MaxIterations > MaxAgentIterations {
+		return fmt.Errorf("agent.max_iterations must be between 1 and %d, got %d", MaxAgentIterations, a.MaxIterations)
+	}
+	if a.MaxToolResultChars < 0 {
+		return fmt.Errorf("agent.max_tool_result_chars must be positive, got %d", a.MaxToolResultChars)
+	}
+	if a.ConfirmFallback != "" && a.ConfirmFallback != ConfirmDeny && a.ConfirmFallback != ConfirmApprove {
+		return fmt.Errorf("agent.confirm_fallback must be %s or %s, got %q", ConfirmDeny, ConfirmApprove, a.ConfirmFallback)
+	}
+	if a.MaxTools < 0 {
+		return fmt.Errorf("agent.max_tools must be positive, got %d", a.MaxTools)
+	}
+	if a.ToolBudget.MaxCalls < 0 {
+		return fmt.Errorf("agent.tool_budget.max_calls must be positive, got %d", a.ToolBudget.MaxCalls)
+	}
+	names := make([]string, 0, len(a.ToolBudget.MaxCallsPerTool))
+	for name := range a.ToolBudget.MaxCallsPerTool {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if calls := a.ToolBudget.MaxCallsPerTool[name]; calls <= 0 {
+			return fmt.Errorf("agent.tool_budget.max_calls_per_tool.%s must be positive, got %d", name, calls)
+		}
+	}
+	if err := validateAgentDuration("agent.tool_budget.max_duration", a.ToolBudget.MaxDuration); err != nil {
+		return err
+	}
+	if a.ToolCache.MaxEntries < 0 {
+		return fmt.Errorf("agent.tool_cache.max_entries must be positive, got %d", a.ToolCache.MaxEntries)
+	}
+	if err := validateAgentDuration("agent.tool_cache.ttl", a.ToolCache.TTL); err != nil {
+		return err
+	}
+	return validateAgentDuration("agent.request_timeout", a.RequestTimeout)
+}
+
+// validateAgentDuration checks an optional, positive duration field
+func validateAgentDuration(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: expected a duration such as \"2m\"", field, value)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %q", field, value)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Servers bundled with ttobot that run in-process with builtin_servers
+const (
+	BuiltinFilesystem = "filesystem"
+	BuiltinGodoc      = "godoc"
+)
+
+// builtinPrefixes are the names the builtin servers are registered under,
+// so their tools appear as fs:read_file or go:go_test
+var builtinPrefixes = map[string]string{
+	BuiltinFilesystem: "fs",
+	BuiltinGodoc:      "go",
+}
+
+// BuiltinServerNames returns the names of the builtin servers in order
+func BuiltinServerNames() []string {
+	names := make([]string, 0, len(builtinPrefixes))
+	for name := range builtinPrefixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinPrefix returns the server name a builtin server is registered
+// under, and false for unknown servers
+func BuiltinPrefix(name string) (string, bool) {
+	prefix, ok := builtinPrefixes[name]
+	return prefix, ok
+}
+
+// validateBuiltins checks the builtin server names and resolves
+// builtin_root against baseDir
+func (f *ConfigFile) validateBuiltins(baseDir string) error {
+	seen := make(map[string]bool, len(f.BuiltinServers))
+	for _, name := range f.BuiltinServers {
+		prefix, ok := BuiltinPrefix(name)
+		if !ok {
+			return fmt.Errorf("unknown builtin server %q (available: %s)", name, strings.Join(BuiltinServerNames(), ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("builtin server %s is listed twice", name)
+		}
+		seen[name] = true
+		if f.hasServer(prefix) {
+			return fmt.Errorf("builtin server %s is registered as %s, which a configured server is named too", name, prefix)
+		}
+	}
+
+	if f.BuiltinRoot != "" {
+		root := expandHome(f.BuiltinRoot)
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(baseDir, root)
+		}
+		f.BuiltinRoot = root
+	}
+	return nil
+}
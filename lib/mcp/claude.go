@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// claudeConfig is the JSON layout used by Claude Desktop, Cursor and other
+// MCP clients: servers keyed by name under "mcpServers"
+type claudeConfig struct {
+	MCPServers map[string]claudeServer `json:"mcpServers"`
+}
+
+// claudeServer is one entry of claudeConfig
+type claudeServer struct {
+	Command  string            `json:"command"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	Type     string            `json:"type"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Disabled bool              `json:"disabled"`
+}
+
+// isClaudeConfig reports whether a JSON document uses the mcpServers layout
+func isClaudeConfig(data []byte) bool {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, ok := probe["mcpServers"]
+	return ok
+}
+
+// parseClaudeConfig translates an mcpServers document into server configs,
+// sorted by name
+func parseClaudeConfig(data []byte) ([]Config, error) {
+	var doc claudeConfig
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse mcpServers config: %w", err)
+	}
+
+	names := make([]string, 0, len(doc.MCPServers))
+	for name := range doc.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	configs := make([]Config, 0, len(names))
+	for _, name := range names {
+		server := doc.MCPServers[name]
+		config := Config{
+			Name:        name,
+			Command:     server.Command,
+			Args:        server.Args,
+			Environment: server.Env,
+			URL:         server.URL,
+			Headers:     server.Headers,
+		}
+
+		switch server.Type {
+		case "", "stdio":
+			// A URL without a type is a remote server in Cursor's layout
+			if server.Command == "" && server.URL != "" {
+				config.Transport = TransportSSE
+			}
+		case "sse":
+			config.Transport = TransportSSE
+		case "http", "streamable-http", "streamableHttp":
+			config.Transport = TransportHTTP
+		default:
+			return nil, fmt.Errorf("server %s has unsupported type %q", name, server.Type)
+		}
+
+		if server.Disabled {
+			enabled := false
+			config.Enabled = &enabled
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// ImportClaudeConfig reads the servers of a Claude Desktop or Cursor style
+// JSON config (servers keyed by name under "mcpServers")
+func ImportClaudeConfig(filePath string) ([]Config, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", filePath, err)
+	}
+	if !isClaudeConfig(data) {
+		return nil, fmt.Errorf("%s has no mcpServers section", filePath)
+	}
+
+	configs, err := parseClaudeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s: %w", filePath, err)
+	}
+	for i, config := range configs {
+		if err := config.validate(i); err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", filePath, err)
+		}
+	}
+	return configs, nil
+}
+
+// MarshalServersYAML renders servers as the servers section of mcp.yaml
+func MarshalServersYAML(servers []Config) ([]byte, error) {
+	doc := struct {
+		Servers []Config `yaml:"servers"`
+	}{servers}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode servers: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode servers: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertClaudeConfig imports a Claude Desktop or Cursor style JSON config
+// and writes the equivalent mcp.yaml to dst
+func ConvertClaudeConfig(src, dst string) error {
+	configs, err := ImportClaudeConfig(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := MarshalServersYAML(configs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", dst, err)
+	}
+	return nil
+}
+
+// externalConfigPaths returns the well-known config locations of other MCP
+// clients: Claude Desktop and Cursor
+func externalConfigPaths() []string {
+	var paths []string
+	if configDir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(configDir, "Claude", "claude_desktop_config.json"))
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, ".cursor", "mcp.json"))
+	}
+	return paths
+}
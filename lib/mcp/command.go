@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// launcherHints names what to install for common package launchers
+var launcherHints = map[string]string{
+	"npx":    "Node.js",
+	"uvx":    "uv",
+	"uv":     "uv",
+	"bunx":   "Bun",
+	"pipx":   "pipx",
+	"docker": "Docker",
+}
+
+// checkCommands checks that the command of every enabled stdio server can
+// be run and reports all problems together
+func (f *ConfigFile) checkCommands() error {
+	var errs []error
+	for _, config := range f.Servers {
+		if !config.IsEnabled() || config.SkipCommandCheck || config.TransportType() != TransportStdio {
+			continue
+		}
+		if err := config.CheckCommand(); err != nil {
+			errs = append(errs, fmt.Errorf("server %s: %w", config.Name, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d server command(s) cannot be run (set skip_command_check: true to skip this check):\n%w", len(errs), errors.Join(errs...))
+}
+
+// CheckCommand checks that the expanded command exists and is executable.
+// For launchers such as npx or uvx, only the launcher itself is checked,
+// not the package it runs.
+func (c Config) CheckCommand() error {
+	command := expandHome(c.expand(c.Command))
+	if command == "" {
+		return fmt.Errorf("command %q expands to an empty string", c.Command)
+	}
+
+	if !strings.ContainsRune(command, '/') && !strings.ContainsRune(command, filepath.Separator) {
+		if _, err := exec.LookPath(command); err != nil {
+			if hint, ok := launcherHints[command]; ok {
+				return fmt.Errorf("command %q not found in PATH; is %s installed?", command, hint)
+			}
+			return fmt.Errorf("command %q not found in PATH", command)
+		}
+		return nil
+	}
+
+	// Relative paths run from the server's working directory
+	path := command
+	if !filepath.IsAbs(path) && c.WorkingDir != "" {
+		path = filepath.Join(c.WorkingDir, path)
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return fmt.Errorf("command %s does not exist", path)
+	case info.IsDir():
+		return fmt.Errorf("command %s is a directory", path)
+	case runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0:
+		return fmt.Errorf("command %s is not executable", path)
+	}
+	return nil
+}
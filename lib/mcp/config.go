@@ -2,183 +2,956 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Supported values for Config.Transport
+const (
+	TransportStdio     = "stdio"
+	TransportHTTP      = "http"
+	TransportSSE       = "sse"
+	TransportWebSocket = "websocket"
+)
+
 // Config represents the configuration for an MCP server
 type Config struct {
 	// Name of the MCP server
 	Name        string            `json:"name" yaml:"name"`
-	Command     string            `json:"command" yaml:"command"`
+	Command     string            `json:"command,omitempty" yaml:"command,omitempty"`
 	Args        []string          `json:"args,omitempty" yaml:"args,omitempty"`
 	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	// Transport selects how to reach the server (default: TransportStdio)
+	Transport string `json:"transport,omitempty" yaml:"transport,omitempty"`
+
+	// URL of a remote server, required for every transport but stdio
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// Headers are sent with every request to a remote server. Values may
+	// reference environment variables, e.g. "Bearer ${API_TOKEN}".
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// WorkingDir is the directory a stdio server is started in. Environment
+	// variables and a leading ~ are expanded, and relative paths are resolved
+	// against the directory of the config file. Relative paths in Args and
+	// a relative Command containing a slash are then relative to it.
+	WorkingDir string `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+
+	// ConnectTimeout bounds connecting and the initialize handshake, and
+	// CallTimeout bounds each tool call, as Go durations such as "30s"
+	// (empty: the client-wide defaults)
+	ConnectTimeout string `json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty"`
+	CallTimeout    string `json:"call_timeout,omitempty" yaml:"call_timeout,omitempty"`
+
+	// AllowedTools exposes only the tools matching one of these glob
+	// patterns, and BlockedTools hides the matching ones. At most one of
+	// them may be set.
+	AllowedTools []string `json:"allowed_tools,omitempty" yaml:"allowed_tools,omitempty"`
+	BlockedTools []string `json:"blocked_tools,omitempty" yaml:"blocked_tools,omitempty"`
+
+	// DestructiveTools and ReadOnlyTools are glob patterns overriding what
+	// the server's annotations, or failing those the tool names, say about
+	// its tools. Destructive tools can be made to ask before they run; a
+	// tool matching both lists counts as destructive.
+	DestructiveTools []string `json:"destructive_tools,omitempty" yaml:"destructive_tools,omitempty"`
+	ReadOnlyTools    []string `json:"read_only_tools,omitempty" yaml:"read_only_tools,omitempty"`
+
+	// Tags are attached to every tool of the server; the first one names
+	// the group the tools are listed under in the system prompt
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// EnvFile loads variables from a .env file, relative to the config file.
+	// They are used for expansion and passed to the server, overriding the
+	// global env_file; the environment section overrides both.
+	EnvFile EnvFile `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+
+	// Enabled turns the server off when set to false; disabled servers are
+	// still validated but not connected (default: true)
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	// LogFile receives the server's stderr, rolled over to LogFile.1 at
+	// LogFileMaxMB. "inherit" passes it to ttobot's stderr (default: the
+	// last StderrBufferSize bytes are kept in memory).
+	LogFile      string `json:"log_file,omitempty" yaml:"log_file,omitempty"`
+	LogFileMaxMB int    `json:"log_file_max_mb,omitempty" yaml:"log_file_max_mb,omitempty"`
+
+	// SkipCommandCheck turns off the load-time check that the command
+	// exists and is executable
+	SkipCommandCheck bool `json:"skip_command_check,omitempty" yaml:"skip_command_check,omitempty"`
+
+	// ResolveArgs makes args starting with ./ or ../ absolute, like a
+	// relative command: against working_dir, or the config file's directory
+	ResolveArgs bool `json:"resolve_args,omitempty" yaml:"resolve_args,omitempty"`
+
+	// Restart decides whether the server is started again after it exits
+	// or its connection drops (default: never)
+	Restart RestartConfig `json:"restart,omitempty" yaml:"restart,omitempty"`
+
+	// fileEnv holds the variables loaded from the global and server env files
+	fileEnv map[string]string
+
+	// denyExecSecrets rejects !cmd: secrets (allow_exec_secrets: false)
+	denyExecSecrets bool
+}
+
+// IsEnabled reports whether the server should be connected
+func (c Config) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// TransportType returns the configured transport, defaulting to stdio
+func (c Config) TransportType() string {
+	if c.Transport == "" {
+		return TransportStdio
+	}
+	return c.Transport
+}
+
+// ResolveHeaders returns the headers with environment variables expanded
+// and secret references resolved. Secrets are read on every call, so a
+// reconnect picks up rotated values.
+func (c Config) ResolveHeaders(ctx context.Context) (map[string]string, error) {
+	if len(c.Headers) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(c.Headers))
+	lookup := c.envLookup()
+	for _, key := range sortedKeys(c.Headers) {
+		value, err := c.resolveValue(ctx, "headers."+key, c.Headers[key], lookup)
+		if err != nil {
+			return nil, err
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// ToolAllowed reports whether the server's tool of the given name (without
+// the server prefix) passes the allowed_tools and blocked_tools filters
+func (c Config) ToolAllowed(name string) bool {
+	if len(c.AllowedTools) > 0 {
+		return matchAny(c.AllowedTools, name)
+	}
+	return !matchAny(c.BlockedTools, name)
+}
+
+// ToolSafety applies the destructive_tools and read_only_tools overrides
+// to what is known about the server's tool of the given name (without the
+// server prefix)
+func (c Config) ToolSafety(name string, readOnly, destructive bool) (bool, bool) {
+	switch {
+	case matchAny(c.DestructiveTools, name):
+		return false, true
+	case matchAny(c.ReadOnlyTools, name):
+		return true, false
+	default:
+		return readOnly, destructive
+	}
+}
+
+// matchAny reports whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeouts returns the parsed connect and call timeouts; zero means the
+// client-wide default
+func (c Config) Timeouts() (connect, call time.Duration) {
+	connect, _ = parseTimeout(c.Name, "connect_timeout", c.ConnectTimeout)
+	call, _ = parseTimeout(c.Name, "call_timeout", c.CallTimeout)
+	return connect, call
+}
+
+// parseTimeout parses an optional, non-negative duration field
+func parseTimeout(server, field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("server %s has invalid %s %q: expected a duration such as \"30s\"", server, field, value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("server %s has negative %s %q", server, field, value)
+	}
+	return d, nil
+}
+
+// validate checks that the fields required by the transport are set and
+// that fields of other transports are not
+func (c Config) validate(index int) error {
+	if c.Name == "" {
+		return fmt.Errorf("server at index %d has empty name", index)
+	}
+	if _, err := parseTimeout(c.Name, "connect_timeout", c.ConnectTimeout); err != nil {
+		return err
+	}
+	if _, err := parseTimeout(c.Name, "call_timeout", c.CallTimeout); err != nil {
+		return err
+	}
+	if err := c.Restart.validate(c.Name); err != nil {
+		return err
+	}
+	if c.LogFileMaxMB < 0 {
+		return fmt.Errorf("server %s has negative log_file_max_mb %d", c.Name, c.LogFileMaxMB)
+	}
+	if len(c.AllowedTools) > 0 && len(c.BlockedTools) > 0 {
+		return fmt.Errorf("server %s sets both allowed_tools and blocked_tools; use one of them", c.Name)
+	}
+	for _, tag := range c.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("server %s has an empty tag", c.Name)
+		}
+	}
+	patterns := append(append([]string(nil), c.AllowedTools...), c.BlockedTools...)
+	patterns = append(append(patterns, c.DestructiveTools...), c.ReadOnlyTools...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("server %s has invalid tool pattern %q: %w", c.Name, pattern, err)
+		}
+	}
+
+	switch c.TransportType() {
+	case TransportStdio:
+		if c.Command == "" {
+			return fmt.Errorf("server %s has empty command", c.Name)
+		}
+		if c.URL != "" {
+			return fmt.Errorf("server %s uses the stdio transport but sets url; set transport to http or sse", c.Name)
+		}
+		if len(c.Headers) > 0 {
+			return fmt.Errorf("server %s uses the stdio transport but sets headers", c.Name)
+		}
+	case TransportHTTP, TransportSSE:
+		if c.URL == "" {
+			return fmt.Errorf("server %s uses the %s transport but has no url", c.Name, c.Transport)
+		}
+		if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("server %s has invalid url %q", c.Name, c.URL)
+		}
+		if c.Command != "" || len(c.Args) > 0 {
+			return fmt.Errorf("server %s uses the %s transport but sets command or args", c.Name, c.Transport)
+		}
+		if c.WorkingDir != "" {
+			return fmt.Errorf("server %s uses the %s transport but sets working_dir", c.Name, c.Transport)
+		}
+		if c.LogFile != "" {
+			return fmt.Errorf("server %s uses the %s transport but sets log_file", c.Name, c.Transport)
+		}
+	case TransportWebSocket:
+		return fmt.Errorf("server %s: the websocket transport is not supported yet", c.Name)
+	default:
+		return fmt.Errorf("server %s has unknown transport %q (expected %s, %s or %s)",
+			c.Name, c.Transport, TransportStdio, TransportHTTP, TransportSSE)
+	}
+	return nil
 }
 
 // OllamaConfig represents the configuration for Ollama
 type OllamaConfig struct {
-	URL   string `json:"url" yaml:"url"`
-	Model string `json:"model" yaml:"model"`
+	URL   string `json:"url" yaml:"url,omitempty"`
+	Model string `json:"model" yaml:"model,omitempty"`
+
+	// Name of the environment variable holding a bearer token for the endpoint
+	AuthTokenEnv string `json:"auth_token_env,omitempty" yaml:"auth_token_env,omitempty"`
+
+	// Options are model options such as temperature or num_ctx sent with
+	// every request; values must be numbers, booleans or strings
+	Options map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+
+	// KeepAlive is how long the model stays loaded after a request, as a Go
+	// duration; negative keeps it loaded (empty: the server default)
+	KeepAlive string `json:"keep_alive,omitempty" yaml:"keep_alive,omitempty"`
+
+	// Timeout bounds each chat request, as a Go duration (empty: the caller's default)
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// KeepAliveDuration returns the parsed keep_alive, or nil when unset
+func (o OllamaConfig) KeepAliveDuration() *time.Duration {
+	if o.KeepAlive == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(o.KeepAlive)
+	if err != nil {
+		return nil
+	}
+	return &d
+}
+
+// RequestTimeout returns the parsed timeout, or fallback when unset
+func (o OllamaConfig) RequestTimeout(fallback time.Duration) time.Duration {
+	if o.Timeout == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(o.Timeout)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+// AuthToken returns the bearer token named by AuthTokenEnv, if any
+func (o OllamaConfig) AuthToken() string {
+	if o.AuthTokenEnv == "" {
+		return ""
+	}
+	return os.Getenv(o.AuthTokenEnv)
+}
+
+// validate applies the default URL and checks the settings; label names
+// the section in errors
+func (o *OllamaConfig) validate(label string) error {
+	if o.URL == "" {
+		o.URL = "http://localhost:11434"
+	}
+	if u, err := url.Parse(o.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s.url %q: expected an http or https URL", label, o.URL)
+	}
+	if strings.TrimSpace(o.Model) == "" {
+		return fmt.Errorf("%s.model must not be blank", label)
+	}
+	if o.KeepAlive != "" {
+		if _, err := time.ParseDuration(o.KeepAlive); err != nil {
+			return fmt.Errorf("invalid %s.keep_alive %q: expected a duration such as \"10m\"", label, o.KeepAlive)
+		}
+	}
+	if o.Timeout != "" {
+		if d, err := time.ParseDuration(o.Timeout); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s.timeout %q: expected a non-negative duration such as \"5m\"", label, o.Timeout)
+		}
+	}
+	for _, key := range sortedAnyKeys(o.Options) {
+		switch o.Options[key].(type) {
+		case int, int64, uint64, float64, bool, string:
+		default:
+			return fmt.Errorf("invalid %s.options.%s: expected a number, boolean or string, got %T", label, key, o.Options[key])
+		}
+	}
+	return nil
+}
+
+// sortedAnyKeys returns the keys of m in order
+func sortedAnyKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
+// OpenAIConfig represents the configuration for an OpenAI-compatible server
+type OpenAIConfig struct {
+	BaseURL string `json:"base_url" yaml:"base_url"`
+	Model   string `json:"model" yaml:"model"`
+
+	// Name of the environment variable holding the API key
+	APIKeyEnv string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+}
+
+// APIKey returns the key named by APIKeyEnv, if any
+func (o OpenAIConfig) APIKey() string {
+	if o.APIKeyEnv == "" {
+		return ""
+	}
+	return os.Getenv(o.APIKeyEnv)
+}
+
+// PromptsConfig overrides the text/template prompts used to phrase tool
+// results and notices fed back to the model. Empty fields keep the defaults.
+type PromptsConfig struct {
+	ToolResult      string `json:"tool_result,omitempty" yaml:"tool_result,omitempty"`
+	ToolError       string `json:"tool_error,omitempty" yaml:"tool_error,omitempty"`
+	Truncation      string `json:"truncation,omitempty" yaml:"truncation,omitempty"`
+	BudgetExhausted string `json:"budget_exhausted,omitempty" yaml:"budget_exhausted,omitempty"`
+	LoopWarning     string `json:"loop_warning,omitempty" yaml:"loop_warning,omitempty"`
+	IterationLimit  string `json:"iteration_limit,omitempty" yaml:"iteration_limit,omitempty"`
+}
+
+// validate checks that every prompt parses as a template
+func (p PromptsConfig) validate() error {
+	prompts := []struct{ name, text string }{
+		{"tool_result", p.ToolResult},
+		{"tool_error", p.ToolError},
+		{"truncation", p.Truncation},
+		{"budget_exhausted", p.BudgetExhausted},
+		{"loop_warning", p.LoopWarning},
+		{"iteration_limit", p.IterationLimit},
+	}
+	for _, prompt := range prompts {
+		if _, err := template.New(prompt.name).Parse(prompt.text); err != nil {
+			return fmt.Errorf("invalid prompts.%s: %w", prompt.name, err)
+		}
+	}
+	return nil
+}
+
+// Supported values for ConfigFile.Provider
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
+
 // ConfigFile represents the structure of the MCP configuration file
 type ConfigFile struct {
+	// Include lists config files merged in before this one, relative to it
+	Include []string `yaml:"include,omitempty"`
+
 	Servers []Config     `yaml:"servers"`
-	Ollama  OllamaConfig `yaml:"ollama"`
+	Ollama  OllamaConfig `yaml:"ollama,omitempty"`
+
+	// Defaults fills in settings that servers leave unset
+	Defaults ServerDefaults `yaml:"defaults,omitempty"`
+
+	// BuiltinServers lists the bundled servers to run in-process, such as
+	// BuiltinFilesystem. BuiltinRoot is the directory they work in,
+	// relative to the config file (empty: the current directory).
+	BuiltinServers []string `yaml:"builtin_servers,omitempty"`
+	BuiltinRoot    string   `yaml:"builtin_root,omitempty"`
+
+	// OllamaProfiles are named alternatives to the ollama section. When
+	// DefaultProfile is set, Ollama holds that profile after loading.
+	OllamaProfiles map[string]OllamaConfig `yaml:"ollama_profiles,omitempty"`
+	DefaultProfile string                  `yaml:"default_profile,omitempty"`
+
+	// Profiles are named setups selected with -profile or /profile.
+	// ActiveProfile names the one applied, if any.
+	Profiles      map[string]Profile `yaml:"profiles,omitempty"`
+	ActiveProfile string             `yaml:"-"`
+
+	// Provider selects the model backend (default: ProviderOllama)
+	Provider string       `yaml:"provider,omitempty"`
+	OpenAI   OpenAIConfig `yaml:"openai,omitempty"`
+
+	Prompts PromptsConfig `yaml:"prompts,omitempty"`
+	Agent   AgentConfig   `yaml:"agent,omitempty"`
+
+	// Serve configures the HTTP API of ttobot serve
+	Serve ServeConfig `yaml:"serve,omitempty"`
+
+	// SystemPrompt is appended to the generated system prompt, after the
+	// tool list. SystemPromptFile reads it from a file instead, relative to
+	// the config file. Environment variables are expanded in either.
+	SystemPrompt     string `yaml:"system_prompt,omitempty"`
+	SystemPromptFile string `yaml:"system_prompt_file,omitempty"`
+
+	// EnvFile loads variables from a .env file for every server, relative
+	// to the config file. Server env_file entries override it.
+	EnvFile EnvFile `yaml:"env_file,omitempty"`
+
+	// StrictEnv makes a reference to an undefined environment variable
+	// without a ${VAR:-default} fallback a load error instead of an empty string
+	StrictEnv bool `yaml:"strict_env,omitempty"`
+
+	// AllowExecSecrets permits !cmd: secret references, which run shell
+	// commands (default: true)
+	AllowExecSecrets *bool `yaml:"allow_exec_secrets,omitempty"`
+
+	// MissingWorkingDir decides whether a server working_dir that does not
+	// exist is reported as a warning or fails loading (default: MissingWorkingDirWarn)
+	MissingWorkingDir string `yaml:"missing_working_dir,omitempty"`
 }
 
-// LoadConfigFromFile loads MCP server configurations from a YAML file
-func LoadConfigFromFile(filePath string) ([]Config, error) {
-	// Read the YAML file
-	data, err := os.ReadFile(filePath)
+// resolveOllamaProfiles validates every profile and makes the default one
+// the effective ollama settings
+func (f *ConfigFile) resolveOllamaProfiles() error {
+	for _, name := range f.OllamaProfileNames() {
+		profile := f.OllamaProfiles[name]
+		if err := profile.validate("ollama_profiles." + name); err != nil {
+			return err
+		}
+		f.OllamaProfiles[name] = profile
+	}
+
+	if f.DefaultProfile == "" {
+		return nil
+	}
+	profile, err := f.OllamaProfile(f.DefaultProfile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %s: %w", filePath, err)
+		return fmt.Errorf("invalid default_profile: %w", err)
 	}
+	f.Ollama = profile
+	return nil
+}
 
-	// Parse the YAML
-	var configFile ConfigFile
-	if err := yaml.Unmarshal(data, &configFile); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+// OllamaProfileNames returns the names of the Ollama profiles in order
+func (f *ConfigFile) OllamaProfileNames() []string {
+	names := make([]string, 0, len(f.OllamaProfiles))
+	for name := range f.OllamaProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OllamaProfile returns the named Ollama profile, or the effective ollama
+// settings when name is empty
+func (f *ConfigFile) OllamaProfile(name string) (OllamaConfig, error) {
+	if name == "" {
+		return f.Ollama, nil
 	}
+	profile, ok := f.OllamaProfiles[name]
+	if !ok {
+		if len(f.OllamaProfiles) == 0 {
+			return OllamaConfig{}, fmt.Errorf("unknown Ollama profile %q: no ollama_profiles are configured", name)
+		}
+		return OllamaConfig{}, fmt.Errorf("unknown Ollama profile %q (available: %s)", name, strings.Join(f.OllamaProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// LoadOllamaProfile loads the configuration file and returns the named
+// Ollama profile, or the default settings when name is empty
+func LoadOllamaProfile(filePath, name string) (OllamaConfig, error) {
+	configFile, err := LoadConfigFile(filePath)
+	if err != nil {
+		return OllamaConfig{}, err
+	}
+	return configFile.OllamaProfile(name)
+}
+
+// SystemPromptWarnChars is the system_prompt length above which loading
+// logs a warning, since a long prompt crowds out the conversation
+const SystemPromptWarnChars = 8000
 
-	// Validate and process each server config
-	for i, config := range configFile.Servers {
-		if config.Name == "" {
-			return nil, fmt.Errorf("server at index %d has empty name", i)
+// resolveSystemPrompt reads system_prompt_file and expands environment
+// variables, leaving the final text in SystemPrompt
+func (f *ConfigFile) resolveSystemPrompt(baseDir string, globalEnv map[string]string) error {
+	lookup := fileEnvLookup(globalEnv)
+	if f.SystemPromptFile != "" {
+		if f.SystemPrompt != "" {
+			return fmt.Errorf("system_prompt and system_prompt_file are both set; use one of them")
 		}
-		if config.Command == "" {
-			return nil, fmt.Errorf("server %s has empty command", config.Name)
+		expanded, _ := expandEnv(f.SystemPromptFile, lookup)
+		path := expandHome(expanded)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
 		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read system_prompt_file: %w", err)
+		}
+		f.SystemPrompt = string(data)
 	}
 
-	return configFile.Servers, nil
+	prompt, _ := expandEnv(f.SystemPrompt, lookup)
+	f.SystemPrompt = strings.TrimSpace(prompt)
+	if len(f.SystemPrompt) > SystemPromptWarnChars {
+		slog.Warn("Config: system_prompt is long; it takes context away from the conversation", "chars", len(f.SystemPrompt), "over", SystemPromptWarnChars)
+	}
+	return nil
 }
 
-// LoadConfigWithOllamaFromFile loads both MCP server and Ollama configurations from a YAML file
-func LoadConfigWithOllamaFromFile(filePath string) ([]Config, OllamaConfig, error) {
-	// Read the YAML file
+// Supported values for ConfigFile.MissingWorkingDir
+const (
+	MissingWorkingDirWarn  = "warn"
+	MissingWorkingDirError = "error"
+)
+
+// resolveWorkingDir expands and absolutizes the working directory against
+// baseDir, then checks that it exists
+func (c *Config) resolveWorkingDir(baseDir, onMissing string) error {
+	if c.WorkingDir == "" {
+		return nil
+	}
+
+	dir := expandHome(c.expand(c.WorkingDir))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+	c.WorkingDir = filepath.Clean(dir)
+
+	info, err := os.Stat(c.WorkingDir)
+	switch {
+	case err == nil && info.IsDir():
+		return nil
+	case err == nil:
+		err = fmt.Errorf("server %s: working_dir %s is not a directory", c.Name, c.WorkingDir)
+	default:
+		err = fmt.Errorf("server %s: working_dir %s does not exist", c.Name, c.WorkingDir)
+	}
+
+	if onMissing == MissingWorkingDirError {
+		return err
+	}
+	slog.Warn("Config: Working directory missing", "error", err)
+	return nil
+}
+
+// resolveCommandPaths makes a command starting with ./ or ../ absolute
+// against dir, and args as well when ResolveArgs is set, so that starting
+// the server does not depend on the current directory
+func (c *Config) resolveCommandPaths(dir string) {
+	if c.TransportType() != TransportStdio {
+		return
+	}
+	if isDotRelative(c.Command) {
+		c.Command = filepath.Join(dir, c.Command)
+	}
+	if !c.ResolveArgs {
+		return
+	}
+	for i, arg := range c.Args {
+		if isDotRelative(arg) {
+			c.Args[i] = filepath.Join(dir, arg)
+		}
+	}
+}
+
+// isDotRelative reports whether path starts with ./ or ../
+func isDotRelative(path string) bool {
+	path = filepath.ToSlash(path)
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// EnabledServers returns the servers that are not disabled
+func (f *ConfigFile) EnabledServers() []Config {
+	var servers []Config
+	for _, config := range f.Servers {
+		if config.IsEnabled() {
+			servers = append(servers, config)
+		}
+	}
+	return servers
+}
+
+// DisabledServers returns the servers turned off with enabled: false
+func (f *ConfigFile) DisabledServers() []Config {
+	var servers []Config
+	for _, config := range f.Servers {
+		if !config.IsEnabled() {
+			servers = append(servers, config)
+		}
+	}
+	return servers
+}
+
+// LoadConfigFile loads the whole configuration file, including the model
+// provider settings, with defaults applied. JSON files are accepted too,
+// either in this schema or in the mcpServers layout of Claude Desktop and
+// Cursor, which only provides the servers. Files listed under include are
+// merged in first; see mergeConfig.
+func LoadConfigFile(filePath string) (*ConfigFile, error) {
+	configFile, err := loadConfigDocument(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := configFile.finalize(filepath.Dir(filePath)); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return configFile, nil
+}
+
+// readConfigDocument reads a config file as YAML in our schema
+func readConfigDocument(filePath string) ([]byte, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, OllamaConfig{}, fmt.Errorf("failed to read config file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to read config file %s: %w", filePath, err)
 	}
 
-	// Parse the YAML
+	if strings.EqualFold(filepath.Ext(filePath), ".json") && isClaudeConfig(data) {
+		servers, err := parseClaudeConfig(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", filePath, err)
+		}
+		// Re-encode in our schema so the usual defaults and validation apply
+		return MarshalServersYAML(servers)
+	}
+
+	// YAML is a superset of JSON, so JSON files in our schema parse as is
+	return data, nil
+}
+
+// decodeConfigDocument parses one configuration document without applying
+// defaults
+func decodeConfigDocument(data []byte) (*ConfigFile, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+	}
+	// Typos such as "comand:" would otherwise be ignored silently
+	if err := checkKnownKeys(&root, reflect.TypeOf(ConfigFile{}), ""); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	normalizeSecretTags(&root)
+
 	var configFile ConfigFile
-	if err := yaml.Unmarshal(data, &configFile); err != nil {
-		return nil, OllamaConfig{}, fmt.Errorf("failed to parse YAML config: %w", err)
+	if err := root.Decode(&configFile); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
+	return &configFile, nil
+}
 
-	// Validate and process each server config
-	for i, config := range configFile.Servers {
-		if config.Name == "" {
-			return nil, OllamaConfig{}, fmt.Errorf("server at index %d has empty name", i)
+// finalize validates a merged configuration and applies defaults. Paths
+// that are still relative are resolved against baseDir.
+func (f *ConfigFile) finalize(baseDir string) error {
+	switch f.MissingWorkingDir {
+	case "":
+		f.MissingWorkingDir = MissingWorkingDirWarn
+	case MissingWorkingDirWarn, MissingWorkingDirError:
+	default:
+		return fmt.Errorf("unknown missing_working_dir %q (expected %s or %s)",
+			f.MissingWorkingDir, MissingWorkingDirWarn, MissingWorkingDirError)
+	}
+
+	globalEnv, err := f.EnvFile.load(baseDir)
+	if err != nil {
+		return err
+	}
+
+	// Validate and process each server config with the defaults applied
+	f.Servers = f.ResolvedServers()
+	for i := range f.Servers {
+		config := &f.Servers[i]
+		if err := config.validate(i); err != nil {
+			return err
+		}
+		serverEnv, err := config.EnvFile.load(baseDir)
+		if err != nil {
+			return fmt.Errorf("server %s: %w", config.Name, err)
+		}
+		config.fileEnv = mergeEnv(globalEnv, serverEnv)
+		if f.AllowExecSecrets != nil && !*f.AllowExecSecrets {
+			config.denyExecSecrets = true
+			if field, ok := config.usesSecretCommand(); ok {
+				return fmt.Errorf("server %s: %s uses a %s secret, but allow_exec_secrets is false", config.Name, field, SecretCommandPrefix)
+			}
+		}
+		if f.StrictEnv {
+			if err := config.checkEnvironmentReferences(); err != nil {
+				return err
+			}
 		}
-		if config.Command == "" {
-			return nil, OllamaConfig{}, fmt.Errorf("server %s has empty command", config.Name)
+		if err := config.resolveWorkingDir(baseDir, f.MissingWorkingDir); err != nil {
+			return err
 		}
+		if config.WorkingDir != "" {
+			config.resolveCommandPaths(config.WorkingDir)
+		}
+		config.resolveLogFile(baseDir)
+	}
+	// A typo in a command would otherwise only show up when connecting
+	if err := f.checkCommands(); err != nil {
+		return err
+	}
+	if err := f.validateBuiltins(baseDir); err != nil {
+		return err
 	}
 
 	// Set default values for Ollama if not provided
-	if configFile.Ollama.URL == "" {
-		configFile.Ollama.URL = "http://localhost:11434"
+	if f.Ollama.Model == "" {
+		f.Ollama.Model = "llama3.2"
+	}
+	if err := f.Ollama.validate("ollama"); err != nil {
+		return err
+	}
+	if err := f.resolveOllamaProfiles(); err != nil {
+		return err
+	}
+
+	if err := f.resolveSystemPrompt(baseDir, globalEnv); err != nil {
+		return err
+	}
+	if err := f.Prompts.validate(); err != nil {
+		return err
 	}
-	if configFile.Ollama.Model == "" {
-		configFile.Ollama.Model = "llama3.2"
+	if err := f.Agent.validate(); err != nil {
+		return err
 	}
+	if err := f.validateProfiles(globalEnv); err != nil {
+		return err
+	}
+	if err := f.Serve.validate(); err != nil {
+		return err
+	}
+
+	switch f.Provider {
+	case "":
+		f.Provider = ProviderOllama
+	case ProviderOllama:
+	case ProviderOpenAI:
+		if f.OpenAI.Model == "" {
+			return fmt.Errorf("provider openai requires openai.model")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q (expected %s or %s)", f.Provider, ProviderOllama, ProviderOpenAI)
+	}
+
+	return nil
+}
 
+// LoadConfigFromFile loads MCP server configurations from a YAML file.
+// Disabled servers are included; check Config.IsEnabled to tell them apart.
+func LoadConfigFromFile(filePath string) ([]Config, error) {
+	configFile, err := LoadConfigFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return configFile.Servers, nil
+}
+
+// LoadConfigWithOllamaFromFile loads both MCP server and Ollama configurations from a YAML file
+func LoadConfigWithOllamaFromFile(filePath string) ([]Config, OllamaConfig, error) {
+	configFile, err := LoadConfigFile(filePath)
+	if err != nil {
+		return nil, OllamaConfig{}, err
+	}
 	return configFile.Servers, configFile.Ollama, nil
 }
 
-// LoadConfigFromDefaultPath loads configuration from default paths
-func LoadConfigFromDefaultPath() ([]Config, error) {
-	// Try common configuration paths
-	possiblePaths := []string{
-		"mcp.yaml",
-		"mcp.yml",
-		"config/mcp.yaml",
-		"config/mcp.yml",
+// DefaultPathOptions controls the search of LoadConfigFromDefaultPaths
+type DefaultPathOptions struct {
+	// IncludeExternal also searches the config files of Claude Desktop and
+	// Cursor after our own locations
+	IncludeExternal bool
+}
+
+// ConfigEnvVar names an environment variable holding the config file path,
+// searched before the default paths
+const ConfigEnvVar = "TTOBOT_CONFIG"
+
+// ErrNoConfigFile is returned when none of the default paths exists
+var ErrNoConfigFile = errors.New("no MCP configuration file found in default paths")
+
+// LoadConfigFromDefaultPath loads configuration from default paths and
+// returns the path it was loaded from
+func LoadConfigFromDefaultPath() ([]Config, string, error) {
+	return LoadConfigFromDefaultPaths(DefaultPathOptions{})
+}
+
+// LoadConfigFromDefaultPaths loads configuration from the first default path
+// that exists and returns that path
+func LoadConfigFromDefaultPaths(opts DefaultPathOptions) ([]Config, string, error) {
+	configFile, path, err := LoadConfigFileFromDefaultPaths(opts)
+	if err != nil {
+		return nil, path, err
 	}
+	return configFile.Servers, path, nil
+}
 
-	// Try user home directory
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		possiblePaths = append(possiblePaths,
-			filepath.Join(homeDir, ".mcp.yaml"),
-			filepath.Join(homeDir, ".mcp.yml"),
-			filepath.Join(homeDir, ".config", "mcp.yaml"),
-			filepath.Join(homeDir, ".config", "mcp.yml"),
-		)
+// LoadConfigFileFromDefaultPaths loads the complete configuration from the
+// first path of DefaultConfigPaths that exists and returns that path. A
+// path set in TTOBOT_CONFIG must exist. ErrNoConfigFile is returned when
+// no file is found.
+func LoadConfigFileFromDefaultPaths(opts DefaultPathOptions) (*ConfigFile, string, error) {
+	if path := os.Getenv(ConfigEnvVar); path != "" {
+		path = expandHome(path)
+		configFile, err := LoadConfigFile(path)
+		if err != nil {
+			return nil, path, fmt.Errorf("%s: %w", ConfigEnvVar, err)
+		}
+		return configFile, path, nil
 	}
 
-	for _, path := range possiblePaths {
+	for _, path := range DefaultConfigPaths(opts) {
 		if _, err := os.Stat(path); err == nil {
-			return LoadConfigFromFile(path)
+			configFile, err := LoadConfigFile(path)
+			return configFile, path, err
 		}
 	}
 
-	return nil, fmt.Errorf("no MCP configuration file found in default paths")
+	return nil, "", ErrNoConfigFile
 }
 
-// applyEnvironment applies environment variables to the configuration
-func (c *Config) applyEnvironment() {
-	if c.Environment == nil {
-		return
+// DefaultConfigPaths lists the searched config locations in order: the
+// current directory, $XDG_CONFIG_HOME/ttobot and ~/.config/ttobot, the
+// home directory, and with IncludeExternal the Claude Desktop and Cursor
+// config files
+func DefaultConfigPaths(opts DefaultPathOptions) []string {
+	names := []string{"mcp.yaml", "mcp.yml", "mcp.json"}
+	var paths []string
+	addDir := func(dir, prefix string) {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, prefix+name))
+		}
 	}
 
-	// Set environment variables for this server
-	for key, value := range c.Environment {
-		expandedValue := expandEnvironmentVariables(value)
-		os.Setenv(key, expandedValue)
+	addDir("", "")
+	addDir("config", "")
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		addDir(filepath.Join(xdg, "ttobot"), "")
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		addDir(filepath.Join(homeDir, ".config", "ttobot"), "")
+		addDir(homeDir, ".")
+		addDir(filepath.Join(homeDir, ".config"), "")
 	}
-}
 
-// CreateCommand creates an exec.Cmd with the configuration
-func (c *Config) CreateCommand(ctx context.Context) *exec.Cmd {
-	// Apply environment variables first
-	c.applyEnvironment()
+	if opts.IncludeExternal {
+		paths = append(paths, externalConfigPaths()...)
+	}
+	return paths
+}
 
+// CreateCommand creates an exec.Cmd with the configuration. The server's
+// environment section and env files are passed to the command only; the
+// process environment is never modified. Secret references in the
+// environment are resolved on every call. Stderr goes to the log_file, or
+// a *RingBuffer when none is set. On unix the command runs in its own
+// process group, which is killed as a whole when ctx ends.
+func (c *Config) CreateCommand(ctx context.Context) (*exec.Cmd, error) {
 	// Expand environment variables in command and args
-	expandedCommand := expandEnvironmentVariables(c.Command)
+	expandedCommand := c.expand(c.Command)
 	expandedArgs := make([]string, len(c.Args))
 	for i, arg := range c.Args {
-		expandedArgs[i] = expandEnvironmentVariables(arg)
+		expandedArgs[i] = c.expand(arg)
 	}
 
 	// Create the command
 	cmd := exec.CommandContext(ctx, expandedCommand, expandedArgs...)
+	cmd.Dir = c.WorkingDir
+	cmd.Stderr = c.stderrWriter()
+	isolateProcess(cmd)
 
-	// Set environment variables for the command
-	if c.Environment != nil {
+	// Set environment variables for the command; later entries win
+	if len(c.Environment) > 0 || len(c.fileEnv) > 0 {
 		env := os.Environ()
-		for key, value := range c.Environment {
-			expandedValue := expandEnvironmentVariables(value)
-			env = append(env, fmt.Sprintf("%s=%s", key, expandedValue))
+		for _, key := range sortedKeys(c.fileEnv) {
+			env = append(env, fmt.Sprintf("%s=%s", key, c.fileEnv[key]))
+		}
+		lookup := fileEnvLookup(c.fileEnv)
+		for _, key := range sortedKeys(c.Environment) {
+			value, err := c.resolveValue(ctx, "environment."+key, c.Environment[key], lookup)
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, fmt.Sprintf("%s=%s", key, value))
 		}
 		cmd.Env = env
 	}
 
-	return cmd
-}
-
-// expandEnvironmentVariables expands environment variables in the format ${VAR_NAME} or $VAR_NAME
-func expandEnvironmentVariables(value string) string {
-	// Handle ${VAR_NAME} format
-	re := regexp.MustCompile(`\$\{([^}]+)\}`)
-	expanded := re.ReplaceAllStringFunc(value, func(match string) string {
-		varName := match[2 : len(match)-1] // Remove ${ and }
-		return os.Getenv(varName)
-	})
-
-	// Handle $VAR_NAME format (simple cases)
-	re2 := regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
-	expanded = re2.ReplaceAllStringFunc(expanded, func(match string) string {
-		varName := match[1:] // Remove $
-		return os.Getenv(varName)
-	})
-
-	return expanded
+	return cmd, nil
 }
@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file named name into dir and returns its path
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadServers loads a config file listing the given servers
+func loadServers(t *testing.T, servers string) (*ConfigFile, error) {
+	t.Helper()
+	return LoadConfigFile(writeConfig(t, t.TempDir(), "config.yaml", "servers:\n"+servers))
+}
+
+func TestTransportValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers string
+		// wantErr is a part of the expected error (empty: valid)
+		wantErr string
+	}{
+		{
+			name: "stdio by default",
+			servers: `
+  - name: local
+    command: /bin/sh
+`,
+		},
+		{
+			name: "stdio without command",
+			servers: `
+  - name: local
+    transport: stdio
+`,
+			wantErr: "server local has empty command",
+		},
+		{
+			name: "stdio with url",
+			servers: `
+  - name: local
+    command: /bin/sh
+    url: http://localhost:3000/mcp
+`,
+			wantErr: "server local uses the stdio transport but sets url; set transport to http or sse",
+		},
+		{
+			name: "stdio with headers",
+			servers: `
+  - name: local
+    command: /bin/sh
+    headers:
+      Authorization: Bearer token
+`,
+			wantErr: "server local uses the stdio transport but sets headers",
+		},
+		{
+			name: "http",
+			servers: `
+  - name: remote
+    transport: http
+    url: https://mcp.example.com/mcp
+    headers:
+      Authorization: Bearer token
+`,
+		},
+		{
+			name: "http without url",
+			servers: `
+  - name: remote
+    transport: http
+`,
+			wantErr: "server remote uses the http transport but has no url",
+		},
+		{
+			name: "sse without url",
+			servers: `
+  - name: events
+    transport: sse
+`,
+			wantErr: "server events uses the sse transport but has no url",
+		},
+		{
+			name: "http with relative url",
+			servers: `
+  - name: remote
+    transport: http
+    url: /mcp
+`,
+			wantErr: `server remote has invalid url "/mcp"`,
+		},
+		{
+			name: "http with command",
+			servers: `
+  - name: remote
+    transport: http
+    url: https://mcp.example.com/mcp
+    command: /bin/sh
+`,
+			wantErr: "server remote uses the http transport but sets command or args",
+		},
+		{
+			name: "sse with args",
+			servers: `
+  - name: events
+    transport: sse
+    url: https://mcp.example.com/sse
+    args: [--verbose]
+`,
+			wantErr: "server events uses the sse transport but sets command or args",
+		},
+		{
+			name: "http with working_dir",
+			servers: `
+  - name: remote
+    transport: http
+    url: https://mcp.example.com/mcp
+    working_dir: /tmp
+`,
+			wantErr: "server remote uses the http transport but sets working_dir",
+		},
+		{
+			name: "sse with log_file",
+			servers: `
+  - name: events
+    transport: sse
+    url: https://mcp.example.com/sse
+    log_file: events.log
+`,
+			wantErr: "server events uses the sse transport but sets log_file",
+		},
+		{
+			name: "websocket",
+			servers: `
+  - name: socket
+    transport: websocket
+    url: wss://mcp.example.com/ws
+`,
+			wantErr: "server socket: the websocket transport is not supported yet",
+		},
+		{
+			name: "unknown transport",
+			servers: `
+  - name: odd
+    transport: grpc
+    url: https://mcp.example.com
+`,
+			wantErr: `server odd has unknown transport "grpc" (expected stdio, http or sse)`,
+		},
+		{
+			name: "disabled servers are still validated",
+			servers: `
+  - name: remote
+    transport: http
+    enabled: false
+`,
+			wantErr: "server remote uses the http transport but has no url",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadServers(t, tt.servers)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("loaded, want an error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("got %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMixedTransportsLoad(t *testing.T) {
+	t.Setenv("TEST_MCP_TOKEN", "s3cret")
+	configFile, err := loadServers(t, `
+  - name: local
+    command: /bin/sh
+    args: [-c, "exit 0"]
+  - name: remote
+    transport: http
+    url: https://mcp.example.com/mcp
+    headers:
+      Authorization: Bearer ${TEST_MCP_TOKEN}
+      X-Client: ttobot
+  - name: events
+    transport: sse
+    url: http://localhost:8931/sse
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var transports []string
+	for _, server := range configFile.Servers {
+		transports = append(transports, server.Name+"="+server.TransportType())
+	}
+	if want := []string{"local=stdio", "remote=http", "events=sse"}; !reflect.DeepEqual(transports, want) {
+		t.Fatalf("transports = %v, want %v", transports, want)
+	}
+
+	headers, err := configFile.Servers[1].ResolveHeaders(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Authorization": "Bearer s3cret", "X-Client": "ttobot"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %v, want %v", headers, want)
+	}
+	if headers, err := configFile.Servers[2].ResolveHeaders(context.Background()); err != nil || headers != nil {
+		t.Errorf("headers without any configured = %v, %v", headers, err)
+	}
+}
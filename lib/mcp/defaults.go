@@ -0,0 +1,61 @@
+package mcp
+
+// ServerDefaults holds settings applied to every server that does not set
+// them itself
+type ServerDefaults struct {
+	// Environment is merged key by key with each server's, the server winning
+	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	ConnectTimeout string `json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty"`
+	CallTimeout    string `json:"call_timeout,omitempty" yaml:"call_timeout,omitempty"`
+
+	// Restart is merged field by field with each server's
+	Restart RestartConfig `json:"restart,omitempty" yaml:"restart,omitempty"`
+
+	// WorkingDir, LogFile and LogFileMaxMB only apply to stdio servers
+	WorkingDir   string `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	LogFile      string `json:"log_file,omitempty" yaml:"log_file,omitempty"`
+	LogFileMaxMB int    `json:"log_file_max_mb,omitempty" yaml:"log_file_max_mb,omitempty"`
+}
+
+// apply returns config with the defaults filled in where it sets nothing
+func (d ServerDefaults) apply(config Config) Config {
+	if len(d.Environment) > 0 {
+		config.Environment = mergeEnv(d.Environment, config.Environment)
+	}
+	config.ConnectTimeout = orDefault(config.ConnectTimeout, d.ConnectTimeout)
+	config.CallTimeout = orDefault(config.CallTimeout, d.CallTimeout)
+
+	config.Restart.Policy = orDefault(config.Restart.Policy, d.Restart.Policy)
+	config.Restart.Backoff = orDefault(config.Restart.Backoff, d.Restart.Backoff)
+	if config.Restart.MaxRetries == 0 {
+		config.Restart.MaxRetries = d.Restart.MaxRetries
+	}
+
+	if config.TransportType() == TransportStdio {
+		config.WorkingDir = orDefault(config.WorkingDir, d.WorkingDir)
+		config.LogFile = orDefault(config.LogFile, d.LogFile)
+		if config.LogFileMaxMB == 0 {
+			config.LogFileMaxMB = d.LogFileMaxMB
+		}
+	}
+	return config
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// ResolvedServers returns the servers with the defaults section applied:
+// the effective settings that are validated and used to start them
+func (f *ConfigFile) ResolvedServers() []Config {
+	servers := make([]Config, len(f.Servers))
+	for i, config := range f.Servers {
+		servers[i] = f.Defaults.apply(config)
+	}
+	return servers
+}
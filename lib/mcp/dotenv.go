@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile names a .env file of KEY=value lines. In YAML it is written as a
+// path, or as a mapping with path and optional.
+type EnvFile struct {
+	Path string `yaml:"path"`
+
+	// Optional makes a missing file load as empty instead of failing
+	Optional bool `yaml:"optional,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain path as well as the mapping form
+func (e *EnvFile) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Path = node.Value
+		e.Optional = false
+		return nil
+	}
+	type plain EnvFile
+	return node.Decode((*plain)(e))
+}
+
+// MarshalYAML writes a required file as a plain path
+func (e EnvFile) MarshalYAML() (any, error) {
+	if !e.Optional {
+		return e.Path, nil
+	}
+	type plain EnvFile
+	return plain(e), nil
+}
+
+// IsZero reports whether no file is set, so omitempty drops it
+func (e EnvFile) IsZero() bool {
+	return e.Path == ""
+}
+
+// load reads the file, resolving a relative path against baseDir. A missing
+// optional file yields no variables.
+func (e EnvFile) load(baseDir string) (map[string]string, error) {
+	if e.Path == "" {
+		return nil, nil
+	}
+
+	path := expandHome(expandEnvironmentVariables(e.Path))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if e.Optional && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read env_file: %w", err)
+	}
+
+	vars, err := parseDotEnv(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse env_file %s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// parseDotEnv parses KEY=value lines. Blank lines and # comments are
+// skipped, an "export " prefix is allowed, single-quoted values are taken
+// literally, and double-quoted values support \n, \t, \" and \\ escapes.
+// Unquoted values end at " #".
+func parseDotEnv(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || !isNameStart(key[0]) || strings.IndexFunc(key, func(r rune) bool { return r > 127 || !isNameChar(byte(r)) }) >= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := unquoteDotEnv(value[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// unquoteDotEnv reads a double-quoted value up to its closing quote
+func unquoteDotEnv(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if i+1 == len(s) {
+				break
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", fmt.Errorf("unterminated double quote")
+}
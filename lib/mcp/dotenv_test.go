@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	data := `
+# Credentials for the test servers
+PLAIN=value
+export EXPORTED=yes
+SPACED = padded value
+EMPTY=
+INLINE=value # a comment
+HASH=a#b
+SINGLE='literal ${HOME} \n # kept'
+DOUBLE="line one\nline two\t\"quoted\" \\ # kept"
+EQUALS=a=b=c
+`
+	got, err := parseDotEnv([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"SPACED":   "padded value",
+		"EMPTY":    "",
+		"INLINE":   "value",
+		"HASH":     "a#b",
+		"SINGLE":   `literal ${HOME} \n # kept`,
+		"DOUBLE":   "line one\nline two\t\"quoted\" \\ # kept",
+		"EQUALS":   "a=b=c",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	for _, bad := range []string{"NO_EQUALS", "=value", "1ST=value", "BAD-KEY=value", "OPEN='value", `OPEN="value`} {
+		if _, err := parseDotEnv([]byte("OK=1\n" + bad + "\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%s: got %v, want an error on line 2", bad, err)
+		}
+	}
+}
+
+// envValue returns the value a process started with env sees for key: the
+// last one set
+func envValue(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(env[i], key+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func TestEnvFilePrecedence(t *testing.T) {
+	for _, key := range []string{"A", "B", "C", "D"} {
+		t.Setenv("TTOBOT_TEST_"+key, "process")
+	}
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "global.env", "TTOBOT_TEST_A=global\nTTOBOT_TEST_B=global\nTTOBOT_TEST_C=global\nTTOBOT_TEST_GLOBAL=global\n")
+	if err := os.Mkdir(filepath.Join(dir, "env"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, filepath.Join(dir, "env"), "server.env", "TTOBOT_TEST_B=server\nTTOBOT_TEST_C=server\nTTOBOT_TEST_SERVER=server\n")
+	path := writeConfig(t, dir, "config.yaml", `env_file: global.env
+servers:
+  - name: both
+    command: /bin/echo
+    args: ["${TTOBOT_TEST_A}", "${TTOBOT_TEST_B}", "${TTOBOT_TEST_C}", "${TTOBOT_TEST_D}", "${TTOBOT_TEST_GLOBAL}", "${TTOBOT_TEST_SERVER}"]
+    env_file: env/server.env
+    environment:
+      TTOBOT_TEST_C: section
+      TTOBOT_TEST_JOINED: "${TTOBOT_TEST_A}+${TTOBOT_TEST_B}"
+  - name: global_only
+    command: /bin/echo
+    args: ["${TTOBOT_TEST_A}", "${TTOBOT_TEST_B}", "${TTOBOT_TEST_C}", "${TTOBOT_TEST_D}", "${TTOBOT_TEST_GLOBAL}", "${TTOBOT_TEST_SERVER:-unset}"]
+`)
+
+	// Run from elsewhere: env files are relative to the config file
+	t.Chdir(t.TempDir())
+	configs, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		server string
+		// args are A, B, C, D, GLOBAL and SERVER after expansion
+		args []string
+		env  map[string]string
+	}{
+		{
+			server: "both",
+			args:   []string{"global", "server", "section", "process", "global", "server"},
+			env: map[string]string{
+				"TTOBOT_TEST_A":      "global",
+				"TTOBOT_TEST_B":      "server",
+				"TTOBOT_TEST_C":      "section",
+				"TTOBOT_TEST_D":      "process",
+				"TTOBOT_TEST_GLOBAL": "global",
+				"TTOBOT_TEST_SERVER": "server",
+				"TTOBOT_TEST_JOINED": "global+server",
+			},
+		},
+		{
+			server: "global_only",
+			args:   []string{"global", "global", "global", "process", "global", "unset"},
+			env: map[string]string{
+				"TTOBOT_TEST_A":      "global",
+				"TTOBOT_TEST_B":      "global",
+				"TTOBOT_TEST_C":      "global",
+				"TTOBOT_TEST_D":      "process",
+				"TTOBOT_TEST_GLOBAL": "global",
+				"TTOBOT_TEST_SERVER": "",
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			cmd, err := configs[i].CreateCommand(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cmd.Args[1:]; !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("args = %v, want %v", got, tt.args)
+			}
+			for key, want := range tt.env {
+				got, ok := envValue(cmd.Env, key)
+				if want == "" && ok {
+					t.Errorf("%s = %q, want it unset", key, got)
+				} else if want != "" && got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	// Loading never changes the process environment
+	for key, want := range map[string]string{"TTOBOT_TEST_A": "process", "TTOBOT_TEST_C": "process"} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("the process sees %s=%q, want %q", key, got, want)
+		}
+	}
+	for _, key := range []string{"TTOBOT_TEST_GLOBAL", "TTOBOT_TEST_SERVER", "TTOBOT_TEST_JOINED"} {
+		if value, ok := os.LookupEnv(key); ok {
+			t.Errorf("the process sees %s=%q, want it unset", key, value)
+		}
+	}
+}
+
+func TestEnvFileMissing(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "global",
+			config:  "env_file: missing.env\nservers:\n  - name: local\n    command: /bin/sh\n",
+			wantErr: "failed to read env_file",
+		},
+		{
+			name:    "server",
+			config:  "servers:\n  - name: local\n    command: /bin/sh\n    env_file: missing.env\n",
+			wantErr: "server local: failed to read env_file",
+		},
+		{
+			name:   "optional global",
+			config: "env_file:\n  path: missing.env\n  optional: true\nservers:\n  - name: local\n    command: /bin/sh\n",
+		},
+		{
+			name:   "optional server",
+			config: "servers:\n  - name: local\n    command: /bin/sh\n    env_file: {path: missing.env, optional: true}\n",
+		},
+		{
+			name:    "malformed",
+			config:  "env_file: bad.env\nservers:\n  - name: local\n    command: /bin/sh\n",
+			wantErr: "line 1: expected KEY=value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfig(t, dir, "bad.env", "not a variable\n")
+			_, err := LoadConfigFile(writeConfig(t, dir, "config.yaml", tt.config))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
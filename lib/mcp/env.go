@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// expandEnvironmentVariables expands environment variables in the format
+// ${VAR_NAME} or $VAR_NAME. ${VAR_NAME:-default} falls back to default when
+// the variable is unset or empty, and $$ is a literal $. Undefined
+// variables without a default expand to the empty string.
+func expandEnvironmentVariables(value string) string {
+	expanded, _ := expandEnv(value, os.LookupEnv)
+	return expanded
+}
+
+// expandEnv expands value using lookup and returns the names of referenced
+// variables that are undefined and have no default
+func expandEnv(value string, lookup func(string) (string, bool)) (string, []string) {
+	var sb strings.Builder
+	var missing []string
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		next := value[i+1]
+		switch {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := closingBrace(value, i+2)
+			if end < 0 {
+				// Unterminated reference: keep the rest as written
+				sb.WriteString(value[i:])
+				return sb.String(), missing
+			}
+			name, fallback, hasFallback := strings.Cut(value[i+2:end], ":-")
+			if v, ok := lookup(name); ok && (v != "" || !hasFallback) {
+				sb.WriteString(v)
+			} else if hasFallback {
+				// Defaults may reference other variables
+				expanded, innerMissing := expandEnv(fallback, lookup)
+				sb.WriteString(expanded)
+				missing = append(missing, innerMissing...)
+			} else {
+				missing = append(missing, name)
+			}
+			i = end
+		case isNameStart(next):
+			end := i + 2
+			for end < len(value) && isNameChar(value[end]) {
+				end++
+			}
+			name := value[i+1 : end]
+			if v, ok := lookup(name); ok {
+				sb.WriteString(v)
+			} else {
+				missing = append(missing, name)
+			}
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+		}
+	}
+	return sb.String(), missing
+}
+
+// closingBrace returns the index of the } closing a ${ whose body starts at
+// start, skipping nested ${...} references, or -1
+func closingBrace(value string, start int) int {
+	depth := 0
+	for i := start; i < len(value); i++ {
+		switch {
+		case value[i] == '$' && i+1 < len(value) && value[i+1] == '{':
+			depth++
+			i++
+		case value[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// isNameStart reports whether b may start a variable name
+func isNameStart(b byte) bool {
+	return b == '_' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// isNameChar reports whether b may continue a variable name
+func isNameChar(b byte) bool {
+	return isNameStart(b) || (b >= '0' && b <= '9')
+}
+
+// fileEnvLookup looks variables up in vars, then in the process environment
+func fileEnvLookup(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+}
+
+// envLookup returns the lookup used to expand the server's fields: its
+// environment section, then its env files, then the process environment.
+// Secret references are skipped; they are only resolved for the value itself.
+func (c Config) envLookup() func(string) (string, bool) {
+	base := fileEnvLookup(c.fileEnv)
+	return func(name string) (string, bool) {
+		if v, ok := c.Environment[name]; ok && !isSecretRef(v) {
+			expanded, _ := expandEnv(v, base)
+			return expanded, true
+		}
+		return base(name)
+	}
+}
+
+// expand expands environment variables in a field of the server
+func (c Config) expand(value string) string {
+	expanded, _ := expandEnv(value, c.envLookup())
+	return expanded
+}
+
+// mergeEnv combines variable sets, later ones overriding earlier ones
+func mergeEnv(sets ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, vars := range sets {
+		for key, value := range vars {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// checkEnvironmentReferences reports the first variable referenced by the
+// server's expanded fields that is undefined and has no default. Variables
+// set in the server's environment section or env files count as defined.
+func (c Config) checkEnvironmentReferences() error {
+	lookup := fileEnvLookup(mergeEnv(c.fileEnv, c.Environment))
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"command", c.Command},
+		{"url", c.URL},
+		{"working_dir", c.WorkingDir},
+	}
+	for i, arg := range c.Args {
+		fields = append(fields, struct{ name, value string }{fmt.Sprintf("args[%d]", i), arg})
+	}
+	for _, key := range sortedKeys(c.Environment) {
+		fields = append(fields, struct{ name, value string }{"environment." + key, c.Environment[key]})
+	}
+	for _, key := range sortedKeys(c.Headers) {
+		fields = append(fields, struct{ name, value string }{"headers." + key, c.Headers[key]})
+	}
+
+	for _, field := range fields {
+		if _, missing := expandEnv(field.value, lookup); len(missing) > 0 {
+			return fmt.Errorf("server %s references undefined environment variable %s in %s", c.Name, missing[0], field.name)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{
+		"FOO":   "foo",
+		"BAR":   "bar",
+		"B":     "b",
+		"EMPTY": "",
+		"PRICE": "$5",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		missing []string
+	}{
+		{name: "plain", value: "no variables", want: "no variables"},
+		{name: "bare", value: "$FOO", want: "foo"},
+		{name: "braced", value: "${FOO}", want: "foo"},
+		{name: "embedded", value: "a-${FOO}-b", want: "a-foo-b"},
+		{name: "adjacent", value: "$FOO$BAR", want: "foobar"},
+		{name: "adjacent braced", value: "${FOO}${BAR}", want: "foobar"},
+		{name: "name ends at punctuation", value: "$FOO.$BAR/x", want: "foo.bar/x"},
+		{name: "name with digits", value: "$FOO1", want: "", missing: []string{"FOO1"}},
+		{name: "dollar dollar", value: "$$", want: "$"},
+		{name: "dollar dollar before name", value: "$$FOO", want: "$FOO"},
+		{name: "dollar dollar braced", value: "$${FOO}", want: "${FOO}"},
+		{name: "trailing dollar", value: "cost$", want: "cost$"},
+		{name: "dollar before digit", value: "$1", want: "$1"},
+		{name: "values are not expanded again", value: "$PRICE", want: "$5"},
+		{name: "default unused", value: "${FOO:-x}", want: "foo"},
+		{name: "default for unset", value: "${NOPE:-x}", want: "x"},
+		{name: "empty default", value: "${NOPE:-}", want: ""},
+		{name: "nested default", value: "${NOPE:-${B}}", want: "b"},
+		{name: "nested default unused", value: "${FOO:-${B}}", want: "foo"},
+		{name: "doubly nested default", value: "${NOPE:-${ALSO:-${B}}}", want: "b"},
+		{name: "nested default text", value: "${NOPE:-x${B}y}z", want: "xbyz"},
+		{name: "nested default missing", value: "${NOPE:-${ALSO}}", want: "", missing: []string{"ALSO"}},
+		{name: "empty without default", value: "[${EMPTY}]", want: "[]"},
+		{name: "empty bare", value: "[$EMPTY]", want: "[]"},
+		{name: "empty with default", value: "${EMPTY:-x}", want: "x"},
+		{name: "empty with nested default", value: "${EMPTY:-${B}}", want: "b"},
+		{name: "unset", value: "[$NOPE]", want: "[]", missing: []string{"NOPE"}},
+		{name: "unset braced", value: "${NOPE}", want: "", missing: []string{"NOPE"}},
+		{name: "several unset", value: "$NOPE ${ALSO}", want: " ", missing: []string{"NOPE", "ALSO"}},
+		{name: "unterminated", value: "x ${FOO", want: "x ${FOO"},
+		{name: "unterminated after reference", value: "$FOO ${BAR", want: "foo ${BAR"},
+		{name: "unterminated nested", value: "${NOPE:-${B}", want: "${NOPE:-${B}"},
+		{name: "lone brace", value: "${", want: "${"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := expandEnv(tt.value, lookup)
+			if got != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("expandEnv(%q) missing = %q, want %q", tt.value, missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestExpandEnvironmentVariables(t *testing.T) {
+	t.Setenv("TTOBOT_TEST_SET", "set")
+	t.Setenv("TTOBOT_TEST_EMPTY", "")
+
+	tests := map[string]string{
+		"${TTOBOT_TEST_SET}":         "set",
+		"${TTOBOT_TEST_EMPTY}":       "",
+		"${TTOBOT_TEST_EMPTY:-dflt}": "dflt",
+		"${TTOBOT_TEST_UNSET:-dflt}": "dflt",
+		"$TTOBOT_TEST_UNSET":         "",
+	}
+	for value, want := range tests {
+		if got := expandEnvironmentVariables(value); got != want {
+			t.Errorf("expandEnvironmentVariables(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// MaxIncludeDepth bounds how deeply config files may include each other
+const MaxIncludeDepth = 8
+
+// loadConfigDocument reads a config file and the files it includes, merged
+// in order. stack holds the absolute paths of the including files.
+func loadConfigDocument(filePath string, stack []string) (*ConfigFile, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path %s: %w", filePath, err)
+	}
+	for _, including := range stack {
+		if including == absPath {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), absPath)
+		}
+	}
+	if len(stack) > MaxIncludeDepth {
+		return nil, fmt.Errorf("%s: includes are nested more than %d levels deep", filePath, MaxIncludeDepth)
+	}
+
+	data, err := readConfigDocument(filePath)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeConfigDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	for i, config := range doc.Servers {
+		if err := config.validate(i); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+
+	// Paths in a document are relative to the file they are written in
+	dir := filepath.Dir(absPath)
+	doc.resolveRelativePaths(dir)
+
+	merged := &ConfigFile{}
+	for _, include := range doc.Include {
+		includePath := expandHome(expandEnvironmentVariables(include))
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(dir, includePath)
+		}
+		included, err := loadConfigDocument(includePath, append(stack, absPath))
+		if err != nil {
+			if len(stack) == 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("included from %s: %w", filePath, err)
+		}
+		mergeConfig(merged, included)
+	}
+	mergeConfig(merged, doc)
+	merged.Include = doc.Include
+	return merged, nil
+}
+
+// resolveRelativePaths makes plain relative paths absolute against dir.
+// Paths starting with ~ or an environment variable are left for finalize.
+func (f *ConfigFile) resolveRelativePaths(dir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") || strings.HasPrefix(path, "$") {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	for i := range f.Servers {
+		config := &f.Servers[i]
+		// Without a working_dir, relative commands run from the config
+		// file's directory; otherwise finalize resolves them against it
+		if config.WorkingDir == "" {
+			config.resolveCommandPaths(dir)
+		}
+		config.WorkingDir = resolve(config.WorkingDir)
+		if config.LogFile != LogFileInherit {
+			config.LogFile = resolve(config.LogFile)
+		}
+		config.EnvFile.Path = resolve(config.EnvFile.Path)
+		for key, value := range config.Environment {
+			config.Environment[key] = resolveSecretFile(value, dir)
+		}
+		for key, value := range config.Headers {
+			config.Headers[key] = resolveSecretFile(value, dir)
+		}
+	}
+	f.Defaults.WorkingDir = resolve(f.Defaults.WorkingDir)
+	if f.Defaults.LogFile != LogFileInherit {
+		f.Defaults.LogFile = resolve(f.Defaults.LogFile)
+	}
+	f.SystemPromptFile = resolve(f.SystemPromptFile)
+	f.EnvFile.Path = resolve(f.EnvFile.Path)
+}
+
+// mergeConfig merges overlay into base. Servers are concatenated, and a
+// server with the name of an earlier one replaces it in place. Other
+// settings set in overlay override base field by field; maps are merged
+// by key.
+func mergeConfig(base, overlay *ConfigFile) {
+	for _, config := range overlay.Servers {
+		replaced := false
+		for i := range base.Servers {
+			if base.Servers[i].Name == config.Name {
+				base.Servers[i] = config
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			base.Servers = append(base.Servers, config)
+		}
+	}
+
+	dst := reflect.ValueOf(base).Elem()
+	src := reflect.ValueOf(overlay).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		switch dst.Type().Field(i).Name {
+		case "Servers", "Include":
+			continue
+		}
+		mergeValue(dst.Field(i), src.Field(i))
+	}
+}
+
+// mergeValue overrides dst with the non-zero parts of src
+func mergeValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+		}
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// DefaultConfigOpts customizes the config written by WriteDefaultConfig
+type DefaultConfigOpts struct {
+	// OllamaURL is the Ollama endpoint (default: $OLLAMA_HOST, or the local default)
+	OllamaURL string
+
+	// Model is the Ollama model (default: qwen3:14b)
+	Model string
+
+	// FromSource runs the filesystem and godoc servers from a source
+	// checkout with go run (default: in-process, with builtin_servers)
+	FromSource bool
+
+	// Force overwrites an existing file
+	Force bool
+}
+
+// defaultConfigTemplate is the commented starter config
+var defaultConfigTemplate = template.Must(template.New("config").Parse(`# ttobot configuration
+# See the readme for every available setting.
+
+# Model backend: "ollama" (default) or "openai" for OpenAI-compatible servers
+provider: ollama
+
+ollama:
+  url: {{printf "%q" .OllamaURL}}
+  model: {{printf "%q" .Model}}
+  # options:
+  #   temperature: 0.2
+  #   num_ctx: 16384
+  # keep_alive: "30m"
+  # timeout: "5m"
+
+{{if .FromSource -}}
+# The bundled servers run from this source checkout with go run below.
+# Installed ttobot binaries can run them in-process instead, registering
+# their tools as fs:read_file and go:go_test:
+# builtin_servers: [filesystem, godoc]
+{{- else -}}
+# Bundled MCP servers run inside ttobot; their tools appear as fs:read_file
+# and go:go_test
+builtin_servers: [filesystem, godoc]
+# Directory they work in, relative to this file (default: the current directory)
+# builtin_root: "."
+{{- end}}
+
+# MCP servers providing the tools the model can call
+servers:
+{{- if .FromSource}}
+  - name: "filesystem"
+    command: "go"
+    args: ["run", "./cmd/filesystem/."]
+    # blocked_tools: ["remove*"]
+
+  - name: "godoc"
+    command: "go"
+    args: ["run", "./cmd/godoc/."]
+{{end}}
+  # A remote MCP server; set enabled to true and fill in the url to use it
+  - name: "remote-example"
+    transport: http
+    url: "https://mcp.example.com/mcp"
+    headers:
+      Authorization: "Bearer ${EXAMPLE_TOKEN:-}"
+    enabled: false
+
+# Extra instructions appended to the generated system prompt
+# system_prompt: |
+#   Answer concisely.
+
+# agent:
+#   max_iterations: 10
+`))
+
+// detectOllamaURL returns the Ollama endpoint from OLLAMA_HOST, or the default
+func detectOllamaURL() string {
+	host := strings.TrimSpace(os.Getenv("OLLAMA_HOST"))
+	switch {
+	case host == "":
+		return "http://localhost:11434"
+	case strings.HasPrefix(host, "http://"), strings.HasPrefix(host, "https://"):
+		return strings.TrimSuffix(host, "/")
+	case strings.HasPrefix(host, ":"):
+		return "http://localhost" + host
+	case !strings.Contains(host, ":"):
+		return "http://" + host + ":11434"
+	default:
+		return "http://" + host
+	}
+}
+
+// WriteDefaultConfig writes a commented starter configuration to path. It
+// refuses to replace an existing file unless opts.Force is set.
+func WriteDefaultConfig(path string, opts DefaultConfigOpts) error {
+	data := struct {
+		OllamaURL  string
+		Model      string
+		FromSource bool
+	}{
+		OllamaURL:  opts.OllamaURL,
+		Model:      opts.Model,
+		FromSource: opts.FromSource,
+	}
+	if data.OllamaURL == "" {
+		data.OllamaURL = detectOllamaURL()
+	}
+	if data.Model == "" {
+		data.Model = "qwen3:14b"
+	}
+
+	var sb strings.Builder
+	if err := defaultConfigTemplate.Execute(&sb, data); err != nil {
+		return fmt.Errorf("failed to render default config: %w", err)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.Force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("config file %s already exists; use force to overwrite it", path)
+		}
+		return fmt.Errorf("failed to create config file %s: %w", path, err)
+	}
+	if _, err := file.WriteString(sb.String()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteDefaultConfigLoads(t *testing.T) {
+	tests := []struct {
+		name string
+		opts DefaultConfigOpts
+		// builtins are the builtin servers run in-process
+		builtins []string
+		// commands are the command lines of the configured servers before
+		// the remote example
+		commands [][]string
+		url      string
+		model    string
+	}{
+		{
+			name:     "defaults",
+			builtins: []string{BuiltinFilesystem, BuiltinGodoc},
+			url:      "http://localhost:11434",
+			model:    "qwen3:14b",
+		},
+		{
+			name:     "source checkout",
+			opts:     DefaultConfigOpts{FromSource: true, OllamaURL: "https://ollama.example.com:8443", Model: "llama3.2:3b"},
+			commands: [][]string{{"go", "run", "./cmd/filesystem/."}, {"go", "run", "./cmd/godoc/."}},
+			url:      "https://ollama.example.com:8443",
+			model:    "llama3.2:3b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OLLAMA_HOST", "")
+			path := filepath.Join(t.TempDir(), "mcp.yaml")
+			if err := WriteDefaultConfig(path, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := LoadConfigFile(path)
+			if err != nil {
+				data, _ := os.ReadFile(path)
+				t.Fatalf("the generated config does not load: %v\n%s", err, data)
+			}
+			if file.Ollama.URL != tt.url || file.Ollama.Model != tt.model {
+				t.Errorf("ollama = %s %s, want %s %s", file.Ollama.URL, file.Ollama.Model, tt.url, tt.model)
+			}
+
+			if !reflect.DeepEqual(file.BuiltinServers, tt.builtins) {
+				t.Errorf("builtin servers = %q, want %q", file.BuiltinServers, tt.builtins)
+			}
+			var prefixes []string
+			for _, name := range file.BuiltinServers {
+				prefix, ok := BuiltinPrefix(name)
+				if !ok {
+					t.Errorf("builtin server %s does not resolve", name)
+				}
+				prefixes = append(prefixes, prefix)
+			}
+			if len(tt.builtins) > 0 && !reflect.DeepEqual(prefixes, []string{"fs", "go"}) {
+				t.Errorf("builtin servers are registered as %q, want fs and go", prefixes)
+			}
+			// Without builtin_root they work in the current directory
+			if file.BuiltinRoot != "" {
+				t.Errorf("builtin root = %q, want none", file.BuiltinRoot)
+			}
+
+			servers := file.Servers
+			if len(servers) != len(tt.commands)+1 {
+				t.Fatalf("got %d servers, want %d", len(servers), len(tt.commands)+1)
+			}
+			for i, name := range []string{"filesystem", "godoc"}[:len(tt.commands)] {
+				server := servers[i]
+				if server.Name != name || !server.IsEnabled() || server.TransportType() != TransportStdio {
+					t.Errorf("server %d = %s (enabled %v, %s), want an enabled stdio %s", i, server.Name, server.IsEnabled(), server.TransportType(), name)
+				}
+				if got := append([]string{server.Command}, server.Args...); !reflect.DeepEqual(got, tt.commands[i]) {
+					t.Errorf("%s command = %q, want %q", name, got, tt.commands[i])
+				}
+			}
+			remote := servers[len(servers)-1]
+			if remote.Name != "remote-example" || remote.IsEnabled() || remote.TransportType() != TransportHTTP || remote.URL == "" {
+				t.Errorf("remote server = %+v, want a disabled http example", remote)
+			}
+			if got := file.EnabledServers(); len(got) != len(tt.commands) {
+				t.Errorf("got %d enabled servers, want %d", len(got), len(tt.commands))
+			}
+		})
+	}
+}
+
+func TestWriteDefaultConfigRefusesOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.yaml")
+	if err := os.WriteFile(path, []byte("servers: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WriteDefaultConfig(path, DefaultConfigOpts{})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("got %v, want an error saying the file exists", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "servers: []\n" {
+		t.Fatalf("the existing file was changed:\n%s", data)
+	}
+
+	if err := WriteDefaultConfig(path, DefaultConfigOpts{Force: true, Model: "forced"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `model: "forced"`) {
+		t.Fatalf("force did not overwrite the file:\n%s", data)
+	}
+}
+
+func TestDetectOllamaURL(t *testing.T) {
+	tests := map[string]string{
+		"":                         "http://localhost:11434",
+		"  ":                       "http://localhost:11434",
+		"0.0.0.0":                  "http://0.0.0.0:11434",
+		":8080":                    "http://localhost:8080",
+		"gpu-box:11434":            "http://gpu-box:11434",
+		"https://ollama.example/":  "https://ollama.example",
+		"http://192.168.1.5:11434": "http://192.168.1.5:11434",
+	}
+	for host, want := range tests {
+		t.Setenv("OLLAMA_HOST", host)
+		if got := detectOllamaURL(); got != want {
+			t.Errorf("OLLAMA_HOST=%q: got %s, want %s", host, got, want)
+		}
+	}
+}
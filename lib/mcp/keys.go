@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkKnownKeys reports the first mapping key in node that has no matching
+// yaml field in t, with its line and the closest valid key
+func checkKnownKeys(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := checkKnownKeys(child, t, path); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+		for i, child := range node.Content {
+			if err := checkKnownKeys(child, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if err := checkKnownKeys(node.Content[i+1], t.Elem(), joinKeyPath(path, key)); err != nil {
+					return err
+				}
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyNode := node.Content[i]
+				field, ok := fields[keyNode.Value]
+				if !ok {
+					return unknownKeyError(keyNode, path, fields)
+				}
+				if err := checkKnownKeys(node.Content[i+1], field, joinKeyPath(path, keyNode.Value)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// yamlFields maps the yaml keys of a struct to their field types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			// yaml.v3 lowercases untagged field names
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownKeyError describes an unknown key, suggesting the closest valid one
+func unknownKeyError(key *yaml.Node, path string, fields map[string]reflect.Type) error {
+	location := "at the top level"
+	if path != "" {
+		location = "in " + path
+	}
+
+	message := fmt.Sprintf("line %d: unknown key %q %s", key.Line, key.Value, location)
+	if suggestion := closestKey(key.Value, fields); suggestion != "" {
+		message += fmt.Sprintf("; did you mean %q?", suggestion)
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// closestKey returns the valid key nearest to key by edit distance, or ""
+// when none is close enough to be a likely typo
+func closestKey(key string, fields map[string]reflect.Type) string {
+	threshold := max(2, len(key)/3)
+	best, bestDistance := "", threshold+1
+	for name := range fields {
+		distance := editDistance(strings.ToLower(key), name)
+		// Break ties by name so the suggestion is deterministic
+		if distance < bestDistance || (distance == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// joinKeyPath appends a key to a dotted path
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
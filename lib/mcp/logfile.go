@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogFileInherit as log_file passes a server's stderr through to ttobot's own
+const LogFileInherit = "inherit"
+
+// Server stderr defaults
+const (
+	// DefaultLogFileMaxMB is the size at which a log file is rolled over
+	DefaultLogFileMaxMB = 10
+
+	// StderrBufferSize is how much of a server's stderr is kept in memory
+	// when no log_file is set
+	StderrBufferSize = 64 * 1024
+)
+
+// stderrWriter returns where the server's stderr goes: its log file, ttobot's
+// stderr, or a new in-memory ring buffer
+func (c Config) stderrWriter() io.Writer {
+	switch c.LogFile {
+	case "":
+		return NewRingBuffer(StderrBufferSize)
+	case LogFileInherit:
+		return os.Stderr
+	}
+	maxMB := c.LogFileMaxMB
+	if maxMB == 0 {
+		maxMB = DefaultLogFileMaxMB
+	}
+	return &rotatingFile{path: c.LogFile, maxSize: int64(maxMB) << 20}
+}
+
+// resolveLogFile expands and absolutizes the log file path against baseDir
+func (c *Config) resolveLogFile(baseDir string) {
+	if c.LogFile == "" || c.LogFile == LogFileInherit {
+		return
+	}
+	path := expandHome(c.expand(c.LogFile))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	c.LogFile = filepath.Clean(path)
+}
+
+// rotatingFile appends to a file, moving it to path.1 once it would grow
+// past maxSize. The file is opened for each write, so nothing has to close
+// it when the server exits.
+//
+// Writes never fail: os/exec stops copying a server's stderr at the first
+// error, and a server blocked on a full stderr pipe hangs. Output that
+// cannot be written is dropped, and the first failure is logged.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	mu      sync.Mutex
+	warned  bool
+}
+
+// Write implements io.Writer
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.write(p); err != nil && !r.warned {
+		r.warned = true
+		slog.Warn("MCP: Dropping server output that cannot be logged", "log_file", r.path, "error", err)
+	}
+	return len(p), nil
+}
+
+// write appends p to the file, rotating it first if needed
+func (r *rotatingFile) write(p []byte) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if info, err := os.Stat(r.path); err == nil && info.Size() > 0 && info.Size()+int64(len(p)) > r.maxSize {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	_, err = file.Write(p)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// RingBuffer is an io.Writer that keeps only the last bytes written to it
+type RingBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+// NewRingBuffer creates a buffer holding at most size bytes
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{size: size}
+}
+
+// Write implements io.Writer, dropping the oldest bytes when full
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = append(r.buf, p...)
+	if over := len(r.buf) - r.size; over > 0 {
+		r.buf = append(r.buf[:0], r.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the buffered output
+func (r *RingBuffer) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.buf)
+}
+
+// Tail returns the last n non-empty lines of the buffered output
+func (r *RingBuffer) Tail(n int) string {
+	lines := strings.Split(strings.TrimRight(r.String(), "\r\n"), "\n")
+	var tail []string
+	for i := len(lines) - 1; i >= 0 && len(tail) < n; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			tail = append([]string{line}, tail...)
+		}
+	}
+	return strings.Join(tail, "\n")
+}
@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	w := &rotatingFile{path: path, maxSize: 10}
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if n, err := w.Write([]byte(line)); n != len(line) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", line, n, err)
+		}
+	}
+
+	for name, want := range map[string]string{path: "third\n", path + ".1": "second\n"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), data, want)
+		}
+	}
+}
+
+func TestRotatingFileUnwritable(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	// The log directory cannot be created below a file
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := &rotatingFile{path: filepath.Join(blocker, "server.log"), maxSize: 1 << 20}
+
+	for range 3 {
+		if n, err := w.Write([]byte("lost\n")); n != 5 || err != nil {
+			t.Fatalf("Write = %d, %v; want the output dropped without an error", n, err)
+		}
+	}
+	if got := strings.Count(logs.String(), "Dropping server output"); got != 1 {
+		t.Errorf("the failure was logged %d times, want once:\n%s", got, logs.String())
+	}
+
+	// A server writing more than a pipe holds to stderr must not block
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to run a server with")
+	}
+	cmd := exec.Command(sh, "-c", "head -c 1048576 /dev/zero >&2")
+	cmd.Stderr = w
+	done := make(chan error, 1)
+	go func() { done <- cmd.Run() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("the server blocked writing to stderr")
+	}
+}
@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Environment variables overriding the config file; command-line flags
+// override them in turn
+const (
+	ModelEnvVar        = "TTOBOT_MODEL"
+	OllamaURLEnvVar    = "TTOBOT_OLLAMA_URL"
+	ProfileEnvVar      = "TTOBOT_PROFILE"
+	SystemPromptEnvVar = "TTOBOT_SYSTEM_PROMPT"
+	BuiltinEnvVar      = "TTOBOT_BUILTIN"
+)
+
+// Overrides replace settings of a loaded config file; empty fields leave
+// the file's settings alone
+type Overrides struct {
+	// Profile selects one of the profiles, or else one of the
+	// ollama_profiles
+	Profile string
+
+	// Model replaces the model of the selected provider
+	Model string
+
+	// OllamaURL replaces the URL of the Ollama server
+	OllamaURL string
+
+	// SystemPrompt replaces system_prompt, the text appended to the
+	// generated system prompt
+	SystemPrompt string
+
+	// MaxIterations replaces agent.max_iterations (zero: no override)
+	MaxIterations int
+
+	// Builtin adds builtin servers to builtin_servers, separated by commas
+	Builtin string
+}
+
+// OverridesFromEnv reads the TTOBOT_* override variables
+func OverridesFromEnv() Overrides {
+	return Overrides{
+		Profile:      os.Getenv(ProfileEnvVar),
+		Model:        os.Getenv(ModelEnvVar),
+		OllamaURL:    os.Getenv(OllamaURLEnvVar),
+		SystemPrompt: os.Getenv(SystemPromptEnvVar),
+		Builtin:      os.Getenv(BuiltinEnvVar),
+	}
+}
+
+// Merge returns o with the fields set in over replacing its own
+func (o Overrides) Merge(over Overrides) Overrides {
+	pick := func(base, override string) string {
+		if override != "" {
+			return override
+		}
+		return base
+	}
+	merged := Overrides{
+		Profile:       pick(o.Profile, over.Profile),
+		Model:         pick(o.Model, over.Model),
+		OllamaURL:     pick(o.OllamaURL, over.OllamaURL),
+		SystemPrompt:  pick(o.SystemPrompt, over.SystemPrompt),
+		Builtin:       pick(o.Builtin, over.Builtin),
+		MaxIterations: o.MaxIterations,
+	}
+	if over.MaxIterations != 0 {
+		merged.MaxIterations = over.MaxIterations
+	}
+	return merged
+}
+
+// ApplyOverrides applies o to a loaded config file: the profile first, then
+// the model and URL on top of it
+func (f *ConfigFile) ApplyOverrides(o Overrides) error {
+	_, named := f.Profiles[o.Profile]
+	_, ollama := f.OllamaProfiles[o.Profile]
+	switch {
+	case o.Profile == "":
+	case named:
+		if err := f.applyProfile(o.Profile); err != nil {
+			return err
+		}
+	case !ollama && len(f.Profiles) > 0:
+		names := append(f.NamedProfileNames(), f.OllamaProfileNames()...)
+		return fmt.Errorf("unknown profile %q (available: %s)", o.Profile, strings.Join(names, ", "))
+	default:
+		profile, err := f.OllamaProfile(o.Profile)
+		if err != nil {
+			return err
+		}
+		f.Ollama = profile
+		f.DefaultProfile = o.Profile
+	}
+
+	if o.Model != "" {
+		if f.Provider == ProviderOpenAI {
+			f.OpenAI.Model = o.Model
+		} else {
+			f.Ollama.Model = o.Model
+		}
+	}
+	if o.OllamaURL != "" {
+		f.Ollama.URL = o.OllamaURL
+	}
+	if (o.Model != "" || o.OllamaURL != "") && f.Provider != ProviderOpenAI {
+		if err := f.Ollama.validate("ollama"); err != nil {
+			return fmt.Errorf("invalid override: %w", err)
+		}
+	}
+
+	if o.SystemPrompt != "" {
+		f.SystemPrompt = o.SystemPrompt
+		f.SystemPromptFile = ""
+	}
+
+	if o.MaxIterations != 0 {
+		f.Agent.MaxIterations = o.MaxIterations
+		if err := f.Agent.validate(); err != nil {
+			return fmt.Errorf("invalid override: %w", err)
+		}
+	}
+
+	if o.Builtin != "" {
+		builtins := slices.Clone(f.BuiltinServers)
+		for _, name := range strings.Split(o.Builtin, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !slices.Contains(builtins, name) {
+				builtins = append(builtins, name)
+			}
+		}
+		f.BuiltinServers = builtins
+		if err := f.validateBuiltins(""); err != nil {
+			return fmt.Errorf("invalid override: %w", err)
+		}
+	}
+	return nil
+}
+
+// redactedValue replaces secret values in Redacted
+const redactedValue = "[redacted]"
+
+// Redacted returns a copy of the config file safe to print: the values of
+// server environments and headers are replaced
+func (f ConfigFile) Redacted() ConfigFile {
+	redact := func(values map[string]string) map[string]string {
+		if values == nil {
+			return nil
+		}
+		redacted := make(map[string]string, len(values))
+		for key := range values {
+			redacted[key] = redactedValue
+		}
+		return redacted
+	}
+
+	servers := make([]Config, len(f.Servers))
+	for i, server := range f.Servers {
+		server.Environment = redact(server.Environment)
+		server.Headers = redact(server.Headers)
+		servers[i] = server
+	}
+	f.Servers = servers
+	f.Defaults.Environment = redact(f.Defaults.Environment)
+	return f
+}
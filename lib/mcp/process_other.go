@@ -0,0 +1,8 @@
+//go:build !unix
+
+package mcp
+
+import "os/exec"
+
+// isolateProcess leaves the command as it is; process groups are unix only
+func isolateProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package mcp
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// isolateProcess starts the command in its own process group, so a Ctrl-C
+// in the terminal reaches ttobot only, and makes cancelling the command kill
+// the whole group, including processes started by a launcher such as npx
+func isolateProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Profile is a named setup selected with -profile or /profile: the servers
+// to connect and overrides of the model, system prompt and agent settings.
+// Empty fields keep the settings of the config file.
+type Profile struct {
+	// Servers names the servers to connect; the others are not started. A
+	// server listed here is connected even if it has enabled: false.
+	// (empty: the enabled servers)
+	Servers []string `json:"servers,omitempty" yaml:"servers,omitempty"`
+
+	// OllamaProfile selects one of the ollama_profiles
+	OllamaProfile string `json:"ollama_profile,omitempty" yaml:"ollama_profile,omitempty"`
+
+	// Model replaces the model of the selected provider
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+
+	// SystemPrompt replaces system_prompt
+	SystemPrompt string `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+
+	// Agent overrides the agent settings set in it, field by field
+	Agent AgentConfig `json:"agent,omitempty" yaml:"agent,omitempty"`
+}
+
+// NamedProfileNames returns the names of the profiles in order
+func (f *ConfigFile) NamedProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateProfiles checks that every profile names known servers and
+// Ollama profiles and results in valid agent settings
+func (f *ConfigFile) validateProfiles(globalEnv map[string]string) error {
+	lookup := fileEnvLookup(globalEnv)
+	for _, name := range f.NamedProfileNames() {
+		profile := f.Profiles[name]
+		for _, server := range profile.Servers {
+			if !f.hasServer(server) {
+				return fmt.Errorf("profiles.%s: unknown server %q", name, server)
+			}
+		}
+		if profile.OllamaProfile != "" {
+			if _, ok := f.OllamaProfiles[profile.OllamaProfile]; !ok {
+				return fmt.Errorf("profiles.%s: unknown ollama_profile %q", name, profile.OllamaProfile)
+			}
+		}
+		if _, err := profile.agentOver(f.Agent); err != nil {
+			return fmt.Errorf("profiles.%s: %w", name, err)
+		}
+		prompt, _ := expandEnv(profile.SystemPrompt, lookup)
+		profile.SystemPrompt = strings.TrimSpace(prompt)
+		f.Profiles[name] = profile
+	}
+	return nil
+}
+
+// hasServer reports whether a server is configured under name
+func (f *ConfigFile) hasServer(name string) bool {
+	for _, config := range f.Servers {
+		if config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// agentOver returns base with the agent settings of the profile applied
+func (p Profile) agentOver(base AgentConfig) (AgentConfig, error) {
+	// The per-tool limits are merged by key; do not change base's map
+	base.ToolBudget.MaxCallsPerTool = maps.Clone(base.ToolBudget.MaxCallsPerTool)
+	mergeValue(reflect.ValueOf(&base).Elem(), reflect.ValueOf(p.Agent))
+	if err := base.validate(); err != nil {
+		return AgentConfig{}, err
+	}
+	return base, nil
+}
+
+// applyProfile applies the named profile. The servers it does not list are
+// removed from the config file, so they are neither connected nor shown.
+func (f *ConfigFile) applyProfile(name string) error {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(f.NamedProfileNames(), ", "))
+	}
+
+	if len(profile.Servers) > 0 {
+		servers := make([]Config, 0, len(profile.Servers))
+		for _, config := range f.Servers {
+			for _, server := range profile.Servers {
+				if config.Name == server {
+					if !config.IsEnabled() {
+						config.Enabled = nil
+					}
+					servers = append(servers, config)
+					break
+				}
+			}
+		}
+		f.Servers = servers
+	}
+
+	if profile.OllamaProfile != "" {
+		ollama, err := f.OllamaProfile(profile.OllamaProfile)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		f.Ollama = ollama
+		f.DefaultProfile = profile.OllamaProfile
+	}
+	if profile.Model != "" {
+		if f.Provider == ProviderOpenAI {
+			f.OpenAI.Model = profile.Model
+		} else {
+			f.Ollama.Model = profile.Model
+		}
+	}
+	if profile.SystemPrompt != "" {
+		f.SystemPrompt = profile.SystemPrompt
+		f.SystemPromptFile = ""
+	}
+
+	agent, err := profile.agentOver(f.Agent)
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	f.Agent = agent
+	f.ActiveProfile = name
+	return nil
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"fmt"
+	"time"
+)
+
+// Supported values for RestartConfig.Policy
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// Restart defaults
+const (
+	DefaultRestartMaxRetries = 3
+	DefaultRestartBackoff    = time.Second
+)
+
+// RestartConfig controls whether a server is started again after its
+// connection ends without being closed by the client
+type RestartConfig struct {
+	// Policy is never (default), on-failure for servers that exit with an
+	// error or drop the connection, or always to also restart clean exits
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+
+	// MaxRetries caps the consecutive restart attempts (default:
+	// DefaultRestartMaxRetries, negative: no limit)
+	MaxRetries int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+
+	// Backoff is the delay before the first attempt, doubled after each
+	// further one (default: DefaultRestartBackoff)
+	Backoff string `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+}
+
+// PolicyName returns the policy, defaulting to never
+func (r RestartConfig) PolicyName() string {
+	if r.Policy == "" {
+		return RestartNever
+	}
+	return r.Policy
+}
+
+// Retries returns the attempt limit; negative means no limit
+func (r RestartConfig) Retries() int {
+	if r.MaxRetries == 0 {
+		return DefaultRestartMaxRetries
+	}
+	return r.MaxRetries
+}
+
+// BackoffDuration returns the delay before the first attempt
+func (r RestartConfig) BackoffDuration() time.Duration {
+	d, err := time.ParseDuration(r.Backoff)
+	if err != nil || d <= 0 {
+		return DefaultRestartBackoff
+	}
+	return d
+}
+
+// ShouldRestart reports whether a session that ended with err is restarted;
+// a nil err is a clean exit
+func (r RestartConfig) ShouldRestart(err error) bool {
+	switch r.PolicyName() {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// validate checks the policy and backoff of a server
+func (r RestartConfig) validate(server string) error {
+	switch r.PolicyName() {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("server %s has unknown restart policy %q: expected %s, %s or %s",
+			server, r.Policy, RestartNever, RestartOnFailure, RestartAlways)
+	}
+	if r.Backoff != "" {
+		d, err := time.ParseDuration(r.Backoff)
+		if err != nil {
+			return fmt.Errorf("server %s has invalid restart backoff %q: expected a duration such as \"2s\"", server, r.Backoff)
+		}
+		if d <= 0 {
+			return fmt.Errorf("server %s has non-positive restart backoff %q", server, r.Backoff)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadConfigFile reads a config file as written, without merging its
+// includes, applying defaults or resolving paths. Use it to edit a file
+// and write it back with SaveConfig.
+func ReadConfigFile(filePath string) (*ConfigFile, error) {
+	data, err := readConfigDocument(filePath)
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := decodeConfigDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return configFile, nil
+}
+
+// MarshalConfigYAML encodes a configuration as YAML, with fields in
+// declaration order, map keys sorted and empty settings left out
+func MarshalConfigYAML(cfg ConfigFile) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveConfig writes cfg to path as YAML. The new file is written next to
+// the old one and checked to load before it replaces it, and the previous
+// file is kept as path.<timestamp>.bak. Comments are not preserved.
+//
+// cfg should come from ReadConfigFile: a config returned by LoadConfigFile
+// has its includes, defaults and system_prompt_file already folded in.
+func SaveConfig(path string, cfg ConfigFile) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return fmt.Errorf("cannot save %s: configs are saved as YAML; use a .yaml path", path)
+	}
+
+	data, err := MarshalConfigYAML(cfg)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	previous, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+	case os.IsNotExist(err):
+		previous = nil
+	default:
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	// The temporary file sits next to the target, so relative paths in the
+	// config resolve the same way while it is checked
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary config file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary config file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set config file permissions: %w", err)
+	}
+
+	if _, err := LoadConfigFile(tmpPath); err != nil {
+		return fmt.Errorf("refusing to save an invalid config: %w", err)
+	}
+
+	if previous != nil {
+		backup := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+		if err := os.WriteFile(backup, previous, mode); err != nil {
+			return fmt.Errorf("failed to back up config file: %w", err)
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// serverIndex returns the index of the named server, or -1
+func (f *ConfigFile) serverIndex(name string) int {
+	for i, config := range f.Servers {
+		if config.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddServer appends a server; its name must not be taken
+func (f *ConfigFile) AddServer(config Config) error {
+	if err := config.validate(len(f.Servers)); err != nil {
+		return err
+	}
+	if f.serverIndex(config.Name) >= 0 {
+		return fmt.Errorf("server %s already exists", config.Name)
+	}
+	f.Servers = append(f.Servers, config)
+	return nil
+}
+
+// RemoveServer removes the named server
+func (f *ConfigFile) RemoveServer(name string) error {
+	i := f.serverIndex(name)
+	if i < 0 {
+		return fmt.Errorf("server %s not found", name)
+	}
+	f.Servers = append(f.Servers[:i], f.Servers[i+1:]...)
+	return nil
+}
+
+// SetEnabled turns the named server on or off. Enabling clears the field,
+// since servers are enabled by default.
+func (f *ConfigFile) SetEnabled(name string, enabled bool) error {
+	i := f.serverIndex(name)
+	if i < 0 {
+		return fmt.Errorf("server %s not found", name)
+	}
+	if enabled {
+		f.Servers[i].Enabled = nil
+	} else {
+		f.Servers[i].Enabled = &enabled
+	}
+	return nil
+}
+
+// SetOllama replaces the Ollama settings. When a default profile is
+// selected, that profile is replaced instead, since it takes precedence.
+func (f *ConfigFile) SetOllama(config OllamaConfig) error {
+	check := config
+	if check.Model == "" {
+		return fmt.Errorf("ollama model must not be empty")
+	}
+	if err := check.validate("ollama"); err != nil {
+		return err
+	}
+
+	if f.DefaultProfile != "" {
+		if _, ok := f.OllamaProfiles[f.DefaultProfile]; ok {
+			f.OllamaProfiles[f.DefaultProfile] = config
+			return nil
+		}
+	}
+	f.Ollama = config
+	return nil
+}
@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// copyDir copies the files under src into dst
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// roundTripFixtures returns the config files under testdata/roundtrip
+func roundTripFixtures(t *testing.T) []string {
+	t.Helper()
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "roundtrip", "*.yaml"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("no round-trip fixtures: %v", err)
+	}
+	return fixtures
+}
+
+func TestSaveConfigRoundTrip(t *testing.T) {
+	for _, fixture := range roundTripFixtures(t) {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			dir := t.TempDir()
+			copyDir(t, filepath.Join("testdata", "roundtrip"), dir)
+			path := filepath.Join(dir, filepath.Base(fixture))
+
+			original, err := ReadConfigFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadConfigFile(path); err != nil {
+				t.Fatalf("the fixture does not load: %v", err)
+			}
+
+			saved := filepath.Join(dir, "saved.yaml")
+			if err := SaveConfig(saved, *original); err != nil {
+				t.Fatal(err)
+			}
+			reloaded, err := ReadConfigFile(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(original, reloaded) {
+				first, _ := MarshalConfigYAML(*original)
+				second, _ := MarshalConfigYAML(*reloaded)
+				t.Fatalf("the config changed in a round trip\nbefore:\n%s\nafter:\n%s", first, second)
+			}
+
+			// Saving what was loaded again writes the same file
+			data, err := os.ReadFile(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := MarshalConfigYAML(*reloaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, again) {
+				t.Fatalf("a second save differs\nfirst:\n%s\nsecond:\n%s", data, again)
+			}
+		})
+	}
+}
+
+func TestSaveConfigKeepsBackup(t *testing.T) {
+	dir := t.TempDir()
+	copyDir(t, filepath.Join("testdata", "roundtrip"), dir)
+	path := filepath.Join(dir, "full.yaml")
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SystemPrompt = "Be thorough."
+	if err := SaveConfig(path, *cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(path + ".*.bak")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got backups %v, %v; want one", backups, err)
+	}
+	backup, err := os.ReadFile(backups[0])
+	if err != nil || !bytes.Equal(backup, previous) {
+		t.Fatalf("the backup does not hold the previous file: %v", err)
+	}
+	saved, err := ReadConfigFile(path)
+	if err != nil || saved.SystemPrompt != "Be thorough." {
+		t.Fatalf("got %v, %v; want the edited config", saved, err)
+	}
+}
+
+func TestSaveConfigRefusesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mcp.yaml")
+	cfg := ConfigFile{Servers: []Config{{Name: "broken", Transport: TransportHTTP}}}
+	if err := SaveConfig(path, cfg); err == nil || !strings.Contains(err.Error(), "refusing to save") {
+		t.Fatalf("got %v, want the invalid config refused", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("an invalid config was written: %v", err)
+	}
+}
+
+// TestRoundTripFixturesCoverEveryField keeps the round-trip fixtures in step
+// with the config: every field saved to YAML must be set in one of them, so
+// a field lost by MarshalConfigYAML cannot go unnoticed
+func TestRoundTripFixturesCoverEveryField(t *testing.T) {
+	set := make(map[string]bool)
+	for _, fixture := range roundTripFixtures(t) {
+		cfg, err := ReadConfigFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collectSetFields(reflect.ValueOf(*cfg), "", set)
+	}
+
+	var unset []string
+	for _, field := range fieldPaths(reflect.TypeOf(ConfigFile{}), "", nil) {
+		if !set[field] {
+			unset = append(unset, field)
+		}
+	}
+	sort.Strings(unset)
+	if len(unset) > 0 {
+		t.Fatalf("no file in testdata/roundtrip sets these fields: %s", strings.Join(unset, ", "))
+	}
+}
+
+// yamlFieldName returns the YAML key of a struct field like yamlFields, or
+// "" when the field is not saved
+func yamlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// fieldPaths lists the paths of the fields of t saved to YAML, descending
+// into structs and the elements of slices and maps
+func fieldPaths(t reflect.Type, prefix string, seen []reflect.Type) []string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, s := range seen {
+		if s == t {
+			return nil
+		}
+	}
+	seen = append(seen, t)
+
+	var paths []string
+	for name, field := range yamlFields(t) {
+		paths = append(paths, prefix+name)
+		paths = append(paths, fieldPaths(field, prefix+name+".", seen)...)
+	}
+	return paths
+}
+
+// collectSetFields marks the paths of the fields of v that are set
+func collectSetFields(v reflect.Value, prefix string, set map[string]bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			collectSetFields(v.Elem(), prefix, set)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			collectSetFields(v.Index(i), prefix, set)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			collectSetFields(v.MapIndex(key), prefix, set)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := yamlFieldName(v.Type().Field(i))
+			if name == "" {
+				continue
+			}
+			field := v.Field(i)
+			if !field.IsZero() {
+				set[prefix+name] = true
+			}
+			collectSetFields(field, prefix+name+".", set)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prefixes of secret references in environment and header values
+const (
+	// SecretFilePrefix reads the value from a file: "!file:/path/to/secret"
+	SecretFilePrefix = "!file:"
+
+	// SecretCommandPrefix runs a shell command and uses its output:
+	// "!cmd:op read op://vault/item/field"
+	SecretCommandPrefix = "!cmd:"
+)
+
+// SecretCommandTimeout bounds a secret command
+const SecretCommandTimeout = 30 * time.Second
+
+// isSecretRef reports whether value is a secret reference
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, SecretFilePrefix) || strings.HasPrefix(value, SecretCommandPrefix)
+}
+
+// normalizeSecretTags turns unquoted secret references back into plain
+// strings. YAML reads `!file:/path` and `!cmd:op read ...` as tags, with
+// the rest of the command as the value.
+func normalizeSecretTags(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && isSecretRef(node.Tag) {
+		value := node.Tag
+		if node.Value != "" {
+			value += " " + node.Value
+		}
+		node.Tag = "!!str"
+		node.Value = value
+		return
+	}
+	for _, child := range node.Content {
+		normalizeSecretTags(child)
+	}
+}
+
+// resolveSecretFile makes a relative !file: path absolute against dir
+func resolveSecretFile(value, dir string) string {
+	if !strings.HasPrefix(value, SecretFilePrefix) {
+		return value
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(value, SecretFilePrefix))
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") || strings.HasPrefix(path, "$") {
+		return value
+	}
+	return SecretFilePrefix + filepath.Join(dir, path)
+}
+
+// usesSecretCommand reports whether any environment or header value of the
+// server runs a command, and names the first such field
+func (c Config) usesSecretCommand() (string, bool) {
+	for _, key := range sortedKeys(c.Environment) {
+		if strings.HasPrefix(c.Environment[key], SecretCommandPrefix) {
+			return "environment." + key, true
+		}
+	}
+	for _, key := range sortedKeys(c.Headers) {
+		if strings.HasPrefix(c.Headers[key], SecretCommandPrefix) {
+			return "headers." + key, true
+		}
+	}
+	return "", false
+}
+
+// resolveValue expands a value and resolves it when it is a secret
+// reference. Errors name the field but never include the secret.
+func (c Config) resolveValue(ctx context.Context, field, value string, lookup func(string) (string, bool)) (string, error) {
+	expand := func(s string) string {
+		expanded, _ := expandEnv(s, lookup)
+		return expanded
+	}
+
+	switch {
+	case strings.HasPrefix(value, SecretFilePrefix):
+		path := expandHome(expand(strings.TrimSpace(strings.TrimPrefix(value, SecretFilePrefix))))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("server %s: failed to read secret file for %s: %w", c.Name, field, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, SecretCommandPrefix):
+		if c.denyExecSecrets {
+			return "", fmt.Errorf("server %s: %s uses a %s secret, but allow_exec_secrets is false", c.Name, field, SecretCommandPrefix)
+		}
+		return c.runSecretCommand(ctx, field, expand(strings.TrimSpace(strings.TrimPrefix(value, SecretCommandPrefix))))
+
+	default:
+		return expand(value), nil
+	}
+}
+
+// runSecretCommand runs command in a shell and returns its output without
+// the trailing newline
+func (c Config) runSecretCommand(ctx context.Context, field, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, SecretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = c.WorkingDir
+	cmd.Env = os.Environ()
+	for _, key := range sortedKeys(c.fileEnv) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, c.fileEnv[key]))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("server %s: secret command for %s timed out after %s", c.Name, field, SecretCommandTimeout)
+	}
+	if err != nil {
+		// Only stderr is reported; stdout may hold part of the secret
+		if msg := firstLine(stderr.String()); msg != "" {
+			return "", fmt.Errorf("server %s: secret command for %s failed: %v: %s", c.Name, field, err, msg)
+		}
+		return "", fmt.Errorf("server %s: secret command for %s failed: %v", c.Name, field, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// firstLine returns the first non-empty line of s, shortened for messages
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > 200 {
+				line = line[:200] + "…"
+			}
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Serve mode defaults
+const (
+	// DefaultServeListen is the address ttobot serve listens on, which only
+	// accepts connections from this machine
+	DefaultServeListen = "127.0.0.1:8080"
+
+	// DefaultServeSessionTTL is how long an idle API session is kept
+	DefaultServeSessionTTL = 30 * time.Minute
+)
+
+// ServeConfig configures the HTTP API of ttobot serve
+type ServeConfig struct {
+	// Listen is the address to listen on (default: DefaultServeListen)
+	Listen string `json:"listen,omitempty" yaml:"listen,omitempty"`
+
+	// AuthTokenEnv names the environment variable holding the bearer token
+	// clients must send (empty: no authentication)
+	AuthTokenEnv string `json:"auth_token_env,omitempty" yaml:"auth_token_env,omitempty"`
+
+	// SessionTTL is how long an idle session is kept, as a Go duration
+	// (default: DefaultServeSessionTTL)
+	SessionTTL string `json:"session_ttl,omitempty" yaml:"session_ttl,omitempty"`
+}
+
+// ListenAddress returns the address to listen on
+func (s ServeConfig) ListenAddress() string {
+	if s.Listen == "" {
+		return DefaultServeListen
+	}
+	return s.Listen
+}
+
+// AuthToken returns the bearer token named by AuthTokenEnv, if any
+func (s ServeConfig) AuthToken() string {
+	if s.AuthTokenEnv == "" {
+		return ""
+	}
+	return os.Getenv(s.AuthTokenEnv)
+}
+
+// IsLoopbackAddress reports whether a listen address only accepts connections
+// from this machine; an empty host listens on every interface
+func IsLoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SessionTTLDuration returns the parsed session TTL
+func (s ServeConfig) SessionTTLDuration() time.Duration {
+	if d, err := time.ParseDuration(s.SessionTTL); err == nil && d > 0 {
+		return d
+	}
+	return DefaultServeSessionTTL
+}
+
+// validate checks the session TTL
+func (s ServeConfig) validate() error {
+	if s.SessionTTL == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s.SessionTTL)
+	if err != nil {
+		return fmt.Errorf("invalid serve.session_ttl %q: %w", s.SessionTTL, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("serve.session_ttl must be positive, got %s", s.SessionTTL)
+	}
+	return nil
+}
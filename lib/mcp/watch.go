@@ -0,0 +1,174 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DefaultWatchInterval is how often WatchConfig checks the file for changes
+const DefaultWatchInterval = time.Second
+
+// ConfigDiff describes how the servers and model settings changed between
+// two versions of the configuration
+type ConfigDiff struct {
+	// Added and Modified hold the new server configs, Removed the old ones
+	Added    []Config
+	Removed  []Config
+	Modified []Config
+
+	// OllamaChanged is set when the ollama section changed, which requires
+	// recreating the Ollama client
+	OllamaChanged bool
+
+	// ProviderChanged is set when the provider or the openai section changed
+	ProviderChanged bool
+}
+
+// IsEmpty reports whether nothing that needs acting on changed
+func (d ConfigDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 &&
+		!d.OllamaChanged && !d.ProviderChanged
+}
+
+// String summarizes the diff, e.g. "added: a; removed: b"
+func (d ConfigDiff) String() string {
+	var parts []string
+	for _, group := range []struct {
+		label   string
+		configs []Config
+	}{{"added", d.Added}, {"removed", d.Removed}, {"modified", d.Modified}} {
+		if len(group.configs) == 0 {
+			continue
+		}
+		names := make([]string, len(group.configs))
+		for i, config := range group.configs {
+			names[i] = config.Name
+		}
+		parts = append(parts, group.label+": "+strings.Join(names, ", "))
+	}
+	if d.OllamaChanged {
+		parts = append(parts, "ollama settings changed")
+	}
+	if d.ProviderChanged {
+		parts = append(parts, "provider settings changed")
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// DiffConfig compares two configurations. Servers are matched by name.
+func DiffConfig(old, new ConfigFile) ConfigDiff {
+	var diff ConfigDiff
+
+	oldServers := make(map[string]Config, len(old.Servers))
+	for _, config := range old.Servers {
+		oldServers[config.Name] = config
+	}
+	newServers := make(map[string]bool, len(new.Servers))
+	for _, config := range new.Servers {
+		newServers[config.Name] = true
+		previous, ok := oldServers[config.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, config)
+		case !reflect.DeepEqual(previous, config):
+			diff.Modified = append(diff.Modified, config)
+		}
+	}
+	for _, config := range old.Servers {
+		if !newServers[config.Name] {
+			diff.Removed = append(diff.Removed, config)
+		}
+	}
+
+	diff.OllamaChanged = !reflect.DeepEqual(old.Ollama, new.Ollama)
+	diff.ProviderChanged = old.Provider != new.Provider || old.OpenAI != new.OpenAI
+	return diff
+}
+
+// WatchConfig loads the configuration at path and then polls it for changes
+// until ctx is done. Each valid change is passed to onChange with the
+// previous version; an edit that fails to load is logged and ignored, so
+// the last valid configuration stays in effect. A change is only read once
+// the file has stopped changing for one interval, so editors saving in
+// several steps trigger a single reload. Only the file at path is watched;
+// the files it includes are reread when it changes.
+//
+// It returns an error only if the initial load fails; watching continues
+// in the background.
+func WatchConfig(ctx context.Context, path string, onChange func(old, new ConfigFile)) error {
+	current, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	stamp, _ := statStamp(path)
+	go watchConfig(ctx, path, *current, stamp, onChange)
+	return nil
+}
+
+// fileStamp identifies a version of a file cheaply
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statStamp returns the stamp of the file at path
+func statStamp(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// watchConfig is the polling loop of WatchConfig
+func watchConfig(ctx context.Context, path string, current ConfigFile, applied fileStamp, onChange func(old, new ConfigFile)) {
+	ticker := time.NewTicker(DefaultWatchInterval)
+	defer ticker.Stop()
+
+	lastData, _ := os.ReadFile(path)
+	pending := applied
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stamp, err := statStamp(path)
+		if err != nil || stamp == applied {
+			// A missing file is usually an editor replacing it; wait for it
+			continue
+		}
+		if stamp != pending {
+			// Still changing: wait until it settles
+			pending = stamp
+			continue
+		}
+		applied = stamp
+
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(data, lastData) {
+			continue
+		}
+		lastData = data
+
+		next, err := LoadConfigFile(path)
+		if err != nil {
+			slog.Warn("Config: Ignoring invalid change", "path", path, "error", err)
+			continue
+		}
+
+		previous := current
+		current = *next
+		onChange(previous, current)
+	}
+}
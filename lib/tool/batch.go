@@ -0,0 +1,112 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotRun marks a batch call that was never started because the batch
+// was cancelled or stopped after a failure
+var ErrNotRun = errors.New("tool call not run")
+
+// Call is a tool call of a batch
+type Call struct {
+	Tool      *Tool
+	Arguments map[string]any
+}
+
+// BatchOpts configures ExecuteBatch
+type BatchOpts struct {
+	// Parallelism bounds the calls running at once (zero: all of them)
+	Parallelism int
+
+	// Timeout bounds each call; see ExecuteWithTimeout (zero: none)
+	Timeout time.Duration
+
+	// FailFast stops the batch at the first call returning an error:
+	// calls not yet started are not run and running ones are cancelled.
+	// Results with IsError set do not count as failures.
+	FailFast bool
+}
+
+// BatchResult is the outcome of one call of a batch
+type BatchResult struct {
+	// Index is the position of the call in the batch
+	Index int
+
+	// Duration is how long the call ran (zero if it was not run)
+	Duration time.Duration
+
+	Result *Result
+	Err    error
+}
+
+// ExecuteBatch runs calls with bounded parallelism and returns their
+// results in the order of calls. Once ctx is cancelled, or a call fails
+// with FailFast set, no more calls are started; the ones not started get
+// an error wrapping ErrNotRun. ExecuteBatch always waits for the calls it
+// started.
+func ExecuteBatch(ctx context.Context, calls []Call, opts BatchOpts) []BatchResult {
+	results := make([]BatchResult, len(calls))
+	if len(calls) == 0 {
+		return results
+	}
+
+	parallelism := opts.Parallelism
+	if parallelism <= 0 || parallelism > len(calls) {
+		parallelism = len(calls)
+	}
+
+	batchCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		results[i].Index = i
+
+		// Wait for a free slot unless the batch is stopped first
+		select {
+		case <-batchCtx.Done():
+		case slots <- struct{}{}:
+		}
+		if batchCtx.Err() != nil {
+			for j := i; j < len(calls); j++ {
+				results[j] = BatchResult{Index: j, Err: fmt.Errorf("%w: %w", ErrNotRun, context.Cause(batchCtx))}
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			started := time.Now()
+			result, err := executeCall(batchCtx, call, opts.Timeout)
+			results[i] = BatchResult{Index: i, Duration: time.Since(started), Result: result, Err: err}
+			if err != nil && opts.FailFast {
+				cancel(fmt.Errorf("call %d failed: %w", i, err))
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// executeCall runs one call of a batch, turning a panic into an error
+func executeCall(ctx context.Context, call Call, timeout time.Duration) (result *Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("tool %s panicked: %v", call.Tool.Name, r)
+		}
+	}()
+	if call.Tool == nil {
+		return nil, fmt.Errorf("no tool given")
+	}
+	return ExecuteWithTimeout(ctx, call.Tool, call.Arguments, timeout)
+}
@@ -0,0 +1,258 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcTool returns a tool running fn
+func funcTool(name string, fn func(ctx context.Context) (*Result, error)) *Tool {
+	return &Tool{
+		Name: name,
+		Executor: ExecFunc(func(ctx context.Context, _ map[string]any) (*Result, error) {
+			return fn(ctx)
+		}),
+	}
+}
+
+func TestExecuteBatchOrder(t *testing.T) {
+	// Later calls finish first, yet results follow the order of the calls
+	const n = 8
+	calls := make([]Call, n)
+	for i := range calls {
+		delay := time.Duration(n-i) * 5 * time.Millisecond
+		calls[i] = Call{Tool: funcTool(fmt.Sprintf("t%d", i), func(ctx context.Context) (*Result, error) {
+			time.Sleep(delay)
+			return TextResult(fmt.Sprintf("result %d", i)), nil
+		})}
+	}
+
+	for _, parallelism := range []int{0, 1, 3, n, 2 * n} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			results := ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: parallelism})
+			if len(results) != n {
+				t.Fatalf("got %d results, want %d", len(results), n)
+			}
+			for i, result := range results {
+				if result.Index != i || result.Err != nil {
+					t.Fatalf("result %d: index %d, error %v", i, result.Index, result.Err)
+				}
+				if got, want := result.Result.Text(), fmt.Sprintf("result %d", i); got != want {
+					t.Errorf("result %d = %q, want %q", i, got, want)
+				}
+				if result.Duration <= 0 {
+					t.Errorf("result %d has no duration", i)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteBatchParallelism(t *testing.T) {
+	var running, peak atomic.Int32
+	calls := make([]Call, 10)
+	for i := range calls {
+		calls[i] = Call{Tool: funcTool("t", func(ctx context.Context) (*Result, error) {
+			now := running.Add(1)
+			for {
+				old := peak.Load()
+				if now <= old || peak.CompareAndSwap(old, now) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return TextResult("ok"), nil
+		})}
+	}
+
+	ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: 3})
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("%d calls ran at once, want at most 3", got)
+	}
+}
+
+func TestExecuteBatchEmpty(t *testing.T) {
+	if results := ExecuteBatch(context.Background(), nil, BatchOpts{}); len(results) != 0 {
+		t.Fatalf("got %d results for no calls", len(results))
+	}
+}
+
+func TestExecuteBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var launched atomic.Int32
+
+	// The first call cancels the batch while holding the only slot
+	calls := []Call{{Tool: funcTool("first", func(ctx context.Context) (*Result, error) {
+		launched.Add(1)
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})}}
+	for range 4 {
+		calls = append(calls, Call{Tool: funcTool("later", func(ctx context.Context) (*Result, error) {
+			launched.Add(1)
+			return TextResult("ran"), nil
+		})})
+	}
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	results := ExecuteBatch(ctx, calls, BatchOpts{Parallelism: 1})
+
+	if got := launched.Load(); got != 1 {
+		t.Fatalf("%d calls were launched, want 1", got)
+	}
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("first call: got %v, want the cancellation", results[0].Err)
+	}
+	for _, result := range results[1:] {
+		if !errors.Is(result.Err, ErrNotRun) || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("call %d: got %v, want ErrNotRun for the cancellation", result.Index, result.Err)
+		}
+		if result.Duration != 0 || result.Result != nil {
+			t.Errorf("call %d was not run but has a result", result.Index)
+		}
+	}
+}
+
+func TestExecuteBatchCancelledBefore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var launched atomic.Int32
+	calls := []Call{
+		{Tool: funcTool("a", func(ctx context.Context) (*Result, error) { launched.Add(1); return TextResult("a"), nil })},
+		{Tool: funcTool("b", func(ctx context.Context) (*Result, error) { launched.Add(1); return TextResult("b"), nil })},
+	}
+	for _, result := range ExecuteBatch(ctx, calls, BatchOpts{}) {
+		if !errors.Is(result.Err, ErrNotRun) {
+			t.Errorf("call %d: got %v, want ErrNotRun", result.Index, result.Err)
+		}
+	}
+	if got := launched.Load(); got != 0 {
+		t.Fatalf("%d calls were launched after cancellation", got)
+	}
+}
+
+func TestExecuteBatchFailFast(t *testing.T) {
+	failed := errors.New("boom")
+	release := make(chan struct{})
+	var cancelled atomic.Bool
+	var launched atomic.Int32
+
+	calls := []Call{
+		// Runs until the failure cancels it
+		{Tool: funcTool("slow", func(ctx context.Context) (*Result, error) {
+			launched.Add(1)
+			close(release)
+			<-ctx.Done()
+			cancelled.Store(true)
+			return nil, ctx.Err()
+		})},
+		{Tool: funcTool("failing", func(ctx context.Context) (*Result, error) {
+			launched.Add(1)
+			<-release
+			return nil, failed
+		})},
+	}
+	for range 3 {
+		calls = append(calls, Call{Tool: funcTool("later", func(ctx context.Context) (*Result, error) {
+			launched.Add(1)
+			return TextResult("ran"), nil
+		})})
+	}
+
+	results := ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: 2, FailFast: true})
+
+	if !errors.Is(results[1].Err, failed) {
+		t.Fatalf("failing call: got %v", results[1].Err)
+	}
+	if !cancelled.Load() {
+		t.Error("the running call was not cancelled")
+	}
+	if got := launched.Load(); got != 2 {
+		t.Errorf("%d calls were launched, want 2", got)
+	}
+	for _, result := range results[2:] {
+		if !errors.Is(result.Err, ErrNotRun) || !strings.Contains(result.Err.Error(), "call 1 failed") {
+			t.Errorf("call %d: got %v, want ErrNotRun naming the failure", result.Index, result.Err)
+		}
+	}
+}
+
+func TestExecuteBatchErrorResultsDoNotFailFast(t *testing.T) {
+	calls := []Call{
+		{Tool: funcTool("reports", func(ctx context.Context) (*Result, error) { return ErrorResult("no such file"), nil })},
+		{Tool: funcTool("next", func(ctx context.Context) (*Result, error) { return TextResult("ok"), nil })},
+	}
+	results := ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: 1, FailFast: true})
+	if results[0].Err != nil || !results[0].Result.IsError {
+		t.Errorf("first call: got %+v, want an error result", results[0])
+	}
+	if results[1].Err != nil || results[1].Result.Text() != "ok" {
+		t.Errorf("second call: got %+v, want it run", results[1])
+	}
+}
+
+func TestExecuteBatchPanic(t *testing.T) {
+	calls := []Call{
+		{Tool: funcTool("panics", func(ctx context.Context) (*Result, error) { panic("oops") })},
+		{Tool: funcTool("fine", func(ctx context.Context) (*Result, error) { return TextResult("ok"), nil })},
+		{},
+	}
+	results := ExecuteBatch(context.Background(), calls, BatchOpts{})
+
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "tool panics panicked: oops") {
+		t.Errorf("panicking call: got %v", results[0].Err)
+	}
+	if results[1].Err != nil || results[1].Result.Text() != "ok" {
+		t.Errorf("other call: got %+v, want it unaffected", results[1])
+	}
+	if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "no tool given") {
+		t.Errorf("call without a tool: got %v", results[2].Err)
+	}
+}
+
+func TestExecuteBatchTimeout(t *testing.T) {
+	calls := []Call{{Tool: funcTool("hangs", func(ctx context.Context) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})}}
+	// A timeout is reported to the model as an error result
+	results := ExecuteBatch(context.Background(), calls, BatchOpts{Timeout: 10 * time.Millisecond})
+	if results[0].Err != nil || !results[0].Result.IsError || !strings.Contains(results[0].Result.Text(), "timed out") {
+		t.Fatalf("got %+v, want a timed out result", results[0])
+	}
+}
+
+func TestExecuteBatchConcurrentBatches(t *testing.T) {
+	// Batches share nothing, so running several at once is safe
+	var wg sync.WaitGroup
+	for b := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls := make([]Call, 5)
+			for i := range calls {
+				calls[i] = Call{Tool: funcTool("t", func(ctx context.Context) (*Result, error) {
+					return TextResult(fmt.Sprintf("%d/%d", b, i)), nil
+				})}
+			}
+			for i, result := range ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: 2}) {
+				if got, want := result.Result.Text(), fmt.Sprintf("%d/%d", b, i); got != want {
+					t.Errorf("batch %d result %d = %q, want %q", b, i, got, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
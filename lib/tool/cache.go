@@ -0,0 +1,194 @@
+package tool
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheEntries bounds a Cache created without a size
+const DefaultCacheEntries = 256
+
+// Cacheable reports whether results of the tool may be reused: it is
+// read-only, or idempotent and not destructive
+func Cacheable(t Tool) bool {
+	return t.ReadOnly || (t.HasTag(TagIdempotent) && !t.Destructive)
+}
+
+// CacheStats counts the work of a Cache
+type CacheStats struct {
+	Hits          int
+	Misses        int
+	Evictions     int
+	Invalidations int
+
+	// Entries is the number of results currently cached
+	Entries int
+}
+
+// Cache keeps tool results for reuse by calls with the same tool name and
+// arguments. Entries expire after a TTL and the least recently used ones
+// are evicted beyond a maximum count. Cached results are shared and must
+// not be modified.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	stats   CacheStats
+}
+
+// cacheEntry is a cached result
+type cacheEntry struct {
+	key     string
+	tool    string
+	result  *Result
+	expires time.Time
+}
+
+// NewCache creates a cache whose entries live for ttl (zero: until evicted
+// or invalidated), holding at most maxEntries results (zero:
+// DefaultCacheEntries)
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheEntries
+	}
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// cacheKey identifies a call by tool name and canonical arguments; JSON
+// encoding sorts map keys at every level
+func cacheKey(toolName string, arguments map[string]any) (string, bool) {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return "", false
+	}
+	return toolName + "\x00" + string(data), true
+}
+
+// get returns the cached result of a call, if it has not expired
+func (c *Cache) get(key string) (*Result, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[key]
+	if ok {
+		entry := element.Value.(*cacheEntry)
+		if c.ttl <= 0 || time.Now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.stats.Hits++
+			return entry.result, true
+		}
+		c.removeLocked(element)
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// put caches the result of a call, evicting the least recently used
+// entries beyond the maximum
+func (c *Cache) put(key, toolName string, result *Result) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := &cacheEntry{key: key, tool: toolName, result: result, expires: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.maxEntries {
+		c.removeLocked(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// removeLocked drops an entry; the caller holds lock
+func (c *Cache) removeLocked(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// Invalidate drops the results of every tool whose name starts with prefix,
+// e.g. "filesystem:" after a file was written ("": every result), and
+// returns how many were dropped
+func (c *Cache) Invalidate(prefix string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	dropped := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if strings.HasPrefix(element.Value.(*cacheEntry).tool, prefix) {
+			c.removeLocked(element)
+			dropped++
+		}
+		element = next
+	}
+	c.stats.Invalidations += dropped
+	return dropped
+}
+
+// Stats returns the cache's counters
+func (c *Cache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// Middleware answers calls from the cache and caches the results of
+// successful calls; results with IsError set are not cached. Apply it only
+// to Cacheable tools.
+func (c *Cache) Middleware() Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			name := ToolName(ctx)
+			key, ok := cacheKey(name, arguments)
+			if !ok {
+				return next(ctx, arguments)
+			}
+			if result, hit := c.get(key); hit {
+				slog.Debug("Tool: Answered from cache", "tool", name)
+				return result, nil
+			}
+
+			result, err := next(ctx, arguments)
+			if err == nil && result != nil && !result.IsError {
+				c.put(key, name, result)
+			}
+			return result, err
+		}
+	}
+}
+
+// InvalidateAfter drops the results of the tools whose name starts with
+// prefix after each call, even a failed one, which may have changed
+// something before failing; apply it to tools that modify what those
+// tools read
+func (c *Cache) InvalidateAfter(prefix string) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			result, err := next(ctx, arguments)
+			if dropped := c.Invalidate(prefix); dropped > 0 {
+				slog.Debug("Tool: Invalidated cached results", "tool", ToolName(ctx), "results", dropped)
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// destructiveWords mark tool names that likely modify or delete data
+var destructiveWords = map[string]bool{
+	"remove": true, "delete": true, "rm": true, "write": true, "move": true,
+	"rename": true, "drop": true, "truncate": true, "clean": true, "reset": true,
+	"kill": true, "push": true, "overwrite": true,
+}
+
+// LooksDestructive guesses from a tool name whether it modifies data; it is
+// used for tools that do not say so themselves
+func LooksDestructive(name string) bool {
+	if _, short, ok := strings.Cut(name, ":"); ok {
+		name = short
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}) {
+		if destructiveWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// Confirmer decides whether a call to a destructive tool may run
+type Confirmer interface {
+	Confirm(ctx context.Context, toolName string, arguments map[string]any) (bool, error)
+}
+
+// ConfirmFunc implements Confirmer with a function
+type ConfirmFunc func(ctx context.Context, toolName string, arguments map[string]any) (bool, error)
+
+// Confirm calls f
+func (f ConfirmFunc) Confirm(ctx context.Context, toolName string, arguments map[string]any) (bool, error) {
+	return f(ctx, toolName, arguments)
+}
+
+var (
+	// AutoApprove allows every call, for unattended runs
+	AutoApprove Confirmer = ConfirmFunc(func(context.Context, string, map[string]any) (bool, error) {
+		return true, nil
+	})
+
+	// AutoDeny blocks every call, for strict runs where nothing may be modified
+	AutoDeny Confirmer = ConfirmFunc(func(context.Context, string, map[string]any) (bool, error) {
+		return false, nil
+	})
+)
+
+// LineReader reads one line of input, giving up when ctx ends
+type LineReader func(ctx context.Context) (string, error)
+
+// TerminalConfirmer asks on a terminal before each call. "y" approves the
+// call and "a" every call of the tool for the rest of the session; "v"
+// denies every call of the tool without asking again. Anything else,
+// including the end of input, denies the call.
+type TerminalConfirmer struct {
+	readLine LineReader
+	out      io.Writer
+
+	// lock keeps the prompts of concurrent calls from interleaving
+	lock sync.Mutex
+
+	// remembered holds the always and never answers by tool name
+	remembered map[string]bool
+}
+
+// NewTerminalConfirmer creates a confirmer reading answers from in and
+// writing prompts to out. A read pending when a call is cancelled is
+// abandoned and consumes the next line; use NewLineConfirmer when in is
+// read elsewhere too.
+func NewTerminalConfirmer(in io.Reader, out io.Writer) *TerminalConfirmer {
+	reader := bufio.NewReader(in)
+	return NewLineConfirmer(func(ctx context.Context) (string, error) {
+		answer := make(chan string, 1)
+		go func() {
+			line, _ := reader.ReadString('\n')
+			answer <- line
+		}()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case line := <-answer:
+			return line, nil
+		}
+	}, out)
+}
+
+// NewLineConfirmer creates a confirmer reading answers with readLine and
+// writing prompts to out
+func NewLineConfirmer(readLine LineReader, out io.Writer) *TerminalConfirmer {
+	return &TerminalConfirmer{readLine: readLine, out: out, remembered: make(map[string]bool)}
+}
+
+// Confirm shows the call and waits for an answer, unless the tool has an
+// always or never answer. A cancelled ctx denies the call.
+func (c *TerminalConfirmer) Confirm(ctx context.Context, toolName string, arguments map[string]any) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if allowed, ok := c.remembered[toolName]; ok {
+		return allowed, nil
+	}
+
+	args, err := json.MarshalIndent(arguments, "   ", "  ")
+	if err != nil {
+		args = []byte(fmt.Sprintf("%v", arguments))
+	}
+	caller := toolName
+	if server := ToolServer(ctx); server != "" {
+		caller = fmt.Sprintf("%s (server %s)", toolName, server)
+	}
+	fmt.Fprintf(c.out, "⚠️  %s wants to run with arguments:\n   %s\n   Allow? [y]es, [N]o, [a]lways or ne[v]er for this tool: ", caller, args)
+
+	line, err := c.readLine(ctx)
+	if err != nil {
+		fmt.Fprintln(c.out)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
+		}
+		return false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	case "a", "always":
+		c.remembered[toolName] = true
+		return true, nil
+	case "v", "never":
+		c.remembered[toolName] = false
+		return false, nil
+	}
+	return false, nil
+}
+
+// WithConfirmation asks c before each call and, if the call is denied,
+// returns an IsError result saying it was blocked so the model can adapt.
+// An error from c fails the call.
+func WithConfirmation(c Confirmer) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			name := ToolName(ctx)
+			ok, err := c.Confirm(ctx, name, arguments)
+			if err != nil {
+				return nil, fmt.Errorf("failed to confirm tool %s: %w", name, err)
+			}
+			if !ok {
+				return ErrorResult(fmt.Sprintf("the call to tool '%s' was blocked: the user did not approve it. "+
+					"Do not retry the same call; ask the user how to proceed or find another way.", name)), nil
+			}
+			return next(ctx, arguments)
+		}
+	}
+}
+
+// RequireConfirmation returns the tools with WithConfirmation(c) around the
+// executor of each destructive tool; other tools are returned unchanged
+func RequireConfirmation(tools []Tool, c Confirmer) []Tool {
+	confirmed := make([]Tool, len(tools))
+	for i, t := range tools {
+		if t.Destructive && t.Executor != nil {
+			t.Executor = Wrap(t.Executor, WithConfirmation(c))
+		}
+		confirmed[i] = t
+	}
+	return confirmed
+}
@@ -0,0 +1,269 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+	resultType  = reflect.TypeFor[*Result]()
+	timeType    = reflect.TypeFor[time.Time]()
+)
+
+// FromFunc builds a tool from a Go function. The function takes an
+// optional context.Context and then an optional pointer to a struct
+// describing its arguments, and returns a value and an error, or only an
+// error:
+//
+//	func(ctx context.Context, args *Args) (string, error)
+//
+// The parameter schema is generated from the struct's fields with the
+// conventions of the bundled servers: the json tag names a field, fields
+// tagged omitempty are optional, and the mcp tag describes the field.
+//
+// Arguments are bound through JSON; arguments that do not fit the struct
+// fail the call. An error returned by the function is reported to the
+// model as an error result. A string result becomes text, a *Result is
+// passed on, and any other value is sent as JSON. The tool is marked
+// Destructive if its name looks like it; set the field to override that.
+func FromFunc(name, description string, fn any) (Tool, error) {
+	executor, params, err := newFuncExecutor(fn)
+	if err != nil {
+		return Tool{}, fmt.Errorf("failed to build tool %s: %w", name, err)
+	}
+
+	return Tool{
+		Name:        name,
+		Description: description,
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  params,
+		},
+		Executor:    executor,
+		Destructive: LooksDestructive(name),
+	}, nil
+}
+
+// MustFromFunc is like FromFunc but panics if fn has an unsupported signature
+func MustFromFunc(name, description string, fn any) Tool {
+	t, err := FromFunc(name, description, fn)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// funcExecutor calls a function built into a tool by FromFunc
+type funcExecutor struct {
+	fn reflect.Value
+
+	// withContext is set when the first parameter is a context.Context
+	withContext bool
+
+	// argsType is the struct the arguments are bound to (nil: no arguments)
+	argsType reflect.Type
+}
+
+// newFuncExecutor checks the signature of fn and generates its parameter schema
+func newFuncExecutor(fn any) (*funcExecutor, ParameterSchema, error) {
+	params := ParameterSchema{Type: "object", Properties: map[string]PropertyDefinition{}}
+
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return nil, params, fmt.Errorf("expected a function, got %T", fn)
+	}
+	fnType := value.Type()
+	if fnType.IsVariadic() {
+		return nil, params, fmt.Errorf("variadic functions are not supported")
+	}
+
+	executor := &funcExecutor{fn: value}
+	in := 0
+	if in < fnType.NumIn() && fnType.In(in) == contextType {
+		executor.withContext = true
+		in++
+	}
+	if in < fnType.NumIn() {
+		argsType := fnType.In(in)
+		if argsType.Kind() != reflect.Pointer || argsType.Elem().Kind() != reflect.Struct {
+			return nil, params, fmt.Errorf("arguments must be a pointer to a struct, got %s", argsType)
+		}
+		executor.argsType = argsType.Elem()
+		in++
+	}
+	if in != fnType.NumIn() {
+		return nil, params, fmt.Errorf("expected (context.Context, *Args) parameters, got %s", fnType)
+	}
+
+	switch {
+	case fnType.NumOut() == 1 && fnType.Out(0) == errorType:
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	default:
+		return nil, params, fmt.Errorf("expected (T, error) or error results, got %s", fnType)
+	}
+
+	if executor.argsType != nil {
+		object := structSchema(executor.argsType, nil)
+		params.Properties = object.Properties
+		params.Required = object.Required
+	}
+	return executor, params, nil
+}
+
+// Execute binds the arguments and calls the function
+func (e *funcExecutor) Execute(ctx context.Context, arguments map[string]any) (result *Result, err error) {
+	var in []reflect.Value
+	if e.withContext {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	if e.argsType != nil {
+		args := reflect.New(e.argsType)
+		data, err := json.Marshal(arguments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode arguments: %w", err)
+		}
+		if err := json.Unmarshal(data, args.Interface()); err != nil {
+			return nil, fmt.Errorf("failed to bind arguments: %w", err)
+		}
+		in = append(in, args)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("tool %s panicked: %v", ToolName(ctx), r)
+		}
+	}()
+	out := e.fn.Call(in)
+
+	if failed, _ := out[len(out)-1].Interface().(error); failed != nil {
+		return ErrorResult(failed.Error()), nil
+	}
+	if len(out) == 1 {
+		return TextResult("ok"), nil
+	}
+	return funcResult(out[0])
+}
+
+// funcResult converts the value returned by a function into a result
+func funcResult(value reflect.Value) (*Result, error) {
+	if value.Type() == resultType {
+		if result := value.Interface().(*Result); result != nil {
+			return result, nil
+		}
+		return TextResult(""), nil
+	}
+	if value.Kind() == reflect.String {
+		return TextResult(value.String()), nil
+	}
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return &Result{Parts: []Part{JSONPart{Data: data}}}, nil
+}
+
+// structSchema generates the object schema of a struct type. seen holds the
+// struct types being generated, to stop at recursive types.
+func structSchema(t reflect.Type, seen []reflect.Type) PropertyDefinition {
+	object := PropertyDefinition{Type: "object", Properties: map[string]PropertyDefinition{}}
+	for _, s := range seen {
+		if s == t {
+			return object
+		}
+	}
+	seen = append(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitempty, skip := jsonField(field)
+		if skip {
+			continue
+		}
+
+		// Untagged embedded structs are flattened, as encoding/json does
+		fieldType := field.Type
+		if field.Anonymous && fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			embedded := structSchema(fieldType, seen)
+			for key, prop := range embedded.Properties {
+				object.Properties[key] = prop
+			}
+			object.Required = append(object.Required, embedded.Required...)
+			continue
+		}
+
+		prop := typeSchema(field.Type, seen)
+		prop.Description = field.Tag.Get("mcp")
+		object.Properties[name] = prop
+		if !omitempty {
+			object.Required = append(object.Required, name)
+		}
+	}
+	return object
+}
+
+// jsonField returns the JSON name of a struct field, whether it is tagged
+// omitempty, and whether encoding/json skips it
+func jsonField(field reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+		return "", false, true
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// typeSchema generates the schema of a Go type
+func typeSchema(t reflect.Type, seen []reflect.Type) PropertyDefinition {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return PropertyDefinition{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return PropertyDefinition{Type: "string"}
+	case reflect.Bool:
+		return PropertyDefinition{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return PropertyDefinition{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return PropertyDefinition{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes []byte as a base64 string
+		if t.Elem().Kind() == reflect.Uint8 {
+			return PropertyDefinition{Type: "string"}
+		}
+		items := typeSchema(t.Elem(), seen)
+		return PropertyDefinition{Type: "array", Items: &items}
+	case reflect.Map:
+		return PropertyDefinition{Type: "object"}
+	case reflect.Struct:
+		return structSchema(t, seen)
+	default:
+		// Interfaces accept any value
+		return PropertyDefinition{}
+	}
+}
@@ -0,0 +1,287 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+)
+
+// ExecFunc executes a tool call; it implements ToolExecutor
+type ExecFunc func(ctx context.Context, arguments map[string]any) (*Result, error)
+
+// Execute calls f
+func (f ExecFunc) Execute(ctx context.Context, arguments map[string]any) (*Result, error) {
+	return f(ctx, arguments)
+}
+
+// Middleware wraps an ExecFunc to observe or modify tool calls
+type Middleware func(next ExecFunc) ExecFunc
+
+// Wrap wraps an executor with middlewares. They run in the order given: the
+// first one sees the call first and the result last. Put WithRetry outside
+// WithTimeout to give each attempt its own timeout, and WithResultLimit
+// innermost so retries and logging see the limited result.
+func Wrap(exec ToolExecutor, mws ...Middleware) ToolExecutor {
+	if len(mws) == 0 {
+		return exec
+	}
+
+	handler := ExecFunc(exec.Execute)
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	return handler
+}
+
+// toolNameKey is the context key holding the name of the tool being called
+type toolNameKey struct{}
+
+// ContextWithToolName returns a context carrying the name of the tool being
+// called; Tool.Execute sets it for middlewares
+func ContextWithToolName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolNameKey{}, name)
+}
+
+// ToolName returns the name of the tool being called, or "(unnamed)" if
+// the context does not carry one
+func ToolName(ctx context.Context) string {
+	if name, ok := ctx.Value(toolNameKey{}).(string); ok && name != "" {
+		return name
+	}
+	return "(unnamed)"
+}
+
+// toolServerKey is the context key holding the server of the tool being called
+type toolServerKey struct{}
+
+// ContextWithToolServer returns a context carrying the ID of the server
+// providing the tool being called; Tool.Execute sets it for middlewares
+func ContextWithToolServer(ctx context.Context, server string) context.Context {
+	return context.WithValue(ctx, toolServerKey{}, server)
+}
+
+// ToolServer returns the ID of the server providing the tool being called,
+// or "" if the context does not carry one
+func ToolServer(ctx context.Context) string {
+	server, _ := ctx.Value(toolServerKey{}).(string)
+	return server
+}
+
+// WithTimeout bounds each call to d unless ctx has an earlier deadline.
+// On expiry it returns an IsError result saying "tool 'X' timed out after
+// D" instead of an error, so the model is told like any other failure.
+//
+// The executor runs in its own goroutine and is cancelled through its
+// context. One that ignores its context keeps running after the timeout:
+// WithTimeout stops waiting for it, but cannot stop it.
+func WithTimeout(d time.Duration) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			callCtx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type outcome struct {
+				result *Result
+				err    error
+			}
+			// Buffered so an executor finishing after the timeout can exit
+			done := make(chan outcome, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- outcome{err: fmt.Errorf("tool %s panicked: %v", ToolName(ctx), r)}
+					}
+				}()
+				result, err := next(callCtx, arguments)
+				done <- outcome{result, err}
+			}()
+
+			select {
+			case o := <-done:
+				// Only our own deadline is reported as a timeout
+				if o.err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+					return timedOut(ctx, d), nil
+				}
+				return o.result, o.err
+			case <-callCtx.Done():
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				return timedOut(ctx, d), nil
+			}
+		}
+	}
+}
+
+// timedOut returns the result reporting a call that ran out of time
+func timedOut(ctx context.Context, d time.Duration) *Result {
+	return ErrorResult(fmt.Sprintf("tool '%s' timed out after %s", ToolName(ctx), d))
+}
+
+// ExecuteWithTimeout runs t with a timeout of d (zero or negative: none),
+// returning an IsError result if it expires; see WithTimeout
+func ExecuteWithTimeout(ctx context.Context, t *Tool, arguments map[string]any, d time.Duration) (*Result, error) {
+	if d <= 0 {
+		return t.Execute(ctx, arguments)
+	}
+	ctx = ContextWithToolName(ctx, t.Name)
+	return WithTimeout(d)(t.Execute)(ctx, arguments)
+}
+
+// Backoff returns how long to wait before retry attempt (1 for the first
+// retry), and false when no more retries should be made
+type Backoff func(attempt int) (time.Duration, bool)
+
+// ConstantBackoff retries up to retries times, waiting delay each time
+func ConstantBackoff(delay time.Duration, retries int) Backoff {
+	return func(attempt int) (time.Duration, bool) {
+		return delay, attempt <= retries
+	}
+}
+
+// ExponentialBackoff retries up to retries times, starting with base and
+// doubling the wait each time up to max
+func ExponentialBackoff(base, max time.Duration, retries int) Backoff {
+	return func(attempt int) (time.Duration, bool) {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max), attempt <= retries
+	}
+}
+
+// WithRetry calls again after errors for which retryable returns true,
+// waiting as backoff says. Results with IsError set are not retried: the
+// tool ran and the model should see its answer.
+func WithRetry(retryable func(error) bool, backoff Backoff) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			for attempt := 1; ; attempt++ {
+				result, err := next(ctx, arguments)
+				if err == nil || !retryable(err) {
+					return result, err
+				}
+
+				delay, ok := backoff(attempt)
+				if !ok {
+					return nil, err
+				}
+				slog.Warn("Tool: Call failed, retrying", "tool", ToolName(ctx), "error", err, "delay", delay)
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, err
+				case <-timer.C:
+				}
+			}
+		}
+	}
+}
+
+// WithLogging logs each call with its arguments, duration and outcome to
+// logger (nil: the default logger): calls at debug level, failures as
+// warnings
+func WithLogging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			name := ToolName(ctx)
+			logger.DebugContext(ctx, "Tool: Calling", "tool", name, "arguments", arguments)
+
+			started := time.Now()
+			result, err := next(ctx, arguments)
+			elapsed := time.Since(started).Round(time.Millisecond)
+
+			switch {
+			case err != nil:
+				logger.WarnContext(ctx, "Tool: Call failed", "tool", name, "elapsed", elapsed, "error", err)
+			case result != nil && result.IsError:
+				logger.WarnContext(ctx, "Tool: Tool reported an error", "tool", name, "elapsed", elapsed, "error", result.Text())
+			default:
+				logger.DebugContext(ctx, "Tool: Finished", "tool", name, "elapsed", elapsed)
+			}
+			return result, err
+		}
+	}
+}
+
+// WithResultLimit caps the text and JSON parts of results at limit bytes in
+// total. Parts beyond the limit are replaced by a note saying how much was
+// cut; binary parts are kept.
+func WithResultLimit(limit int) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+			result, err := next(ctx, arguments)
+			if err != nil || result == nil {
+				return result, err
+			}
+			return limitResult(result, limit), nil
+		}
+	}
+}
+
+// limitResult returns result with its text and JSON parts cut to limit bytes
+func limitResult(result *Result, limit int) *Result {
+	total := 0
+	for _, part := range result.Parts {
+		total += len(partText(part))
+	}
+	if total <= limit {
+		return result
+	}
+
+	limited := &Result{IsError: result.IsError}
+	remaining := limit
+	shown := 0
+	for _, part := range result.Parts {
+		if _, ok := part.(BinaryPart); ok {
+			limited.Parts = append(limited.Parts, part)
+			continue
+		}
+
+		text := partText(part)
+		if len(text) <= remaining {
+			limited.Parts = append(limited.Parts, part)
+			remaining -= len(text)
+			shown += len(text)
+			continue
+		}
+
+		// A cut JSON part is no longer valid JSON, so it becomes text
+		if remaining > 0 {
+			cut := remaining
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			limited.Parts = append(limited.Parts, TextPart{Text: text[:cut]})
+			shown += cut
+		}
+		remaining = 0
+	}
+
+	limited.Parts = append(limited.Parts, TextPart{
+		Text: fmt.Sprintf("\n…[result truncated: %d of %d bytes shown]", shown, total),
+	})
+	return limited
+}
+
+// partText returns the text of a text or JSON part
+func partText(part Part) string {
+	switch p := part.(type) {
+	case TextPart:
+		return p.Text
+	case JSONPart:
+		return string(p.Data)
+	default:
+		return ""
+	}
+}
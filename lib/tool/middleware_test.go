@@ -0,0 +1,354 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("connection reset")
+
+// sequence returns an executor answering with the given outcomes in turn,
+// and a function reporting how many calls it got
+func sequence(outcomes ...func(ctx context.Context) (*Result, error)) (ExecFunc, func() int) {
+	var mu sync.Mutex
+	calls := 0
+	exec := func(ctx context.Context, _ map[string]any) (*Result, error) {
+		mu.Lock()
+		i := min(calls, len(outcomes)-1)
+		calls++
+		mu.Unlock()
+		return outcomes[i](ctx)
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+	return exec, count
+}
+
+func returns(result *Result, err error) func(context.Context) (*Result, error) {
+	return func(context.Context) (*Result, error) { return result, err }
+}
+
+func namedContext(name string) context.Context {
+	return ContextWithToolName(context.Background(), name)
+}
+
+func TestWrapOrder(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next ExecFunc) ExecFunc {
+			return func(ctx context.Context, arguments map[string]any) (*Result, error) {
+				order = append(order, name+" call")
+				result, err := next(ctx, arguments)
+				order = append(order, name+" result")
+				return result, err
+			}
+		}
+	}
+	exec := ExecFunc(func(ctx context.Context, _ map[string]any) (*Result, error) {
+		order = append(order, "tool")
+		return TextResult("ok"), nil
+	})
+
+	if _, err := Wrap(exec, trace("first"), trace("second")).Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "first call,second call,tool,second result,first result"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("order = %s, want %s", got, want)
+	}
+}
+
+func TestWrapWithoutMiddlewares(t *testing.T) {
+	exec := funcTool("plain", func(ctx context.Context) (*Result, error) { return TextResult("plain"), nil })
+	if wrapped := Wrap(exec); wrapped != ToolExecutor(exec) {
+		t.Fatalf("Wrap without middlewares returned %T, want the executor itself", wrapped)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	hang := func(ctx context.Context) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	t.Run("in time", func(t *testing.T) {
+		exec, _ := sequence(returns(TextResult("fast"), nil))
+		result, err := WithTimeout(time.Second)(exec)(namedContext("quick"), nil)
+		if err != nil || result.Text() != "fast" {
+			t.Fatalf("got %v, %v", result, err)
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		exec, _ := sequence(hang)
+		result, err := WithTimeout(10*time.Millisecond)(exec)(namedContext("slow"), nil)
+		if err != nil {
+			t.Fatalf("got error %v, want an error result", err)
+		}
+		if !result.IsError || result.Text() != "tool 'slow' timed out after 10ms" {
+			t.Fatalf("got %+v", result)
+		}
+	})
+
+	t.Run("ignores its context", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		exec, _ := sequence(func(context.Context) (*Result, error) {
+			<-release
+			return TextResult("late"), nil
+		})
+		started := time.Now()
+		result, err := WithTimeout(10*time.Millisecond)(exec)(namedContext("stubborn"), nil)
+		if err != nil || !result.IsError || time.Since(started) > time.Second {
+			t.Fatalf("got %v, %v after %s; want a timeout without waiting", result, err, time.Since(started))
+		}
+	})
+
+	t.Run("caller cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(namedContext("cancelled"))
+		exec, _ := sequence(func(ctx context.Context) (*Result, error) {
+			cancel()
+			return hang(ctx)
+		})
+		result, err := WithTimeout(time.Second)(exec)(ctx, nil)
+		if !errors.Is(err, context.Canceled) || result != nil {
+			t.Fatalf("got %v, %v; want the caller's cancellation, not a timeout", result, err)
+		}
+	})
+
+	t.Run("caller deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(namedContext("deadline"), 10*time.Millisecond)
+		defer cancel()
+		exec, _ := sequence(hang)
+		_, err := WithTimeout(time.Second)(exec)(ctx, nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got %v, want the caller's deadline as an error", err)
+		}
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		exec, _ := sequence(func(context.Context) (*Result, error) { panic("boom") })
+		_, err := WithTimeout(time.Second)(exec)(namedContext("broken"), nil)
+		if err == nil || err.Error() != "tool broken panicked: boom" {
+			t.Fatalf("got %v", err)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	constant := ConstantBackoff(time.Second, 2)
+	for attempt := 1; attempt <= 3; attempt++ {
+		if delay, ok := constant(attempt); delay != time.Second || ok != (attempt <= 2) {
+			t.Errorf("constant attempt %d = %s, %v", attempt, delay, ok)
+		}
+	}
+
+	exponential := ExponentialBackoff(100*time.Millisecond, time.Second, 5)
+	wants := []time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second}
+	for attempt := 1; attempt <= 5; attempt++ {
+		if delay, ok := exponential(attempt); delay != wants[attempt] || !ok {
+			t.Errorf("exponential attempt %d = %s, %v; want %s", attempt, delay, ok, wants[attempt])
+		}
+	}
+	if _, ok := exponential(6); ok {
+		t.Error("exponential backoff retried past its limit")
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	retryable := func(err error) bool { return errors.Is(err, errFlaky) }
+	noWait := ConstantBackoff(0, 3)
+
+	t.Run("until success", func(t *testing.T) {
+		exec, calls := sequence(returns(nil, errFlaky), returns(nil, errFlaky), returns(TextResult("ok"), nil))
+		result, err := WithRetry(retryable, noWait)(exec)(namedContext("flaky"), nil)
+		if err != nil || result.Text() != "ok" || calls() != 3 {
+			t.Fatalf("got %v, %v after %d calls", result, err, calls())
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		exec, calls := sequence(returns(nil, errFlaky))
+		_, err := WithRetry(retryable, noWait)(exec)(namedContext("down"), nil)
+		if !errors.Is(err, errFlaky) || calls() != 4 {
+			t.Fatalf("got %v after %d calls, want the error after 4", err, calls())
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		fatal := errors.New("bad request")
+		exec, calls := sequence(returns(nil, fatal))
+		_, err := WithRetry(retryable, noWait)(exec)(namedContext("bad"), nil)
+		if !errors.Is(err, fatal) || calls() != 1 {
+			t.Fatalf("got %v after %d calls", err, calls())
+		}
+	})
+
+	t.Run("error results", func(t *testing.T) {
+		exec, calls := sequence(returns(ErrorResult("no such file"), nil))
+		result, err := WithRetry(retryable, noWait)(exec)(namedContext("missing"), nil)
+		if err != nil || !result.IsError || calls() != 1 {
+			t.Fatalf("got %v, %v after %d calls; an error result must not be retried", result, err, calls())
+		}
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(namedContext("waiting"), 20*time.Millisecond)
+		defer cancel()
+		exec, calls := sequence(returns(nil, errFlaky))
+		started := time.Now()
+		_, err := WithRetry(retryable, ConstantBackoff(time.Hour, 3))(exec)(ctx, nil)
+		if !errors.Is(err, errFlaky) || calls() != 1 || time.Since(started) > time.Second {
+			t.Fatalf("got %v after %d calls and %s", err, calls(), time.Since(started))
+		}
+	})
+}
+
+// captureLogs returns a logger writing text records at every level to buf
+func captureLogs(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestWithLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome func(context.Context) (*Result, error)
+		want    []string
+	}{
+		{"success", returns(TextResult("ok"), nil), []string{`level=DEBUG msg="Tool: Calling" tool=logged arguments=map[path:go.mod]`, `level=DEBUG msg="Tool: Finished" tool=logged elapsed=`}},
+		{"failure", returns(nil, errFlaky), []string{`level=WARN msg="Tool: Call failed" tool=logged`, `error="connection reset"`}},
+		{"error result", returns(ErrorResult("no such file"), nil), []string{`level=WARN msg="Tool: Tool reported an error" tool=logged`, `error="no such file"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			exec, _ := sequence(tt.outcome)
+			WithLogging(captureLogs(&buf))(exec)(namedContext("logged"), map[string]any{"path": "go.mod"})
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("log lacks %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWithResultLimit(t *testing.T) {
+	t.Run("under the limit", func(t *testing.T) {
+		original := TextResult("short")
+		exec, _ := sequence(returns(original, nil))
+		result, _ := WithResultLimit(10)(exec)(context.Background(), nil)
+		if result != original {
+			t.Fatalf("a result under the limit was changed: %+v", result)
+		}
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		original := &Result{IsError: true, Parts: []Part{
+			TextPart{Text: "0123456789"},
+			BinaryPart{MIMEType: "image/png", Data: []byte{1, 2, 3}},
+			JSONPart{Data: json.RawMessage(`{"key":"value"}`)},
+			TextPart{Text: "dropped"},
+		}}
+		exec, _ := sequence(returns(original, nil))
+		result, _ := WithResultLimit(14)(exec)(context.Background(), nil)
+
+		if !result.IsError {
+			t.Error("the limited result lost IsError")
+		}
+		if len(result.Parts) != 4 {
+			t.Fatalf("got parts %+v", result.Parts)
+		}
+		if text, ok := result.Parts[0].(TextPart); !ok || text.Text != "0123456789" {
+			t.Errorf("part 0 = %+v", result.Parts[0])
+		}
+		if _, ok := result.Parts[1].(BinaryPart); !ok {
+			t.Errorf("part 1 = %+v, want the binary part kept", result.Parts[1])
+		}
+		// The cut JSON is no longer valid, so it is kept as text
+		if text, ok := result.Parts[2].(TextPart); !ok || text.Text != `{"ke` {
+			t.Errorf("part 2 = %+v, want the JSON cut as text", result.Parts[2])
+		}
+		if text, ok := result.Parts[3].(TextPart); !ok || text.Text != "\n…[result truncated: 14 of 32 bytes shown]" {
+			t.Errorf("part 3 = %+v, want the truncation note", result.Parts[3])
+		}
+	})
+
+	t.Run("multibyte", func(t *testing.T) {
+		exec, _ := sequence(returns(TextResult("añb"), nil))
+		result, _ := WithResultLimit(2)(exec)(context.Background(), nil)
+		if text := result.Parts[0].(TextPart).Text; text != "a" {
+			t.Fatalf("got %q, want the cut before the split rune", text)
+		}
+	})
+
+	t.Run("errors pass through", func(t *testing.T) {
+		exec, _ := sequence(returns(nil, errFlaky))
+		if _, err := WithResultLimit(1)(exec)(context.Background(), nil); !errors.Is(err, errFlaky) {
+			t.Fatalf("got %v", err)
+		}
+	})
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	// The order Wrap recommends: retry outside timeout, the limit innermost
+	var deadlines []time.Time
+	var mu sync.Mutex
+	record := func(ctx context.Context) {
+		deadline, _ := ctx.Deadline()
+		mu.Lock()
+		deadlines = append(deadlines, deadline)
+		mu.Unlock()
+	}
+	exec, calls := sequence(
+		func(ctx context.Context) (*Result, error) {
+			record(ctx)
+			time.Sleep(5 * time.Millisecond)
+			return nil, errFlaky
+		},
+		func(ctx context.Context) (*Result, error) {
+			record(ctx)
+			return ErrorResult(strings.Repeat("x", 100)), nil
+		},
+	)
+
+	var logs bytes.Buffer
+	wrapped := Wrap(exec,
+		WithLogging(captureLogs(&logs)),
+		WithRetry(func(err error) bool { return errors.Is(err, errFlaky) }, ConstantBackoff(0, 2)),
+		WithTimeout(time.Second),
+		WithResultLimit(10),
+	)
+	result, err := wrapped.Execute(namedContext("chained"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls() != 2 {
+		t.Fatalf("got %d calls, want a retry after the first failure", calls())
+	}
+	// Each attempt gets its own timeout
+	if len(deadlines) != 2 || !deadlines[1].After(deadlines[0]) {
+		t.Errorf("deadlines = %v, want a later one for the retry", deadlines)
+	}
+	// The limit applies before the result reaches the retry and logging
+	if !result.IsError || !strings.HasPrefix(result.Text(), "xxxxxxxxxx\n…[result truncated: 10 of 100 bytes shown]") {
+		t.Errorf("result = %q", result.Text())
+	}
+	if !strings.Contains(logs.String(), "result truncated: 10 of 100 bytes shown") {
+		t.Errorf("the log did not see the limited result:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), strings.Repeat("x", 11)) {
+		t.Errorf("the log saw the full result:\n%s", logs.String())
+	}
+}
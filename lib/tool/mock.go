@@ -0,0 +1,195 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RecordedCall is a tool call seen by a CallRecorder
+type RecordedCall struct {
+	Tool      string
+	Arguments map[string]any
+}
+
+// CallRecorder records tool calls; it is safe for concurrent use
+type CallRecorder struct {
+	lock  sync.Mutex
+	calls []RecordedCall
+}
+
+// Record adds a call
+func (r *CallRecorder) Record(toolName string, arguments map[string]any) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, RecordedCall{Tool: toolName, Arguments: arguments})
+}
+
+// Calls returns the recorded calls in the order they were made
+func (r *CallRecorder) Calls() []RecordedCall {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]RecordedCall(nil), r.calls...)
+}
+
+// CallsTo returns the recorded calls of one tool
+func (r *CallRecorder) CallsTo(toolName string) []RecordedCall {
+	var calls []RecordedCall
+	for _, call := range r.Calls() {
+		if call.Tool == toolName {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls
+func (r *CallRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = nil
+}
+
+// Snapshot renders the recorded calls one per line, as the tool name
+// followed by its arguments in JSON with sorted keys, for comparison with
+// a golden file
+func (r *CallRecorder) Snapshot() string {
+	var sb strings.Builder
+	for _, call := range r.Calls() {
+		fmt.Fprintf(&sb, "%s %s\n", call.Tool, formatArguments(call.Arguments))
+	}
+	return sb.String()
+}
+
+// formatArguments renders arguments as compact JSON with sorted keys
+func formatArguments(arguments map[string]any) string {
+	if arguments == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return fmt.Sprintf("%v", arguments)
+	}
+	return string(data)
+}
+
+// ArgMatcher selects the calls a canned response applies to
+type ArgMatcher func(arguments map[string]any) bool
+
+// AnyArgs matches every call
+func AnyArgs(map[string]any) bool {
+	return true
+}
+
+// ArgsEqual matches calls whose arguments equal want exactly. Numbers are
+// compared by value, so 3 matches the 3.0 a model sends.
+func ArgsEqual(want map[string]any) ArgMatcher {
+	return func(arguments map[string]any) bool {
+		if len(arguments) != len(want) {
+			return false
+		}
+		for key, value := range want {
+			got, ok := arguments[key]
+			if !ok || !sameValue(got, value) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ArgEquals matches calls whose argument key equals value
+func ArgEquals(key string, value any) ArgMatcher {
+	return func(arguments map[string]any) bool {
+		got, ok := arguments[key]
+		return ok && sameValue(got, value)
+	}
+}
+
+// sameValue compares two argument values, numbers by value
+func sameValue(a, b any) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// mockResponse is a canned response of a MockExecutor
+type mockResponse struct {
+	match  ArgMatcher
+	result *Result
+	err    error
+}
+
+// MockExecutor answers calls with canned responses and records them, to
+// test agents and prompts without live servers. Responses are tried in the
+// order they were added; a call no response matches fails.
+type MockExecutor struct {
+	CallRecorder
+
+	lock      sync.Mutex
+	responses []mockResponse
+}
+
+// NewMockExecutor creates a mock without responses
+func NewMockExecutor() *MockExecutor {
+	return &MockExecutor{}
+}
+
+// On answers the calls match selects with result
+func (m *MockExecutor) On(match ArgMatcher, result *Result) *MockExecutor {
+	return m.add(mockResponse{match: match, result: result})
+}
+
+// OnError fails the calls match selects with err
+func (m *MockExecutor) OnError(match ArgMatcher, err error) *MockExecutor {
+	return m.add(mockResponse{match: match, err: err})
+}
+
+// add appends a canned response
+func (m *MockExecutor) add(response mockResponse) *MockExecutor {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.responses = append(m.responses, response)
+	return m
+}
+
+// Execute records the call and returns the first matching response
+func (m *MockExecutor) Execute(ctx context.Context, arguments map[string]any) (*Result, error) {
+	name := ToolName(ctx)
+	m.Record(name, arguments)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, response := range m.responses {
+		if response.match(arguments) {
+			return response.result, response.err
+		}
+	}
+	return nil, fmt.Errorf("mock of tool %s has no response for arguments %s", name, formatArguments(arguments))
+}
+
+// DryRun returns the tools with every tool that is not ReadOnly replaced by
+// one that records the call to recorder (nil: none) and answers "DRY RUN:
+// would have executed …" instead of running it
+func DryRun(tools []Tool, recorder *CallRecorder) []Tool {
+	dry := make([]Tool, len(tools))
+	for i, t := range tools {
+		if !t.ReadOnly {
+			t.Executor = ExecFunc(func(ctx context.Context, arguments map[string]any) (*Result, error) {
+				name := ToolName(ctx)
+				if recorder != nil {
+					recorder.Record(name, arguments)
+				}
+				return TextResult(fmt.Sprintf("DRY RUN: would have executed %s with %s", name, formatArguments(arguments))), nil
+			})
+		}
+		dry[i] = t
+	}
+	return dry
+}
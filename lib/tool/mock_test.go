@@ -0,0 +1,155 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMockExecutor(t *testing.T) {
+	errDenied := errors.New("permission denied")
+	mock := NewMockExecutor().
+		On(ArgEquals("path", "go.mod"), TextResult("module example.com/demo")).
+		OnError(ArgEquals("path", "/etc/shadow"), errDenied).
+		On(ArgsEqual(map[string]any{"path": "big.txt", "limit": 3}), TextResult("first three lines")).
+		On(ArgEquals("path", "go.mod"), TextResult("never returned"))
+	read := &Tool{Name: "read_file", Executor: mock}
+
+	tests := []struct {
+		args    map[string]any
+		text    string
+		err     error
+		noMatch bool
+	}{
+		{args: map[string]any{"path": "go.mod"}, text: "module example.com/demo"},
+		{args: map[string]any{"path": "go.mod", "limit": 1}, text: "module example.com/demo"},
+		{args: map[string]any{"path": "/etc/shadow"}, err: errDenied},
+		// Models send numbers as float64
+		{args: map[string]any{"path": "big.txt", "limit": 3.0}, text: "first three lines"},
+		{args: map[string]any{"path": "big.txt", "limit": 3.0, "offset": 1}, noMatch: true},
+		{args: map[string]any{"path": "missing.txt"}, noMatch: true},
+	}
+	for _, tt := range tests {
+		result, err := read.Execute(context.Background(), tt.args)
+		switch {
+		case tt.noMatch:
+			if err == nil || !strings.Contains(err.Error(), "mock of tool read_file has no response") {
+				t.Errorf("%v: got %v, %v; want no response", tt.args, result, err)
+			}
+		case tt.err != nil:
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%v: got %v, want %v", tt.args, err, tt.err)
+			}
+		default:
+			if err != nil || result.Text() != tt.text {
+				t.Errorf("%v: got %v, %v; want %q", tt.args, result, err, tt.text)
+			}
+		}
+	}
+
+	// Every call is recorded under the tool's name, matched or not
+	if got := len(mock.CallsTo("read_file")); got != len(tests) {
+		t.Errorf("recorded %d calls, want %d", got, len(tests))
+	}
+	mock.Reset()
+	if calls := mock.Calls(); len(calls) != 0 {
+		t.Errorf("calls after Reset = %v", calls)
+	}
+}
+
+func TestMockExecutorConcurrentCalls(t *testing.T) {
+	mock := NewMockExecutor().On(AnyArgs, TextResult("ok"))
+	lookup := &Tool{Name: "lookup", Executor: mock}
+	calls := make([]Call, 50)
+	for i := range calls {
+		calls[i] = Call{Tool: lookup, Arguments: map[string]any{"n": i}}
+	}
+	for i, result := range ExecuteBatch(context.Background(), calls, BatchOpts{Parallelism: 8}) {
+		if result.Err != nil || result.Result.Text() != "ok" {
+			t.Fatalf("call %d = %v, %v", i, result.Result, result.Err)
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, call := range mock.Calls() {
+		seen[call.Arguments["n"].(int)] = true
+	}
+	if len(seen) != len(calls) {
+		t.Errorf("recorded %d distinct calls, want %d", len(seen), len(calls))
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var ran []string
+	real := func(name string, readOnly bool) Tool {
+		return Tool{Name: name, ReadOnly: readOnly, Executor: ExecFunc(func(ctx context.Context, _ map[string]any) (*Result, error) {
+			ran = append(ran, name)
+			return TextResult("ran " + name), nil
+		})}
+	}
+	tools := []Tool{real("fs:read_file", true), real("fs:write_file", false), real("fs:remove", false)}
+
+	var recorder CallRecorder
+	dry := DryRun(tools, &recorder)
+	if len(dry) != len(tools) {
+		t.Fatalf("got %d tools, want %d", len(dry), len(tools))
+	}
+
+	calls := []struct {
+		tool int
+		args map[string]any
+	}{
+		{0, map[string]any{"path": "go.mod"}},
+		{1, map[string]any{"path": "notes.md", "content": "# Notes\n"}},
+		{2, map[string]any{"path": "build", "recursive": true}},
+		{1, nil},
+	}
+	var texts []string
+	for _, call := range calls {
+		result, err := dry[call.tool].Execute(context.Background(), call.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, result.Text())
+	}
+
+	if !reflect.DeepEqual(ran, []string{"fs:read_file"}) {
+		t.Errorf("ran %v, want only the read-only tool", ran)
+	}
+	golden(t, "dry_run", strings.Join(texts, "\n")+"\n---\n"+recorder.Snapshot())
+
+	// The original tools are unchanged
+	if _, err := tools[1].Execute(context.Background(), nil); err != nil || len(ran) != 2 {
+		t.Errorf("the original tool no longer runs: %v, ran %v", err, ran)
+	}
+
+	// Without a recorder the calls are still answered
+	result, err := DryRun(tools, nil)[2].Execute(context.Background(), map[string]any{"path": "x"})
+	if err != nil || !strings.HasPrefix(result.Text(), "DRY RUN: would have executed fs:remove") {
+		t.Errorf("got %v, %v", result, err)
+	}
+}
+
+func TestCallRecorderSnapshot(t *testing.T) {
+	var recorder CallRecorder
+	recorder.Record("memory:search_nodes", map[string]any{"query": "ttobot", "limit": 5})
+	recorder.Record("memory:read_graph", nil)
+	recorder.Record("fs:write_file", map[string]any{"path": "a.txt", "content": "line one\nline \"two\"", "mode": map[string]any{"z": 1, "a": true}})
+	recorder.Record("fs:bad", map[string]any{"value": func() {}})
+
+	lines := strings.Split(strings.TrimSuffix(recorder.Snapshot(), "\n"), "\n")
+	want := []string{
+		`memory:search_nodes {"limit":5,"query":"ttobot"}`,
+		`memory:read_graph {}`,
+		`fs:write_file {"content":"line one\nline \"two\"","mode":{"a":true,"z":1},"path":"a.txt"}`,
+	}
+	if len(lines) != 4 || !reflect.DeepEqual(lines[:3], want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	// Arguments that cannot be encoded fall back to fmt
+	if !strings.HasPrefix(lines[3], "fs:bad map[value:") {
+		t.Errorf("got %q for unencodable arguments", lines[3])
+	}
+}
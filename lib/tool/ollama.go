@@ -0,0 +1,151 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// ollamaProperty is the property type of api.ToolFunction parameters
+type ollamaProperty = struct {
+	Type        api.PropertyType `json:"type"`
+	Items       any              `json:"items,omitempty"`
+	Description string           `json:"description"`
+	Enum        []any            `json:"enum,omitempty"`
+}
+
+// ToOllama converts tools to the Ollama API format. Ollama properties
+// cannot carry nested properties, unions or constraints, so those are
+// described in the property descriptions instead.
+func ToOllama(tools []Tool) []api.Tool {
+	ollamaTools := make([]api.Tool, 0, len(tools))
+	for _, t := range tools {
+		ollamaTool := api.Tool{
+			Type: "function",
+			Function: api.ToolFunction{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+			},
+		}
+
+		params := &ollamaTool.Function.Parameters
+		params.Type = t.Function.Parameters.Type
+		params.Defs = t.Function.Parameters.Defs
+		params.Items = t.Function.Parameters.Items
+		params.Required = t.Function.Parameters.Required
+		params.Properties = make(map[string]ollamaProperty, len(t.Function.Parameters.Properties))
+		for name, prop := range t.Function.Parameters.Properties {
+			params.Properties[name] = ollamaProperty{
+				Type:        api.PropertyType{propertyType(prop)},
+				Items:       propertyItems(prop),
+				Description: propertyDescription(prop),
+				Enum:        prop.Enum,
+			}
+		}
+
+		ollamaTools = append(ollamaTools, ollamaTool)
+	}
+	return ollamaTools
+}
+
+// propertyItems returns the item schema of an array property. The Ollama
+// API passes items through as written, so nested schemas survive.
+func propertyItems(prop PropertyDefinition) any {
+	if prop.Items == nil {
+		// A nil *PropertyDefinition would be sent as "items": null
+		return nil
+	}
+	return prop.Items
+}
+
+// propertyType returns the single type sent for a property. Ollama rejects
+// empty types and unions, so a union is sent as its primary non-null type,
+// and a property without any type as a string; the description lists the
+// alternatives.
+func propertyType(prop PropertyDefinition) string {
+	if t := prop.PrimaryType(); t != "" {
+		return t
+	}
+	return "string"
+}
+
+// propertyDescription returns the description sent for a property.
+// Ollama properties cannot declare constraints or nested properties, so
+// constraints are folded into the description as hints, and the schema of
+// an object property is embedded as JSON.
+func propertyDescription(prop PropertyDefinition) string {
+	description := prop.Description
+	if hints := constraintHints(prop); len(hints) > 0 {
+		description += " (" + strings.Join(hints, "; ") + ")"
+	}
+
+	if len(prop.Properties) > 0 {
+		nested := PropertyDefinition{
+			Type:       prop.Type,
+			Properties: prop.Properties,
+			Required:   prop.Required,
+		}
+		if schema, err := json.Marshal(nested); err == nil {
+			description += " JSON schema: " + string(schema)
+		}
+	}
+
+	return strings.TrimSpace(description)
+}
+
+// constraintHints describes the alternatives of a union and the property's
+// default, bounds, pattern and examples, e.g. "default: true" or "1–100"
+func constraintHints(prop PropertyDefinition) []string {
+	var hints []string
+
+	if prop.IsUnion() || prop.PrimaryType() == "" {
+		hints = append(hints, "accepts "+prop.DescribeType())
+	}
+
+	if prop.Default != nil {
+		hints = append(hints, "default: "+formatValue(prop.Default))
+	}
+
+	switch {
+	case prop.Minimum != nil && prop.Maximum != nil:
+		hints = append(hints, formatNumber(*prop.Minimum)+"–"+formatNumber(*prop.Maximum))
+	case prop.Minimum != nil:
+		hints = append(hints, "at least "+formatNumber(*prop.Minimum))
+	case prop.Maximum != nil:
+		hints = append(hints, "at most "+formatNumber(*prop.Maximum))
+	}
+
+	switch {
+	case prop.MinLength != nil && prop.MaxLength != nil:
+		hints = append(hints, fmt.Sprintf("%d–%d characters", *prop.MinLength, *prop.MaxLength))
+	case prop.MinLength != nil:
+		hints = append(hints, fmt.Sprintf("at least %d characters", *prop.MinLength))
+	case prop.MaxLength != nil:
+		hints = append(hints, fmt.Sprintf("at most %d characters", *prop.MaxLength))
+	}
+
+	if prop.Pattern != "" {
+		hints = append(hints, "pattern: "+prop.Pattern)
+	}
+
+	if len(prop.Examples) > 0 {
+		examples := make([]string, len(prop.Examples))
+		for i, example := range prop.Examples {
+			examples[i] = formatValue(example)
+		}
+		hints = append(hints, "e.g. "+strings.Join(examples, ", "))
+	}
+
+	return hints
+}
+
+// formatValue renders a schema value as JSON
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,261 @@
+package tool
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name.golden, rewriting the file instead
+// when the test runs with -update
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// loadSchema decodes a parameter schema from testdata
+func loadSchema(t *testing.T, name string) ParameterSchema {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema ParameterSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to decode %s: %v", name, err)
+	}
+	return schema
+}
+
+// renderOllama lists the properties of a converted tool, one per line and
+// sorted by name, as the model sees them
+func renderOllama(tool api.Tool) string {
+	params := tool.Function.Parameters
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", tool.Function.Name, tool.Function.Description)
+	sb.WriteString("required:")
+	if len(params.Required) > 0 {
+		sb.WriteString(" " + strings.Join(params.Required, ", "))
+	}
+	sb.WriteString("\n")
+	names := make([]string, 0, len(params.Properties))
+	for name := range params.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		prop := params.Properties[name]
+		fmt.Fprintf(&sb, "- %s (%s)", name, strings.Join(prop.Type, "|"))
+		if len(prop.Enum) > 0 {
+			fmt.Fprintf(&sb, " enum %s", formatValue(prop.Enum))
+		}
+		if prop.Items != nil {
+			fmt.Fprintf(&sb, " items %s", formatValue(prop.Items))
+		}
+		if prop.Description != "" {
+			fmt.Fprintf(&sb, ": %s", prop.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// sampleTools covers nested objects, enums, arrays of objects and of enums,
+// and properties without descriptions
+func sampleTools() []Tool {
+	return []Tool{
+		{
+			Name: "fs:create_files",
+			Function: ToolFunction{
+				Name:        "fs:create_files",
+				Description: "Creates files",
+				Parameters: ParameterSchema{
+					Type:     "object",
+					Required: []string{"files", "mode"},
+					Properties: map[string]PropertyDefinition{
+						"mode": {Type: "string", Description: "How to write", Enum: []any{"create", "overwrite"}},
+						"files": {
+							Type:        "array",
+							Description: "Files to write",
+							Items: &PropertyDefinition{
+								Type:     "object",
+								Required: []string{"path"},
+								Properties: map[string]PropertyDefinition{
+									"path":    {Type: "string", Description: "Path of the file"},
+									"content": {Type: "string"},
+									"lines": {Type: "array", Items: &PropertyDefinition{
+										Type: "array", Items: &PropertyDefinition{Type: "string"},
+									}},
+								},
+							},
+						},
+						"tags":  {Type: "array", Items: &PropertyDefinition{Type: "string", Enum: []any{"draft", "final"}}},
+						"level": {Type: "number", Enum: []any{1.0, 2.5}},
+						"owner": {
+							Type:        "object",
+							Description: "Who owns the files",
+							Required:    []string{"name"},
+							Properties: map[string]PropertyDefinition{
+								"name":  {Type: "string"},
+								"email": {Type: "string", Description: "Email address"},
+							},
+						},
+						"count": {Type: "integer"},
+					},
+				},
+			},
+		},
+		{
+			Name:     "ping",
+			Function: ToolFunction{Name: "ping", Parameters: ParameterSchema{Type: "object"}},
+		},
+	}
+}
+
+// ollamaWire is what a tool sent to Ollama decodes to
+type ollamaWire struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  ParameterSchema `json:"parameters"`
+	} `json:"function"`
+}
+
+func TestToOllamaRoundTrip(t *testing.T) {
+	tools := sampleTools()
+	data, err := json.Marshal(ToOllama(tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire []ollamaWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if len(wire) != len(tools) {
+		t.Fatalf("got %d tools, want %d", len(wire), len(tools))
+	}
+
+	for i, got := range wire {
+		want := tools[i].Function
+		if got.Type != "function" || got.Function.Name != want.Name || got.Function.Description != want.Description {
+			t.Errorf("tool %d = %s %q %q", i, got.Type, got.Function.Name, got.Function.Description)
+		}
+		params := got.Function.Parameters
+		if params.Type != want.Parameters.Type || !reflect.DeepEqual(params.Required, want.Parameters.Required) {
+			t.Errorf("%s parameters = %s, required %v", want.Name, params.Type, params.Required)
+		}
+		if len(params.Properties) != len(want.Parameters.Properties) {
+			t.Fatalf("%s has %d properties, want %d", want.Name, len(params.Properties), len(want.Parameters.Properties))
+		}
+
+		for name, wantProp := range want.Parameters.Properties {
+			prop := params.Properties[name]
+			if prop.Type != wantProp.Type {
+				t.Errorf("%s type = %q, want %q", name, prop.Type, wantProp.Type)
+			}
+			if !reflect.DeepEqual(prop.Enum, wantProp.Enum) {
+				t.Errorf("%s enum = %v, want %v", name, prop.Enum, wantProp.Enum)
+			}
+			// Items are passed through whole, nested schemas included
+			if !reflect.DeepEqual(prop.Items, wantProp.Items) {
+				t.Errorf("%s items = %+v, want %+v", name, prop.Items, wantProp.Items)
+			}
+			if prop.Properties != nil {
+				t.Errorf("%s kept nested properties, which Ollama cannot carry", name)
+			}
+
+			if len(wantProp.Properties) == 0 {
+				if prop.Description != wantProp.Description {
+					t.Errorf("%s description = %q, want %q", name, prop.Description, wantProp.Description)
+				}
+				continue
+			}
+			// A nested object is described by its schema instead
+			before, schema, ok := strings.Cut(prop.Description, "JSON schema: ")
+			if !ok || strings.TrimSpace(before) != wantProp.Description {
+				t.Fatalf("%s description = %q, want the description and a JSON schema", name, prop.Description)
+			}
+			var nested PropertyDefinition
+			if err := json.Unmarshal([]byte(schema), &nested); err != nil {
+				t.Fatalf("%s embedded schema: %v", name, err)
+			}
+			if !reflect.DeepEqual(nested.Properties, wantProp.Properties) || !reflect.DeepEqual(nested.Required, wantProp.Required) {
+				t.Errorf("%s embedded schema = %+v", name, nested)
+			}
+		}
+	}
+}
+
+func TestToOllamaWireFormat(t *testing.T) {
+	data, err := json.Marshal(ToOllama(sampleTools()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw []map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	properties := raw[0]["function"].(map[string]any)["parameters"].(map[string]any)["properties"].(map[string]any)
+	for name, prop := range properties {
+		prop := prop.(map[string]any)
+		// Ollama requires a description, even an empty one
+		if _, ok := prop["description"]; !ok {
+			t.Errorf("%s has no description field", name)
+		}
+		if items, ok := prop["items"]; ok && items == nil {
+			t.Errorf("%s is sent with \"items\": null", name)
+		}
+		if _, ok := prop["type"].(string); !ok {
+			t.Errorf("%s type = %v, want a single type", name, prop["type"])
+		}
+	}
+	if _, ok := properties["count"].(map[string]any)["items"]; ok {
+		t.Error("a property that is not an array has items")
+	}
+
+	// A tool without parameters still sends an object
+	params := raw[1]["function"].(map[string]any)["parameters"].(map[string]any)
+	if params["type"] != "object" {
+		t.Errorf("ping parameters = %v", params)
+	}
+}
+
+func TestToOllamaEmpty(t *testing.T) {
+	if tools := ToOllama(nil); tools == nil || len(tools) != 0 {
+		t.Fatalf("got %v, want an empty list", tools)
+	}
+}
+
+func TestToOllamaConstraintHints(t *testing.T) {
+	tools := ToOllama([]Tool{{Function: ToolFunction{
+		Name:        "search",
+		Description: "Searches entries",
+		Parameters:  loadSchema(t, "constraints.json"),
+	}}})
+	golden(t, "constraints", renderOllama(tools[0]))
+}
@@ -0,0 +1,47 @@
+package tool
+
+import (
+	"regexp"
+	"strings"
+)
+
+// OpenAITool declares a function the model may call, in the format of the
+// OpenAI chat completions API
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction is the function of an OpenAITool
+type OpenAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  ParameterSchema `json:"parameters"`
+}
+
+// invalidFunctionChars matches characters not allowed in function names
+var invalidFunctionChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// OpenAIFunctionName maps a tool name such as "filesystem:read_file" to a
+// valid function name such as "filesystem__read_file"
+func OpenAIFunctionName(name string) string {
+	return invalidFunctionChars.ReplaceAllString(strings.ReplaceAll(name, ":", "__"), "_")
+}
+
+// ToOpenAI converts tools to OpenAI function definitions. The schema is
+// passed through whole, as the API accepts JSON schema; only names are
+// changed with OpenAIFunctionName.
+func ToOpenAI(tools []Tool) []OpenAITool {
+	defs := make([]OpenAITool, 0, len(tools))
+	for _, t := range tools {
+		defs = append(defs, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunction{
+				Name:        OpenAIFunctionName(t.Function.Name),
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			},
+		})
+	}
+	return defs
+}
@@ -0,0 +1,83 @@
+package tool
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToOpenAIRoundTrip(t *testing.T) {
+	tools := sampleTools()
+	data, err := json.Marshal(ToOpenAI(tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []OpenAITool
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tools) {
+		t.Fatalf("got %d tools, want %d", len(got), len(tools))
+	}
+
+	for i, def := range got {
+		want := tools[i].Function
+		if def.Type != "function" || def.Function.Description != want.Description {
+			t.Errorf("tool %d = %s %q", i, def.Type, def.Function.Description)
+		}
+		if def.Function.Name != OpenAIFunctionName(want.Name) {
+			t.Errorf("tool %d name = %q, want %q", i, def.Function.Name, OpenAIFunctionName(want.Name))
+		}
+		// The schema is passed through whole
+		if !reflect.DeepEqual(def.Function.Parameters, want.Parameters) {
+			t.Errorf("%s parameters changed in a round trip\ngot:  %+v\nwant: %+v", want.Name, def.Function.Parameters, want.Parameters)
+		}
+	}
+}
+
+func TestToOpenAIKeepsConstraintsAndUnions(t *testing.T) {
+	minimum, maximum := 1.0, 100.0
+	minLength := 3
+	tools := []Tool{{Function: ToolFunction{
+		Name: "search",
+		Parameters: ParameterSchema{
+			Type: "object",
+			Properties: map[string]PropertyDefinition{
+				"limit": {Type: "integer", Minimum: &minimum, Maximum: &maximum, Default: 10.0, Examples: []any{5.0}},
+				"query": {Type: "string", MinLength: &minLength, Pattern: "^[a-z]+$"},
+				"since": {Type: "string", Types: []string{"string", "null"}},
+				"target": {AnyOf: []PropertyDefinition{
+					{Type: "string", Description: "A path"},
+					{Type: "object", Properties: map[string]PropertyDefinition{"id": {Type: "integer"}}},
+				}},
+			},
+		},
+	}}}
+
+	data, err := json.Marshal(ToOpenAI(tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []OpenAITool
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got[0].Function.Parameters, tools[0].Function.Parameters) {
+		t.Errorf("parameters changed in a round trip\ngot:  %+v\nwant: %+v", got[0].Function.Parameters, tools[0].Function.Parameters)
+	}
+}
+
+func TestOpenAIFunctionName(t *testing.T) {
+	tests := map[string]string{
+		"read_file":            "read_file",
+		"filesystem:read_file": "filesystem__read_file",
+		"git-hub:search.code":  "git-hub__search_code",
+		"weird name/v2":        "weird_name_v2",
+		"ünïcode":              "_n_code",
+	}
+	for name, want := range tests {
+		if got := OpenAIFunctionName(name); got != want {
+			t.Errorf("OpenAIFunctionName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tool
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// DuplicatePolicy controls what Registry.Add does with a name already in use
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates makes Add fail
+	RejectDuplicates DuplicatePolicy = iota
+	// RenameDuplicates registers the tool as name_2, name_3, ...
+	RenameDuplicates
+)
+
+// Registry indexes tools by name and by original (unprefixed) name. Reads
+// work on immutable snapshots and never block; writes replace the snapshot.
+type Registry struct {
+	policy DuplicatePolicy
+
+	// writeLock serializes writers; readers only load state
+	writeLock sync.Mutex
+	state     atomic.Pointer[registryState]
+}
+
+// registryState is an immutable snapshot of the registry
+type registryState struct {
+	tools      []Tool
+	byName     map[string]int
+	byOriginal map[string][]int
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(policy DuplicatePolicy) *Registry {
+	r := &Registry{policy: policy}
+	r.state.Store(newRegistryState(nil))
+	return r
+}
+
+// newRegistryState indexes tools whose names are known to be unique
+func newRegistryState(tools []Tool) *registryState {
+	state := &registryState{
+		tools:      tools,
+		byName:     make(map[string]int, len(tools)),
+		byOriginal: make(map[string][]int),
+	}
+	for i, t := range tools {
+		state.byName[t.Name] = i
+		original := t.originalName()
+		state.byOriginal[original] = append(state.byOriginal[original], i)
+	}
+	return state
+}
+
+// Add registers a tool and returns the name it was registered under, which
+// differs from t.Name if the tool was renamed to avoid a collision
+func (r *Registry) Add(t Tool) (string, error) {
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	current := r.state.Load()
+	t, err := r.uniqueName(current.byName, t)
+	if err != nil {
+		return "", err
+	}
+
+	tools := make([]Tool, len(current.tools), len(current.tools)+1)
+	copy(tools, current.tools)
+	r.state.Store(newRegistryState(append(tools, t)))
+	return t.Name, nil
+}
+
+// Set replaces every tool, applying the duplicate policy among them
+func (r *Registry) Set(tools []Tool) error {
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	names := make(map[string]int, len(tools))
+	unique := make([]Tool, 0, len(tools))
+	for _, t := range tools {
+		t, err := r.uniqueName(names, t)
+		if err != nil {
+			return err
+		}
+		names[t.Name] = len(unique)
+		unique = append(unique, t)
+	}
+
+	r.state.Store(newRegistryState(unique))
+	return nil
+}
+
+// uniqueName applies the duplicate policy to a tool about to be added to
+// the names in use
+func (r *Registry) uniqueName(names map[string]int, t Tool) (Tool, error) {
+	if _, taken := names[t.Name]; !taken {
+		return t, nil
+	}
+	if r.policy == RejectDuplicates {
+		return t, fmt.Errorf("tool %s is already registered", t.Name)
+	}
+
+	if t.OriginalName == "" {
+		t.OriginalName = t.Name
+	}
+	for i := 2; ; i++ {
+		name := t.Name + "_" + strconv.Itoa(i)
+		if _, taken := names[name]; !taken {
+			t.Name = name
+			t.Function.Name = name
+			return t, nil
+		}
+	}
+}
+
+// Get returns the tool registered under name or, failing that, the only
+// tool with that original name
+func (r *Registry) Get(name string) (Tool, bool) {
+	state := r.state.Load()
+	if i, ok := state.byName[name]; ok {
+		return state.tools[i], true
+	}
+	if matches := state.byOriginal[name]; len(matches) == 1 {
+		return state.tools[matches[0]], true
+	}
+	return Tool{}, false
+}
+
+// List returns the tools for which filter returns true, in registration
+// order; a nil filter returns every tool
+func (r *Registry) List(filter func(Tool) bool) []Tool {
+	state := r.state.Load()
+	var tools []Tool
+	for _, t := range state.tools {
+		if filter == nil || filter(t) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// Snapshot returns every tool in registration order. The slice is shared
+// with other readers and must not be modified.
+func (r *Registry) Snapshot() []Tool {
+	return r.state.Load().tools
+}
+
+// Len returns the number of tools
+func (r *Registry) Len() int {
+	return len(r.state.Load().tools)
+}
+
+// Remove unregisters the tool with the given name and reports whether it existed
+func (r *Registry) Remove(name string) bool {
+	return r.removeWhere(func(t Tool) bool { return t.Name == name }) > 0
+}
+
+// RemoveServer unregisters every tool provided by the server and returns
+// how many were removed
+func (r *Registry) RemoveServer(server string) int {
+	return r.removeWhere(func(t Tool) bool { return t.Server == server })
+}
+
+// removeWhere unregisters the tools matching remove
+func (r *Registry) removeWhere(remove func(Tool) bool) int {
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	current := r.state.Load()
+	kept := make([]Tool, 0, len(current.tools))
+	for _, t := range current.tools {
+		if !remove(t) {
+			kept = append(kept, t)
+		}
+	}
+
+	removed := len(current.tools) - len(kept)
+	if removed > 0 {
+		r.state.Store(newRegistryState(kept))
+	}
+	return removed
+}
@@ -0,0 +1,139 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Part is one piece of a tool result: a TextPart, JSONPart or BinaryPart
+type Part interface {
+	isPart()
+}
+
+// TextPart is plain text
+type TextPart struct {
+	Text string
+}
+
+// JSONPart is structured data, kept as the raw message the tool returned
+type JSONPart struct {
+	Data json.RawMessage
+}
+
+// BinaryPart is binary content such as an image or audio clip
+type BinaryPart struct {
+	// MIMEType describes Data, e.g. image/png
+	MIMEType string
+
+	// Data is the raw (decoded) content
+	Data []byte
+}
+
+func (TextPart) isPart()   {}
+func (JSONPart) isPart()   {}
+func (BinaryPart) isPart() {}
+
+// Result is the outcome of a tool call
+type Result struct {
+	// Parts are the contents of the result, in the order the tool returned them
+	Parts []Part
+
+	// IsError reports that the tool ran but reported a failure, which the
+	// model should see as such
+	IsError bool
+}
+
+// TextResult creates a result holding a single text part
+func TextResult(text string) *Result {
+	return &Result{Parts: []Part{TextPart{Text: text}}}
+}
+
+// ErrorResult creates a result reporting a tool failure with the given text
+func ErrorResult(text string) *Result {
+	return &Result{Parts: []Part{TextPart{Text: text}}, IsError: true}
+}
+
+// Text renders the result as text: text parts as written, JSON parts as
+// their raw message and binary parts as a short placeholder
+func (r *Result) Text() string {
+	if r == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, part := range r.Parts {
+		switch p := part.(type) {
+		case TextPart:
+			sb.WriteString(p.Text)
+		case JSONPart:
+			sb.Write(p.Data)
+		case BinaryPart:
+			fmt.Fprintf(&sb, "[%s, %d bytes]", p.MIMEType, len(p.Data))
+		}
+	}
+	return sb.String()
+}
+
+// MustText returns the joined text parts, panicking if the result holds
+// any other kind of part
+func (r *Result) MustText() string {
+	if r == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, part := range r.Parts {
+		p, ok := part.(TextPart)
+		if !ok {
+			panic(fmt.Sprintf("tool result has a non-text part %T", part))
+		}
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
+
+// Binary returns the binary parts whose MIME type starts with prefix
+// (e.g. "image/"); an empty prefix matches every binary part
+func (r *Result) Binary(prefix string) []BinaryPart {
+	if r == nil {
+		return nil
+	}
+
+	var parts []BinaryPart
+	for _, part := range r.Parts {
+		if p, ok := part.(BinaryPart); ok && strings.HasPrefix(p.MIMEType, prefix) {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// TextExecutor is the executor signature used before results were typed
+type TextExecutor interface {
+	Execute(ctx context.Context, arguments map[string]any) (string, error)
+}
+
+// TextAdapter adapts a TextExecutor to ToolExecutor, wrapping its output
+// in a single text part
+type TextAdapter struct {
+	Executor TextExecutor
+}
+
+// Execute runs the wrapped executor
+func (a TextAdapter) Execute(ctx context.Context, arguments map[string]any) (*Result, error) {
+	text, err := a.Executor.Execute(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return TextResult(text), nil
+}
+
+// TextExecutorFunc adapts a plain function to TextExecutor
+type TextExecutorFunc func(ctx context.Context, arguments map[string]any) (string, error)
+
+// Execute calls f
+func (f TextExecutorFunc) Execute(ctx context.Context, arguments map[string]any) (string, error) {
+	return f(ctx, arguments)
+}
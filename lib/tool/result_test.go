@@ -0,0 +1,135 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var pngBytes = []byte("\x89PNG\r\n\x1a\n\x00\x00")
+
+func TestResultText(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *Result
+		text   string
+	}{
+		{"nil", nil, ""},
+		{"empty", &Result{}, ""},
+		{"text", TextResult("hello"), "hello"},
+		{"json", &Result{Parts: []Part{JSONPart{Data: json.RawMessage(`{"files":["a.go"]}`)}}}, `{"files":["a.go"]}`},
+		{"binary", &Result{Parts: []Part{BinaryPart{MIMEType: "image/png", Data: pngBytes}}}, "[image/png, 10 bytes]"},
+		{
+			name: "mixed parts keep their order",
+			result: &Result{Parts: []Part{
+				TextPart{Text: "Found: "},
+				JSONPart{Data: json.RawMessage(`[1,2]`)},
+				TextPart{Text: " and "},
+				BinaryPart{MIMEType: "audio/wav", Data: []byte{1, 2, 3}},
+			}},
+			text: "Found: [1,2] and [audio/wav, 3 bytes]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Text(); got != tt.text {
+				t.Errorf("Text() = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestResultMustText(t *testing.T) {
+	var nilResult *Result
+	if got := nilResult.MustText(); got != "" {
+		t.Errorf("nil MustText() = %q", got)
+	}
+	joined := &Result{Parts: []Part{TextPart{Text: "one, "}, TextPart{Text: "two"}}}
+	if got := joined.MustText(); got != "one, two" {
+		t.Errorf("MustText() = %q, want the joined text parts", got)
+	}
+
+	for _, part := range []Part{JSONPart{Data: json.RawMessage(`{}`)}, BinaryPart{MIMEType: "image/png"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MustText() with a %T did not panic", part)
+				}
+			}()
+			(&Result{Parts: []Part{TextPart{Text: "ok"}, part}}).MustText()
+		}()
+	}
+}
+
+func TestResultBinary(t *testing.T) {
+	png := BinaryPart{MIMEType: "image/png", Data: pngBytes}
+	jpeg := BinaryPart{MIMEType: "image/jpeg", Data: []byte{0xff, 0xd8}}
+	wav := BinaryPart{MIMEType: "audio/wav", Data: []byte{1}}
+	result := &Result{Parts: []Part{png, TextPart{Text: "caption"}, wav, jpeg}}
+
+	tests := map[string][]BinaryPart{
+		"image/":    {png, jpeg},
+		"audio/":    {wav},
+		"":          {png, wav, jpeg},
+		"image/png": {png},
+		"video/":    nil,
+	}
+	for prefix, want := range tests {
+		if got := result.Binary(prefix); !reflect.DeepEqual(got, want) {
+			t.Errorf("Binary(%q) = %v, want %v", prefix, got, want)
+		}
+	}
+	var nilResult *Result
+	if got := nilResult.Binary(""); got != nil {
+		t.Errorf("nil Binary() = %v", got)
+	}
+}
+
+func TestResultConstructors(t *testing.T) {
+	if got := TextResult("done"); got.IsError || !reflect.DeepEqual(got.Parts, []Part{TextPart{Text: "done"}}) {
+		t.Errorf("TextResult() = %+v", got)
+	}
+	if got := ErrorResult("no such file"); !got.IsError || got.Text() != "no such file" {
+		t.Errorf("ErrorResult() = %+v", got)
+	}
+}
+
+type ctxKey struct{}
+
+func TestTextAdapter(t *testing.T) {
+	var gotArgs map[string]any
+	var gotValue any
+	adapter := TextAdapter{Executor: TextExecutorFunc(func(ctx context.Context, arguments map[string]any) (string, error) {
+		gotArgs, gotValue = arguments, ctx.Value(ctxKey{})
+		if arguments["fail"] == true {
+			return "ignored", errors.New("boom")
+		}
+		return "plain text", nil
+	})}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "passed")
+	result, err := adapter.Execute(ctx, map[string]any{"path": "a.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || !reflect.DeepEqual(result.Parts, []Part{TextPart{Text: "plain text"}}) {
+		t.Errorf("result = %+v, want a single text part", result)
+	}
+	if gotArgs["path"] != "a.go" || gotValue != "passed" {
+		t.Errorf("the executor got %v and %v, want the arguments and context", gotArgs, gotValue)
+	}
+
+	result, err = adapter.Execute(ctx, map[string]any{"fail": true})
+	if err == nil || err.Error() != "boom" || result != nil {
+		t.Errorf("got %+v, %v; want the executor's error and no result", result, err)
+	}
+
+	// An adapted executor works as a tool's executor
+	legacy := &Tool{Name: "legacy", Executor: adapter}
+	result, err = legacy.Execute(context.Background(), nil)
+	if err != nil || result.MustText() != "plain text" {
+		t.Errorf("tool result = %+v, %v", result, err)
+	}
+}
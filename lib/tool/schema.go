@@ -0,0 +1,152 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// propertyAlias has the fields of PropertyDefinition without its JSON methods
+type propertyAlias PropertyDefinition
+
+// UnmarshalJSON accepts a type written as a string or as a union of types
+func (p *PropertyDefinition) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		propertyAlias
+		Type any `json:"type"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*p = PropertyDefinition(wire.propertyAlias)
+
+	switch t := wire.Type.(type) {
+	case nil:
+	case string:
+		p.Type = t
+	case []any:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("type union must list type names, got %v", v)
+			}
+			p.Types = append(p.Types, name)
+		}
+		p.Type = primaryType(p.Types)
+		if len(p.Types) == 1 {
+			p.Types = nil
+		}
+	default:
+		return fmt.Errorf("type must be a string or an array of strings, got %T", wire.Type)
+	}
+	return nil
+}
+
+// MarshalJSON writes a union back as an array of types and omits an empty type
+func (p PropertyDefinition) MarshalJSON() ([]byte, error) {
+	wire := struct {
+		propertyAlias
+		Type any `json:"type,omitempty"`
+	}{propertyAlias: propertyAlias(p)}
+
+	switch {
+	case len(p.Types) > 1:
+		wire.Type = p.Types
+	case p.Type != "":
+		wire.Type = p.Type
+	}
+	return json.Marshal(wire)
+}
+
+// PrimaryType returns the type to present for the property: its own type,
+// or the first non-null type among its anyOf/oneOf branches
+func (p PropertyDefinition) PrimaryType() string {
+	if p.Type != "" {
+		return p.Type
+	}
+
+	var types []string
+	for _, branch := range p.branches() {
+		if t := branch.PrimaryType(); t != "" {
+			types = append(types, t)
+		}
+	}
+	return primaryType(types)
+}
+
+// IsUnion reports whether the property accepts more than one kind of value
+func (p PropertyDefinition) IsUnion() bool {
+	return len(p.Types) > 1 || len(p.branches()) > 1
+}
+
+// DescribeType describes the values the property accepts, e.g. "a string
+// or null"
+func (p PropertyDefinition) DescribeType() string {
+	var alternatives []string
+	switch {
+	case len(p.Enum) > 0:
+		return "one of " + formatEnum(p.Enum)
+	case len(p.Types) > 0:
+		for _, t := range p.Types {
+			alternatives = append(alternatives, p.describeSingleType(t))
+		}
+	case len(p.branches()) > 0:
+		// Branches differing only in their properties read the same
+		for _, branch := range p.branches() {
+			if description := branch.DescribeType(); !slices.Contains(alternatives, description) {
+				alternatives = append(alternatives, description)
+			}
+		}
+	case p.Type != "":
+		return p.describeSingleType(p.Type)
+	default:
+		return "any value"
+	}
+	return strings.Join(alternatives, " or ")
+}
+
+// describeSingleType describes one of the property's types, naming the
+// element type of arrays
+func (p PropertyDefinition) describeSingleType(t string) string {
+	if t == "array" && p.Items != nil && p.Items.PrimaryType() != "" {
+		return "an array of " + p.Items.PrimaryType()
+	}
+	return withArticle(t)
+}
+
+// branches returns the anyOf and oneOf alternatives
+func (p PropertyDefinition) branches() []PropertyDefinition {
+	if len(p.OneOf) == 0 {
+		return p.AnyOf
+	}
+	return append(append([]PropertyDefinition(nil), p.AnyOf...), p.OneOf...)
+}
+
+// matchesType reports whether value has the property's type, or one of
+// the types of its union
+func (p PropertyDefinition) matchesType(value any) bool {
+	if len(p.Types) == 0 {
+		return p.Type == "" || matchesType(p.Type, value)
+	}
+	for _, t := range p.Types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryType returns the first type that is not null, or null if that is
+// the only one
+func primaryType(types []string) string {
+	for _, t := range types {
+		if t != "null" {
+			return t
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
@@ -0,0 +1,282 @@
+package tool
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPropertyDefinitionUnions(t *testing.T) {
+	tests := []struct {
+		schema   string
+		primary  string
+		union    bool
+		describe string
+		// marshaled is the property written back (empty: as given)
+		marshaled string
+	}{
+		{`{"type":"string"}`, "string", false, "a string", ""},
+		{`{"type":["string","null"]}`, "string", true, "a string or null", ""},
+		{`{"type":["null","integer"]}`, "integer", true, "null or an integer", ""},
+		{`{"type":["null"]}`, "null", false, "null", `{"type":"null"}`},
+		{`{"type":["array","string"],"items":{"type":"integer"}}`, "array", true, "an array of integer or a string", ""},
+		{`{"anyOf":[{"type":"string"},{"type":"null"}]}`, "string", true, "a string or null", ""},
+		{`{"oneOf":[{"type":"object"},{"type":"array","items":{"type":"string"}}]}`, "object", true, "an object or an array of string", ""},
+		{`{"type":"string","enum":["a","b"]}`, "string", false, `one of ["a", "b"]`, ""},
+		{`{"anyOf":[{"type":"object","required":["line"]},{"type":"object","required":["position"]}]}`, "object", true, "an object", ""},
+		{`{}`, "", false, "any value", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			var prop PropertyDefinition
+			if err := json.Unmarshal([]byte(tt.schema), &prop); err != nil {
+				t.Fatal(err)
+			}
+			if got := prop.PrimaryType(); got != tt.primary {
+				t.Errorf("PrimaryType() = %q, want %q", got, tt.primary)
+			}
+			if got := prop.IsUnion(); got != tt.union {
+				t.Errorf("IsUnion() = %v, want %v", got, tt.union)
+			}
+			if got := prop.DescribeType(); got != tt.describe {
+				t.Errorf("DescribeType() = %q, want %q", got, tt.describe)
+			}
+
+			data, err := json.Marshal(prop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.marshaled
+			if want == "" {
+				want = tt.schema
+			}
+			if !jsonEqual(t, data, []byte(want)) {
+				t.Errorf("marshaled %s, want %s", data, want)
+			}
+		})
+	}
+
+	for _, bad := range []string{`{"type":3}`, `{"type":["string",1]}`} {
+		var prop PropertyDefinition
+		if err := json.Unmarshal([]byte(bad), &prop); err == nil {
+			t.Errorf("%s: decoded, want an error", bad)
+		}
+	}
+}
+
+// jsonEqual reports whether a and b hold the same JSON value
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// serverTools is a tools/list result of an MCP server
+type serverTools struct {
+	Tools []struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		InputSchema json.RawMessage `json:"inputSchema"`
+	} `json:"tools"`
+}
+
+// serverFixtures are the tool lists in testdata/servers, modeled on the
+// schemas the GitHub and memory MCP servers publish
+var serverFixtures = []string{"github", "memory"}
+
+// loadServerTools decodes a server's tool list the way the MCP client
+// does, naming each tool "server:tool". It also returns each tool's input
+// schema as given.
+func loadServerTools(t *testing.T, server string) ([]Tool, map[string]json.RawMessage) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "servers", server+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list serverTools
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("failed to decode %s tools: %v", server, err)
+	}
+
+	tools := make([]Tool, 0, len(list.Tools))
+	schemas := make(map[string]json.RawMessage, len(list.Tools))
+	for _, mcpTool := range list.Tools {
+		name := server + ":" + mcpTool.Name
+		var params ParameterSchema
+		if err := json.Unmarshal(mcpTool.InputSchema, &params); err != nil {
+			t.Fatalf("failed to convert the schema of %s: %v", name, err)
+		}
+		tools = append(tools, Tool{
+			Name:        name,
+			Description: mcpTool.Description,
+			Server:      server,
+			Function:    ToolFunction{Name: name, Description: mcpTool.Description, Parameters: params},
+		})
+		schemas[name] = mcpTool.InputSchema
+	}
+	return tools, schemas
+}
+
+func TestServerSchemasToOllama(t *testing.T) {
+	jsonTypes := map[string]bool{"string": true, "number": true, "integer": true, "boolean": true, "array": true, "object": true}
+	for _, server := range serverFixtures {
+		t.Run(server, func(t *testing.T) {
+			tools, _ := loadServerTools(t, server)
+			converted := ToOllama(tools)
+
+			// Ollama rejects a tool whose property has no single JSON type
+			var sb strings.Builder
+			for _, ollamaTool := range converted {
+				for name, prop := range ollamaTool.Function.Parameters.Properties {
+					if len(prop.Type) != 1 || !jsonTypes[prop.Type[0]] {
+						t.Errorf("%s.%s has type %v", ollamaTool.Function.Name, name, prop.Type)
+					}
+				}
+				if _, err := json.Marshal(ollamaTool); err != nil {
+					t.Errorf("%s cannot be sent: %v", ollamaTool.Function.Name, err)
+				}
+				sb.WriteString(renderOllama(ollamaTool))
+				sb.WriteString("\n")
+			}
+			golden(t, filepath.Join("servers", server), sb.String())
+		})
+	}
+}
+
+// dropUnmodeled removes the schema keywords ParameterSchema does not keep,
+// and empty property lists, which are written back as absent
+func dropUnmodeled(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		delete(v, "$schema")
+		delete(v, "additionalProperties")
+		if props, ok := v["properties"].(map[string]any); ok && len(props) == 0 {
+			delete(v, "properties")
+		}
+		for key, value := range v {
+			v[key] = dropUnmodeled(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = dropUnmodeled(value)
+		}
+	}
+	return v
+}
+
+func TestServerSchemasToOpenAI(t *testing.T) {
+	for _, server := range serverFixtures {
+		t.Run(server, func(t *testing.T) {
+			tools, schemas := loadServerTools(t, server)
+			for i, openAITool := range ToOpenAI(tools) {
+				name := tools[i].Name
+				if want := strings.ReplaceAll(name, ":", "__"); openAITool.Function.Name != want {
+					t.Errorf("%s is sent as %s, want %s", name, openAITool.Function.Name, want)
+				}
+
+				// Unions and anyOf branches reach OpenAI as the server wrote them
+				sent, err := json.Marshal(openAITool.Function.Parameters)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got, want any
+				if err := json.Unmarshal(sent, &got); err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(schemas[name], &want); err != nil {
+					t.Fatal(err)
+				}
+				if want = dropUnmodeled(want); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s parameters changed\ngot:  %s\nwant: %s", name, sent, formatValue(want))
+				}
+			}
+		})
+	}
+}
+
+func TestServerSchemasValidation(t *testing.T) {
+	tools := map[string]Tool{}
+	for _, server := range serverFixtures {
+		list, _ := loadServerTools(t, server)
+		for _, tool := range list {
+			tools[tool.Name] = tool
+		}
+	}
+
+	tests := []struct {
+		tool string
+		args string
+		// errors are the expected validation errors, in order
+		errors []string
+	}{
+		{
+			tool: "memory:create_entities",
+			args: `{"entities":[{"name":"Ada","entityType":"person","observations":["wrote the first program"]}]}`,
+		},
+		{
+			tool:   "memory:create_entities",
+			args:   `{"entities":[{"name":"Ada","observations":"wrote the first program"}]}`,
+			errors: []string{"argument 'entities[0].observations' must be an array of string, got string \"wrote the first program\"", "required argument 'entities[0].entityType' is missing"},
+		},
+		{tool: "memory:read_graph", args: `{}`},
+		{
+			tool: "github:create_or_update_file",
+			args: `{"owner":"o","repo":"r","path":"a.md","content":"hi","message":"Add a.md","branch":"main","sha":null}`,
+		},
+		{
+			tool: "github:create_or_update_file",
+			args: `{"owner":"o","repo":"r","path":"a.md","content":"hi","message":"Update a.md","branch":"main","sha":"abc123"}`,
+		},
+		{
+			tool:   "github:create_or_update_file",
+			args:   `{"owner":"o","repo":"r","path":"a.md","content":"hi","message":"m","branch":"main","sha":42}`,
+			errors: []string{"argument 'sha' must be a string or null, got number 42"},
+		},
+		{tool: "github:update_issue", args: `{"owner":"o","repo":"r","issue_number":7,"milestone":null,"state":"closed"}`},
+		{
+			tool:   "github:list_issues",
+			args:   `{"owner":"o","repo":"r","per_page":500,"state":"merged"}`,
+			errors: []string{"argument 'per_page' must be at most 100, got number 500", `argument 'state' must be one of ["open", "closed", "all"], got string "merged"`},
+		},
+		{
+			tool: "github:create_pull_request_review",
+			args: `{"owner":"o","repo":"r","pull_number":1,"body":"LGTM","event":"COMMENT","comments":[{"path":"a.go","position":3,"body":"nit"},{"path":"b.go","line":10,"body":"typo"}]}`,
+		},
+		{
+			tool:   "github:create_pull_request_review",
+			args:   `{"owner":"o","repo":"r","pull_number":1,"body":"LGTM","event":"COMMENT","comments":["looks good"]}`,
+			errors: []string{`argument 'comments[0]' must be an object, got string "looks good"`},
+		},
+		{tool: "github:get_file_contents", args: `{"owner":"o","repo":"r","path":"go.mod","ref":null}`},
+		{tool: "github:get_file_contents", args: `{"owner":"o","repo":"r","path":"go.mod","ref":"v1.0.0"}`},
+		{
+			tool:   "github:get_file_contents",
+			args:   `{"owner":"o","repo":"r","path":"go.mod","ref":["main"]}`,
+			errors: []string{`argument 'ref' must be a string or null, got array`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			var args map[string]any
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, err := range tools[tt.tool].Function.Parameters.ValidateArguments(args) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.errors) {
+				t.Errorf("%s\ngot errors:  %q\nwant errors: %q", tt.args, got, tt.errors)
+			}
+		})
+	}
+}
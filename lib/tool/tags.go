@@ -0,0 +1,152 @@
+package tool
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Hint tags derived from the annotations of MCP tools. They describe
+// behavior, not subject, and are not used as categories.
+const (
+	TagReadOnly    = "read-only"
+	TagDestructive = "destructive"
+	TagIdempotent  = "idempotent"
+	TagOpenWorld   = "open-world"
+)
+
+// IsHintTag reports whether tag is one of the annotation-derived hint tags
+func IsHintTag(tag string) bool {
+	switch tag {
+	case TagReadOnly, TagDestructive, TagIdempotent, TagOpenWorld:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasTag reports whether the tool carries the tag
+func (t Tool) HasTag(tag string) bool {
+	for _, own := range t.Tags {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTag returns a Registry.List filter selecting tools with the tag
+func WithTag(tag string) func(Tool) bool {
+	return func(t Tool) bool {
+		return t.HasTag(tag)
+	}
+}
+
+// Category returns the group the tool is listed under: its first tag that
+// is not a hint tag, else its server, else the prefix of a "server:tool"
+// name, else "tools"
+func (t Tool) Category() string {
+	for _, tag := range t.Tags {
+		if !IsHintTag(tag) {
+			return tag
+		}
+	}
+	if t.Server != "" {
+		return t.Server
+	}
+	if server, _, ok := strings.Cut(t.Name, ":"); ok {
+		return server
+	}
+	return "tools"
+}
+
+// Category is a named group of tools
+type Category struct {
+	Name  string
+	Tools []Tool
+}
+
+// Categorize partitions tools by Tool.Category. Categories are sorted by
+// name; tools keep their order within a category.
+func Categorize(tools []Tool) []Category {
+	index := make(map[string]int)
+	var categories []Category
+	for _, t := range tools {
+		name := t.Category()
+		i, ok := index[name]
+		if !ok {
+			i = len(categories)
+			index[name] = i
+			categories = append(categories, Category{Name: name})
+		}
+		categories[i].Tools = append(categories[i].Tools, t)
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories
+}
+
+// RankFunc scores how relevant a tool is to a query; higher is more relevant
+type RankFunc func(t Tool, query string) float64
+
+// KeywordRank counts the words of the query, longer than two letters, that
+// appear in the tool's name, description or tags
+func KeywordRank(t Tool, query string) float64 {
+	haystack := strings.ToLower(t.Name + " " + t.Description + " " + strings.Join(t.Tags, " "))
+
+	score := 0.0
+	for _, word := range queryWords(query) {
+		if strings.Contains(haystack, word) {
+			score++
+		}
+	}
+	return score
+}
+
+// queryWords splits a query into lower-case words longer than two letters
+func queryWords(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := fields[:0]
+	for _, word := range fields {
+		if len([]rune(word)) > 2 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// SelectTools returns at most max tools, the most relevant to query by
+// rank (nil: KeywordRank), in their original order. Ties keep the earlier
+// tool. All tools are returned when there are no more than max.
+func SelectTools(tools []Tool, query string, max int, rank RankFunc) []Tool {
+	if max <= 0 || len(tools) <= max {
+		return tools
+	}
+	if rank == nil {
+		rank = KeywordRank
+	}
+
+	order := make([]int, len(tools))
+	scores := make([]float64, len(tools))
+	for i, t := range tools {
+		order[i] = i
+		scores[i] = rank(t, query)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	chosen := order[:max]
+	sort.Ints(chosen)
+
+	selected := make([]Tool, len(chosen))
+	for i, index := range chosen {
+		selected[i] = tools[index]
+	}
+	return selected
+}
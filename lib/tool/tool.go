@@ -5,9 +5,10 @@ import (
 	"fmt"
 )
 
-// ToolExecutor defines the interface for executing tools
+// ToolExecutor defines the interface for executing tools. Executors that
+// return plain text can be wrapped in a TextAdapter.
 type ToolExecutor interface {
-	Execute(ctx context.Context, arguments map[string]any) (string, error)
+	Execute(ctx context.Context, arguments map[string]any) (*Result, error)
 }
 
 // Tool represents a common tool structure that can be used across different APIs
@@ -26,12 +27,41 @@ type Tool struct {
 
 	// Executor for the tool (not serialized)
 	Executor ToolExecutor `json:"-"`
+
+	// Server is the ID of the MCP server providing the tool, if any
+	Server string `json:"-"`
+
+	// OriginalName is the name the tool had before it was namespaced or
+	// renamed (empty: Name)
+	OriginalName string `json:"-"`
+
+	// Tags group and filter tools: the server's configured tags, then hint
+	// tags such as TagReadOnly derived from MCP annotations
+	Tags []string `json:"-"`
+
+	// ReadOnly marks a tool that does not modify its environment, and
+	// Destructive one that may modify or delete data; destructive tools can
+	// be made to ask first with RequireConfirmation
+	ReadOnly    bool `json:"-"`
+	Destructive bool `json:"-"`
+}
+
+// originalName returns the tool's original name
+func (t Tool) originalName() string {
+	if t.OriginalName != "" {
+		return t.OriginalName
+	}
+	return t.Name
 }
 
 // Execute executes the tool with the given arguments
-func (t *Tool) Execute(ctx context.Context, arguments map[string]any) (string, error) {
+func (t *Tool) Execute(ctx context.Context, arguments map[string]any) (*Result, error) {
 	if t.Executor == nil {
-		return "", fmt.Errorf("no executor available for tool %s", t.Name)
+		return nil, fmt.Errorf("no executor available for tool %s", t.Name)
+	}
+	ctx = ContextWithToolName(ctx, t.Name)
+	if t.Server != "" {
+		ctx = ContextWithToolServer(ctx, t.Server)
 	}
 	return t.Executor.Execute(ctx, arguments)
 }
@@ -66,17 +96,48 @@ type ParameterSchema struct {
 	Items any `json:"items,omitempty"`
 }
 
-// PropertyDefinition represents a single property in the parameter schema
+// PropertyDefinition represents a single property in the parameter schema.
+// Type unions and anyOf/oneOf branches are kept; see schema.go.
 type PropertyDefinition struct {
-	// The type of the property
+	// The type of the property; for a union, its primary non-null type
 	Type string `json:"type"`
 
+	// Types lists every allowed type of a union such as ["string", "null"]
+	Types []string `json:"-"`
+
+	// AnyOf and OneOf are alternative schemas for the property
+	AnyOf []PropertyDefinition `json:"anyOf,omitempty"`
+	OneOf []PropertyDefinition `json:"oneOf,omitempty"`
+
 	// Description of the property
 	Description string `json:"description,omitempty"`
 
-	// Items for array types
-	Items any `json:"items,omitempty"`
+	// Properties of object types
+	Properties map[string]PropertyDefinition `json:"properties,omitempty"`
+
+	// Required property names of object types
+	Required []string `json:"required,omitempty"`
+
+	// Items is the schema of the elements of array types
+	Items *PropertyDefinition `json:"items,omitempty"`
 
 	// Enum values for the property
 	Enum []any `json:"enum,omitempty"`
+
+	// Default is the value used when the property is omitted
+	Default any `json:"default,omitempty"`
+
+	// Minimum and Maximum bound numeric values (inclusive)
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	// MinLength and MaxLength bound the length of strings, in characters
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+
+	// Pattern is a regular expression strings must match
+	Pattern string `json:"pattern,omitempty"`
+
+	// Examples are sample values for the property
+	Examples []any `json:"examples,omitempty"`
 }
@@ -0,0 +1,398 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationErrorKind classifies a ValidationError
+type ValidationErrorKind int
+
+const (
+	// KindMissing is a required argument that was not given
+	KindMissing ValidationErrorKind = iota
+	// KindType is an argument of the wrong JSON type
+	KindType
+	// KindEnum is an argument outside its enum
+	KindEnum
+	// KindUnknown is an argument the schema does not declare
+	KindUnknown
+	// KindRange is a number outside its minimum or maximum
+	KindRange
+	// KindLength is a string shorter or longer than allowed
+	KindLength
+	// KindPattern is a string not matching its pattern
+	KindPattern
+)
+
+// UnknownPolicy controls how arguments not declared in the schema are reported
+type UnknownPolicy int
+
+const (
+	// UnknownWarn reports unknown arguments as warnings
+	UnknownWarn UnknownPolicy = iota
+	// UnknownReject reports unknown arguments as errors
+	UnknownReject
+	// UnknownIgnore does not report unknown arguments
+	UnknownIgnore
+)
+
+// ValidationOptions configures ValidateArgumentsWith
+type ValidationOptions struct {
+	// Unknown controls how undeclared arguments are reported (default: UnknownWarn).
+	// Schemas without properties accept any argument.
+	Unknown UnknownPolicy
+}
+
+// ValidationError describes one problem with the arguments of a tool call
+type ValidationError struct {
+	// Kind classifies the problem
+	Kind ValidationErrorKind
+
+	// Path locates the argument, e.g. "path" or "edits[0].old_text"
+	Path string
+
+	// Expected describes what the schema asks for, e.g. "a string"
+	Expected string
+
+	// Got describes the value that was given, e.g. "number 3"
+	Got string
+
+	// Warning marks a problem that does not prevent the call
+	Warning bool
+}
+
+// Error describes the problem in a sentence
+func (e ValidationError) Error() string {
+	switch e.Kind {
+	case KindMissing:
+		return fmt.Sprintf("required argument '%s' is missing", e.Path)
+	case KindUnknown:
+		return fmt.Sprintf("unknown argument '%s'", e.Path)
+	default:
+		return fmt.Sprintf("argument '%s' must be %s, got %s", e.Path, e.Expected, e.Got)
+	}
+}
+
+// HasErrors reports whether errs holds anything other than warnings
+func HasErrors(errs []ValidationError) bool {
+	for _, err := range errs {
+		if !err.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatForModel renders the errors (not the warnings) as a short
+// instruction a model can act on, or an empty string if there are none
+func FormatForModel(errs []ValidationError) string {
+	var problems []string
+	for _, err := range errs {
+		if !err.Warning {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+	return strings.Join(problems, "; ") +
+		". Correct the arguments according to the tool's parameter schema and call the tool again."
+}
+
+// ValidateArguments checks arguments against the schema, reporting unknown
+// arguments as warnings
+func (s ParameterSchema) ValidateArguments(args map[string]any) []ValidationError {
+	return s.ValidateArgumentsWith(args, ValidationOptions{})
+}
+
+// ValidateArgumentsWith checks arguments against the schema: required
+// arguments, JSON types, enums, numeric ranges, string lengths and patterns
+// and, depending on opts, unknown arguments.
+// Nested objects and array elements are checked against their own schemas.
+// Errors are ordered by argument name, followed by missing arguments.
+func (s ParameterSchema) ValidateArgumentsWith(args map[string]any, opts ValidationOptions) []ValidationError {
+	root := PropertyDefinition{Type: "object", Properties: s.Properties, Required: s.Required}
+	return root.validateObject("", args, opts)
+}
+
+// validateValue checks a value against the property's schema
+func (p PropertyDefinition) validateValue(path string, value any, opts ValidationOptions) []ValidationError {
+	if !p.matchesType(value) {
+		return []ValidationError{{
+			Kind:     KindType,
+			Path:     path,
+			Expected: p.DescribeType(),
+			Got:      describeValue(value),
+		}}
+	}
+
+	// A value matching any of the alternatives is accepted
+	if branches := p.branches(); len(branches) > 0 {
+		matched := false
+		for _, branch := range branches {
+			if !HasErrors(branch.validateValue(path, value, opts)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return []ValidationError{{
+				Kind:     KindType,
+				Path:     path,
+				Expected: p.DescribeType(),
+				Got:      describeValue(value),
+			}}
+		}
+	}
+
+	errs := p.validateConstraints(path, value)
+	if len(p.Enum) > 0 && !inEnum(p.Enum, value) {
+		errs = append(errs, ValidationError{
+			Kind:     KindEnum,
+			Path:     path,
+			Expected: "one of " + formatEnum(p.Enum),
+			Got:      describeValue(value),
+		})
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		errs = append(errs, p.validateObject(path, v, opts)...)
+	case []any:
+		if p.Items != nil {
+			for i, item := range v {
+				errs = append(errs, p.Items.validateValue(fmt.Sprintf("%s[%d]", path, i), item, opts)...)
+			}
+		}
+	}
+	return errs
+}
+
+// validateConstraints checks a number against the property's range and a
+// string against its length bounds and pattern
+func (p PropertyDefinition) validateConstraints(path string, value any) []ValidationError {
+	var errs []ValidationError
+	constraint := func(kind ValidationErrorKind, expected string) {
+		errs = append(errs, ValidationError{Kind: kind, Path: path, Expected: expected, Got: describeValue(value)})
+	}
+
+	if f, ok := toFloat(value); ok {
+		if p.Minimum != nil && f < *p.Minimum {
+			constraint(KindRange, "at least "+formatNumber(*p.Minimum))
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			constraint(KindRange, "at most "+formatNumber(*p.Maximum))
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		length := utf8.RuneCountInString(s)
+		if p.MinLength != nil && length < *p.MinLength {
+			constraint(KindLength, fmt.Sprintf("at least %d characters long", *p.MinLength))
+		}
+		if p.MaxLength != nil && length > *p.MaxLength {
+			constraint(KindLength, fmt.Sprintf("at most %d characters long", *p.MaxLength))
+		}
+		// Patterns Go cannot compile (e.g. with lookaheads) are not enforced
+		if p.Pattern != "" {
+			if re, err := regexp.Compile(p.Pattern); err == nil && !re.MatchString(s) {
+				constraint(KindPattern, fmt.Sprintf("a string matching %s", p.Pattern))
+			}
+		}
+	}
+
+	return errs
+}
+
+// formatNumber renders a schema bound without a trailing .0 or exponent
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// validateObject checks the members of an object against the property's
+// schema; path is the object's own path, empty for the arguments themselves
+func (p PropertyDefinition) validateObject(path string, object map[string]any, opts ValidationOptions) []ValidationError {
+	var errs []ValidationError
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := object[name]
+		prop, ok := p.Properties[name]
+		if !ok {
+			if opts.Unknown != UnknownIgnore && len(p.Properties) > 0 {
+				errs = append(errs, ValidationError{
+					Kind:     KindUnknown,
+					Path:     joinPath(path, name),
+					Expected: "no such argument",
+					Got:      describeValue(value),
+					Warning:  opts.Unknown == UnknownWarn,
+				})
+			}
+			continue
+		}
+		errs = append(errs, prop.validateValue(joinPath(path, name), value, opts)...)
+	}
+
+	for _, name := range p.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, ValidationError{
+				Kind:     KindMissing,
+				Path:     joinPath(path, name),
+				Expected: "a value",
+				Got:      "nothing",
+			})
+		}
+	}
+
+	return errs
+}
+
+// joinPath appends a member name to an argument path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// matchesType reports whether a JSON-decoded value matches a JSON schema type
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		// Decoded JSON numbers are float64, so 3.0 counts as an integer
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	case "array":
+		if value == nil {
+			return false
+		}
+		kind := reflect.TypeOf(value).Kind()
+		return kind == reflect.Slice || kind == reflect.Array
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		// Unknown types are not ours to reject
+		return true
+	}
+}
+
+// toFloat converts any Go numeric value, or a json.Number, to float64
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// inEnum reports whether value equals one of the enum values
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+		ef, eok := toFloat(e)
+		vf, vok := toFloat(value)
+		if eok && vok && ef == vf {
+			return true
+		}
+	}
+	return false
+}
+
+// describeValue renders a value with its JSON type for error messages
+func describeValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case map[string]any:
+		return "object"
+	}
+	if f, ok := toFloat(value); ok {
+		return fmt.Sprintf("number %v", f)
+	}
+	if kind := reflect.TypeOf(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// formatEnum renders enum values as a comma-separated list
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		if s, ok := e.(string); ok {
+			values[i] = fmt.Sprintf("%q", s)
+		} else {
+			values[i] = fmt.Sprintf("%v", e)
+		}
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// withArticle prefixes a type name with "a" or "an"
+func withArticle(typeName string) string {
+	switch typeName {
+	case "null":
+		return typeName
+	case "array", "object", "integer":
+		return "an " + typeName
+	default:
+		return "a " + typeName
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logToFile is set when logs go to a log file rather than standard error
+var logToFile bool
+
+// logOptions chooses where logs go and how much is logged
+type logOptions struct {
+	// level is the -log-level name; it wins over quiet and verbose
+	level string
+
+	// file is the file logs are appended to (empty: standard error)
+	file string
+
+	quiet bool
+}
+
+// logFlags defines the flags that control logging
+func logFlags(flags *flag.FlagSet, opts *logOptions) {
+	flags.StringVar(&opts.level, "log-level", "", "lowest level logged: debug, info, warn or error (default: warn, info with -verbose)")
+	flags.StringVar(&opts.file, "log-file", "", "append logs to this file instead of standard error")
+	flags.BoolVar(&opts.quiet, "quiet", false, "log only errors")
+}
+
+// parseLogLevel parses a -log-level name
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(name))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, use debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// setupLogging routes all logging, including the log package's, to the
+// log file or standard error, leaving standard output to the conversation.
+// An explicit -log-level wins over -quiet, which wins over -verbose; the
+// default level is fallback. The returned function closes the log file.
+func setupLogging(opts logOptions, verbose bool, fallback slog.Level) (func(), error) {
+	level := fallback
+	switch {
+	case opts.level != "":
+		var err error
+		if level, err = parseLogLevel(opts.level); err != nil {
+			return nil, err
+		}
+	case opts.quiet:
+		level = slog.LevelError
+	case verbose:
+		level = min(level, slog.LevelInfo)
+	}
+
+	var w io.Writer = os.Stderr
+	closeLog := func() {}
+	if opts.file != "" {
+		file, err := os.OpenFile(opts.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w = file
+		logToFile = true
+		closeLog = func() { file.Close() }
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+	return closeLog, nil
+}
+
+// fatalf reports an error on standard error, and in the log file if there
+// is one, and exits
+func fatalf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, msg)
+	if logToFile {
+		slog.Error(msg)
+	}
+	os.Exit(1)
+}
@@ -2,119 +2,343 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/ollama/ollama/api"
+	"github.com/snowmerak/ttobot/lib/llm"
 	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
-	"github.com/snowmerak/ttobot/pkg/mcp"
-	"github.com/snowmerak/ttobot/pkg/ollama"
 )
 
 func main() {
-	// Check command line arguments
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: ./ttobot \"your question here\"")
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:]); err != nil {
+			fatalf("Failed to initialize config: %v", err)
+		}
+		return
 	}
-
-	userQuery := strings.Join(os.Args[1:], " ")
-	ctx := context.Background()
-
-	// Load configuration
-	configs, ollamaConfig, err := mcpConfig.LoadConfigWithOllamaFromFile("mcp.yaml")
-	if err != nil {
-		configs, err = mcpConfig.LoadConfigFromDefaultPath()
-		if err != nil {
-			configs = []mcpConfig.Config{
-				{
-					Name:    "memory-server",
-					Command: "npx",
-					Args:    []string{"-y", "@modelcontextprotocol/server-memory"},
-				},
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		if err := runDoctor(os.Args[2:]); err != nil {
+			if errors.Is(err, errChecksFailed) {
+				os.Exit(1)
 			}
+			fatalf("Failed to run the checks: %v", err)
 		}
-		ollamaConfig = mcpConfig.OllamaConfig{
-			URL:   "http://localhost:11434",
-			Model: "qwen3:14b",
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tools" {
+		if err := runTools(os.Args[2:]); err != nil {
+			if errors.Is(err, errToolError) {
+				os.Exit(1)
+			}
+			fatalf("Failed to run tools: %v", err)
 		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			fatalf("Failed to serve: %v", err)
+		}
+		return
 	}
 
-	// Create and connect MCP client
-	mcpClient := mcp.NewClient("ttobot", "1.0.0")
-	err = mcpClient.ConnectFromConfigs(ctx, configs)
+	configFlag := flag.String("config", "", "config file to use instead of "+mcpConfig.ConfigEnvVar+" and the default paths")
+	var flagOverrides mcpConfig.Overrides
+	overrideFlags(flag.CommandLine, &flagOverrides)
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	dryRun := flag.Bool("dry-run", false, "record the calls of tools that are not read-only instead of running them")
+	verbose := flag.Bool("verbose", false, "print the tool calls and results that led to each answer, and log at info level")
+	showThinking := flag.Bool("show-thinking", false, "print the model's reasoning, dimmed, in the interactive session")
+	noColor := flag.Bool("no-color", false, "print answers without ANSI colors (env NO_COLOR)")
+	noStats := flag.Bool("no-stats", false, "do not print the tokens, time and tool calls of each answer")
+	resume := flag.Bool("resume", false, "pick a recent session to continue")
+	var prompt string
+	flag.StringVar(&prompt, "p", "", "answer this prompt once for scripts: only the answer goes to standard output")
+	flag.StringVar(&prompt, "prompt", "", "same as -p")
+	jsonOutput := flag.Bool("json", false, "with -p, print the answer, usage and tool calls as one JSON object")
+	export := flag.String("export", "", "with -p, also write the conversation as Markdown to this file")
+	confirmDestructive := flag.Bool("confirm-destructive", false, "ask before running destructive tools, also with -p")
+	var logOpts logOptions
+	logFlags(flag.CommandLine, &logOpts)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: ./ttobot [flags] [\"your question here\"]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./ttobot -p \"your question here\" [-json]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./ttobot serve [-listen 127.0.0.1:8080] [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./ttobot init [-force] [path]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./ttobot tools list | describe <name> | call <name> [-args json]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       ./ttobot doctor [-json]")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	closeLog, err := setupLogging(logOpts, *verbose, slog.LevelWarn)
 	if err != nil {
-		log.Fatalf("Failed to connect to MCP servers: %v", err)
+		fatalf("Failed to set up logging: %v", err)
 	}
+	defer closeLog()
 
-	// Get tools
-	tools, err := mcpClient.Tools(ctx)
+	source, err := loadConfigSource(*configFlag, flagOverrides)
+	if err != nil {
+		fatalf("Failed to load config: %v", err)
+	}
+	configFile, err := source.withProfile("")
 	if err != nil {
-		log.Fatalf("Failed to get tools: %v", err)
+		fatalf("Failed to load config: %v", err)
+	}
+
+	if *printConfig {
+		data, err := mcpConfig.MarshalConfigYAML(configFile.Redacted())
+		if err != nil {
+			fatalf("Failed to print config: %v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+
+	// A question on the command line is answered once; without one, ttobot
+	// starts an interactive session
+	userQuery := strings.Join(flag.Args(), " ")
+	if prompt != "" && userQuery != "" {
+		fatalf("Give the question either with -p or as arguments, not both")
 	}
+	if *jsonOutput && prompt == "" {
+		fatalf("-json needs -p")
+	}
+	if *export != "" && prompt == "" {
+		fatalf("-export needs -p")
+	}
+	query := userQuery + prompt
 
-	// Create Ollama client
-	ollamaClient, err := ollama.NewClient(ollama.ClientOptions{
-		URL:   ollamaConfig.URL,
-		Model: ollamaConfig.Model,
+	// Text piped to ttobot is attached to the question; without a question
+	// there is no terminal to hold an interactive session on
+	var input pipedInput
+	if !stdinIsTerminal() {
+		if query == "" {
+			fmt.Fprintln(os.Stderr, "Standard input is not a terminal. Give the question with -p, for example: git diff | ttobot -p \"review this change\"")
+			flag.Usage()
+			os.Exit(2)
+		}
+		if input, err = readPipedInput(os.Stdin, maxStdinBytes); err != nil {
+			fatalf("Failed to read input: %v", err)
+		}
+		if input.truncated() {
+			slog.Warn("Input: Standard input is too large, attaching only the start", "bytes", input.size, "attached", len(input.text))
+		}
+	}
+
+	// The context ends when a signal asks for a shutdown; in-flight model
+	// requests and tool calls stop with it
+	ctx, signals := handleSignals(context.Background(), os.Stdout)
+	a, err := newApp(ctx, configFile, appOptions{
+		dryRun:             *dryRun,
+		verbose:            *verbose,
+		showThinking:       *showThinking,
+		noColor:            *noColor,
+		scripted:           prompt != "",
+		confirmDestructive: *confirmDestructive,
+		signals:            signals,
+		source:             source,
+		quiet:              logOpts.quiet,
+		noStats:            *noStats,
 	})
 	if err != nil {
-		log.Fatalf("Failed to create Ollama client: %v", err)
+		if code := exitCode(context.Cause(ctx)); code != 0 {
+			os.Exit(code)
+		}
+		fatalf("Failed to start: %v", err)
 	}
 
-	// Set tools
-	ollamaClient.SetTools(tools)
+	if *resume {
+		if err := a.pickSession(); err != nil {
+			a.close()
+			fatalf("Failed to resume: %v", err)
+		}
+	}
 
-	fmt.Printf("Question: %s\n", userQuery)
+	incomplete := false
+	switch {
+	case prompt != "":
+		incomplete, err = a.runPrompt(ctx, input.attachTo(prompt), *jsonOutput)
+		if *export != "" {
+			if _, exportErr := a.exportConversation(*export); exportErr != nil && err == nil {
+				err = exportErr
+			}
+		}
+	case userQuery != "":
+		fmt.Printf("Question: %s\n", userQuery)
+		err = a.ask(ctx, input.attachTo(userQuery))
+	default:
+		err = runREPL(ctx, a)
+	}
+	a.finish()
+	a.close()
 
-	messages := []api.Message{
-		{
-			Role:    "system",
-			Content: "You are a helpful assistant with access to various tools. Use the appropriate tools to answer user questions whenever possible.",
-		},
-		{
-			Role:    "user",
-			Content: userQuery,
-		},
+	if code := exitCode(context.Cause(ctx)); code != 0 {
+		slog.Info("Shutdown", "cause", context.Cause(ctx))
+		os.Exit(code)
+	}
+	if err != nil {
+		fatalf("Chat failed: %v", err)
+	}
+	if incomplete {
+		os.Exit(exitIncomplete)
 	}
+}
+
+// overrideFlags defines the flags that override the config file
+func overrideFlags(flags *flag.FlagSet, overrides *mcpConfig.Overrides) {
+	flags.StringVar(&overrides.Model, "model", "", "model to use (env "+mcpConfig.ModelEnvVar+")")
+	flags.StringVar(&overrides.OllamaURL, "ollama-url", "", "URL of the Ollama server (env "+mcpConfig.OllamaURLEnvVar+")")
+	flags.StringVar(&overrides.Profile, "profile", "", "profile from profiles, or Ollama profile from ollama_profiles (env "+mcpConfig.ProfileEnvVar+")")
+	flags.StringVar(&overrides.SystemPrompt, "system-prompt", "", "text appended to the system prompt (env "+mcpConfig.SystemPromptEnvVar+")")
+	flags.IntVar(&overrides.MaxIterations, "max-iterations", 0, "cap on model calls per answer (overrides agent.max_iterations)")
+	flags.StringVar(&overrides.Builtin, "builtin", "", "builtin servers to run in-process, separated by commas: "+strings.Join(mcpConfig.BuiltinServerNames(), ", ")+" (env "+mcpConfig.BuiltinEnvVar+")")
+}
 
-	// Send to Ollama
-	response, err := ollamaClient.Chat(ctx, messages)
+// configSource is a config file as loaded and the flag and environment
+// overrides applied to it, kept so /profile can apply another profile
+type configSource struct {
+	file      *mcpConfig.ConfigFile
+	overrides mcpConfig.Overrides
+
+	// path is the absolute path of the config file (empty: none was found)
+	path string
+}
+
+// loadConfigSource loads the config file and reads the overrides
+func loadConfigSource(path string, flagOverrides mcpConfig.Overrides) (configSource, error) {
+	configFile, path, err := findConfig(path)
 	if err != nil {
-		log.Fatalf("Chat request failed: %v", err)
+		return configSource{}, err
 	}
+	return configSource{file: configFile, overrides: mcpConfig.OverridesFromEnv().Merge(flagOverrides), path: path}, nil
+}
 
-	// Show response
-	if response.Message.Content != "" {
-		fmt.Printf("Response: %s\n", response.Message.Content)
+// withProfile returns the config file with the overrides applied, and the
+// named profile instead of the one they select (empty: theirs); flags take
+// precedence over the environment, which takes precedence over the profile
+// and the config file
+func (s configSource) withProfile(profile string) (*mcpConfig.ConfigFile, error) {
+	overrides := s.overrides
+	if profile != "" {
+		overrides.Profile = profile
+	}
+	configFile := *s.file
+	if err := configFile.ApplyOverrides(overrides); err != nil {
+		return nil, fmt.Errorf("failed to apply overrides: %w", err)
 	}
+	return &configFile, nil
+}
 
-	// Handle tool calls if any
-	if len(response.Message.ToolCalls) > 0 {
-		fmt.Printf("🔧 Tools called: %d\n", len(response.Message.ToolCalls))
+// loadConfig loads the config file with the flag and environment overrides
+// applied
+func loadConfig(path string, flagOverrides mcpConfig.Overrides) (*mcpConfig.ConfigFile, error) {
+	source, err := loadConfigSource(path, flagOverrides)
+	if err != nil {
+		return nil, err
+	}
+	return source.withProfile("")
+}
 
-		for i, toolCall := range response.Message.ToolCalls {
-			fmt.Printf("  %d. %s\n", i+1, toolCall.Function.Name)
-			if len(toolCall.Function.Arguments) > 0 {
-				fmt.Printf("     Arguments: %v\n", toolCall.Function.Arguments)
-			}
+// findConfig loads the config file at path or, when path is empty, from
+// TTOBOT_CONFIG or the first default path, and returns its absolute path.
+// Without a config file the memory server is used and the path is empty.
+func findConfig(path string) (*mcpConfig.ConfigFile, string, error) {
+	var configFile *mcpConfig.ConfigFile
+	var err error
+	if path != "" {
+		configFile, err = mcpConfig.LoadConfigFile(path)
+	} else {
+		configFile, path, err = mcpConfig.LoadConfigFileFromDefaultPaths(mcpConfig.DefaultPathOptions{})
+	}
+
+	switch {
+	case err == nil:
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
 		}
-		fmt.Println()
+		slog.Info("Config: Using config file", "path", path)
+		return configFile, path, nil
+	case errors.Is(err, mcpConfig.ErrNoConfigFile):
+		slog.Info("Config: No config file found, using the memory server")
+		return &mcpConfig.ConfigFile{
+			Servers: []mcpConfig.Config{
+				{
+					Name:    "memory-server",
+					Command: "npx",
+					Args:    []string{"-y", "@modelcontextprotocol/server-memory"},
+				},
+			},
+			Provider: mcpConfig.ProviderOllama,
+			Ollama: mcpConfig.OllamaConfig{
+				URL:   "http://localhost:11434",
+				Model: "qwen3:14b",
+			},
+		}, "", nil
+	default:
+		return nil, path, err
+	}
+}
 
-		fmt.Println("⚙️  Executing tools...")
-		toolResults, err := ollamaClient.HandleToolCallsInResponse(ctx, response)
-		if err != nil {
-			log.Printf("Tool execution failed: %v", err)
-		} else {
-			for i, result := range toolResults {
-				fmt.Printf("� Tool %d result:\n%s\n\n", i+1, result.Content)
-			}
+// errorAdvice suggests a remedy for a failed model request
+func errorAdvice(err error, configFile *mcpConfig.ConfigFile) string {
+	if configFile.Provider == mcpConfig.ProviderOpenAI {
+		switch {
+		case errors.Is(err, llm.ErrConnection):
+			return fmt.Sprintf("The server is not reachable at %s. Check openai.base_url in mcp.yaml.", configFile.OpenAI.BaseURL)
+		case errors.Is(err, llm.ErrModelNotFound):
+			return fmt.Sprintf("Model %s is not served at %s. Check openai.model in mcp.yaml.", configFile.OpenAI.Model, configFile.OpenAI.BaseURL)
+		case errors.Is(err, llm.ErrUnauthorized):
+			return "The server rejected the API key. Check openai.api_key_env in mcp.yaml."
 		}
-	} else {
-		fmt.Println("ℹ️  No tools were called for this query")
 	}
 
-	fmt.Println("✨ Done!")
+	config := configFile.Ollama
+	switch {
+	case errors.Is(err, llm.ErrConnection):
+		return fmt.Sprintf("Ollama is not reachable at %s. Start it with 'ollama serve' or check the url in mcp.yaml.", config.URL)
+	case errors.Is(err, llm.ErrModelNotFound):
+		return fmt.Sprintf("Model %s is not installed. Pull it with 'ollama pull %s'.", config.Model, config.Model)
+	case errors.Is(err, llm.ErrContextTooLarge):
+		return "The conversation is too large for the model's context. Shorten the question or use a model with a larger context."
+	case errors.Is(err, llm.ErrUnauthorized):
+		return "The Ollama server rejected the credentials. Check auth_token_env in mcp.yaml."
+	case errors.Is(err, llm.ErrTimeout):
+		return "The model did not answer in time. It may still be loading; try again or use a smaller model."
+	}
+	return ""
+}
+
+// runInit writes a starter mcp.yaml: ttobot init [-force] [path]
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	force := flags.Bool("force", false, "overwrite an existing config file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ./ttobot init [-force] [path]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	path := "mcp.yaml"
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+
+	// The bundled servers run in-process, unless ttobot is started from its
+	// source checkout, where go run builds them
+	opts := mcpConfig.DefaultConfigOpts{Force: *force}
+	if _, err := os.Stat(filepath.Join("cmd", "filesystem", "main.go")); err == nil {
+		opts.FromSource = true
+	}
+
+	if err := mcpConfig.WriteDefaultConfig(path, opts); err != nil {
+		return err
+	}
+	fmt.Printf("📝 Wrote %s\n", path)
+	return nil
 }
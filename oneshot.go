@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// exitIncomplete is the exit code of a -p run that hit its iteration
+// limit; the answer then summarizes the findings so far
+const exitIncomplete = 2
+
+// promptOutput is the -json output of a -p run
+type promptOutput struct {
+	Answer     string          `json:"answer"`
+	Usage      llm.Usage       `json:"usage"`
+	ToolCalls  []llm.ToolTrace `json:"tool_calls"`
+	Incomplete bool            `json:"incomplete,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// runPrompt answers one prompt for scripts: the answer, or with asJSON a
+// promptOutput, is the only thing written to standard output. Tool calls,
+// usage and errors go to standard error. It reports whether the answer is
+// incomplete.
+func (a *app) runPrompt(ctx context.Context, prompt string, asJSON bool) (bool, error) {
+	a.conversation.AddUser(prompt)
+
+	started := time.Now()
+	result, err := llm.ChatWithTools(ctx, a.provider, a.toolHandler, a.conversation, a.turnOpts(prompt))
+	a.lastRun = result
+	a.saveSession()
+
+	if asJSON {
+		output := promptOutput{ToolCalls: result.ToolCalls()}
+		if result != nil {
+			output.Usage = result.Usage
+			output.Incomplete = result.Incomplete
+			if result.Response != nil {
+				output.Answer = strings.TrimSpace(result.Response.Message.Content)
+			}
+		}
+		if err != nil {
+			output.Error = err.Error()
+		}
+		if encodeErr := json.NewEncoder(os.Stdout).Encode(output); encodeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write the answer: %w", encodeErr)
+		}
+	}
+
+	if err != nil {
+		a.stats.record(result, time.Since(started))
+		if advice := errorAdvice(err, a.config); advice != "" {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", advice)
+		}
+		return false, err
+	}
+
+	if a.verbose {
+		a.printTrace()
+	} else if calls := result.Usage.ToolCalls; calls > 0 {
+		fmt.Fprintf(a.out, "🔧 %d tool calls\n", calls)
+	}
+	if !asJSON {
+		fmt.Fprintln(os.Stdout, strings.TrimSpace(result.Response.Message.Content))
+	}
+	if result.Incomplete {
+		fmt.Fprintf(a.out, "⚠️  Stopped after %d model calls; the answer summarizes the findings so far.\n", result.Iterations)
+	}
+	a.printTurnStats(result, time.Since(started))
+	return result.Incomplete, nil
+}
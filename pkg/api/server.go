@@ -0,0 +1,357 @@
+// Package api serves the agent loop over HTTP: chat requests answered as
+// JSON or streamed as server-sent events, with conversations kept in memory
+// per session.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	"github.com/snowmerak/ttobot/lib/tool"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+)
+
+// Server defaults
+const (
+	// DefaultSessionTTL is how long an idle session is kept
+	DefaultSessionTTL = 30 * time.Minute
+
+	// DefaultShutdownTimeout is how long in-flight requests may finish
+	// after shutdown starts
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// maxRequestBytes bounds the body of a chat request
+	maxRequestBytes = 4 << 20
+)
+
+// Options configures a Server
+type Options struct {
+	// Provider answers, and ToolHandler runs the tool calls
+	Provider    llm.ChatProvider
+	ToolHandler llm.ToolHandler
+
+	// Tools are the tools offered to the model, listed by /v1/tools
+	Tools []tool.Tool
+
+	// SystemPrompt starts every session
+	SystemPrompt string
+
+	// Opts apply to every agent run
+	Opts llm.Opts
+
+	// SelectTools names the tools offered for a question (nil: all tools)
+	SelectTools func(question string) []string
+
+	// ServerStatus reports the MCP servers for /v1/servers (nil: none)
+	ServerStatus func(ctx context.Context) ([]mcp.ServerStatus, error)
+
+	// AuthToken must be sent as "Authorization: Bearer <token>" (empty: no
+	// authentication)
+	AuthToken string
+
+	// SessionTTL is how long an idle session is kept (default: DefaultSessionTTL)
+	SessionTTL time.Duration
+
+	// ShutdownTimeout is how long in-flight requests may finish after
+	// shutdown starts; then they are cancelled (default: DefaultShutdownTimeout)
+	ShutdownTimeout time.Duration
+}
+
+// Server is the HTTP API
+type Server struct {
+	opts     Options
+	sessions *sessionStore
+	mux      *http.ServeMux
+}
+
+// New creates a server
+func New(opts Options) *Server {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	s := &Server{
+		opts: opts,
+		sessions: newSessionStore(opts.SessionTTL, func() *llm.Conversation {
+			return llm.NewConversation(opts.SystemPrompt)
+		}),
+		mux: http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /v1/chat", s.handleChat)
+	s.mux.HandleFunc("GET /v1/chat/stream", s.handleChatStream)
+	s.mux.HandleFunc("GET /v1/tools", s.handleTools)
+	s.mux.HandleFunc("GET /v1/servers", s.handleServers)
+	return s
+}
+
+// ServeHTTP implements http.Handler, checking the bearer token first
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.AuthToken != "" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ttobot"`)
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether the request carries the bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.AuthToken)) == 1
+}
+
+// ListenAndServe serves on addr until ctx ends, then shuts down: new
+// connections are refused and in-flight requests get ShutdownTimeout to
+// finish before they are cancelled. Idle sessions expire meanwhile.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	// Requests outlive ctx until the shutdown timeout
+	requestCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRequests()
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestCtx },
+	}
+
+	go s.expireSessions(ctx)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	slog.Info("API: Listening", "address", listener.Addr().String())
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("API: Shutting down, waiting for in-flight requests", "timeout", s.opts.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("API: Cancelling the requests still running", "error", err)
+		cancelRequests()
+		server.Close()
+	}
+	return nil
+}
+
+// expireSessions drops idle sessions until ctx ends
+func (s *Server) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(min(s.opts.SessionTTL/2, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if expired := s.sessions.expire(now); expired > 0 {
+				slog.Info("API: Expired idle sessions", "sessions", expired)
+			}
+		}
+	}
+}
+
+// ChatMessage is a message of a conversation sent by a client
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is the body of POST /v1/chat. A request without a session ID
+// starts a session from Messages, Message, or both; one with a session ID
+// continues that session with Message.
+type ChatRequest struct {
+	SessionID string        `json:"session_id,omitempty"`
+	Message   string        `json:"message,omitempty"`
+	Messages  []ChatMessage `json:"messages,omitempty"`
+}
+
+// ChatResponse is the answer to a chat request, also sent as the done
+// event of a stream
+type ChatResponse struct {
+	SessionID  string          `json:"session_id"`
+	Answer     string          `json:"answer"`
+	ToolCalls  []llm.ToolTrace `json:"tool_calls"`
+	Usage      llm.Usage       `json:"usage"`
+	Incomplete bool            `json:"incomplete,omitempty"`
+}
+
+// messages converts the request into the messages to append to the
+// session's conversation, which must end with a user message
+func (r ChatRequest) messages() ([]llm.Message, error) {
+	if r.SessionID != "" && len(r.Messages) > 0 {
+		return nil, errors.New("messages can only start a session; continue one with message")
+	}
+
+	messages := make([]llm.Message, 0, len(r.Messages)+1)
+	for i, message := range r.Messages {
+		if message.Role != llm.RoleUser && message.Role != llm.RoleAssistant {
+			return nil, fmt.Errorf("messages[%d]: role must be %s or %s, got %q", i, llm.RoleUser, llm.RoleAssistant, message.Role)
+		}
+		messages = append(messages, llm.Message{Role: message.Role, Content: message.Content})
+	}
+	if r.Message != "" {
+		messages = append(messages, llm.UserMessage(r.Message))
+	}
+
+	if len(messages) == 0 {
+		return nil, errors.New("message is required")
+	}
+	if messages[len(messages)-1].Role != llm.RoleUser {
+		return nil, errors.New("the last message must be from the user")
+	}
+	return messages, nil
+}
+
+// startRun looks up or creates the session of a request and appends its
+// messages; the caller releases the session after the run
+func (s *Server) startRun(w http.ResponseWriter, req ChatRequest) (*session, bool) {
+	messages, err := req.messages()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	sess, err := s.sessions.acquire(req.SessionID)
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return nil, false
+	case errors.Is(err, errSessionBusy):
+		writeError(w, http.StatusConflict, err)
+		return nil, false
+	}
+	sess.conversation.Append(messages...)
+	return sess, true
+}
+
+// runOpts returns the agent options for a run answering the last message
+// of a session
+func (s *Server) runOpts(sess *session) llm.Opts {
+	opts := s.opts.Opts
+	if s.opts.SelectTools != nil {
+		history := sess.conversation.History()
+		opts.ToolNames = s.opts.SelectTools(history[len(history)-1].Content)
+	}
+	return opts
+}
+
+// chatResponse builds the response to a finished run
+func chatResponse(sess *session, result *llm.AgentResult) ChatResponse {
+	response := ChatResponse{SessionID: sess.id, ToolCalls: result.ToolCalls()}
+	if result != nil {
+		response.Usage = result.Usage
+		response.Incomplete = result.Incomplete
+		if result.Response != nil {
+			response.Answer = strings.TrimSpace(result.Response.Message.Content)
+		}
+	}
+	return response
+}
+
+// handleChat runs the agent loop on a request and answers with the result
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	sess, ok := s.startRun(w, req)
+	if !ok {
+		return
+	}
+	defer s.sessions.release(sess)
+
+	result, err := llm.ChatWithTools(r.Context(), s.opts.Provider, s.opts.ToolHandler, sess.conversation, s.runOpts(sess))
+	if err != nil {
+		slog.Warn("API: Run failed", "session", sess.id, "error", err)
+		writeError(w, runErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, chatResponse(sess, result))
+}
+
+// runErrorStatus maps a failed agent run to a status code
+func runErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, llm.ErrTimeout):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// toolInfo describes a tool in /v1/tools
+type toolInfo struct {
+	Name        string               `json:"name"`
+	Server      string               `json:"server,omitempty"`
+	Description string               `json:"description"`
+	ReadOnly    bool                 `json:"read_only,omitempty"`
+	Destructive bool                 `json:"destructive,omitempty"`
+	Parameters  tool.ParameterSchema `json:"parameters"`
+}
+
+// handleTools lists the tools offered to the model
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	tools := make([]toolInfo, len(s.opts.Tools))
+	for i, t := range s.opts.Tools {
+		tools[i] = toolInfo{
+			Name:        t.Name,
+			Server:      t.Server,
+			Description: t.Description,
+			ReadOnly:    t.ReadOnly,
+			Destructive: t.Destructive,
+			Parameters:  t.Function.Parameters,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tools": tools})
+}
+
+// handleServers reports the state of the MCP servers
+func (s *Server) handleServers(w http.ResponseWriter, r *http.Request) {
+	servers := []mcp.ServerStatus{}
+	if s.opts.ServerStatus != nil {
+		var err error
+		if servers, err = s.opts.ServerStatus(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"servers": servers})
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Warn("API: Failed to write response", "error", err)
+	}
+}
+
+// writeError writes {"error": message}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
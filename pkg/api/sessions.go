@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// Session lookup failures
+var (
+	errSessionNotFound = errors.New("session not found or expired")
+	errSessionBusy     = errors.New("session is answering another request")
+)
+
+// session is the conversation of an API client
+type session struct {
+	id           string
+	conversation *llm.Conversation
+	lastUsed     time.Time
+
+	// busy is set while a request uses the conversation
+	busy bool
+}
+
+// sessionStore keeps sessions in memory until they are idle for ttl
+type sessionStore struct {
+	ttl             time.Duration
+	newConversation func() *llm.Conversation
+
+	lock     sync.Mutex
+	sessions map[string]*session
+}
+
+// newSessionStore creates an empty store
+func newSessionStore(ttl time.Duration, newConversation func() *llm.Conversation) *sessionStore {
+	return &sessionStore{
+		ttl:             ttl,
+		newConversation: newConversation,
+		sessions:        make(map[string]*session),
+	}
+}
+
+// acquire returns the session with the given ID, or a new one for an empty
+// ID, and marks it busy until release
+func (s *sessionStore) acquire(id string) (*session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if id == "" {
+		sess := &session{id: newSessionID(), conversation: s.newConversation(), busy: true}
+		s.sessions[sess.id] = sess
+		return sess, nil
+	}
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	if sess.busy {
+		return nil, errSessionBusy
+	}
+	sess.busy = true
+	return sess, nil
+}
+
+// release ends a request on a session and restarts its TTL
+func (s *sessionStore) release(sess *session) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess.busy = false
+	sess.lastUsed = time.Now()
+}
+
+// expire drops the sessions idle for longer than the TTL and returns how
+// many were dropped
+func (s *sessionStore) expire(now time.Time) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	expired := 0
+	for id, sess := range s.sessions {
+		if !sess.busy && now.Sub(sess.lastUsed) > s.ttl {
+			delete(s.sessions, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// newSessionID returns a random session ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// Stream event payloads
+type (
+	tokenEvent struct {
+		Content  string `json:"content,omitempty"`
+		Thinking string `json:"thinking,omitempty"`
+	}
+
+	toolCallEvent struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+
+	toolResultEvent struct {
+		Name   string `json:"name"`
+		Result string `json:"result"`
+	}
+
+	sessionEvent struct {
+		SessionID string `json:"session_id"`
+	}
+)
+
+// eventWriter writes server-sent events, flushing each one
+type eventWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+// send writes one event with data encoded as JSON
+func (e *eventWriter) send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return e.controller.Flush()
+}
+
+// handleChatStream runs the agent loop on the message in the query and
+// streams it as server-sent events: session first, then token, tool_call
+// and tool_result as they happen, and done with the ChatResponse, or error
+// with {"error": message}.
+func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	sess, ok := s.startRun(w, ChatRequest{
+		SessionID: query.Get("session_id"),
+		Message:   query.Get("message"),
+	})
+	if !ok {
+		return
+	}
+	defer s.sessions.release(sess)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	events := &eventWriter{w: w, controller: http.NewResponseController(w)}
+	if err := events.send("session", sessionEvent{SessionID: sess.id}); err != nil {
+		slog.Info("API: Client went away", "session", sess.id, "error", err)
+		return
+	}
+
+	result, err := llm.StreamWithTools(r.Context(), s.opts.Provider, s.opts.ToolHandler, sess.conversation, func(event llm.StreamEvent) error {
+		switch event.Kind {
+		case llm.EventToken:
+			return events.send("token", tokenEvent{Content: event.Content, Thinking: event.Thinking})
+		case llm.EventToolCall:
+			return events.send("tool_call", toolCallEvent{Name: event.ToolCall.Name, Arguments: event.ToolCall.Arguments})
+		case llm.EventToolResult:
+			return events.send("tool_result", toolResultEvent{Name: event.ToolCall.Name, Result: event.Content})
+		}
+		return nil
+	}, s.runOpts(sess))
+
+	if err != nil {
+		slog.Warn("API: Run failed", "session", sess.id, "error", err)
+		// The client may be gone already; then there is no one to tell
+		events.send("error", map[string]string{"error": err.Error()})
+		return
+	}
+	if err := events.send("done", chatResponse(sess, result)); err != nil {
+		slog.Info("API: Client went away", "session", sess.id, "error", err)
+	}
+}
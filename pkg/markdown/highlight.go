@@ -0,0 +1,149 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// language describes enough of a programming language to color its lines
+type language struct {
+	keywords map[string]bool
+
+	// comment starts a comment running to the end of the line
+	comment string
+
+	// quotes are the characters that delimit strings
+	quotes string
+
+	// caseInsensitive matches keywords in any case
+	caseInsensitive bool
+}
+
+// newLanguage builds a language from space-separated keywords
+func newLanguage(keywords, comment, quotes string, caseInsensitive bool) *language {
+	l := &language{keywords: make(map[string]bool), comment: comment, quotes: quotes, caseInsensitive: caseInsensitive}
+	for _, keyword := range strings.Fields(keywords) {
+		l.keywords[keyword] = true
+	}
+	return l
+}
+
+// Known languages, by the names used after a code fence
+var languages = func() map[string]*language {
+	golang := newLanguage("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var true false nil iota", "//", "\"'`", false)
+	python := newLanguage("and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield self", "#", "\"'", false)
+	javascript := newLanguage("async await break case catch class const continue debugger default delete do else enum export extends false finally for from function if implements import in instanceof interface let new null return super switch this throw true try type typeof undefined var void while yield", "//", "\"'`", false)
+	rust := newLanguage("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while", "//", "\"", false)
+	shell := newLanguage("if then else elif fi for while until do done case esac function in return local export unset set source alias echo exit", "#", "\"'", false)
+	c := newLanguage("auto break case catch char class const continue default delete do double else enum extends extern false final finally float for goto if implements import int interface long namespace new null nullptr package private protected public return short signed sizeof static struct super switch template this throw throws true try typedef union unsigned using virtual void volatile while", "//", "\"'", false)
+	json := newLanguage("true false null", "", "\"", false)
+	yaml := newLanguage("true false null yes no on off", "#", "\"'", false)
+	sql := newLanguage("select from where and or not insert into values update set delete create table index drop alter add join left right inner outer on group by order having limit offset as distinct null is in like between case when then else end primary key references union all exists", "--", "'\"", true)
+
+	return map[string]*language{
+		"go": golang, "golang": golang,
+		"python": python, "py": python,
+		"javascript": javascript, "js": javascript, "jsx": javascript, "typescript": javascript, "ts": javascript, "tsx": javascript,
+		"rust": rust, "rs": rust,
+		"sh": shell, "bash": shell, "zsh": shell, "shell": shell, "console": shell,
+		"c": c, "h": c, "cpp": c, "c++": c, "java": c, "kotlin": c, "cs": c, "csharp": c,
+		"json": json,
+		"yaml": yaml, "yml": yaml,
+		"sql": sql,
+	}
+}()
+
+// yamlKeyPattern matches the key of a YAML mapping line
+var yamlKeyPattern = regexp.MustCompile(`^(\s*(?:-\s+)?)([\w.-]+)(:)(\s|$)`)
+
+// highlight colors one line of code in the given language; unknown
+// languages and lines without color are returned as they are
+func (r *Renderer) highlight(line, lang string) string {
+	if !r.opts.Color {
+		return line
+	}
+
+	if lang == "diff" || lang == "patch" {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			return r.style(line, bold)
+		case strings.HasPrefix(line, "+"):
+			return r.style(line, green)
+		case strings.HasPrefix(line, "-"):
+			return r.style(line, red)
+		case strings.HasPrefix(line, "@@"):
+			return r.style(line, cyan)
+		}
+		return line
+	}
+
+	l := languages[lang]
+	if l == nil {
+		return line
+	}
+
+	prefix := ""
+	if lang == "yaml" || lang == "yml" {
+		if m := yamlKeyPattern.FindStringSubmatch(line); m != nil {
+			prefix = m[1] + r.style(m[2], blue) + m[3]
+			line = line[len(m[1])+len(m[2])+len(m[3]):]
+		}
+	}
+	return prefix + r.tokens(line, l)
+}
+
+// tokens colors the comments, strings, numbers and keywords of a line
+func (r *Renderer) tokens(line string, l *language) string {
+	var b strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case l.comment != "" && strings.HasPrefix(string(runes[i:]), l.comment):
+			b.WriteString(r.style(string(runes[i:]), gray))
+			return b.String()
+
+		case strings.ContainsRune(l.quotes, c):
+			end := i + 1
+			for end < len(runes) && runes[end] != c {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(runes))
+			b.WriteString(r.style(string(runes[i:end]), green))
+			i = end
+
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || unicode.IsLetter(runes[end]) || runes[end] == '.' || runes[end] == '_') {
+				end++
+			}
+			b.WriteString(r.style(string(runes[i:end]), yellow))
+			i = end
+
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			word := string(runes[i:end])
+			key := word
+			if l.caseInsensitive {
+				key = strings.ToLower(word)
+			}
+			if l.keywords[key] {
+				word = r.style(word, magenta)
+			}
+			b.WriteString(word)
+			i = end
+
+		default:
+			b.WriteRune(c)
+			i++
+		}
+	}
+	return b.String()
+}
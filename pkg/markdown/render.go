@@ -0,0 +1,307 @@
+// Package markdown renders the markdown of model answers for a terminal:
+// headings, lists, quotes, tables and fenced code with syntax colors, with
+// paragraphs wrapped to the terminal width. Rendering is line by line, so
+// a streamed answer can be rendered as it arrives.
+package markdown
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Options configures rendering
+type Options struct {
+	// Width is the column paragraphs, list items and quotes are wrapped at
+	// (0: not wrapped)
+	Width int
+
+	// Color styles the text with ANSI sequences; without it only the
+	// layout is rendered: wrapping, bullets, tables and code gutters
+	Color bool
+}
+
+// minWrapWidth keeps deeply indented text readable on narrow terminals
+const minWrapWidth = 20
+
+// Block patterns
+var (
+	fencePattern    = regexp.MustCompile("^\\s*(`{3,}|~{3,})\\s*([\\w+#.-]*)")
+	headingPattern  = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	rulePattern     = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	quotePattern    = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	listPattern     = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	delimiterCell   = regexp.MustCompile(`^:?-+:?$`)
+	taskPattern     = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	orderedListMark = regexp.MustCompile(`^\d`)
+)
+
+// Renderer renders markdown written to it line by line. A line is
+// rendered once it is complete; tables once their last row is.
+type Renderer struct {
+	w    io.Writer
+	opts Options
+
+	// partial is the incomplete last line written
+	partial []byte
+
+	// fence is the open code fence (nil: none)
+	fence *fence
+
+	// table holds the rows of the table being read
+	table [][]string
+
+	err error
+}
+
+// fence is an open fenced code block
+type fence struct {
+	marker string
+	lang   string
+}
+
+// NewRenderer creates a renderer writing to w
+func NewRenderer(w io.Writer, opts Options) *Renderer {
+	return &Renderer{w: w, opts: opts}
+}
+
+// Render renders a complete markdown text
+func Render(text string, opts Options) string {
+	var b strings.Builder
+	r := NewRenderer(&b, opts)
+	r.Write([]byte(text))
+	r.Flush()
+	return b.String()
+}
+
+// Write renders the complete lines of p and keeps the rest for later
+func (r *Renderer) Write(p []byte) (int, error) {
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		r.line(string(r.partial[:i]))
+		r.partial = r.partial[i+1:]
+	}
+	// Do not keep the consumed lines' storage alive
+	r.partial = append([]byte(nil), r.partial...)
+	return len(p), r.err
+}
+
+// Flush renders the incomplete last line and a pending table, as the end
+// of the text or before other output is interleaved
+func (r *Renderer) Flush() error {
+	if len(r.partial) > 0 {
+		r.line(string(r.partial))
+		r.partial = r.partial[:0]
+	}
+	r.flushTable()
+	return r.err
+}
+
+// emit writes one output line
+func (r *Renderer) emit(s string) {
+	if r.err == nil {
+		_, r.err = io.WriteString(r.w, s+"\n")
+	}
+}
+
+// line renders one line of markdown
+func (r *Renderer) line(s string) {
+	s = strings.TrimSuffix(s, "\r")
+
+	if r.fence != nil {
+		if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, r.fence.marker) && strings.Trim(trimmed, r.fence.marker[:1]) == "" {
+			r.fence = nil
+			return
+		}
+		r.emit(r.style("│ ", dim) + r.highlight(s, r.fence.lang))
+		return
+	}
+
+	if row, ok := tableRow(s); ok {
+		r.table = append(r.table, row)
+		return
+	}
+	r.flushTable()
+
+	trimmed := strings.TrimSpace(s)
+	if m := fencePattern.FindStringSubmatch(s); m != nil {
+		r.fence = &fence{marker: m[1], lang: strings.ToLower(m[2])}
+		if m[2] != "" {
+			r.emit(r.style("│ "+m[2], dim))
+		}
+		return
+	}
+
+	switch {
+	case trimmed == "":
+		r.emit("")
+	case headingPattern.MatchString(s):
+		r.heading(s)
+	case rulePattern.MatchString(s):
+		width := 40
+		if r.opts.Width > 0 {
+			width = min(width, r.opts.Width)
+		}
+		r.emit(r.style(strings.Repeat("─", width), dim))
+	case quotePattern.MatchString(s):
+		text := quotePattern.FindStringSubmatch(s)[1]
+		gutter := r.style("│ ", dim)
+		r.emitWrapped(r.inline(text), gutter, gutter)
+	case listPattern.MatchString(s):
+		r.listItem(listPattern.FindStringSubmatch(s))
+	default:
+		r.emitWrapped(r.inline(trimmed), "", "")
+	}
+}
+
+// heading renders a heading; without color the markers are kept
+func (r *Renderer) heading(s string) {
+	if !r.opts.Color {
+		r.emit(strings.TrimSpace(s))
+		return
+	}
+	m := headingPattern.FindStringSubmatch(s)
+	switch len(m[1]) {
+	case 1:
+		r.emit(r.style(m[2], bold+underline+magenta))
+	case 2:
+		r.emit(r.style(m[2], bold+cyan))
+	default:
+		r.emit(r.style(m[2], bold))
+	}
+}
+
+// listItem renders a bullet or numbered item with a hanging indent
+func (r *Renderer) listItem(m []string) {
+	indent := strings.Repeat(" ", len(strings.ReplaceAll(m[1], "\t", "    ")))
+	marker, text := m[2], m[3]
+	if !orderedListMark.MatchString(marker) {
+		marker = "•"
+		if len(indent) >= 2 {
+			marker = "◦"
+		}
+	}
+	if task := taskPattern.FindStringSubmatch(text); task != nil {
+		text = text[len(task[0]):]
+		if task[1] == " " {
+			marker += " ☐"
+		} else {
+			marker += " ☑"
+		}
+	}
+	hanging := indent + strings.Repeat(" ", visibleWidth(marker)+1)
+	r.emitWrapped(r.inline(text), indent+r.style(marker, cyan)+" ", hanging)
+}
+
+// emitWrapped writes text wrapped to the width, the first line after
+// first and the others after rest
+func (r *Renderer) emitWrapped(text, first, rest string) {
+	width := 0
+	if r.opts.Width > 0 {
+		width = max(r.opts.Width-visibleWidth(first), minWrapWidth)
+	}
+	for _, line := range wrap(text, width) {
+		r.emit(first + line)
+		first = rest
+	}
+}
+
+// tableRow splits a table line into its cells
+func tableRow(s string) ([]string, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "|") || strings.Count(trimmed, "|") < 2 {
+		return nil, false
+	}
+	trimmed = strings.TrimSuffix(strings.TrimPrefix(trimmed, "|"), "|")
+	cells := strings.Split(trimmed, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells, true
+}
+
+// isDelimiterRow reports whether a row separates the header from the body
+func isDelimiterRow(row []string) bool {
+	for _, cell := range row {
+		if !delimiterCell.MatchString(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// flushTable renders the pending table with aligned columns
+func (r *Renderer) flushTable() {
+	rows := r.table
+	r.table = nil
+	if len(rows) == 0 {
+		return
+	}
+
+	var align []string
+	header := len(rows) > 1 && isDelimiterRow(rows[1])
+	if header {
+		align = rows[1]
+		rows = append(rows[:1], rows[2:]...)
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	rendered := make([][]string, len(rows))
+	widths := make([]int, columns)
+	for i, row := range rows {
+		rendered[i] = make([]string, columns)
+		for j := range columns {
+			if j < len(row) {
+				rendered[i][j] = r.inline(row[j])
+			}
+			widths[j] = max(widths[j], visibleWidth(rendered[i][j]))
+		}
+	}
+
+	separator := r.style(" │ ", dim)
+	for i, row := range rendered {
+		cells := make([]string, columns)
+		for j, cell := range row {
+			alignment := ""
+			if j < len(align) {
+				alignment = align[j]
+			}
+			cells[j] = pad(cell, widths[j], alignment)
+			if header && i == 0 {
+				cells[j] = r.style(cells[j], bold)
+			}
+		}
+		r.emit(strings.TrimRight(strings.Join(cells, separator), " "))
+
+		if header && i == 0 {
+			rules := make([]string, columns)
+			for j, width := range widths {
+				rules[j] = strings.Repeat("─", width)
+			}
+			r.emit(r.style(strings.Join(rules, "─┼─"), dim))
+		}
+	}
+}
+
+// pad pads a cell to width following its delimiter's alignment
+func pad(cell string, width int, alignment string) string {
+	space := width - visibleWidth(cell)
+	if space <= 0 {
+		return cell
+	}
+	switch {
+	case strings.HasPrefix(alignment, ":") && strings.HasSuffix(alignment, ":"):
+		return strings.Repeat(" ", space/2) + cell + strings.Repeat(" ", space-space/2)
+	case strings.HasSuffix(alignment, ":"):
+		return strings.Repeat(" ", space) + cell
+	}
+	return cell + strings.Repeat(" ", space)
+}
@@ -0,0 +1,135 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ANSI styles
+const (
+	reset     = "\x1b[0m"
+	bold      = "\x1b[1m"
+	dim       = "\x1b[2m"
+	italic    = "\x1b[3m"
+	underline = "\x1b[4m"
+	strike    = "\x1b[9m"
+	red       = "\x1b[31m"
+	green     = "\x1b[32m"
+	yellow    = "\x1b[33m"
+	blue      = "\x1b[34m"
+	magenta   = "\x1b[35m"
+	cyan      = "\x1b[36m"
+	gray      = "\x1b[90m"
+)
+
+// Inline patterns, applied outside code spans
+var (
+	linkPattern   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldPattern   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	italicPattern = regexp.MustCompile(`(^|[^*\w])\*([^*\s](?:[^*]*[^*\s])?)\*([^*\w]|$)`)
+	strikePattern = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	ansiPattern   = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// style wraps s in an ANSI style when colors are on
+func (r *Renderer) style(s, codes string) string {
+	if !r.opts.Color || s == "" {
+		return s
+	}
+	return codes + s + reset
+}
+
+// inline styles code spans, links, bold, italic and struck text; without
+// color the text is kept as written
+func (r *Renderer) inline(s string) string {
+	if !r.opts.Color {
+		return s
+	}
+
+	// Odd parts are code spans
+	parts := strings.Split(s, "`")
+	if len(parts)%2 == 0 {
+		// An unclosed backtick is literal
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			b.WriteString(r.style(part, yellow))
+			continue
+		}
+		part = linkPattern.ReplaceAllStringFunc(part, func(link string) string {
+			m := linkPattern.FindStringSubmatch(link)
+			if m[1] == m[2] {
+				return r.style(m[1], underline+blue)
+			}
+			return r.style(m[1], underline+blue) + r.style(" ("+m[2]+")", dim)
+		})
+		part = boldPattern.ReplaceAllString(part, bold+"$1"+reset)
+		part = italicPattern.ReplaceAllString(part, "$1"+italic+"$2"+reset+"$3")
+		part = strikePattern.ReplaceAllString(part, strike+"$1"+reset)
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// visibleWidth is the number of terminal columns s takes, not counting
+// ANSI sequences; wide characters take two
+func visibleWidth(s string) int {
+	s = ansiPattern.ReplaceAllString(s, "")
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth is the number of columns r takes
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, // CJK
+		r >= 0xac00 && r <= 0xd7a3,                // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff,                // CJK compatibility
+		r >= 0xfe30 && r <= 0xfe4f,                // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60,                // Fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1faff, // Emoji
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// wrap splits text into lines of at most width columns at spaces; words
+// longer than width get a line of their own (width 0: not wrapped)
+func wrap(text string, width int) []string {
+	if width <= 0 || visibleWidth(text) <= width {
+		return []string{text}
+	}
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	for _, word := range strings.Fields(text) {
+		wordWidth := visibleWidth(word)
+		if lineWidth > 0 && lineWidth+1+wordWidth > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		if lineWidth > 0 {
+			line.WriteByte(' ')
+			lineWidth++
+		}
+		line.WriteString(word)
+		lineWidth += wordWidth
+	}
+	if line.Len() > 0 || len(lines) == 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
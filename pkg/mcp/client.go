@@ -4,9 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os/exec"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
@@ -35,60 +38,490 @@ func generateServerID(originalID string) string {
 }
 
 type Client struct {
-	client      *mcp.Client
-	servers     map[string]*mcp.ClientSession
-	serverIDs   map[*mcp.ClientSession]string // Maps session to our generated ID
-	serversLock sync.RWMutex
+	client       *mcp.Client
+	servers      map[string]*mcp.ClientSession
+	serverIDs    map[*mcp.ClientSession]string    // Maps session to our generated ID
+	instructions map[string]string                // Server-provided usage instructions by server ID
+	callTimeouts map[string]time.Duration         // Per-server tool call timeouts by server ID
+	toolFilters  map[string]func(string) bool     // Per-server tool filters by server ID
+	toolTags     map[string][]string              // Configured tags of each server's tools by server ID
+	toolSafety   map[string]safetyFunc            // Per-server read-only and destructive overrides by server ID
+	stderr       map[string]*mcpConfig.RingBuffer // Recent stderr of local servers without a log file
+	serversLock  sync.RWMutex
+
+	// supervisors watch configured servers for their restart policy
+	supervisors map[string]*supervisor
+
+	// onServerEvent receives connection state changes (nil: logged only)
+	onServerEvent func(ServerEvent)
+
+	// toolMiddlewares wrap the executor of every tool, outermost first
+	toolMiddlewares []tool.Middleware
+
+	// toolCache keeps the results of cacheable tools (nil: no caching)
+	toolCache *tool.Cache
+
+	// Client-wide defaults for servers that do not set their own (zero: none)
+	connectTimeout time.Duration
+	callTimeout    time.Duration
+
+	// stats counts the tool calls for Stats
+	stats callStats
 }
 
 func NewClient(name string, version string) *Client {
 	return &Client{
-		client:    mcp.NewClient(&mcp.Implementation{Name: name, Version: version}, nil),
-		servers:   make(map[string]*mcp.ClientSession),
-		serverIDs: make(map[*mcp.ClientSession]string),
+		client:       mcp.NewClient(&mcp.Implementation{Name: name, Version: version}, nil),
+		servers:      make(map[string]*mcp.ClientSession),
+		serverIDs:    make(map[*mcp.ClientSession]string),
+		instructions: make(map[string]string),
+		callTimeouts: make(map[string]time.Duration),
+		toolFilters:  make(map[string]func(string) bool),
+		toolTags:     make(map[string][]string),
+		toolSafety:   make(map[string]safetyFunc),
+		stderr:       make(map[string]*mcpConfig.RingBuffer),
+		supervisors:  make(map[string]*supervisor),
 	}
 }
 
+// UseToolMiddleware adds middlewares around the executor of every tool
+// returned from now on. They run in the order added, outside the server's
+// call timeout; see tool.Wrap.
+func (c *Client) UseToolMiddleware(mws ...tool.Middleware) {
+	c.serversLock.Lock()
+	defer c.serversLock.Unlock()
+	c.toolMiddlewares = append(c.toolMiddlewares, mws...)
+}
+
+// SetToolCache caches the results of the read-only and idempotent tools
+// returned from now on in cache. Calls to any other tool of a server drop
+// the cached results of that server's tools.
+func (c *Client) SetToolCache(cache *tool.Cache) {
+	c.serversLock.Lock()
+	defer c.serversLock.Unlock()
+	c.toolCache = cache
+}
+
+// SetDefaultTimeouts sets the connect and tool call timeouts used for
+// servers that do not configure their own. Zero disables a timeout.
+func (c *Client) SetDefaultTimeouts(connect, call time.Duration) {
+	c.serversLock.Lock()
+	defer c.serversLock.Unlock()
+	c.connectTimeout = connect
+	c.callTimeout = call
+}
+
 func (c *Client) Connect(ctx context.Context, filepath string, args ...string) error {
 	ct := mcp.NewCommandTransport(exec.CommandContext(ctx, filepath, args...))
-	return c.connectWithTransport(ctx, ct)
+	_, err := c.connectWithTransport(ctx, ct, connectOptions{})
+	return err
 }
 
 // ConnectWithCommand connects to an MCP server using a pre-configured command
 func (c *Client) ConnectWithCommand(ctx context.Context, cmd *exec.Cmd) error {
 	ct := mcp.NewCommandTransport(cmd)
-	return c.connectWithTransport(ctx, ct)
+	_, err := c.connectWithTransport(ctx, ct, connectOptions{})
+	return err
+}
+
+// ConnectHTTP connects to a remote MCP server over the streamable HTTP
+// transport, sending headers with every request
+func (c *Client) ConnectHTTP(ctx context.Context, url string, headers map[string]string) error {
+	_, err := c.connectWithTransport(ctx, streamableTransport(url, headers), connectOptions{})
+	return err
+}
+
+// ConnectSSE connects to a remote MCP server over the legacy SSE transport,
+// sending headers with every request
+func (c *Client) ConnectSSE(ctx context.Context, url string, headers map[string]string) error {
+	_, err := c.connectWithTransport(ctx, sseTransport(url, headers), connectOptions{})
+	return err
 }
 
-// connectWithTransport handles the common connection logic
-func (c *Client) connectWithTransport(ctx context.Context, ct *mcp.CommandTransport) error {
-	ss, err := c.client.Connect(ctx, ct)
+// ConnectInProcess connects to a server running in this process over an
+// in-memory transport, registering it under name
+func (c *Client) ConnectInProcess(ctx context.Context, name string, server *mcp.Server) error {
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ss, err := server.Connect(ctx, serverTransport)
 	if err != nil {
-		return fmt.Errorf("failed to connect to MCP server: %w", err)
+		return fmt.Errorf("failed to start in-process server %s: %w", name, err)
+	}
+	if _, err := c.connectWithTransport(ctx, clientTransport, connectOptions{name: name}); err != nil {
+		ss.Close()
+		return err
+	}
+	return nil
+}
+
+// ConnectError reports a server that could not be connected, with the
+// last lines its process wrote to stderr
+type ConnectError struct {
+	Err error
+
+	// Stderr is the tail of the server's stderr (empty: none or remote)
+	Stderr string
+}
+
+func (e *ConnectError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("failed to connect to MCP server: %v\nstderr:\n%s", e.Err, e.Stderr)
+	}
+	return fmt.Sprintf("failed to connect to MCP server: %v", e.Err)
+}
+
+// Unwrap returns the error of the connection
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// connectOptions holds the per-server settings of a connection
+type connectOptions struct {
+	// name registers the server; a generated ID is used when empty
+	name string
+
+	// Timeouts overriding the client-wide defaults (zero: the default)
+	connectTimeout time.Duration
+	callTimeout    time.Duration
+
+	// abort stops a local server whose connection timed out
+	abort func()
+
+	// toolAllowed hides the tools it rejects (nil: all tools are exposed)
+	toolAllowed func(name string) bool
+
+	// stderr holds the recent stderr of a local server
+	stderr *mcpConfig.RingBuffer
+
+	// tags are attached to every tool of the server
+	tags []string
+
+	// toolSafety overrides whether tools are read-only or destructive (nil: none)
+	toolSafety safetyFunc
+}
+
+// safetyFunc adjusts whether a tool, by its name without the server prefix,
+// is read-only and whether it is destructive
+type safetyFunc func(name string, readOnly, destructive bool) (bool, bool)
+
+// connectWithTransport handles the common connection logic and returns the
+// registered session
+func (c *Client) connectWithTransport(ctx context.Context, ct mcp.Transport, opts connectOptions) (*mcp.ClientSession, error) {
+	c.serversLock.RLock()
+	connectTimeout, callTimeout := c.connectTimeout, c.callTimeout
+	c.serversLock.RUnlock()
+	if opts.connectTimeout != 0 {
+		connectTimeout = opts.connectTimeout
+	}
+	if opts.callTimeout != 0 {
+		callTimeout = opts.callTimeout
+	}
+
+	it := &instructionsTransport{Transport: ct}
+	ss, err := c.connectSession(ctx, it, connectTimeout, opts.abort)
+	if err != nil {
+		connectErr := &ConnectError{Err: err}
+		if opts.stderr != nil {
+			connectErr.Stderr = opts.stderr.Tail(5)
+		}
+		return nil, connectErr
 	}
 
 	c.serversLock.Lock()
 	defer c.serversLock.Unlock()
 
-	// Generate a unique server ID if the original ID is empty
-	originalID := ss.ID()
-	serverID := generateServerID(originalID)
+	// Generate a unique server ID if neither a name nor an original ID is available
+	serverID := opts.name
+	if serverID == "" {
+		serverID = generateServerID(ss.ID())
+	}
 
 	// Check if server with this ID already exists
 	_, ok := c.servers[serverID]
 	if ok {
 		ss.Close()
-		return fmt.Errorf("server with ID %s already exists", serverID)
+		return nil, fmt.Errorf("server with ID %s already exists", serverID)
 	}
 
 	// Store the server with the generated ID
 	c.servers[serverID] = ss
 	c.serverIDs[ss] = serverID
+	if instructions := it.Instructions(); instructions != "" {
+		c.instructions[serverID] = instructions
+	}
+	if callTimeout > 0 {
+		c.callTimeouts[serverID] = callTimeout
+	}
+	if opts.toolAllowed != nil {
+		c.toolFilters[serverID] = opts.toolAllowed
+	}
+	if opts.stderr != nil {
+		c.stderr[serverID] = opts.stderr
+	}
+	if len(opts.tags) > 0 {
+		c.toolTags[serverID] = opts.tags
+	}
+	if opts.toolSafety != nil {
+		c.toolSafety[serverID] = opts.toolSafety
+	}
 
-	return nil
+	return ss, nil
+}
+
+// connectSession connects and runs the initialize handshake, giving up after
+// timeout when it is positive. The deadline is not put on ctx because some
+// transports keep using the connect context for the life of the session.
+func (c *Client) connectSession(ctx context.Context, ct mcp.Transport, timeout time.Duration, abort func()) (*mcp.ClientSession, error) {
+	if timeout <= 0 {
+		return c.client.Connect(ctx, ct)
+	}
+
+	type connectResult struct {
+		session *mcp.ClientSession
+		err     error
+	}
+	done := make(chan connectResult, 1)
+	go func() {
+		ss, err := c.client.Connect(ctx, ct)
+		done <- connectResult{ss, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result.session, result.err
+	case <-timer.C:
+		if abort != nil {
+			abort()
+		}
+		// Close the session should the handshake still complete
+		go func() {
+			if result := <-done; result.session != nil {
+				result.session.Close()
+			}
+		}()
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// Instructions returns the usage instructions provided by connected servers,
+// formatted as "server: instructions" and sorted by server ID
+func (c *Client) Instructions() []string {
+	c.serversLock.RLock()
+	defer c.serversLock.RUnlock()
+
+	ids := make([]string, 0, len(c.instructions))
+	for id := range c.instructions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, fmt.Sprintf("%s: %s", id, c.instructions[id]))
+	}
+	return result
+}
+
+// Stderr returns the recent stderr output of a local server that has no
+// log_file, and false for other servers
+func (c *Client) Stderr(serverID string) (string, bool) {
+	c.serversLock.RLock()
+	defer c.serversLock.RUnlock()
+
+	buf, ok := c.stderr[serverID]
+	if !ok {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// toolAllowed reports whether a server's tool passes its configured filter
+func (c *Client) toolAllowed(serverID, name string) bool {
+	filter, ok := c.toolFilters[serverID]
+	return !ok || filter(name)
 }
 
+// listTools lists a server's tools that pass its filter and counts the ones
+// the filter removed
+func (c *Client) listTools(ctx context.Context, serverID string, server *mcp.ClientSession) ([]*mcp.Tool, int, error) {
+	var tools []*mcp.Tool
+	filtered := 0
+	for mcpTool, err := range server.Tools(ctx, &mcp.ListToolsParams{}) {
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list tools: %w", err)
+		}
+		if mcpTool == nil {
+			continue
+		}
+		if !c.toolAllowed(serverID, mcpTool.Name) {
+			filtered++
+			continue
+		}
+		tools = append(tools, mcpTool)
+	}
+	return tools, filtered, nil
+}
+
+// ServerInfo describes a connected server
+type ServerInfo struct {
+	// ID the server is registered under
+	ID string
+
+	// Tools is the number of exposed tools
+	Tools int
+
+	// FilteredTools is the number of tools hidden by allowed_tools or blocked_tools
+	FilteredTools int
+}
+
+// ListServers describes the connected servers, sorted by ID
+func (c *Client) ListServers(ctx context.Context) ([]ServerInfo, error) {
+	c.serversLock.RLock()
+	defer c.serversLock.RUnlock()
+
+	result := make([]ServerInfo, 0, len(c.servers))
+	for serverID, server := range c.servers {
+		tools, filtered, err := c.listTools(ctx, serverID, server)
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", serverID, err)
+		}
+		result = append(result, ServerInfo{ID: serverID, Tools: len(tools), FilteredTools: filtered})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// Server describes one connected server
+func (c *Client) Server(ctx context.Context, serverID string) (ServerInfo, error) {
+	c.serversLock.RLock()
+	defer c.serversLock.RUnlock()
+
+	server, ok := c.servers[serverID]
+	if !ok {
+		return ServerInfo{}, fmt.Errorf("server %s not found", serverID)
+	}
+	tools, filtered, err := c.listTools(ctx, serverID, server)
+	if err != nil {
+		return ServerInfo{}, fmt.Errorf("server %s: %w", serverID, err)
+	}
+	return ServerInfo{ID: serverID, Tools: len(tools), FilteredTools: filtered}, nil
+}
+
+// Ping checks that a connected server responds and returns the round trip time
+func (c *Client) Ping(ctx context.Context, serverID string) (time.Duration, error) {
+	c.serversLock.RLock()
+	server, ok := c.servers[serverID]
+	c.serversLock.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("server %s not found", serverID)
+	}
+
+	started := time.Now()
+	if err := server.Ping(ctx, nil); err != nil {
+		return 0, fmt.Errorf("server %s did not answer a ping: %w", serverID, err)
+	}
+	return time.Since(started), nil
+}
+
+// Server states reported in ServerStatus.State
+const (
+	// StatusConnected: the server is connected and answered a ping
+	StatusConnected = "connected"
+
+	// StatusUnresponsive: the server is connected but did not answer a ping
+	StatusUnresponsive = "unresponsive"
+
+	// StatusNotConnected: the server is enabled but not connected, e.g.
+	// between restart attempts or after giving up
+	StatusNotConnected = "not-connected"
+
+	// StatusDisabled: the server is disabled in the configuration
+	StatusDisabled = "disabled"
+)
+
+// ServerStatus describes the state of a configured or connected server
+type ServerStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+
+	// Tools and FilteredTools count the tools of a connected server as in ServerInfo
+	Tools         int `json:"tools"`
+	FilteredTools int `json:"filtered_tools,omitempty"`
+
+	// Ping is the round trip time of a connected server's ping
+	Ping time.Duration `json:"ping,omitempty"`
+
+	// Error is why an unresponsive server failed its ping
+	Error string `json:"error,omitempty"`
+}
+
+// Status reports the state of each configured server, in order, followed
+// by servers connected without a configuration. Connected servers are
+// pinged, each within pingTimeout.
+func (c *Client) Status(ctx context.Context, configs []mcpConfig.Config, pingTimeout time.Duration) ([]ServerStatus, error) {
+	servers, err := c.ListServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connected := make(map[string]ServerInfo, len(servers))
+	for _, server := range servers {
+		connected[server.ID] = server
+	}
+
+	ping := func(server ServerInfo) ServerStatus {
+		status := ServerStatus{Name: server.ID, State: StatusConnected, Tools: server.Tools, FilteredTools: server.FilteredTools}
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		latency, err := c.Ping(pingCtx, server.ID)
+		if err != nil {
+			status.State = StatusUnresponsive
+			status.Error = err.Error()
+		}
+		status.Ping = latency
+		return status
+	}
+
+	statuses := make([]ServerStatus, 0, len(configs))
+	for _, config := range configs {
+		server, ok := connected[config.Name]
+		switch {
+		case !config.IsEnabled():
+			statuses = append(statuses, ServerStatus{Name: config.Name, State: StatusDisabled})
+		case !ok:
+			statuses = append(statuses, ServerStatus{Name: config.Name, State: StatusNotConnected})
+		default:
+			statuses = append(statuses, ping(server))
+			delete(connected, config.Name)
+		}
+	}
+	for _, server := range servers {
+		if _, ok := connected[server.ID]; ok {
+			statuses = append(statuses, ping(server))
+		}
+	}
+	return statuses, nil
+}
+
+// Tools returns the tools of every connected server, named
+// "server:tool", in server order
 func (c *Client) Tools(ctx context.Context) ([]tool.Tool, error) {
+	registry, err := c.Registry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return registry.Snapshot(), nil
+}
+
+// Registry returns a registry of the tools of every connected server.
+// Tools are registered as "server:tool" and can also be looked up by their
+// original name when only one server provides it; a name that still
+// collides gets a numeric suffix.
+func (c *Client) Registry(ctx context.Context) (*tool.Registry, error) {
 	c.serversLock.RLock()
 	defer c.serversLock.RUnlock()
 
@@ -96,19 +529,27 @@ func (c *Client) Tools(ctx context.Context) ([]tool.Tool, error) {
 		return nil, fmt.Errorf("no servers connected")
 	}
 
-	var result []tool.Tool
-
-	for _, server := range c.servers {
-		for mcpTool, err := range server.Tools(ctx, &mcp.ListToolsParams{}) {
-			if err != nil {
-				return nil, fmt.Errorf("failed to list tools: %w", err)
-			}
+	serverIDs := make([]string, 0, len(c.servers))
+	for serverID := range c.servers {
+		serverIDs = append(serverIDs, serverID)
+	}
+	sort.Strings(serverIDs)
+
+	registry := tool.NewRegistry(tool.RenameDuplicates)
+	for _, serverID := range serverIDs {
+		server := c.servers[serverID]
+		tools, filtered, err := c.listTools(ctx, serverID, server)
+		if err != nil {
+			return nil, err
+		}
+		if filtered > 0 {
+			slog.Info("MCP: Filtered tools", "server", serverID, "tools", filtered)
+		}
 
-			if mcpTool == nil {
-				continue
-			} // Create the common tool structure with server ID prefix
-			serverID := c.serverIDs[server]
+		for _, mcpTool := range tools {
+			// Create the common tool structure with server ID prefix
 			toolName := fmt.Sprintf("%s:%s", serverID, mcpTool.Name)
+			readOnly, destructive := c.toolSafetyOf(serverID, mcpTool)
 
 			commonTool := tool.Tool{
 				Name:        toolName,
@@ -123,13 +564,18 @@ func (c *Client) Tools(ctx context.Context) ([]tool.Tool, error) {
 						Required:   []string{},
 					},
 				},
-				Executor: &MCPToolExecutor{
-					client:       c,
-					serverID:     serverID,
-					toolName:     mcpTool.Name, // Original tool name without server prefix
-					originalTool: mcpTool,
-				},
+				Server:       serverID,
+				OriginalName: mcpTool.Name,
+				Tags:         toolTags(c.toolTags[serverID], readOnly, destructive, mcpTool.Annotations),
+				ReadOnly:     readOnly,
+				Destructive:  destructive,
 			}
+			commonTool.Executor = c.wrapExecutor(commonTool, &MCPToolExecutor{
+				client:       c,
+				serverID:     serverID,
+				toolName:     mcpTool.Name, // Original tool name without server prefix
+				originalTool: mcpTool,
+			})
 
 			// Convert MCP input schema to common parameter schema
 			if mcpTool.InputSchema != nil {
@@ -138,15 +584,87 @@ func (c *Client) Tools(ctx context.Context) ([]tool.Tool, error) {
 				}
 			}
 
-			result = append(result, commonTool)
+			name, err := registry.Add(commonTool)
+			if err != nil {
+				return nil, err
+			}
+			if name != toolName {
+				slog.Warn("MCP: Tool name collides with another tool", "tool", toolName, "registered_as", name)
+			}
 		}
 	}
 
-	if len(result) == 0 {
+	if registry.Len() == 0 {
 		return nil, fmt.Errorf("no tools found")
 	}
 
-	return result, nil
+	return registry, nil
+}
+
+// toolSafetyOf returns whether a server's tool is read-only and whether it
+// is destructive: from its annotations or, without those, its name, then
+// adjusted by the server's overrides. The caller holds serversLock.
+func (c *Client) toolSafetyOf(serverID string, mcpTool *mcp.Tool) (readOnly, destructive bool) {
+	if annotations := mcpTool.Annotations; annotations != nil {
+		readOnly = annotations.ReadOnlyHint
+		// The MCP default for a tool that is not read-only is destructive,
+		// but few servers annotate, so an unset hint falls back to the name
+		if !readOnly {
+			if annotations.DestructiveHint != nil {
+				destructive = *annotations.DestructiveHint
+			} else {
+				destructive = tool.LooksDestructive(mcpTool.Name)
+			}
+		}
+	} else {
+		destructive = tool.LooksDestructive(mcpTool.Name)
+	}
+
+	if override, ok := c.toolSafety[serverID]; ok {
+		readOnly, destructive = override(mcpTool.Name, readOnly, destructive)
+	}
+	return readOnly, destructive
+}
+
+// toolTags returns the configured tags of a server followed by the hint
+// tags describing a tool
+func toolTags(serverTags []string, readOnly, destructive bool, annotations *mcp.ToolAnnotations) []string {
+	tags := append([]string(nil), serverTags...)
+	if readOnly {
+		tags = append(tags, tool.TagReadOnly)
+	} else if destructive {
+		tags = append(tags, tool.TagDestructive)
+	}
+	if annotations == nil {
+		return tags
+	}
+
+	if annotations.IdempotentHint {
+		tags = append(tags, tool.TagIdempotent)
+	}
+	if annotations.OpenWorldHint != nil && *annotations.OpenWorldHint {
+		tags = append(tags, tool.TagOpenWorld)
+	}
+	return tags
+}
+
+// wrapExecutor applies the result cache, outermost, then the configured
+// middlewares and the server's call timeout, innermost, to the executor of
+// t. The caller holds serversLock.
+func (c *Client) wrapExecutor(t tool.Tool, exec tool.ToolExecutor) tool.ToolExecutor {
+	var mws []tool.Middleware
+	if c.toolCache != nil {
+		if tool.Cacheable(t) {
+			mws = append(mws, c.toolCache.Middleware())
+		} else {
+			mws = append(mws, c.toolCache.InvalidateAfter(t.Server+":"))
+		}
+	}
+	mws = append(mws, c.toolMiddlewares...)
+	if timeout := c.callTimeouts[t.Server]; timeout > 0 {
+		mws = append(mws, tool.WithTimeout(timeout))
+	}
+	return tool.Wrap(exec, mws...)
 }
 
 // MCPToolExecutor implements the ToolExecutor interface for MCP tools
@@ -158,13 +676,19 @@ type MCPToolExecutor struct {
 }
 
 // Execute executes the MCP tool with the given arguments
-func (e *MCPToolExecutor) Execute(ctx context.Context, arguments map[string]any) (string, error) {
+func (e *MCPToolExecutor) Execute(ctx context.Context, arguments map[string]any) (*tool.Result, error) {
 	e.client.serversLock.RLock()
 	server, exists := e.client.servers[e.serverID]
+	allowed := e.client.toolAllowed(e.serverID, e.toolName)
 	e.client.serversLock.RUnlock()
 
 	if !exists {
-		return "", fmt.Errorf("server %s not found", e.serverID)
+		return nil, fmt.Errorf("server %s not found", e.serverID)
+	}
+
+	// The filter also guards execution, in case a model calls a hidden tool
+	if !allowed {
+		return nil, fmt.Errorf("tool %s is disabled by the configuration of server %s", e.toolName, e.serverID)
 	}
 
 	// Convert arguments to MCP format
@@ -174,47 +698,266 @@ func (e *MCPToolExecutor) Execute(ctx context.Context, arguments map[string]any)
 	}
 
 	// Call the tool
+	started := time.Now()
 	result, err := server.CallTool(ctx, params)
+	e.client.stats.record(e.serverID, e.toolName, time.Since(started), err != nil || result.IsError)
 	if err != nil {
-		return "", fmt.Errorf("failed to call tool %s: %w", e.toolName, err)
+		return nil, fmt.Errorf("failed to call tool %s: %w", e.toolName, err)
 	}
 
-	// Convert result to string
-	if result.Content != nil {
-		// Handle different content types
-		var content strings.Builder
-		for _, c := range result.Content {
-			// Try to convert to TextContent
-			if textContent, ok := c.(*mcp.TextContent); ok {
-				content.WriteString(textContent.Text)
-			} else {
-				// For other content types, try to marshal as JSON
-				if jsonBytes, err := c.MarshalJSON(); err == nil {
-					content.Write(jsonBytes)
-				}
+	return convertCallToolResult(result), nil
+}
+
+// convertCallToolResult converts an MCP call result into a tool result,
+// keeping the order of its contents
+func convertCallToolResult(result *mcp.CallToolResult) *tool.Result {
+	converted := &tool.Result{IsError: result.IsError}
+	for _, c := range result.Content {
+		switch content := c.(type) {
+		case *mcp.TextContent:
+			converted.Parts = append(converted.Parts, tool.TextPart{Text: content.Text})
+		case *mcp.ImageContent:
+			converted.Parts = append(converted.Parts, tool.BinaryPart{MIMEType: content.MIMEType, Data: content.Data})
+		case *mcp.AudioContent:
+			converted.Parts = append(converted.Parts, tool.BinaryPart{MIMEType: content.MIMEType, Data: content.Data})
+		default:
+			// Resources and links are passed on as JSON
+			if jsonBytes, err := c.MarshalJSON(); err == nil {
+				converted.Parts = append(converted.Parts, tool.JSONPart{Data: jsonBytes})
 			}
 		}
-		return content.String(), nil
 	}
 
-	return "Tool executed successfully", nil
+	if len(converted.Parts) == 0 && result.StructuredContent != nil {
+		if jsonBytes, err := json.Marshal(result.StructuredContent); err == nil {
+			converted.Parts = append(converted.Parts, tool.JSONPart{Data: jsonBytes})
+		}
+	}
+	if len(converted.Parts) == 0 {
+		converted.Parts = append(converted.Parts, tool.TextPart{Text: "Tool executed successfully"})
+	}
+	return converted
 }
 
-// ConnectFromConfig connects to an MCP server using the configuration
+// ConnectFromConfig connects to an MCP server using the configuration,
+// registering it under its configured name. Unless its restart policy is
+// never, the server is started again when its connection ends.
 func (c *Client) ConnectFromConfig(ctx context.Context, config mcpConfig.Config) error {
-	// Create command from config
-	cmd := config.CreateCommand(ctx)
+	// A server that is between restart attempts is not registered, so its
+	// supervisor is stopped before connecting it anew
+	c.serversLock.Lock()
+	if sv, ok := c.supervisors[config.Name]; ok {
+		delete(c.supervisors, config.Name)
+		sv.cancel()
+	}
+	c.serversLock.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	ss, err := c.connectConfig(ctx, config)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	sv := &supervisor{cancel: cancel}
+	c.serversLock.Lock()
+	c.supervisors[config.Name] = sv
+	c.serversLock.Unlock()
 
-	// Connect to the server
-	return c.ConnectWithCommand(ctx, cmd)
+	go c.supervise(ctx, sv, config, ss)
+	return nil
 }
 
-// ConnectFromConfigs connects to multiple MCP servers from configurations
+// connectConfig connects to the server described by config
+func (c *Client) connectConfig(ctx context.Context, config mcpConfig.Config) (*mcp.ClientSession, error) {
+	connectTimeout, callTimeout := config.Timeouts()
+	opts := connectOptions{
+		name:           config.Name,
+		connectTimeout: connectTimeout,
+		callTimeout:    callTimeout,
+		tags:           config.Tags,
+	}
+	if len(config.AllowedTools) > 0 || len(config.BlockedTools) > 0 {
+		opts.toolAllowed = config.ToolAllowed
+	}
+	if len(config.DestructiveTools) > 0 || len(config.ReadOnlyTools) > 0 {
+		opts.toolSafety = config.ToolSafety
+	}
+
+	var ct mcp.Transport
+	switch config.TransportType() {
+	case mcpConfig.TransportStdio:
+		// The command runs under its own context so a connect timeout can
+		// stop it without tying its lifetime to the handshake
+		cmdCtx, cancel := context.WithCancel(ctx)
+		opts.abort = cancel
+		cmd, err := config.CreateCommand(cmdCtx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		opts.stderr, _ = cmd.Stderr.(*mcpConfig.RingBuffer)
+		ct = mcp.NewCommandTransport(cmd)
+	case mcpConfig.TransportHTTP:
+		headers, err := config.ResolveHeaders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ct = streamableTransport(config.URL, headers)
+	case mcpConfig.TransportSSE:
+		headers, err := config.ResolveHeaders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ct = sseTransport(config.URL, headers)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", config.Transport)
+	}
+
+	return c.connectWithTransport(ctx, ct, opts)
+}
+
+// ConnectFromConfigs connects to multiple MCP servers from configurations,
+// skipping the disabled ones
 func (c *Client) ConnectFromConfigs(ctx context.Context, configs []mcpConfig.Config) error {
 	for _, config := range configs {
+		if !config.IsEnabled() {
+			slog.Info("MCP: Skipping disabled server", "server", config.Name)
+			continue
+		}
 		if err := c.ConnectFromConfig(ctx, config); err != nil {
 			return fmt.Errorf("failed to connect to server %s: %w", config.Name, err)
 		}
 	}
 	return nil
 }
+
+// Disconnect closes the connection to a server and forgets it, stopping any
+// pending restart
+func (c *Client) Disconnect(serverID string) error {
+	c.serversLock.Lock()
+	ss, ok := c.servers[serverID]
+	if ok {
+		c.forgetLocked(serverID)
+	}
+	sv, supervised := c.supervisors[serverID]
+	delete(c.supervisors, serverID)
+	c.serversLock.Unlock()
+
+	// Cancel after closing, so the server can shut down gracefully
+	if supervised {
+		defer sv.cancel()
+	}
+	if !ok {
+		if supervised {
+			return nil
+		}
+		return fmt.Errorf("server %s not found", serverID)
+	}
+	if err := ss.Close(); err != nil {
+		return fmt.Errorf("failed to close server %s: %w", serverID, err)
+	}
+	return nil
+}
+
+// killWait is how long Close waits for killed server processes to exit
+const killWait = time.Second
+
+// Close disconnects every server and stops pending restarts. Servers are
+// asked to exit as in Disconnect, all at once; when ctx ends first, the
+// processes of configured servers that are still running are killed.
+func (c *Client) Close(ctx context.Context) error {
+	c.serversLock.Lock()
+	sessions := make(map[string]*mcp.ClientSession, len(c.servers))
+	for serverID, ss := range c.servers {
+		sessions[serverID] = ss
+		c.forgetLocked(serverID)
+	}
+	supervisors := c.supervisors
+	c.supervisors = make(map[string]*supervisor)
+	c.serversLock.Unlock()
+
+	// Cancelling a supervisor kills the processes it started
+	kill := func() {
+		for _, sv := range supervisors {
+			sv.cancel()
+		}
+	}
+	defer kill()
+
+	errs := make(chan error, len(sessions))
+	for serverID, ss := range sessions {
+		go func() {
+			if err := ss.Close(); err != nil {
+				errs <- fmt.Errorf("failed to close server %s: %w", serverID, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	var closeErrs []error
+	for closed := 0; closed < len(sessions); closed++ {
+		select {
+		case err := <-errs:
+			closeErrs = append(closeErrs, err)
+		case <-ctx.Done():
+			kill()
+			// Give the killed processes a moment to be reaped
+			timer := time.NewTimer(killWait)
+			defer timer.Stop()
+			for ; closed < len(sessions); closed++ {
+				select {
+				case <-errs:
+				case <-timer.C:
+					return fmt.Errorf("servers did not exit in time, %d could not be killed: %w", len(sessions)-closed, context.Cause(ctx))
+				}
+			}
+			return fmt.Errorf("servers did not exit in time and were killed: %w", context.Cause(ctx))
+		}
+	}
+	return errors.Join(closeErrs...)
+}
+
+// forgetLocked removes a server from the client; the caller holds serversLock
+func (c *Client) forgetLocked(serverID string) {
+	delete(c.serverIDs, c.servers[serverID])
+	delete(c.servers, serverID)
+	delete(c.instructions, serverID)
+	delete(c.callTimeouts, serverID)
+	delete(c.toolFilters, serverID)
+	delete(c.toolTags, serverID)
+	delete(c.toolSafety, serverID)
+	delete(c.stderr, serverID)
+}
+
+// ApplyConfigDiff reconnects only the servers that changed between two
+// configurations: removed servers are disconnected, added ones connected,
+// and modified ones restarted. Servers that were not connected, such as
+// disabled ones, are skipped when disconnecting.
+func (c *Client) ApplyConfigDiff(ctx context.Context, diff mcpConfig.ConfigDiff) error {
+	for _, config := range append(append([]mcpConfig.Config(nil), diff.Removed...), diff.Modified...) {
+		c.serversLock.RLock()
+		_, connected := c.servers[config.Name]
+		_, supervised := c.supervisors[config.Name]
+		c.serversLock.RUnlock()
+		connected = connected || supervised
+		if !connected {
+			continue
+		}
+		if err := c.Disconnect(config.Name); err != nil {
+			slog.Warn("MCP: Failed to disconnect", "server", config.Name, "error", err)
+		}
+	}
+
+	var errs []error
+	for _, config := range append(append([]mcpConfig.Config(nil), diff.Added...), diff.Modified...) {
+		if !config.IsEnabled() {
+			continue
+		}
+		if err := c.ConnectFromConfig(ctx, config); err != nil {
+			errs = append(errs, fmt.Errorf("failed to connect to server %s: %w", config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,278 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// stdioServerEnv makes the test binary run as a stdio MCP server
+const stdioServerEnv = "TTOBOT_TEST_STDIO_SERVER"
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(stdioServerEnv); name != "" {
+		if err := newTestServer(name).Run(context.Background(), mcp.NewStdioTransport()); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type whoamiArgs struct{}
+
+// newTestServer returns a server whose whoami tool answers with name
+func newTestServer(name string) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "v1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "whoami", Description: "Name the server"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[whoamiArgs]) (*mcp.CallToolResultFor[any], error) {
+			return &mcp.CallToolResultFor[any]{Content: []mcp.Content{&mcp.TextContent{Text: name}}}, nil
+		})
+	return server
+}
+
+// headerRecorder keeps the Authorization headers a handler received
+type headerRecorder struct {
+	mu     sync.Mutex
+	values []string
+}
+
+// wrap records the header of each request before passing it on
+func (r *headerRecorder) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.values = append(r.values, req.Header.Get("Authorization"))
+		r.mu.Unlock()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// all returns the distinct headers received
+func (r *headerRecorder) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := slices.Clone(r.values)
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+func TestConnectFromConfigsMixedTransports(t *testing.T) {
+	var httpHeaders, sseHeaders headerRecorder
+	httpServer := httptest.NewServer(httpHeaders.wrap(mcp.NewStreamableHTTPHandler(
+		func(*http.Request) *mcp.Server { return newTestServer("remote") }, nil)))
+	t.Cleanup(httpServer.Close)
+	sseServer := httptest.NewServer(sseHeaders.wrap(mcp.NewSSEHandler(
+		func(*http.Request) *mcp.Server { return newTestServer("events") })))
+	t.Cleanup(sseServer.Close)
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_MCP_TOKEN", "s3cret")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `servers:
+  - name: local
+    command: ` + executable + `
+    environment:
+      ` + stdioServerEnv + `: local
+  - name: remote
+    transport: http
+    url: ` + httpServer.URL + `
+    headers:
+      Authorization: Bearer ${TEST_MCP_TOKEN}
+  - name: events
+    transport: sse
+    url: ` + sseServer.URL + `
+    headers:
+      Authorization: Token ${TEST_MCP_TOKEN}
+  - name: off
+    transport: http
+    url: http://127.0.0.1:1/mcp
+    enabled: false
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := mcpConfig.LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client := NewClient("ttobot-test", "v0.0.0")
+	defer client.Close(context.Background())
+	if err := client.ConnectFromConfigs(ctx, configs); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := client.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		result, err := tool.Execute(ctx, map[string]any{})
+		if err != nil {
+			t.Fatalf("%s: %v", tool.Name, err)
+		}
+		if got := result.Text(); got != tool.Server {
+			t.Errorf("%s answered %q, want %q", tool.Name, got, tool.Server)
+		}
+	}
+	slices.Sort(names)
+	if want := []string{"events:whoami", "local:whoami", "remote:whoami"}; !slices.Equal(names, want) {
+		t.Fatalf("tools = %v, want %v", names, want)
+	}
+
+	if got := httpHeaders.all(); !slices.Equal(got, []string{"Bearer s3cret"}) {
+		t.Errorf("the http server received Authorization %q", got)
+	}
+	if got := sseHeaders.all(); !slices.Equal(got, []string{"Token s3cret"}) {
+		t.Errorf("the sse server received Authorization %q", got)
+	}
+}
+
+func TestSetDefaultTimeoutsWhileConnecting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client := NewClient("ttobot-test", "v0.0.0")
+	defer client.Close(context.Background())
+
+	// Run with -race: the defaults change while servers connect and tools run
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				client.SetDefaultTimeouts(time.Duration(i)*time.Minute, time.Duration(i)*time.Minute)
+			}
+		}
+	}()
+
+	names := []string{"one", "two", "three", "four"}
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go func() { errs <- client.ConnectInProcess(ctx, name, newTestServer(name)) }()
+	}
+	for range names {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tools, err := client.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools {
+		if result, err := tool.Execute(ctx, map[string]any{}); err != nil || result.Text() != tool.Server {
+			t.Errorf("%s = %v, %v", tool.Name, result, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if len(tools) != len(names) {
+		t.Errorf("got %d tools, want %d", len(tools), len(names))
+	}
+}
+
+func TestConvertCallToolResult(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tests := []struct {
+		name   string
+		result *mcp.CallToolResult
+		want   *tool.Result
+	}{
+		{
+			name:   "text",
+			result: &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "hello"}}},
+			want:   tool.TextResult("hello"),
+		},
+		{
+			name: "error",
+			result: &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: "no such file"}},
+				IsError: true,
+			},
+			want: tool.ErrorResult("no such file"),
+		},
+		{
+			name: "image and audio keep their order among text",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.TextContent{Text: "before"},
+				&mcp.ImageContent{MIMEType: "image/png", Data: png},
+				&mcp.AudioContent{MIMEType: "audio/wav", Data: []byte{1, 2}},
+				&mcp.TextContent{Text: "after"},
+			}},
+			want: &tool.Result{Parts: []tool.Part{
+				tool.TextPart{Text: "before"},
+				tool.BinaryPart{MIMEType: "image/png", Data: png},
+				tool.BinaryPart{MIMEType: "audio/wav", Data: []byte{1, 2}},
+				tool.TextPart{Text: "after"},
+			}},
+		},
+		{
+			name: "resources as JSON",
+			result: &mcp.CallToolResult{Content: []mcp.Content{
+				&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///a.txt", MIMEType: "text/plain", Text: "contents"}},
+				&mcp.ResourceLink{URI: "file:///b.txt", Name: "b.txt"},
+			}},
+			want: &tool.Result{Parts: []tool.Part{
+				tool.JSONPart{Data: json.RawMessage(`{"type":"resource","resource":{"uri":"file:///a.txt","mimeType":"text/plain","text":"contents"}}`)},
+				tool.JSONPart{Data: json.RawMessage(`{"type":"resource_link","uri":"file:///b.txt","name":"b.txt"}`)},
+			}},
+		},
+		{
+			name:   "structured content without content",
+			result: &mcp.CallToolResult{StructuredContent: map[string]any{"count": 3}},
+			want:   &tool.Result{Parts: []tool.Part{tool.JSONPart{Data: json.RawMessage(`{"count":3}`)}}},
+		},
+		{
+			name:   "empty",
+			result: &mcp.CallToolResult{},
+			want:   tool.TextResult("Tool executed successfully"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertCallToolResult(tt.result)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s\nwant %s", describeResult(got), describeResult(tt.want))
+			}
+		})
+	}
+}
+
+// describeResult renders a result with its JSON parts readable
+func describeResult(r *tool.Result) string {
+	var parts []string
+	for _, part := range r.Parts {
+		if p, ok := part.(tool.JSONPart); ok {
+			parts = append(parts, "json "+string(p.Data))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%+v", part))
+	}
+	return fmt.Sprintf("error %v: %s", r.IsError, strings.Join(parts, ", "))
+}
@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// instructionsTransport wraps a transport to record the instructions a server
+// returns in its initialize response, which the SDK does not expose
+type instructionsTransport struct {
+	mcp.Transport
+
+	mu           sync.Mutex
+	instructions string
+}
+
+// Connect implements mcp.Transport
+func (t *instructionsTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instructionsConn{Connection: conn, transport: t}, nil
+}
+
+// Instructions returns the recorded server instructions, if any
+func (t *instructionsTransport) Instructions() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.instructions
+}
+
+// instructionsConn inspects responses until the initialize result is seen
+type instructionsConn struct {
+	mcp.Connection
+	transport *instructionsTransport
+	seen      bool
+}
+
+// Read implements mcp.Connection
+func (c *instructionsConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.Connection.Read(ctx)
+	if err != nil || c.seen {
+		return msg, err
+	}
+
+	if resp, ok := msg.(*jsonrpc.Response); ok && len(resp.Result) > 0 {
+		var result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			Instructions    string `json:"instructions"`
+		}
+		if json.Unmarshal(resp.Result, &result) == nil && result.ProtocolVersion != "" {
+			c.seen = true
+			c.transport.mu.Lock()
+			c.transport.instructions = result.Instructions
+			c.transport.mu.Unlock()
+		}
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,228 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+)
+
+// Restart pacing
+const (
+	// maxRestartBackoff caps the doubled delay between attempts, unless the
+	// configured backoff is longer
+	maxRestartBackoff = time.Minute
+
+	// restartResetAfter is how long a restarted server must stay up for its
+	// attempt count to start over
+	restartResetAfter = time.Minute
+)
+
+// Server states reported in ServerEvent.State
+const (
+	// ServerExited: the connection ended and the policy does not restart it
+	ServerExited = "exited"
+
+	// ServerRestarting: a restart attempt is scheduled
+	ServerRestarting = "restarting"
+
+	// ServerRestarted: the server is connected again
+	ServerRestarted = "restarted"
+
+	// ServerGaveUp: the restart attempts are used up
+	ServerGaveUp = "gave-up"
+)
+
+// ServerEvent reports a change in the connection state of a server and the
+// restart policy decision taken for it
+type ServerEvent struct {
+	Server string
+	State  string
+
+	// Policy is the server's restart policy
+	Policy string
+
+	// Attempt counts the consecutive restart attempts, starting at 1
+	Attempt int
+
+	// MaxRetries is the attempt limit of the policy (negative: no limit)
+	MaxRetries int
+
+	// Delay is the wait before the attempt of a ServerRestarting event
+	Delay time.Duration
+
+	// Err is why the connection ended or the last attempt failed (nil: a
+	// clean exit)
+	Err error
+
+	// Stderr holds the last lines the server wrote to stderr before its
+	// connection ended, when kept in memory
+	Stderr string
+}
+
+// Decision describes the restart decision in words, such as "restarting in
+// 2s (attempt 1 of 3)" or "gave up after 3 attempt(s)"
+func (e ServerEvent) Decision() string {
+	switch e.State {
+	case ServerRestarting:
+		if e.MaxRetries < 0 {
+			return fmt.Sprintf("restarting in %s (attempt %d)", e.Delay, e.Attempt)
+		}
+		return fmt.Sprintf("restarting in %s (attempt %d of %d)", e.Delay, e.Attempt, e.MaxRetries)
+	case ServerRestarted:
+		return fmt.Sprintf("restarted after %d attempt(s)", e.Attempt)
+	case ServerGaveUp:
+		return fmt.Sprintf("gave up after %d attempt(s)", e.Attempt)
+	default:
+		return "not restarting, policy=" + e.Policy
+	}
+}
+
+// String formats the event for logs
+func (e ServerEvent) String() string {
+	msg := fmt.Sprintf("server %s %s; %s", e.Server, e.State, e.Decision())
+	if e.Err != nil {
+		msg = fmt.Sprintf("server %s %s: %v; %s", e.Server, e.State, e.Err, e.Decision())
+	}
+	if e.Stderr != "" {
+		lines := strings.Split(e.Stderr, "\n")
+		msg += fmt.Sprintf(" (stderr: %s)", lines[len(lines)-1])
+	}
+	return msg
+}
+
+// OnServerEvent sets a callback for connection state changes, such as a
+// server exiting or being restarted. Events are logged either way.
+func (c *Client) OnServerEvent(callback func(ServerEvent)) {
+	c.serversLock.Lock()
+	c.onServerEvent = callback
+	c.serversLock.Unlock()
+}
+
+// emit logs an event and passes it to the callback
+func (c *Client) emit(event ServerEvent) {
+	level := slog.LevelWarn
+	if event.State == ServerRestarted {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, "MCP: Server state changed", "event", event.String())
+
+	c.serversLock.RLock()
+	callback := c.onServerEvent
+	c.serversLock.RUnlock()
+	if callback != nil {
+		callback(event)
+	}
+}
+
+// stderrTail returns the last stderr lines kept for a server
+func (c *Client) stderrTail(serverID string) string {
+	c.serversLock.RLock()
+	defer c.serversLock.RUnlock()
+
+	if buf, ok := c.stderr[serverID]; ok {
+		return buf.Tail(5)
+	}
+	return ""
+}
+
+// supervisor is the handle of a running supervise loop
+type supervisor struct {
+	// cancel stops the loop and the server processes it started
+	cancel context.CancelFunc
+}
+
+// release forgets the server if ss is still its session, and reports
+// whether it was. A session closed by Disconnect is no longer registered.
+func (c *Client) release(serverID string, ss *mcp.ClientSession) bool {
+	c.serversLock.Lock()
+	defer c.serversLock.Unlock()
+
+	if c.servers[serverID] != ss {
+		return false
+	}
+	c.forgetLocked(serverID)
+	return true
+}
+
+// supervise waits for the session of a configured server to end and applies
+// the server's restart policy, until the server is disconnected, ctx ends,
+// or the attempts are used up
+func (c *Client) supervise(ctx context.Context, sv *supervisor, config mcpConfig.Config, ss *mcp.ClientSession) {
+	defer func() {
+		c.serversLock.Lock()
+		if c.supervisors[config.Name] == sv {
+			delete(c.supervisors, config.Name)
+		}
+		c.serversLock.Unlock()
+		sv.cancel()
+	}()
+
+	restart := config.Restart
+	policy := restart.PolicyName()
+	maxRetries := restart.Retries()
+
+	attempt := 0
+	for {
+		connectedAt := time.Now()
+		err := ss.Wait()
+		stderr := c.stderrTail(config.Name)
+		if !c.release(config.Name, ss) || ctx.Err() != nil {
+			return
+		}
+		if time.Since(connectedAt) >= restartResetAfter {
+			attempt = 0
+		}
+
+		if !restart.ShouldRestart(err) {
+			c.emit(ServerEvent{Server: config.Name, State: ServerExited, Policy: policy, Err: err, Stderr: stderr})
+			return
+		}
+
+		for {
+			if maxRetries >= 0 && attempt >= maxRetries {
+				c.emit(ServerEvent{Server: config.Name, State: ServerGaveUp, Policy: policy, Attempt: attempt, MaxRetries: maxRetries, Err: err, Stderr: stderr})
+				return
+			}
+			attempt++
+
+			delay := restartDelay(restart.BackoffDuration(), attempt)
+			c.emit(ServerEvent{Server: config.Name, State: ServerRestarting, Policy: policy, Attempt: attempt, MaxRetries: maxRetries, Delay: delay, Err: err, Stderr: stderr})
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			stderr = ""
+			ss, err = c.connectConfig(ctx, config)
+			if err == nil && ctx.Err() != nil {
+				// Disconnected while the attempt was running
+				c.release(config.Name, ss)
+				ss.Close()
+				return
+			}
+			if err == nil {
+				c.emit(ServerEvent{Server: config.Name, State: ServerRestarted, Policy: policy, Attempt: attempt})
+				break
+			}
+		}
+	}
+}
+
+// restartDelay doubles the backoff for each attempt after the first
+func restartDelay(backoff time.Duration, attempt int) time.Duration {
+	limit := max(backoff, maxRestartBackoff)
+	delay := backoff
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolStats counts the calls made to one tool
+type ToolStats struct {
+	// Server is the ID of the server and Tool the tool's name on it
+	Server string
+	Tool   string
+
+	// Calls is the number of calls; Errors those that failed or returned
+	// an error result
+	Calls  int
+	Errors int
+
+	// Duration is the time spent in all calls and Slowest the longest call
+	Duration time.Duration
+	Slowest  time.Duration
+}
+
+// Name returns the tool as server:tool
+func (s ToolStats) Name() string {
+	return s.Server + ":" + s.Tool
+}
+
+// callStats collects the ToolStats of a client
+type callStats struct {
+	lock  sync.Mutex
+	tools map[[2]string]*ToolStats
+}
+
+// record counts a call to a tool of a server
+func (s *callStats) record(serverID, toolName string, duration time.Duration, failed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := [2]string{serverID, toolName}
+	stats, ok := s.tools[key]
+	if !ok {
+		if s.tools == nil {
+			s.tools = make(map[[2]string]*ToolStats)
+		}
+		stats = &ToolStats{Server: serverID, Tool: toolName}
+		s.tools[key] = stats
+	}
+	stats.Calls++
+	if failed {
+		stats.Errors++
+	}
+	stats.Duration += duration
+	stats.Slowest = max(stats.Slowest, duration)
+}
+
+// Stats returns the calls made to each tool since the client was created,
+// sorted by server and tool. Results served from the tool cache are not
+// counted.
+func (c *Client) Stats() []ToolStats {
+	c.stats.lock.Lock()
+	defer c.stats.lock.Unlock()
+
+	result := make([]ToolStats, 0, len(c.stats.tools))
+	for _, stats := range c.stats.tools {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Server != result[j].Server {
+			return result[i].Server < result[j].Server
+		}
+		return result[i].Tool < result[j].Tool
+	})
+	return result
+}
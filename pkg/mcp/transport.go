@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"net/http"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// headerTransport adds the configured headers to every request, including
+// the long-lived streaming ones
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient returns the client used for remote servers. Without headers
+// the SDK's default client is used.
+func newHTTPClient(headers map[string]string) *http.Client {
+	if len(headers) == 0 {
+		return nil
+	}
+	return &http.Client{
+		Transport: &headerTransport{
+			base:    http.DefaultTransport,
+			headers: headers,
+		},
+	}
+}
+
+// streamableTransport creates a streamable HTTP client transport
+func streamableTransport(url string, headers map[string]string) mcp.Transport {
+	return mcp.NewStreamableClientTransport(url, &mcp.StreamableClientTransportOptions{
+		HTTPClient: newHTTPClient(headers),
+	})
+}
+
+// sseTransport creates an SSE client transport
+func sseTransport(url string, headers map[string]string) mcp.Transport {
+	return mcp.NewSSEClientTransport(url, &mcp.SSEClientTransportOptions{
+		HTTPClient: newHTTPClient(headers),
+	})
+}
@@ -2,265 +2,436 @@ package ollama
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/types/model"
+	"github.com/snowmerak/ttobot/lib/llm"
 	"github.com/snowmerak/ttobot/lib/tool"
 )
 
 type Client struct {
-	model  string
-	client *api.Client
-	tools  []tool.Tool
+	endpoints     []*endpoint
+	active        int
+	endpointsLock sync.Mutex
+
+	failoverCooldown time.Duration
+	onFailover       func(FailoverEvent)
+
+	tools          *llm.ToolRunner
+	requestTimeout time.Duration
+
+	options   map[string]any // Default model options for every request
+	keepAlive *api.Duration  // How long the model stays loaded (nil: server default)
+
+	secrets secretRedactor
+
+	middlewares    []ChatMiddleware
+	middlewareLock sync.RWMutex
+
+	capabilities     map[Endpoint][]model.Capability // Cached model capabilities
+	capabilitiesLock sync.Mutex
 }
 
 type ClientOptions struct {
 	URL   string
 	Model string
+
+	// HTTPClient is used for all requests when set; TLSConfig, Timeout, and
+	// ProxyURL are ignored in that case
+	HTTPClient *http.Client
+
+	// TLSConfig configures TLS, e.g. a custom CA for a TLS-terminating proxy
+	TLSConfig *tls.Config
+
+	// Timeout bounds dialing and the TLS handshake (default: DefaultConnectTimeout)
+	Timeout time.Duration
+
+	// ProxyURL routes requests through a proxy (default: the environment's proxy settings)
+	ProxyURL string
+
+	// Headers are added to every request
+	Headers map[string]string
+
+	// BearerToken is sent as "Authorization: Bearer <token>" on every request
+	BearerToken string
+
+	// Fallbacks are tried in order when the primary endpoint is unreachable
+	// or lacks the model; an empty URL or Model inherits the primary's
+	Fallbacks []Endpoint
+
+	// FailoverCooldown is how long a failed endpoint is skipped (default: DefaultFailoverCooldown)
+	FailoverCooldown time.Duration
+
+	// OnFailover is called whenever a request moves to another endpoint
+	OnFailover func(FailoverEvent)
+
+	// ArgumentValidation controls how tool-call arguments are checked before
+	// execution (default: llm.ValidationLenient)
+	ArgumentValidation llm.ValidationMode
+
+	// RequestTimeout bounds each chat request (zero: no timeout). For
+	// streaming requests it is an inactivity timeout: the request fails only
+	// when no chunk arrives for this long.
+	RequestTimeout time.Duration
+
+	// MaxToolResultChars caps each tool result fed back to the model, keeping
+	// the head and tail (zero: llm.DefaultMaxToolResultChars, negative: no cap)
+	MaxToolResultChars int
+
+	// ParallelToolCalls bounds the tool calls of one response that run at
+	// once (zero: one after another)
+	ParallelToolCalls int
+
+	// SummarizeTruncated replaces the omitted middle of a truncated tool
+	// result with a short summary produced by an extra model call
+	SummarizeTruncated bool
+
+	// Prompts phrase tool results and notices fed back to the model
+	// (default: the llm.Default*Prompt templates)
+	Prompts llm.Prompts
+
+	// Options are model options such as temperature or num_ctx sent with
+	// every request; per-call settings like Stop take precedence
+	Options map[string]any
+
+	// KeepAlive controls how long the model stays loaded after a request; a
+	// negative value keeps it loaded (nil: the server default)
+	KeepAlive *time.Duration
+}
+
+// ChatOpts holds per-call overrides for chat requests
+type ChatOpts = llm.Opts
+
+// Done reasons reported in api.ChatResponse.DoneReason
+const (
+	DoneReasonStop   = llm.DoneReasonStop
+	DoneReasonLength = llm.DoneReasonLength
+)
+
+// requestOptions builds the model options for a call
+func (c *Client) requestOptions(o ChatOpts) map[string]any {
+	options := make(map[string]any, len(c.options))
+	for key, value := range c.options {
+		options[key] = value
+	}
+	if len(o.Stop) > 0 {
+		options["stop"] = o.Stop
+	}
+	if o.NumPredict != 0 {
+		options["num_predict"] = o.NumPredict
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// chatOpts returns the first options value or the zero value
+func chatOpts(opts []ChatOpts) ChatOpts {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return ChatOpts{}
+}
+
+// timeout returns the effective timeout for a call
+func (c *Client) timeout(o ChatOpts) time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return c.requestTimeout
 }
 
 func NewClient(opt ClientOptions) (*Client, error) {
-	u, err := url.Parse(opt.URL)
+	hc, err := newHTTPClient(opt)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL %s: %w", opt.URL, err)
+		return nil, err
 	}
+	hc = withHeaders(hc, opt.Headers, opt.BearerToken)
 
-	hc := &http.Client{}
+	endpoints, err := newEndpoints(opt, hc)
+	if err != nil {
+		return nil, err
+	}
 
-	client := api.NewClient(u, hc)
+	failoverCooldown := opt.FailoverCooldown
+	if failoverCooldown <= 0 {
+		failoverCooldown = DefaultFailoverCooldown
+	}
 
-	return &Client{
-		model:  opt.Model,
-		client: client,
-		tools:  []tool.Tool{},
-	}, nil
+	c := &Client{
+		endpoints:        endpoints,
+		failoverCooldown: failoverCooldown,
+		onFailover:       opt.OnFailover,
+
+		tools: llm.NewToolRunner(llm.ToolRunnerOptions{
+			ArgumentValidation: opt.ArgumentValidation,
+			MaxResultChars:     opt.MaxToolResultChars,
+			Parallelism:        opt.ParallelToolCalls,
+			Prompts:            opt.Prompts,
+		}),
+		requestTimeout: opt.RequestTimeout,
+		options:        opt.Options,
+
+		secrets: newSecretRedactor(opt),
+	}
+	if opt.KeepAlive != nil {
+		c.keepAlive = &api.Duration{Duration: *opt.KeepAlive}
+	}
+	if opt.SummarizeTruncated {
+		c.tools.SetSummarizer(NewProvider(c))
+	}
+	return c, nil
 }
 
 // SetTools sets the available tools for the client
 func (c *Client) SetTools(tools []tool.Tool) {
-	c.tools = tools
-	log.Printf("Ollama client: Set %d tools", len(tools))
+	c.tools.SetTools(tools)
+	slog.Info("Ollama client: Set tools", "tools", len(tools))
 	for _, t := range tools {
-		log.Printf("  - Tool: %s (%s)", t.Name, t.Description)
+		slog.Debug("Ollama client: Tool", "tool", t.Name, "description", t.Description)
 	}
 }
 
+// SetPrompts replaces the templates phrasing tool results and notices
+func (c *Client) SetPrompts(prompts llm.Prompts) {
+	c.tools.SetPrompts(prompts)
+}
+
 // GetTools returns the currently available tools
 func (c *Client) GetTools() []tool.Tool {
-	return c.tools
+	return c.tools.Tools()
 }
 
-// convertToOllamaTools converts common tool format to Ollama API format
-func (c *Client) convertToOllamaTools() []api.Tool {
-	ollamaTools := make([]api.Tool, 0, len(c.tools))
-
-	for _, t := range c.tools {
-		ollamaTool := api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        t.Function.Name,
-				Description: t.Function.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Defs       any      `json:"$defs,omitempty"`
-					Items      any      `json:"items,omitempty"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        api.PropertyType `json:"type"`
-						Items       any              `json:"items,omitempty"`
-						Description string           `json:"description"`
-						Enum        []any            `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:     t.Function.Parameters.Type,
-					Defs:     t.Function.Parameters.Defs,
-					Items:    t.Function.Parameters.Items,
-					Required: t.Function.Parameters.Required,
-					Properties: make(map[string]struct {
-						Type        api.PropertyType `json:"type"`
-						Items       any              `json:"items,omitempty"`
-						Description string           `json:"description"`
-						Enum        []any            `json:"enum,omitempty"`
-					}),
-				},
-			},
-		}
-
-		// Convert properties
-		for propName, propDef := range t.Function.Parameters.Properties {
-			ollamaTool.Function.Parameters.Properties[propName] = struct {
-				Type        api.PropertyType `json:"type"`
-				Items       any              `json:"items,omitempty"`
-				Description string           `json:"description"`
-				Enum        []any            `json:"enum,omitempty"`
-			}{
-				Type:        api.PropertyType{propDef.Type},
-				Items:       propDef.Items,
-				Description: propDef.Description,
-				Enum:        propDef.Enum,
-			}
-		}
-
-		ollamaTools = append(ollamaTools, ollamaTool)
-	}
-
-	return ollamaTools
+// Registry returns the registry of the available tools, for lookups by
+// name or by unprefixed name
+func (c *Client) Registry() *tool.Registry {
+	return c.tools.Registry()
 }
 
 // Chat sends a chat request with tool support
-func (c *Client) Chat(ctx context.Context, messages []api.Message) (*api.ChatResponse, error) {
+func (c *Client) Chat(ctx context.Context, messages []api.Message, opts ...ChatOpts) (*api.ChatResponse, error) {
+	o := chatOpts(opts)
 	req := &api.ChatRequest{
-		Model:    c.model,
-		Messages: messages,
-		Stream:   new(bool), // Disable streaming for complete response
+		Model:     c.ActiveEndpoint().Model,
+		Messages:  c.withSupportedImages(ctx, stripThinking(messages)),
+		Stream:    new(bool), // Disable streaming for complete response
+		Think:     o.Think,
+		Options:   c.requestOptions(o),
+		KeepAlive: c.keepAlive,
 	}
 
 	// Add tools if available
-	if len(c.tools) > 0 {
-		req.Tools = c.convertToOllamaTools()
-		log.Printf("Ollama chat: Sending request with %d tools available", len(c.tools))
-	} else {
-		log.Printf("Ollama chat: Sending request without tools")
+	if tools := o.OfferedTools(c.GetTools()); len(tools) > 0 {
+		req.Tools = tool.ToOllama(tools)
 	}
+	logRequest(ctx, "Ollama chat", req)
+
+	core := func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+		callCtx := ctx
+		timeout := c.timeout(o)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 
-	var finalResponse api.ChatResponse
-	var responseContent string
+		// A non-streaming request normally yields a single response, but the
+		// accumulator also assembles content, tool calls, done reason and
+		// metrics correctly if the server sends chunks anyway
+		var acc streamAccumulator
+		err := c.doChat(callCtx, req, func(resp api.ChatResponse) error {
+			acc.Add(resp)
+			return nil
+		})
 
-	err := c.client.Chat(ctx, req, func(resp api.ChatResponse) error {
-		finalResponse = resp
-		if resp.Message.Content != "" {
-			responseContent += resp.Message.Content
+		if err != nil {
+			// Only report our own deadline as a timeout, not the caller's
+			if timeout > 0 && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				err = &TimeoutError{Timeout: timeout}
+			}
+			return nil, err
 		}
-		return nil
-	})
 
-	if err != nil {
-		log.Printf("Ollama chat: Request failed: %v", err)
-		return nil, fmt.Errorf("chat request failed: %w", err)
+		// Separate any inline reasoning segment from the answer
+		finalResponse := acc.Response()
+		content, thinking := splitThinking(finalResponse.Message.Content)
+		finalResponse.Message.Content = content
+		finalResponse.Message.Thinking += thinking
+		return finalResponse, nil
 	}
 
-	// Combine all content
-	finalResponse.Message.Content = responseContent
+	finalResponse, err := c.chain(core)(ctx, req)
+	if err != nil {
+		slog.Debug("Ollama chat: Request failed", "error", c.secrets.Redact(err))
+		return nil, fmt.Errorf("chat request failed: %w", classifyError(err))
+	}
 
-	// Log tool calls if any
-	if len(finalResponse.Message.ToolCalls) > 0 {
-		log.Printf("Ollama chat: Response contains %d tool calls", len(finalResponse.Message.ToolCalls))
-		for i, toolCall := range finalResponse.Message.ToolCalls {
-			log.Printf("  Tool call %d: %s", i+1, toolCall.Function.Name)
-			if toolCall.Function.Arguments != nil {
-				log.Printf("    Arguments: %v", toolCall.Function.Arguments)
-			}
-		}
-	} else {
-		log.Printf("Ollama chat: Response completed without tool calls")
+	if finalResponse.Message.Thinking != "" && o.OnThinking != nil {
+		o.OnThinking(finalResponse.Message.Thinking)
 	}
 
-	return &finalResponse, nil
+	logResponse(ctx, "Ollama chat", finalResponse)
+	return finalResponse, nil
 }
 
 // ChatStream sends a streaming chat request with tool support
-func (c *Client) ChatStream(ctx context.Context, messages []api.Message, callback func(api.ChatResponse) error) error {
+func (c *Client) ChatStream(ctx context.Context, messages []api.Message, callback func(api.ChatResponse) error, opts ...ChatOpts) error {
+	o := chatOpts(opts)
 	req := &api.ChatRequest{
-		Model:    c.model,
-		Messages: messages,
+		Model:     c.ActiveEndpoint().Model,
+		Messages:  c.withSupportedImages(ctx, stripThinking(messages)),
+		Think:     o.Think,
+		Options:   c.requestOptions(o),
+		KeepAlive: c.keepAlive,
 	}
 
 	// Add tools if available
-	if len(c.tools) > 0 {
-		req.Tools = c.convertToOllamaTools()
-		log.Printf("Ollama chat stream: Starting with %d tools available", len(c.tools))
-	} else {
-		log.Printf("Ollama chat stream: Starting without tools")
+	if tools := o.OfferedTools(c.GetTools()); len(tools) > 0 {
+		req.Tools = tool.ToOllama(tools)
 	}
+	logRequest(ctx, "Ollama chat stream", req)
+
+	core := func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+		// Cancel the stream when no chunk arrives within the timeout
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		timeout := c.timeout(o)
+		var stalled atomic.Bool
+		var idleTimer *time.Timer
+		if timeout > 0 {
+			idleTimer = time.AfterFunc(timeout, func() {
+				stalled.Store(true)
+				cancel()
+			})
+			defer idleTimer.Stop()
+		}
 
-	// Wrap callback to add logging
-	wrappedCallback := func(resp api.ChatResponse) error {
-		// Log tool calls if any
-		if len(resp.Message.ToolCalls) > 0 {
-			log.Printf("Ollama chat stream: Received %d tool calls", len(resp.Message.ToolCalls))
-			for i, toolCall := range resp.Message.ToolCalls {
-				log.Printf("  Tool call %d: %s", i+1, toolCall.Function.Name)
-				if toolCall.Function.Arguments != nil {
-					log.Printf("    Arguments: %v", toolCall.Function.Arguments)
-				}
+		var acc streamAccumulator
+		var thinkParser thinkParser
+
+		// Wrap callback to split off reasoning and reset the idle timer
+		wrappedCallback := func(resp api.ChatResponse) error {
+			if idleTimer != nil {
+				idleTimer.Reset(timeout)
+			}
+
+			// Move inline reasoning out of the content
+			content, thinking := thinkParser.Push(resp.Message.Content)
+			if resp.Done {
+				fc, ft := thinkParser.Flush()
+				content += fc
+				thinking += ft
 			}
+			resp.Message.Content = content
+			resp.Message.Thinking += thinking
+			if resp.Message.Thinking != "" && o.OnThinking != nil {
+				o.OnThinking(resp.Message.Thinking)
+			}
+			acc.Add(resp)
+
+			// Call the original callback
+			return callback(resp)
 		}
 
-		// Call the original callback
-		return callback(resp)
+		err := c.doChat(streamCtx, req, wrappedCallback)
+		// The API client ends a cancelled stream without an error
+		if err == nil && !acc.last.Done && streamCtx.Err() != nil {
+			err = streamCtx.Err()
+		}
+		if err != nil {
+			switch {
+			case stalled.Load() && ctx.Err() == nil:
+				err = &TimeoutError{
+					Timeout: timeout,
+					Stalled: acc.received,
+					Partial: acc.Message(),
+				}
+			case ctx.Err() != nil:
+				// Keep what the caller has already been shown
+				err = &llm.InterruptedError{
+					Partial: FromAPIMessage(acc.Message()),
+					Cause:   ctx.Err(),
+				}
+			}
+			return nil, err
+		}
+		return acc.Response(), nil
 	}
 
-	err := c.client.Chat(ctx, req, wrappedCallback)
+	finalResponse, err := c.chain(core)(ctx, req)
 	if err != nil {
-		log.Printf("Ollama chat stream: Request failed: %v", err)
-		return fmt.Errorf("streaming chat request failed: %w", err)
+		slog.Debug("Ollama chat stream: Request failed", "error", c.secrets.Redact(err))
+		return fmt.Errorf("streaming chat request failed: %w", classifyError(err))
 	}
 
-	log.Printf("Ollama chat stream: Completed successfully")
+	logResponse(ctx, "Ollama chat stream", finalResponse)
 	return nil
 }
 
 // ExecuteToolCall executes a tool call and returns the result
-func (c *Client) ExecuteToolCall(ctx context.Context, toolCall api.ToolCall) (string, error) {
-	log.Printf("Ollama tool execution: Executing tool call %s", toolCall.Function.Name)
-
-	// Find the tool by name
-	var targetTool *tool.Tool
-	for _, t := range c.tools {
-		if t.Function.Name == toolCall.Function.Name {
-			targetTool = &t
-			break
-		}
-	}
+func (c *Client) ExecuteToolCall(ctx context.Context, toolCall api.ToolCall) (*tool.Result, error) {
+	return c.tools.Execute(ctx, llm.ToolCall{
+		Name:      toolCall.Function.Name,
+		Arguments: toolCall.Function.Arguments,
+	})
+}
 
-	if targetTool == nil {
-		return "", fmt.Errorf("tool %s not found", toolCall.Function.Name)
+// HandleToolCallsInResponse executes the tool calls in a chat response and
+// returns the messages needed to continue the conversation.
+//
+// The returned slice is ordered so it can be appended to the history verbatim:
+// the first element is the assistant message that issued the tool calls,
+// followed by one "tool" message per call in the same order as
+// response.Message.ToolCalls. Appending only the tool messages without the
+// preceding assistant message produces a malformed transcript.
+func (c *Client) HandleToolCallsInResponse(ctx context.Context, response *api.ChatResponse) ([]api.Message, error) {
+	messages, err := c.tools.HandleToolCalls(ctx, &llm.Response{Message: FromAPIMessage(response.Message)})
+	if err != nil || len(messages) == 0 {
+		return nil, err
 	}
-	// Parse arguments
-	arguments := map[string]any(toolCall.Function.Arguments)
 
-	log.Printf("Ollama tool execution: Tool name: %s", toolCall.Function.Name)
-	log.Printf("Ollama tool execution: Arguments: %v", arguments)
+	// Keep the original assistant message rather than its round-tripped copy
+	out := ToAPIMessages(messages)
+	out[0] = response.Message
+	return out, nil
+}
 
-	// Execute the tool using its executor
-	result, err := targetTool.Execute(ctx, arguments)
-	if err != nil {
-		log.Printf("Ollama tool execution: Execution failed: %v", err)
-		return "", fmt.Errorf("tool execution failed: %w", err)
+// logRequest logs a chat request at debug level, with its size as JSON
+func logRequest(ctx context.Context, kind string, req *api.ChatRequest) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
 	}
-
-	log.Printf("Ollama tool execution: Result: %s", result)
-	return result, nil
+	slog.Debug(kind+": Sending request", "model", req.Model, "messages", len(req.Messages), "tools", len(req.Tools), "bytes", jsonSize(req))
 }
 
-// HandleToolCallsInResponse processes tool calls in a chat response and returns updated messages
-func (c *Client) HandleToolCallsInResponse(ctx context.Context, response *api.ChatResponse) ([]api.Message, error) {
-	if len(response.Message.ToolCalls) == 0 {
-		return nil, nil
+// logResponse logs a chat response and its tool calls at debug level, with
+// its size as JSON
+func logResponse(ctx context.Context, kind string, resp *api.ChatResponse) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
 	}
-
-	log.Printf("Ollama tool handling: Processing %d tool calls", len(response.Message.ToolCalls))
-
-	var newMessages []api.Message
-
-	for _, toolCall := range response.Message.ToolCalls {
-		result, err := c.ExecuteToolCall(ctx, toolCall)
-		if err != nil {
-			log.Printf("Ollama tool handling: Tool call failed: %v", err)
-			result = fmt.Sprintf("Tool execution failed: %v", err)
-		}
-
-		// Add tool result as a message
-		toolMessage := api.Message{
-			Role:    "tool",
-			Content: result,
-		}
-		newMessages = append(newMessages, toolMessage)
+	slog.Debug(kind+": Received response", "done_reason", resp.DoneReason, "tool_calls", len(resp.Message.ToolCalls), "bytes", jsonSize(resp))
+	for _, toolCall := range resp.Message.ToolCalls {
+		slog.Debug(kind+": Tool call", "tool", toolCall.Function.Name, "arguments", toolCall.Function.Arguments)
 	}
+}
 
-	log.Printf("Ollama tool handling: Created %d tool result messages", len(newMessages))
-	return newMessages, nil
+// jsonSize returns the size of v encoded as JSON (0: not encodable)
+func jsonSize(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
 }
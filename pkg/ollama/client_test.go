@@ -0,0 +1,340 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/snowmerak/ttobot/lib/llm"
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// fakeOllama serves /api/chat with handler and returns a client for it
+func fakeOllama(t *testing.T, handler func(w http.ResponseWriter, req *api.ChatRequest)) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		handler(w, &req)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(ClientOptions{URL: server.URL, Model: "test-model"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// writeChunks writes each chunk as a line of JSON, flushing after each
+func writeChunks(t *testing.T, w http.ResponseWriter, chunks ...api.ChatResponse) {
+	for _, chunk := range chunks {
+		if err := json.NewEncoder(w).Encode(chunk); err != nil {
+			t.Errorf("failed to write chunk: %v", err)
+			return
+		}
+		w.(http.Flusher).Flush()
+	}
+}
+
+var (
+	testToolCall = api.ToolCall{Function: api.ToolCallFunction{
+		Name:      "read_file",
+		Arguments: api.ToolCallFunctionArguments{"path": "go.mod"},
+	}}
+	testMetrics = api.Metrics{
+		TotalDuration:   2 * time.Second,
+		LoadDuration:    100 * time.Millisecond,
+		PromptEvalCount: 42,
+		EvalCount:       7,
+	}
+)
+
+// checkResponse compares the parts of a response the client assembles
+func checkResponse(t *testing.T, got *api.ChatResponse, content string, toolCalls []api.ToolCall, doneReason string) {
+	t.Helper()
+	if got.Message.Role != "assistant" {
+		t.Errorf("role = %q, want assistant", got.Message.Role)
+	}
+	if got.Message.Content != content {
+		t.Errorf("content = %q, want %q", got.Message.Content, content)
+	}
+	if !reflect.DeepEqual(got.Message.ToolCalls, toolCalls) {
+		t.Errorf("tool calls = %+v, want %+v", got.Message.ToolCalls, toolCalls)
+	}
+	if !got.Done || got.DoneReason != doneReason {
+		t.Errorf("done = %v, done reason = %q; want done with %q", got.Done, got.DoneReason, doneReason)
+	}
+	if got.Metrics != testMetrics {
+		t.Errorf("metrics = %+v, want %+v", got.Metrics, testMetrics)
+	}
+}
+
+func TestChatSingleResponse(t *testing.T) {
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		if req.Stream == nil || *req.Stream {
+			t.Errorf("chat request asked for a stream")
+		}
+		if req.Model != "test-model" || len(req.Messages) != 1 || req.Messages[0].Content != "hi" {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		writeChunks(t, w, api.ChatResponse{
+			Model:      req.Model,
+			Message:    api.Message{Role: "assistant", Content: "Reading it.", ToolCalls: []api.ToolCall{testToolCall}},
+			Done:       true,
+			DoneReason: DoneReasonStop,
+			Metrics:    testMetrics,
+		})
+	})
+
+	resp, err := client.Chat(context.Background(), []api.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, resp, "Reading it.", []api.ToolCall{testToolCall}, DoneReasonStop)
+}
+
+func TestChatOffersNamedTools(t *testing.T) {
+	var offered [][]string
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		var names []string
+		for _, t := range req.Tools {
+			names = append(names, t.Function.Name)
+		}
+		offered = append(offered, names)
+		writeChunks(t, w, api.ChatResponse{Message: api.Message{Role: "assistant", Content: "ok"}, Done: true})
+	})
+	client.SetTools([]tool.Tool{
+		{Name: "fs:read_file", Function: tool.ToolFunction{Name: "fs:read_file"}},
+		{Name: "git:git_log", Function: tool.ToolFunction{Name: "git:git_log"}},
+		{Name: "github:search_issues", Function: tool.ToolFunction{Name: "github:search_issues"}},
+	})
+
+	messages := []api.Message{{Role: "user", Content: "hi"}}
+	for _, opts := range []ChatOpts{
+		{},
+		{ToolNames: []string{"github:search_issues", "fs:read_file"}},
+		{ToolNames: []string{}},
+		{ToolNames: []string{"fs:read_file"}, NoTools: true},
+	} {
+		if _, err := client.Chat(context.Background(), messages, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.ChatStream(context.Background(), messages, func(api.ChatResponse) error { return nil }, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := [][]string{
+		{"fs:read_file", "git:git_log", "github:search_issues"},
+		{"fs:read_file", "github:search_issues"},
+		nil,
+		nil,
+	}
+	for i, names := range want {
+		for _, got := range offered[2*i : 2*i+2] {
+			if !reflect.DeepEqual(got, names) {
+				t.Errorf("request %d offered %v, want %v", i, got, names)
+			}
+		}
+	}
+}
+
+func TestChatChunkedResponse(t *testing.T) {
+	// A server may send chunks even when no stream is asked for
+	second := api.ToolCall{Function: api.ToolCallFunction{Name: "list_dir", Arguments: api.ToolCallFunctionArguments{"path": "."}}}
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		writeChunks(t, w,
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "The answer "}},
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "is", ToolCalls: []api.ToolCall{testToolCall}}},
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: " long", ToolCalls: []api.ToolCall{second}}},
+			api.ChatResponse{Message: api.Message{Role: "assistant"}, Done: true, DoneReason: DoneReasonLength, Metrics: testMetrics},
+		)
+	})
+
+	resp, err := client.Chat(context.Background(), []api.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, resp, "The answer is long", []api.ToolCall{testToolCall, second}, DoneReasonLength)
+}
+
+func TestChatSplitsThinking(t *testing.T) {
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		writeChunks(t, w,
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "<think>Let me see"}},
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: ".</think>Hello"}},
+			api.ChatResponse{Message: api.Message{Role: "assistant"}, Done: true, DoneReason: DoneReasonStop, Metrics: testMetrics},
+		)
+	})
+
+	resp, err := client.Chat(context.Background(), []api.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, resp, "Hello", nil, DoneReasonStop)
+	if resp.Message.Thinking != "Let me see." {
+		t.Errorf("thinking = %q, want %q", resp.Message.Thinking, "Let me see.")
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		if req.Stream != nil && !*req.Stream {
+			t.Errorf("stream request did not ask for a stream")
+		}
+		writeChunks(t, w,
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "Hel"}},
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "lo", ToolCalls: []api.ToolCall{testToolCall}}},
+			api.ChatResponse{Message: api.Message{Role: "assistant"}, Done: true, DoneReason: DoneReasonStop, Metrics: testMetrics},
+		)
+	})
+
+	var chunks []api.ChatResponse
+	err := client.ChatStream(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, func(resp api.ChatResponse) error {
+		chunks = append(chunks, resp)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	var acc streamAccumulator
+	for _, chunk := range chunks {
+		acc.Add(chunk)
+	}
+	checkResponse(t, acc.Response(), "Hello", []api.ToolCall{testToolCall}, DoneReasonStop)
+}
+
+func TestChatStreamInterrupted(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		writeChunks(t, w,
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "<think>Hmm</think>Partial "}},
+			api.ChatResponse{Message: api.Message{Role: "assistant", Content: "answer", ToolCalls: []api.ToolCall{testToolCall}}},
+		)
+		// Hang mid-response until the test ends
+		<-hang
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var received int
+	err := client.ChatStream(ctx, []api.Message{{Role: "user", Content: "hi"}}, func(resp api.ChatResponse) error {
+		received++
+		if received == 2 {
+			cancel()
+		}
+		return nil
+	})
+
+	var interrupted *llm.InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("got %v, want an InterruptedError", err)
+	}
+	if !errors.Is(interrupted.Cause, context.Canceled) {
+		t.Errorf("cause = %v, want context.Canceled", interrupted.Cause)
+	}
+	if received != 2 {
+		t.Errorf("got %d chunks, want 2", received)
+	}
+	partial := interrupted.Partial
+	if partial.Role != llm.RoleAssistant || partial.Content != "Partial answer" || partial.Thinking != "Hmm" {
+		t.Errorf("partial = %+v, want the assistant text so far", partial)
+	}
+	if len(partial.ToolCalls) != 1 || partial.ToolCalls[0].Name != "read_file" {
+		t.Errorf("partial tool calls = %+v, want the read_file call", partial.ToolCalls)
+	}
+}
+
+func TestHandleToolCallsInResponseOrder(t *testing.T) {
+	// Earlier calls finish later, so completion order is the reverse of call order
+	delayed := func(name string, delay time.Duration, result *tool.Result) tool.Tool {
+		return tool.Tool{
+			Name:     name,
+			Function: tool.ToolFunction{Name: name, Parameters: tool.ParameterSchema{Type: "object"}},
+			Executor: tool.ExecFunc(func(ctx context.Context, _ map[string]any) (*tool.Result, error) {
+				time.Sleep(delay)
+				return result, nil
+			}),
+		}
+	}
+
+	for _, parallel := range []int{0, 4} {
+		t.Run(fmt.Sprintf("parallel=%d", parallel), func(t *testing.T) {
+			client, err := NewClient(ClientOptions{URL: "http://127.0.0.1:0", Model: "test-model", ParallelToolCalls: parallel})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.SetTools([]tool.Tool{
+				delayed("first", 60*time.Millisecond, tool.TextResult("result one")),
+				delayed("second", 30*time.Millisecond, tool.ErrorResult("result two failed")),
+				delayed("third", 0, tool.TextResult("result three")),
+			})
+
+			call := func(name string) api.ToolCall {
+				return api.ToolCall{Function: api.ToolCallFunction{Name: name, Arguments: api.ToolCallFunctionArguments{}}}
+			}
+			response := &api.ChatResponse{Message: api.Message{
+				Role:      "assistant",
+				Content:   "Let me check.",
+				Thinking:  "Three lookups",
+				ToolCalls: []api.ToolCall{call("first"), call("second"), call("missing"), call("third")},
+			}}
+
+			messages, err := client.HandleToolCallsInResponse(context.Background(), response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != len(response.Message.ToolCalls)+1 {
+				t.Fatalf("got %d messages, want the assistant message and %d tool messages", len(messages), len(response.Message.ToolCalls))
+			}
+			if !reflect.DeepEqual(messages[0], response.Message) {
+				t.Errorf("first message = %+v, want the assistant message unchanged", messages[0])
+			}
+
+			wants := []string{"result one", "result two failed", "missing", "result three"}
+			for i, message := range messages[1:] {
+				name := response.Message.ToolCalls[i].Function.Name
+				if message.Role != "tool" || message.ToolName != name {
+					t.Errorf("message %d = %s from %q, want a tool message from %q", i+1, message.Role, message.ToolName, name)
+				}
+				if !strings.Contains(message.Content, wants[i]) {
+					t.Errorf("message %d content = %q, want it to mention %q", i+1, message.Content, wants[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleToolCallsInResponseWithoutCalls(t *testing.T) {
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		t.Error("no request should be sent")
+	})
+	messages, err := client.HandleToolCallsInResponse(context.Background(), &api.ChatResponse{
+		Message: api.Message{Role: "assistant", Content: "Done."},
+	})
+	if err != nil || messages != nil {
+		t.Fatalf("got %v, %v; want nothing to append", messages, err)
+	}
+}
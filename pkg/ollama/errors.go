@@ -0,0 +1,102 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// TimeoutError is returned when a chat request exceeds its timeout
+type TimeoutError struct {
+	// Timeout is the limit that was exceeded
+	Timeout time.Duration
+
+	// Stalled is true when the stream produced output and then went quiet,
+	// false when no response arrived at all
+	Stalled bool
+
+	// Partial holds whatever was received before the timeout fired
+	Partial api.Message
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Stalled {
+		return fmt.Sprintf("model stalled mid-stream: no output for %s (%d bytes received)", e.Timeout, len(e.Partial.Content))
+	}
+	return fmt.Sprintf("no response from model within %s", e.Timeout)
+}
+
+// Unwrap allows errors.Is(err, context.DeadlineExceeded)
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Error classes for failed requests, shared with other providers
+var (
+	ErrConnection      = llm.ErrConnection
+	ErrModelNotFound   = llm.ErrModelNotFound
+	ErrContextTooLarge = llm.ErrContextTooLarge
+	ErrUnauthorized    = llm.ErrUnauthorized
+	ErrTimeout         = llm.ErrTimeout
+)
+
+// Is allows errors.Is(err, ErrTimeout)
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// classifyError wraps err with the error class matching its cause, if any
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	if class := errorClass(err); class != nil {
+		return fmt.Errorf("%w: %w", class, err)
+	}
+	return err
+}
+
+// errorClass detects the error class from the status code or message
+func errorClass(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrUnauthorized
+		case http.StatusRequestEntityTooLarge:
+			return ErrContextTooLarge
+		case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+			return ErrTimeout
+		}
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrConnection
+	}
+
+	// Ollama streams some failures back as plain messages
+	if isModelNotFound(err) {
+		return ErrModelNotFound
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "context length") || strings.Contains(msg, "context window") || strings.Contains(msg, "too large") {
+		return ErrContextTooLarge
+	}
+	if strings.Contains(msg, "unauthorized") {
+		return ErrUnauthorized
+	}
+	return nil
+}
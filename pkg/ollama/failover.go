@@ -0,0 +1,177 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// DefaultFailoverCooldown is how long a failed endpoint is skipped
+const DefaultFailoverCooldown = 30 * time.Second
+
+// Endpoint is an Ollama server and the model to use on it
+type Endpoint struct {
+	URL   string
+	Model string
+}
+
+// String renders the endpoint as "model@url"
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%s@%s", e.Model, e.URL)
+}
+
+// FailoverEvent describes a switch away from an unavailable endpoint
+type FailoverEvent struct {
+	From Endpoint
+	To   Endpoint
+	Err  error
+}
+
+// endpoint is a candidate backend with its health state
+type endpoint struct {
+	Endpoint
+	client    *api.Client
+	deadUntil time.Time
+}
+
+// newEndpoints builds the primary endpoint followed by the fallbacks
+func newEndpoints(opt ClientOptions, hc *http.Client) ([]*endpoint, error) {
+	candidates := append([]Endpoint{{URL: opt.URL, Model: opt.Model}}, opt.Fallbacks...)
+
+	endpoints := make([]*endpoint, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.URL == "" {
+			candidate.URL = opt.URL
+		}
+		if candidate.Model == "" {
+			candidate.Model = opt.Model
+		}
+
+		u, err := url.Parse(candidate.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %s: %w", candidate.URL, err)
+		}
+		endpoints = append(endpoints, &endpoint{
+			Endpoint: candidate,
+			client:   api.NewClient(u, hc),
+		})
+	}
+	return endpoints, nil
+}
+
+// ActiveEndpoint returns the endpoint that served the most recent request
+func (c *Client) ActiveEndpoint() Endpoint {
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+	return c.endpoints[c.active].Endpoint
+}
+
+// activeClient returns the API client and model of the active endpoint
+func (c *Client) activeClient() (*api.Client, string) {
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+	ep := c.endpoints[c.active]
+	return ep.client, ep.Model
+}
+
+// candidates returns the endpoints to try in order: healthy ones first,
+// then those still cooling down as a last resort
+func (c *Client) candidates() []int {
+	c.endpointsLock.Lock()
+	defer c.endpointsLock.Unlock()
+
+	now := time.Now()
+	var healthy, cooling []int
+	for i, ep := range c.endpoints {
+		if now.Before(ep.deadUntil) {
+			cooling = append(cooling, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// doChat sends a chat request, failing over to the next endpoint when one is
+// unreachable or lacks the model. Requests that already produced output are
+// never retried elsewhere.
+func (c *Client) doChat(ctx context.Context, req *api.ChatRequest, fn api.ChatResponseFunc) error {
+	var lastErr error
+	var lastEndpoint *Endpoint
+
+	for _, i := range c.candidates() {
+		ep := c.endpoints[i]
+		if lastEndpoint != nil {
+			c.notifyFailover(FailoverEvent{From: *lastEndpoint, To: ep.Endpoint, Err: lastErr})
+		}
+
+		req.Model = ep.Model
+		started := false
+		err := ep.client.Chat(ctx, req, func(resp api.ChatResponse) error {
+			started = true
+			return fn(resp)
+		})
+
+		c.endpointsLock.Lock()
+		if err == nil {
+			ep.deadUntil = time.Time{}
+			c.active = i
+			c.endpointsLock.Unlock()
+			return nil
+		}
+		unavailable := !started && ctx.Err() == nil && isUnavailable(err)
+		if unavailable {
+			ep.deadUntil = time.Now().Add(c.failoverCooldown)
+		}
+		c.endpointsLock.Unlock()
+
+		if !unavailable {
+			return err
+		}
+		lastErr = err
+		lastEndpoint = &ep.Endpoint
+	}
+
+	return lastErr
+}
+
+// notifyFailover logs a failover and reports it to the configured callback
+func (c *Client) notifyFailover(event FailoverEvent) {
+	slog.Warn("Ollama failover: Server unavailable", "server", event.From, "error", c.secrets.Redact(event.Err), "next", event.To)
+	if c.onFailover != nil {
+		c.onFailover(event)
+	}
+}
+
+// isUnavailable reports whether an error means the endpoint cannot serve the
+// request at all, as opposed to a problem with the request itself
+func isUnavailable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return isModelNotFound(err)
+}
+
+// isModelNotFound matches the error Ollama streams back for a missing model
+func isModelNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "model") && strings.Contains(msg, "not found")
+}
@@ -0,0 +1,84 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/types/model"
+)
+
+// SupportsVision reports whether the active model accepts images.
+// The answer is cached per endpoint after the first successful lookup.
+func (c *Client) SupportsVision(ctx context.Context) (bool, error) {
+	capabilities, err := c.modelCapabilities(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(capabilities, model.CapabilityVision), nil
+}
+
+// CheckModel implements llm.ModelChecker: it looks up the active endpoint's
+// model on its server
+func (c *Client) CheckModel(ctx context.Context) error {
+	_, err := c.modelCapabilities(ctx)
+	return err
+}
+
+// modelCapabilities returns the capabilities of the active endpoint's model
+func (c *Client) modelCapabilities(ctx context.Context) ([]model.Capability, error) {
+	client, modelName := c.activeClient()
+	key := c.ActiveEndpoint()
+
+	c.capabilitiesLock.Lock()
+	defer c.capabilitiesLock.Unlock()
+
+	if capabilities, ok := c.capabilities[key]; ok {
+		return capabilities, nil
+	}
+
+	resp, err := client.Show(ctx, &api.ShowRequest{Model: modelName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up model %s: %w", modelName, classifyError(err))
+	}
+	if c.capabilities == nil {
+		c.capabilities = make(map[Endpoint][]model.Capability)
+	}
+	c.capabilities[key] = resp.Capabilities
+	return resp.Capabilities, nil
+}
+
+// imagePlaceholder describes images a model without vision support cannot see
+func imagePlaceholder(count int) string {
+	if count == 1 {
+		return "[1 image omitted: the current model cannot view images]"
+	}
+	return fmt.Sprintf("[%d images omitted: the current model cannot view images]", count)
+}
+
+// withSupportedImages replaces image attachments with a textual placeholder
+// when the model cannot view them
+func (c *Client) withSupportedImages(ctx context.Context, messages []api.Message) []api.Message {
+	hasImages := slices.ContainsFunc(messages, func(m api.Message) bool { return len(m.Images) > 0 })
+	if !hasImages {
+		return messages
+	}
+
+	vision, err := c.SupportsVision(ctx)
+	if err != nil || vision {
+		// Let the server decide when capabilities are unknown
+		return messages
+	}
+
+	out := make([]api.Message, len(messages))
+	for i, m := range messages {
+		if len(m.Images) > 0 {
+			m.Content = strings.TrimSpace(m.Content + "\n" + imagePlaceholder(len(m.Images)))
+			m.Images = nil
+		}
+		out[i] = m
+	}
+	return out
+}
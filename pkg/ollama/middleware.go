@@ -0,0 +1,123 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// ChatFunc performs a chat request and returns the complete response
+type ChatFunc func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error)
+
+// ChatMiddleware wraps a ChatFunc to observe or modify requests and responses.
+// For streaming requests the middleware sees the assembled final response
+// after every chunk has been delivered to the caller.
+type ChatMiddleware func(next ChatFunc) ChatFunc
+
+// Use registers a middleware around both Chat and ChatStream. Middlewares run
+// in registration order: the first one registered sees the request first and
+// the response last.
+func (c *Client) Use(mw ChatMiddleware) {
+	c.middlewareLock.Lock()
+	defer c.middlewareLock.Unlock()
+	c.middlewares = append(c.middlewares, mw)
+}
+
+// chain wraps core with the registered middlewares
+func (c *Client) chain(core ChatFunc) ChatFunc {
+	c.middlewareLock.RLock()
+	defer c.middlewareLock.RUnlock()
+
+	handler := core
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	return handler
+}
+
+// transcriptEntry is one line written by TranscriptRecorder
+type transcriptEntry struct {
+	Time     time.Time         `json:"time"`
+	Duration string            `json:"duration"`
+	Model    string            `json:"model"`
+	Request  []api.Message     `json:"request"`
+	Response *api.ChatResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// TranscriptRecorder returns a middleware that appends every prompt/response
+// pair to w as one JSON object per line
+func TranscriptRecorder(w io.Writer) ChatMiddleware {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+
+	return func(next ChatFunc) ChatFunc {
+		return func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+			started := time.Now()
+			resp, err := next(ctx, req)
+
+			entry := transcriptEntry{
+				Time:     started,
+				Duration: time.Since(started).String(),
+				Model:    req.Model,
+				Request:  req.Messages,
+				Response: resp,
+			}
+			// A RedactMessages registered before the recorder has not yet
+			// redacted the response
+			if redact, ok := ctx.Value(redactorKey{}).(func(string) string); ok && resp != nil {
+				redacted := *resp
+				redacted.Message.Content = redact(redacted.Message.Content)
+				entry.Response = &redacted
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			_ = encoder.Encode(entry) // Recording must never fail the request
+			return resp, err
+		}
+	}
+}
+
+// redactorKey is the context key under which RedactMessages passes its
+// redaction to the middlewares it wraps
+type redactorKey struct{}
+
+// RedactMessages returns a middleware that replaces every match of the
+// patterns with "[REDACTED]" in outgoing message content and in the response.
+// Register it before a recorder so recorded transcripts are redacted too.
+// A stream has delivered its chunks to the caller before the middleware
+// sees the response, so only the request and the transcript are redacted.
+func RedactMessages(patterns ...*regexp.Regexp) ChatMiddleware {
+	redact := func(s string) string {
+		for _, pattern := range patterns {
+			s = pattern.ReplaceAllString(s, "[REDACTED]")
+		}
+		return s
+	}
+
+	return func(next ChatFunc) ChatFunc {
+		return func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+			redacted := *req
+			redacted.Messages = make([]api.Message, len(req.Messages))
+			for i, m := range req.Messages {
+				m.Content = redact(m.Content)
+				redacted.Messages[i] = m
+			}
+
+			resp, err := next(context.WithValue(ctx, redactorKey{}, redact), &redacted)
+			if resp != nil {
+				resp.Message.Content = redact(resp.Message.Content)
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// transcriptLines decodes the JSONL written by a TranscriptRecorder
+func transcriptLines(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("transcript line is not JSON: %v\n%s", err, scanner.Bytes())
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// keys returns the sorted keys of m
+func keys(m map[string]any) []string {
+	var out []string
+	for key := range m {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestTranscriptRecorder(t *testing.T) {
+	fail := false
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		if fail {
+			http.Error(w, `{"error":"model crashed"}`, http.StatusInternalServerError)
+			return
+		}
+		writeChunks(t, w, api.ChatResponse{
+			Model:      req.Model,
+			Message:    api.Message{Role: "assistant", Content: "Hi there"},
+			Done:       true,
+			DoneReason: DoneReasonStop,
+			Metrics:    testMetrics,
+		})
+	})
+	var transcript bytes.Buffer
+	client.Use(TranscriptRecorder(&transcript))
+
+	messages := []api.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "hi"}}
+	if _, err := client.Chat(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ChatStream(context.Background(), messages, func(api.ChatResponse) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if _, err := client.Chat(context.Background(), messages); err == nil {
+		t.Fatal("the failing request succeeded")
+	}
+
+	lines := transcriptLines(t, transcript.Bytes())
+	if len(lines) != 3 {
+		t.Fatalf("got %d transcript lines, want 3:\n%s", len(lines), transcript.String())
+	}
+	for i, line := range lines[:2] {
+		if got, want := strings.Join(keys(line), ","), "duration,model,request,response,time"; got != want {
+			t.Errorf("line %d has keys %s, want %s", i, got, want)
+		}
+		if line["model"] != "test-model" {
+			t.Errorf("line %d model = %v", i, line["model"])
+		}
+		if _, err := time.Parse(time.RFC3339Nano, line["time"].(string)); err != nil {
+			t.Errorf("line %d time: %v", i, err)
+		}
+		if _, err := time.ParseDuration(line["duration"].(string)); err != nil {
+			t.Errorf("line %d duration: %v", i, err)
+		}
+		request := line["request"].([]any)
+		if len(request) != 2 || request[1].(map[string]any)["content"] != "hi" {
+			t.Errorf("line %d request = %v", i, request)
+		}
+		response := line["response"].(map[string]any)
+		if response["message"].(map[string]any)["content"] != "Hi there" || response["done_reason"] != DoneReasonStop {
+			t.Errorf("line %d response = %v", i, response)
+		}
+		if response["eval_count"] != float64(testMetrics.EvalCount) {
+			t.Errorf("line %d response lacks the metrics: %v", i, response)
+		}
+	}
+
+	failed := lines[2]
+	if got, want := strings.Join(keys(failed), ","), "duration,error,model,request,time"; got != want {
+		t.Errorf("failed line has keys %s, want %s", got, want)
+	}
+	if !strings.Contains(failed["error"].(string), "model crashed") {
+		t.Errorf("failed line error = %v", failed["error"])
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+		writeChunks(t, w, api.ChatResponse{Message: api.Message{Role: "assistant", Content: "ok"}, Done: true})
+	})
+
+	var mu sync.Mutex
+	var order []string
+	trace := func(name string) ChatMiddleware {
+		return func(next ChatFunc) ChatFunc {
+			return func(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+				mu.Lock()
+				order = append(order, name+" request")
+				mu.Unlock()
+				resp, err := next(ctx, req)
+				mu.Lock()
+				order = append(order, name+" response")
+				mu.Unlock()
+				return resp, err
+			}
+		}
+	}
+	client.Use(trace("first"))
+	client.Use(trace("second"))
+
+	if _, err := client.Chat(context.Background(), []api.Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "first request,second request,second response,first response"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("order = %s, want %s", got, want)
+	}
+}
+
+func TestRedactMessages(t *testing.T) {
+	secret := regexp.MustCompile(`sk-[a-z0-9]+`)
+
+	tests := []struct {
+		name string
+		call func(client *Client, messages []api.Message) (string, error)
+		// returned is the content the caller receives
+		returned string
+	}{
+		{
+			name: "chat",
+			call: func(client *Client, messages []api.Message) (string, error) {
+				resp, err := client.Chat(context.Background(), messages)
+				if err != nil {
+					return "", err
+				}
+				return resp.Message.Content, nil
+			},
+			returned: "Your key is [REDACTED].",
+		},
+		{
+			name: "stream",
+			call: func(client *Client, messages []api.Message) (string, error) {
+				var sb strings.Builder
+				err := client.ChatStream(context.Background(), messages, func(resp api.ChatResponse) error {
+					sb.WriteString(resp.Message.Content)
+					return nil
+				})
+				return sb.String(), err
+			},
+			// Chunks reach the caller before the middleware runs
+			returned: "Your key is sk-abc123.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []api.Message
+			client := fakeOllama(t, func(w http.ResponseWriter, req *api.ChatRequest) {
+				received = req.Messages
+				writeChunks(t, w,
+					api.ChatResponse{Message: api.Message{Role: "assistant", Content: "Your key is sk-"}},
+					api.ChatResponse{Message: api.Message{Role: "assistant", Content: "abc123."}},
+					api.ChatResponse{Message: api.Message{Role: "assistant"}, Done: true, DoneReason: DoneReasonStop},
+				)
+			})
+			var transcript bytes.Buffer
+			client.Use(RedactMessages(secret))
+			client.Use(TranscriptRecorder(&transcript))
+
+			messages := []api.Message{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: "Is sk-abc123 my key? And sk-zz9?"},
+			}
+			returned, err := tt.call(client, messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(received) != 2 || received[1].Content != "Is [REDACTED] my key? And [REDACTED]?" {
+				t.Errorf("the server received %+v", received)
+			}
+			if messages[1].Content != "Is sk-abc123 my key? And sk-zz9?" {
+				t.Errorf("the caller's messages were changed: %q", messages[1].Content)
+			}
+			if returned != tt.returned {
+				t.Errorf("the caller got %q, want %q", returned, tt.returned)
+			}
+			if secret.Match(transcript.Bytes()) {
+				t.Errorf("the transcript holds a secret:\n%s", transcript.String())
+			}
+			lines := transcriptLines(t, transcript.Bytes())
+			if len(lines) != 1 {
+				t.Fatalf("got %d transcript lines, want 1", len(lines))
+			}
+			content := lines[0]["response"].(map[string]any)["message"].(map[string]any)["content"]
+			if content != "Your key is [REDACTED]." {
+				t.Errorf("the transcript response = %q", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/ollama/ollama/types/model"
+)
+
+// Version returns the version of the active endpoint's Ollama server,
+// checking that it is reachable
+func (c *Client) Version(ctx context.Context) (string, error) {
+	client, _ := c.activeClient()
+	version, err := client.Version(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach Ollama at %s: %w", c.ActiveEndpoint().URL, classifyError(err))
+	}
+	return version, nil
+}
+
+// SupportsTools reports whether the active model accepts tools.
+// The answer is cached per endpoint like SupportsVision's.
+func (c *Client) SupportsTools(ctx context.Context) (bool, error) {
+	capabilities, err := c.modelCapabilities(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(capabilities, model.CapabilityTools), nil
+}
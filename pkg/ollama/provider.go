@@ -0,0 +1,140 @@
+package ollama
+
+import (
+	"context"
+
+	"github.com/ollama/ollama/api"
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// Provider adapts a Client to llm.ChatProvider, llm.ToolHandler,
+// llm.PromptSource, and llm.ModelChecker
+type Provider struct {
+	client *Client
+}
+
+// NewProvider wraps a client for use with provider-agnostic code
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client}
+}
+
+// Client returns the underlying client for Ollama-specific features
+func (p *Provider) Client() *Client {
+	return p.client
+}
+
+// Chat implements llm.ChatProvider
+func (p *Provider) Chat(ctx context.Context, messages []llm.Message, opts llm.Opts) (*llm.Response, error) {
+	resp, err := p.client.Chat(ctx, ToAPIMessages(messages), opts)
+	if err != nil {
+		return nil, err
+	}
+	return FromAPIResponse(resp), nil
+}
+
+// ChatStream implements llm.ChatProvider
+func (p *Provider) ChatStream(ctx context.Context, messages []llm.Message, callback func(llm.Response) error, opts llm.Opts) error {
+	return p.client.ChatStream(ctx, ToAPIMessages(messages), func(resp api.ChatResponse) error {
+		return callback(*FromAPIResponse(&resp))
+	}, opts)
+}
+
+// HandleToolCalls implements llm.ToolHandler
+func (p *Provider) HandleToolCalls(ctx context.Context, response *llm.Response) ([]llm.Message, error) {
+	return p.client.tools.HandleToolCalls(ctx, response)
+}
+
+// Prompts implements llm.PromptSource
+func (p *Provider) Prompts() llm.Prompts {
+	return p.client.tools.Prompts()
+}
+
+// CheckModel implements llm.ModelChecker
+func (p *Provider) CheckModel(ctx context.Context) error {
+	return p.client.CheckModel(ctx)
+}
+
+// UsageFromResponse extracts the metrics of a single chat response
+func UsageFromResponse(resp *api.ChatResponse) llm.Usage {
+	if resp == nil || !resp.Done {
+		return llm.Usage{}
+	}
+	return llm.Usage{
+		Requests:         1,
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalDuration:    resp.TotalDuration,
+		LoadDuration:     resp.LoadDuration,
+	}
+}
+
+// FromAPIResponse converts an Ollama response
+func FromAPIResponse(resp *api.ChatResponse) *llm.Response {
+	return &llm.Response{
+		Message:    FromAPIMessage(resp.Message),
+		Done:       resp.Done,
+		DoneReason: resp.DoneReason,
+		Usage:      UsageFromResponse(resp),
+	}
+}
+
+// ToAPIMessage converts a message to the Ollama type
+func ToAPIMessage(m llm.Message) api.Message {
+	msg := api.Message{
+		Role:     m.Role,
+		Content:  m.Content,
+		Thinking: m.Thinking,
+		ToolName: m.ToolName,
+	}
+	for _, image := range m.Images {
+		msg.Images = append(msg.Images, api.ImageData(image))
+	}
+	for i, call := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, api.ToolCall{
+			Function: api.ToolCallFunction{
+				Index:     i,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		})
+	}
+	return msg
+}
+
+// FromAPIMessage converts an Ollama message
+func FromAPIMessage(m api.Message) llm.Message {
+	msg := llm.Message{
+		Role:     m.Role,
+		Content:  m.Content,
+		Thinking: m.Thinking,
+		ToolName: m.ToolName,
+	}
+	for _, image := range m.Images {
+		msg.Images = append(msg.Images, []byte(image))
+	}
+	for _, call := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return msg
+}
+
+// ToAPIMessages converts a message list to the Ollama type
+func ToAPIMessages(messages []llm.Message) []api.Message {
+	out := make([]api.Message, len(messages))
+	for i, m := range messages {
+		out[i] = ToAPIMessage(m)
+	}
+	return out
+}
+
+// FromAPIMessages converts an Ollama message list
+func FromAPIMessages(messages []api.Message) []llm.Message {
+	out := make([]llm.Message, len(messages))
+	for i, m := range messages {
+		out[i] = FromAPIMessage(m)
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package ollama
+
+import (
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// streamAccumulator assembles streamed chunks into a complete response
+type streamAccumulator struct {
+	content   strings.Builder
+	thinking  strings.Builder
+	toolCalls []api.ToolCall
+	last      api.ChatResponse
+	received  bool
+}
+
+// Add records a chunk
+func (a *streamAccumulator) Add(resp api.ChatResponse) {
+	a.received = true
+	a.content.WriteString(resp.Message.Content)
+	a.thinking.WriteString(resp.Message.Thinking)
+	a.toolCalls = append(a.toolCalls, resp.Message.ToolCalls...)
+	a.last = resp
+}
+
+// Message returns the assistant message assembled so far
+func (a *streamAccumulator) Message() api.Message {
+	return api.Message{
+		Role:      "assistant",
+		Content:   a.content.String(),
+		Thinking:  a.thinking.String(),
+		ToolCalls: a.toolCalls,
+	}
+}
+
+// Response returns the assembled response, carrying the metrics and done
+// reason of the final chunk
+func (a *streamAccumulator) Response() *api.ChatResponse {
+	resp := a.last
+	resp.Message = a.Message()
+	return &resp
+}
@@ -0,0 +1,135 @@
+package ollama
+
+import (
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// thinkParser separates a leading <think>…</think> segment from model output.
+// It is fed incrementally so tags split across stream chunks are handled.
+type thinkParser struct {
+	buf         string // held-back text that may be the start of a tag
+	inThink     bool   // currently inside the thinking segment
+	done        bool   // past the point where a thinking segment may start
+	trimLeading bool   // drop whitespace that follows the closing tag
+}
+
+// Push consumes the next piece of output and returns the parts that can be
+// emitted as content and as thinking so far
+func (p *thinkParser) Push(s string) (content, thinking string) {
+	p.buf += s
+	var c, t strings.Builder
+
+	for {
+		if p.inThink {
+			if i := strings.Index(p.buf, thinkCloseTag); i >= 0 {
+				t.WriteString(p.buf[:i])
+				p.buf = p.buf[i+len(thinkCloseTag):]
+				p.inThink = false
+				p.done = true
+				p.trimLeading = true
+				continue
+			}
+			keep := partialSuffix(p.buf, thinkCloseTag)
+			t.WriteString(p.buf[:len(p.buf)-keep])
+			p.buf = p.buf[len(p.buf)-keep:]
+			break
+		}
+
+		if p.trimLeading {
+			p.buf = strings.TrimLeft(p.buf, " \t\r\n")
+			if p.buf == "" {
+				break
+			}
+			p.trimLeading = false
+		}
+
+		if p.done {
+			c.WriteString(p.buf)
+			p.buf = ""
+			break
+		}
+
+		// Only a segment at the very start of the output counts as thinking
+		trimmed := strings.TrimLeft(p.buf, " \t\r\n")
+		if strings.HasPrefix(trimmed, thinkOpenTag) {
+			p.buf = trimmed[len(thinkOpenTag):]
+			p.inThink = true
+			continue
+		}
+		if strings.HasPrefix(thinkOpenTag, trimmed) {
+			// Could still turn into the opening tag; wait for more input
+			break
+		}
+
+		p.done = true
+		c.WriteString(p.buf)
+		p.buf = ""
+		break
+	}
+
+	return c.String(), t.String()
+}
+
+// Flush returns any held-back text once the output is complete
+func (p *thinkParser) Flush() (content, thinking string) {
+	if p.inThink {
+		thinking = p.buf
+	} else if !p.trimLeading {
+		content = p.buf
+	}
+	p.buf = ""
+	return content, thinking
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a
+// proper prefix of tag
+func partialSuffix(s, tag string) int {
+	for k := min(len(tag)-1, len(s)); k > 0; k-- {
+		if strings.HasSuffix(s, tag[:k]) {
+			return k
+		}
+	}
+	return 0
+}
+
+// splitThinking separates a complete response into content and thinking
+func splitThinking(s string) (content, thinking string) {
+	var p thinkParser
+	c, t := p.Push(s)
+	fc, ft := p.Flush()
+	return c + fc, t + ft
+}
+
+// stripThinking returns messages with reasoning removed from assistant turns
+// so it is never sent back to the model on later requests
+func stripThinking(messages []api.Message) []api.Message {
+	var out []api.Message
+	for i, m := range messages {
+		if m.Role != "assistant" || (m.Thinking == "" && !strings.Contains(m.Content, thinkOpenTag)) {
+			if out != nil {
+				out = append(out, m)
+			}
+			continue
+		}
+
+		if out == nil {
+			out = make([]api.Message, i, len(messages))
+			copy(out, messages[:i])
+		}
+		m.Thinking = ""
+		m.Content, _ = splitThinking(m.Content)
+		out = append(out, m)
+	}
+
+	if out == nil {
+		return messages
+	}
+	return out
+}
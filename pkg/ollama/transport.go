@@ -0,0 +1,128 @@
+package ollama
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultConnectTimeout bounds dialing and the TLS handshake when no Timeout is set
+const DefaultConnectTimeout = 10 * time.Second
+
+// newHTTPClient builds the HTTP client used to reach Ollama
+func newHTTPClient(opt ClientOptions) (*http.Client, error) {
+	if opt.HTTPClient != nil {
+		return opt.HTTPClient, nil
+	}
+
+	connectTimeout := opt.Timeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opt.ProxyURL != "" {
+		proxyURL, err := url.Parse(opt.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %s: %w", opt.ProxyURL, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	// No overall client timeout: streamed generations may legitimately run
+	// for minutes. RequestTimeout bounds requests instead.
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 proxy,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       opt.TLSConfig,
+			TLSHandshakeTimeout:   connectTimeout,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}, nil
+}
+
+// headerTransport adds authentication and custom headers to every request,
+// including streaming ones
+type headerTransport struct {
+	base        http.RoundTripper
+	headers     map[string]string
+	bearerToken string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	if t.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withHeaders wraps the client's transport when headers or a token are configured
+func withHeaders(hc *http.Client, headers map[string]string, bearerToken string) *http.Client {
+	if len(headers) == 0 && bearerToken == "" {
+		return hc
+	}
+
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	wrapped := *hc
+	wrapped.Transport = &headerTransport{
+		base:        base,
+		headers:     headers,
+		bearerToken: bearerToken,
+	}
+	return &wrapped
+}
+
+// secretRedactor removes credentials from text before it is logged
+type secretRedactor []string
+
+// newSecretRedactor collects the secrets configured on the client
+func newSecretRedactor(opt ClientOptions) secretRedactor {
+	var secrets secretRedactor
+	if opt.BearerToken != "" {
+		secrets = append(secrets, opt.BearerToken)
+	}
+	for key, value := range opt.Headers {
+		switch http.CanonicalHeaderKey(key) {
+		case "Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key":
+			if value != "" {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	if u, err := url.Parse(opt.URL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok && password != "" {
+			secrets = append(secrets, password)
+		}
+	}
+	return secrets
+}
+
+// Redact replaces every known secret in v's string form
+func (r secretRedactor) Redact(v any) string {
+	s := fmt.Sprint(v)
+	for _, secret := range r {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+package ollama
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA creates a self-signed certificate authority
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// pool returns a certificate pool trusting only the CA
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// serverCert issues a certificate for 127.0.0.1
+func (ca *testCA) serverCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ollama.test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// tlsOllama serves /api/version over TLS with a certificate from ca and
+// reports the Authorization header of each request
+func tlsOllama(t *testing.T, ca *testCA) (*httptest.Server, <-chan string) {
+	t.Helper()
+	authorization := make(chan string, 10)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/version" {
+			http.NotFound(w, r)
+			return
+		}
+		authorization <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"0.9.6"}`))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.serverCert(t)}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, authorization
+}
+
+func TestClientTLSWithCustomCA(t *testing.T) {
+	ca := newTestCA(t, "Test Ollama CA")
+	server, authorization := tlsOllama(t, ca)
+
+	tests := []struct {
+		name string
+		opt  ClientOptions
+		// unknownAuthority is whether the certificate should be rejected
+		unknownAuthority bool
+	}{
+		{
+			name:             "system roots",
+			opt:              ClientOptions{},
+			unknownAuthority: true,
+		},
+		{
+			name: "custom CA",
+			opt:  ClientOptions{TLSConfig: &tls.Config{RootCAs: ca.pool()}},
+		},
+		{
+			name:             "other CA",
+			opt:              ClientOptions{TLSConfig: &tls.Config{RootCAs: newTestCA(t, "Other CA").pool()}},
+			unknownAuthority: true,
+		},
+		{
+			name: "custom CA with a token",
+			opt:  ClientOptions{TLSConfig: &tls.Config{RootCAs: ca.pool()}, BearerToken: "secret-token"},
+		},
+		{
+			name: "HTTP client",
+			opt: ClientOptions{HTTPClient: &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: ca.pool()},
+			}}},
+		},
+		{
+			// The HTTP client wins over the TLS config
+			name: "HTTP client without the CA",
+			opt: ClientOptions{
+				HTTPClient: &http.Client{Transport: &http.Transport{}},
+				TLSConfig:  &tls.Config{RootCAs: ca.pool()},
+			},
+			unknownAuthority: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opt.URL = server.URL
+			tt.opt.Model = "test-model"
+			client, err := NewClient(tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version, err := client.Version(context.Background())
+			if tt.unknownAuthority {
+				var unknown x509.UnknownAuthorityError
+				if !errors.As(err, &unknown) {
+					t.Fatalf("got %v, want an unknown authority error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != "0.9.6" {
+				t.Errorf("version = %q", version)
+			}
+
+			want := ""
+			if tt.opt.BearerToken != "" {
+				want = "Bearer " + tt.opt.BearerToken
+			}
+			if got := <-authorization; got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClientTLSHandshakeTimeout(t *testing.T) {
+	// A listener that accepts connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	client, err := NewClient(ClientOptions{
+		URL:     "https://" + listener.Addr().String(),
+		Model:   "test-model",
+		Timeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.Version(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "handshake timeout") {
+		t.Fatalf("got %v, want a handshake timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the handshake took %s to time out", elapsed)
+	}
+}
@@ -0,0 +1,433 @@
+// Package openai implements llm.ChatProvider for servers speaking the OpenAI
+// chat completions API, such as vLLM, llama.cpp server, and OpenRouter.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// DefaultBaseURL is the OpenAI API endpoint
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+type Client struct {
+	baseURL        string
+	apiKey         string
+	model          string
+	http           *http.Client
+	headers        map[string]string
+	tools          *llm.ToolRunner
+	requestTimeout time.Duration
+}
+
+type ClientOptions struct {
+	// BaseURL is the API root, e.g. "http://localhost:8000/v1" (default: DefaultBaseURL)
+	BaseURL string
+	APIKey  string
+	Model   string
+
+	// HTTPClient is used for all requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+
+	// Headers are added to every request
+	Headers map[string]string
+
+	// ArgumentValidation controls how tool-call arguments are checked before
+	// execution (default: llm.ValidationLenient)
+	ArgumentValidation llm.ValidationMode
+
+	// RequestTimeout bounds each chat request (zero: no timeout). For
+	// streaming requests it is an inactivity timeout.
+	RequestTimeout time.Duration
+
+	// MaxToolResultChars caps each tool result fed back to the model
+	// (zero: llm.DefaultMaxToolResultChars, negative: no cap)
+	MaxToolResultChars int
+
+	// ParallelToolCalls bounds the tool calls of one response that run at
+	// once (zero: one after another)
+	ParallelToolCalls int
+
+	// SummarizeTruncated replaces the omitted middle of a truncated tool
+	// result with a short summary produced by an extra model call
+	SummarizeTruncated bool
+
+	// Prompts phrase tool results and notices fed back to the model
+	// (default: the llm.Default*Prompt templates)
+	Prompts llm.Prompts
+}
+
+func NewClient(opt ClientOptions) (*Client, error) {
+	if opt.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	baseURL := opt.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	hc := opt.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  opt.APIKey,
+		model:   opt.Model,
+		http:    hc,
+		headers: opt.Headers,
+		tools: llm.NewToolRunner(llm.ToolRunnerOptions{
+			ArgumentValidation: opt.ArgumentValidation,
+			MaxResultChars:     opt.MaxToolResultChars,
+			Parallelism:        opt.ParallelToolCalls,
+			Prompts:            opt.Prompts,
+		}),
+		requestTimeout: opt.RequestTimeout,
+	}
+	if opt.SummarizeTruncated {
+		c.tools.SetSummarizer(c)
+	}
+	return c, nil
+}
+
+// SetTools sets the available tools for the client
+func (c *Client) SetTools(tools []tool.Tool) {
+	c.tools.SetTools(tools)
+	slog.Info("OpenAI client: Set tools", "tools", len(tools))
+	for _, t := range tools {
+		slog.Debug("OpenAI client: Tool", "tool", t.Name, "description", t.Description)
+	}
+}
+
+// SetPrompts replaces the templates phrasing tool results and notices
+func (c *Client) SetPrompts(prompts llm.Prompts) {
+	c.tools.SetPrompts(prompts)
+}
+
+// Prompts implements llm.PromptSource
+func (c *Client) Prompts() llm.Prompts {
+	return c.tools.Prompts()
+}
+
+// GetTools returns the currently available tools
+func (c *Client) GetTools() []tool.Tool {
+	return c.tools.Tools()
+}
+
+// Registry returns the registry of the available tools, for lookups by
+// name or by unprefixed name
+func (c *Client) Registry() *tool.Registry {
+	return c.tools.Registry()
+}
+
+// HandleToolCalls implements llm.ToolHandler
+func (c *Client) HandleToolCalls(ctx context.Context, response *llm.Response) ([]llm.Message, error) {
+	return c.tools.HandleToolCalls(ctx, response)
+}
+
+// withToolNames restores the tool names of calls made by function name
+func (c *Client) withToolNames(msg llm.Message) llm.Message {
+	tools := c.GetTools()
+	for i, call := range msg.ToolCalls {
+		msg.ToolCalls[i].Name = toolName(tools, call.Name)
+	}
+	return msg
+}
+
+// timeout returns the effective timeout for a call
+func (c *Client) timeout(o llm.Opts) time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return c.requestTimeout
+}
+
+// newRequest builds the request body for a call
+func (c *Client) newRequest(messages []llm.Message, o llm.Opts, stream bool) *chatRequest {
+	req := &chatRequest{
+		Model:     c.model,
+		Messages:  toChatMessages(messages),
+		Stream:    stream,
+		Stop:      o.Stop,
+		MaxTokens: o.NumPredict,
+	}
+	if stream {
+		req.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	if tools := o.OfferedTools(c.GetTools()); len(tools) > 0 {
+		req.Tools = tool.ToOpenAI(tools)
+	}
+	return req
+}
+
+// post sends a request body and returns the successful response
+func (c *Client) post(ctx context.Context, body *chatRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return c.do(req)
+}
+
+// do sends a request with the configured headers and returns the
+// successful response
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp, nil
+}
+
+// CheckModel implements llm.ModelChecker by looking for the model in the
+// server's model list. Servers that do not list their models are trusted.
+func (c *Client) CheckModel(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+			slog.Info("OpenAI client: The server does not list its models, not checking the model", "model", c.model)
+			return nil
+		}
+		return fmt.Errorf("failed to list models: %w", classifyError(err))
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("failed to decode model list: %w", err)
+	}
+	for _, model := range list.Data {
+		if model.ID == c.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not served at %s", llm.ErrModelNotFound, c.model, c.baseURL)
+}
+
+// Chat implements llm.ChatProvider
+func (c *Client) Chat(ctx context.Context, messages []llm.Message, opts llm.Opts) (*llm.Response, error) {
+	req := c.newRequest(messages, opts, false)
+	logRequest(ctx, "OpenAI chat", req)
+
+	callCtx := ctx
+	timeout := c.timeout(opts)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	result, err := c.chat(callCtx, req)
+	if err != nil {
+		// Only report our own deadline as a timeout, not the caller's
+		if timeout > 0 && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = &TimeoutError{Timeout: timeout}
+		}
+		slog.Debug("OpenAI chat: Request failed", "error", err)
+		return nil, fmt.Errorf("chat request failed: %w", classifyError(err))
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("chat request failed: response has no choices")
+	}
+	choice := result.Choices[0]
+
+	response := &llm.Response{
+		Message:    c.withToolNames(fromResponseMessage(choice.Message)),
+		Done:       true,
+		DoneReason: choice.FinishReason,
+		Usage:      usageOf(result.Usage, time.Since(started)),
+	}
+	if response.Message.Thinking != "" && opts.OnThinking != nil {
+		opts.OnThinking(response.Message.Thinking)
+	}
+
+	logResponse(ctx, "OpenAI chat", response, jsonSize(result))
+	return response, nil
+}
+
+// chat performs a non-streaming request and decodes the result
+func (c *Client) chat(ctx context.Context, req *chatRequest) (*chatResponse, error) {
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// ChatStream implements llm.ChatProvider. Each chunk carries the content
+// delta; the final chunk carries the assembled tool calls, done reason, and
+// usage.
+func (c *Client) ChatStream(ctx context.Context, messages []llm.Message, callback func(llm.Response) error, opts llm.Opts) error {
+	req := c.newRequest(messages, opts, true)
+	logRequest(ctx, "OpenAI chat stream", req)
+
+	// Cancel the stream when no chunk arrives within the timeout
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timeout := c.timeout(opts)
+	var stalled atomic.Bool
+	var idleTimer *time.Timer
+	if timeout > 0 {
+		idleTimer = time.AfterFunc(timeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer idleTimer.Stop()
+	}
+
+	started := time.Now()
+	var acc deltaAccumulator
+	received := 0
+	err := func() error {
+		resp, err := c.post(streamCtx, req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		return readEvents(resp.Body, func(data []byte) error {
+			if idleTimer != nil {
+				idleTimer.Reset(timeout)
+			}
+			received += len(data)
+
+			chunk, err := acc.Add(data)
+			if err != nil {
+				return err
+			}
+			for _, choice := range chunk.Choices {
+				thinking := choice.Delta.ReasoningContent + choice.Delta.Reasoning
+				if thinking != "" && opts.OnThinking != nil {
+					opts.OnThinking(thinking)
+				}
+				if choice.Delta.Content == "" && thinking == "" {
+					continue
+				}
+				if err := callback(llm.Response{Message: llm.Message{
+					Role:     llm.RoleAssistant,
+					Content:  choice.Delta.Content,
+					Thinking: thinking,
+				}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}()
+	if err != nil {
+		switch {
+		case stalled.Load() && ctx.Err() == nil:
+			err = &TimeoutError{Timeout: timeout}
+		case ctx.Err() != nil:
+			// Keep what the caller has already been shown
+			err = &llm.InterruptedError{
+				Partial: c.withToolNames(acc.Message()),
+				Cause:   ctx.Err(),
+			}
+		}
+		slog.Debug("OpenAI chat stream: Request failed", "error", err)
+		return fmt.Errorf("streaming chat request failed: %w", classifyError(err))
+	}
+
+	// Deliver the tool calls and metrics with the final chunk
+	final := llm.Response{
+		Message:    c.withToolNames(acc.Message()),
+		Done:       true,
+		DoneReason: acc.finishReason,
+		Usage:      usageOf(acc.usage, time.Since(started)),
+	}
+	logResponse(ctx, "OpenAI chat stream", &final, received)
+	final.Message.Content = ""
+	final.Message.Thinking = ""
+	return callback(final)
+}
+
+// usageOf converts reported token counts, timing the request locally
+func usageOf(u *usage, elapsed time.Duration) llm.Usage {
+	usage := llm.Usage{Requests: 1, TotalDuration: elapsed}
+	if u != nil {
+		usage.PromptTokens = u.PromptTokens
+		usage.CompletionTokens = u.CompletionTokens
+	}
+	return usage
+}
+
+// logRequest logs a chat request at debug level, with its size as JSON
+func logRequest(ctx context.Context, kind string, req *chatRequest) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	slog.Debug(kind+": Sending request", "model", req.Model, "messages", len(req.Messages), "tools", len(req.Tools), "bytes", jsonSize(req))
+}
+
+// logResponse logs a chat response of size bytes and its tool calls at
+// debug level
+func logResponse(ctx context.Context, kind string, resp *llm.Response, size int) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	slog.Debug(kind+": Received response", "done_reason", resp.DoneReason, "tool_calls", len(resp.Message.ToolCalls), "bytes", size)
+	for _, toolCall := range resp.Message.ToolCalls {
+		slog.Debug(kind+": Tool call", "tool", toolCall.Name, "arguments", toolCall.Arguments)
+	}
+}
+
+// jsonSize returns the size of v encoded as JSON (0: not encodable)
+func jsonSize(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
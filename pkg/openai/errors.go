@@ -0,0 +1,113 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// APIError is an error response from the server
+type APIError struct {
+	StatusCode int
+	Message    string
+	Code       string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// TimeoutError is returned when a chat request exceeds its timeout
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no response from model within %s", e.Timeout)
+}
+
+// Is allows errors.Is(err, llm.ErrTimeout)
+func (e *TimeoutError) Is(target error) bool {
+	return target == llm.ErrTimeout
+}
+
+// Unwrap allows errors.Is(err, context.DeadlineExceeded)
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// readAPIError builds an APIError from an unsuccessful response
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error.Message != "" {
+		apiErr.Message = payload.Error.Message
+		if payload.Error.Code != nil {
+			apiErr.Code = fmt.Sprint(payload.Error.Code)
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+// classifyError wraps err with the error class matching its cause, if any
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, llm.ErrTimeout) {
+		return err
+	}
+	if class := errorClass(err); class != nil {
+		return fmt.Errorf("%w: %w", class, err)
+	}
+	return err
+}
+
+// errorClass detects the error class from the status code or message
+func errorClass(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return llm.ErrTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return llm.ErrConnection
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return llm.ErrUnauthorized
+	case apiErr.StatusCode == http.StatusRequestEntityTooLarge || apiErr.Code == "context_length_exceeded" || strings.Contains(msg, "context length"):
+		return llm.ErrContextTooLarge
+	case apiErr.Code == "model_not_found" || (strings.Contains(msg, "model") && (strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist"))):
+		return llm.ErrModelNotFound
+	case apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusGatewayTimeout:
+		return llm.ErrTimeout
+	case apiErr.StatusCode == http.StatusBadGateway || apiErr.StatusCode == http.StatusServiceUnavailable:
+		return llm.ErrConnection
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// readEvents calls fn with the data of each server-sent event until the
+// stream ends or sends [DONE]
+func readEvents(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if string(data) == "[DONE]" {
+			return nil
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// partialToolCall collects the fragments of one streamed tool call
+type partialToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// deltaAccumulator assembles streamed deltas into a complete response.
+// Tool calls arrive in fragments keyed by index: the first carries the ID and
+// name, the rest append to the encoded arguments.
+type deltaAccumulator struct {
+	content      strings.Builder
+	thinking     strings.Builder
+	toolCalls    map[int]*partialToolCall
+	finishReason string
+	usage        *usage
+}
+
+// Add records a chunk
+func (a *deltaAccumulator) Add(data []byte) (chatChunk, error) {
+	var chunk chatChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return chunk, fmt.Errorf("failed to decode stream chunk: %w", err)
+	}
+
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		a.content.WriteString(choice.Delta.Content)
+		a.thinking.WriteString(choice.Delta.ReasoningContent + choice.Delta.Reasoning)
+		if choice.FinishReason != "" {
+			a.finishReason = choice.FinishReason
+		}
+
+		for i, call := range choice.Delta.ToolCalls {
+			index := i
+			if call.Index != nil {
+				index = *call.Index
+			}
+			if a.toolCalls == nil {
+				a.toolCalls = make(map[int]*partialToolCall)
+			}
+			partial, ok := a.toolCalls[index]
+			if !ok {
+				partial = &partialToolCall{}
+				a.toolCalls[index] = partial
+			}
+			if call.ID != "" {
+				partial.id = call.ID
+			}
+			if call.Function.Name != "" {
+				partial.name = call.Function.Name
+			}
+			partial.arguments.WriteString(call.Function.Arguments)
+		}
+	}
+	return chunk, nil
+}
+
+// Message returns the assistant message assembled so far
+func (a *deltaAccumulator) Message() llm.Message {
+	msg := llm.Message{
+		Role:     llm.RoleAssistant,
+		Content:  a.content.String(),
+		Thinking: a.thinking.String(),
+	}
+
+	indexes := make([]int, 0, len(a.toolCalls))
+	for index := range a.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		partial := a.toolCalls[index]
+		msg.ToolCalls = append(msg.ToolCalls, fromToolCall(toolCall{
+			ID:       partial.id,
+			Function: toolCallFunction{Name: partial.name, Arguments: partial.arguments.String()},
+		}))
+	}
+	return msg
+}
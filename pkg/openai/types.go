@@ -0,0 +1,184 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	"github.com/snowmerak/ttobot/lib/tool"
+)
+
+// chatRequest is the body of a chat completions request
+type chatRequest struct {
+	Model         string            `json:"model"`
+	Messages      []chatMessage     `json:"messages"`
+	Tools         []tool.OpenAITool `json:"tools,omitempty"`
+	Stream        bool              `json:"stream,omitempty"`
+	StreamOptions *streamOptions    `json:"stream_options,omitempty"`
+	Stop          []string          `json:"stop,omitempty"`
+	MaxTokens     int               `json:"max_tokens,omitempty"`
+}
+
+// streamOptions asks the server to report usage in the final chunk
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessage is a message in the wire format. Content is a string, or a
+// list of parts when the message carries images.
+type chatMessage struct {
+	Role       string     `json:"role"`
+	Content    any        `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// contentPart is one element of a multi-part message
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+// imageURL holds an image, here always as a data URL
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// toolCall is a function call in the wire format. Arguments are a JSON
+// document encoded as a string.
+type toolCall struct {
+	Index    *int             `json:"index,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function toolCallFunction `json:"function"`
+}
+
+// toolCallFunction names the function and carries its encoded arguments
+type toolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// chatResponse is the body of a non-streaming response
+type chatResponse struct {
+	Choices []struct {
+		Message      responseMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage,omitempty"`
+}
+
+// chatChunk is one event of a streaming response
+type chatChunk struct {
+	Choices []struct {
+		Delta        responseMessage `json:"delta"`
+		FinishReason string          `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage,omitempty"`
+}
+
+// responseMessage is an assistant message or delta as returned by the server
+type responseMessage struct {
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ReasoningContent string     `json:"reasoning_content"`
+	Reasoning        string     `json:"reasoning"`
+	ToolCalls        []toolCall `json:"tool_calls"`
+}
+
+// usage holds the token counts reported by the server
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// toolName maps a function name back to the tool it was derived from
+func toolName(tools []tool.Tool, name string) string {
+	for _, t := range tools {
+		if tool.OpenAIFunctionName(t.Function.Name) == name {
+			return t.Function.Name
+		}
+	}
+	return name
+}
+
+// toChatMessages converts messages to the wire format
+func toChatMessages(messages []llm.Message) []chatMessage {
+	out := make([]chatMessage, 0, len(messages))
+	for _, m := range messages {
+		msg := chatMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID,
+		}
+
+		// Tool messages must be text; their images are described in the content
+		if len(m.Images) > 0 && m.Role != llm.RoleTool {
+			parts := []contentPart{{Type: "text", Text: m.Content}}
+			for _, image := range m.Images {
+				parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: dataURL(image)}})
+			}
+			msg.Content = parts
+		}
+
+		for _, call := range m.ToolCalls {
+			arguments, err := json.Marshal(call.Arguments)
+			if err != nil {
+				arguments = []byte("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, toolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: toolCallFunction{Name: tool.OpenAIFunctionName(call.Name), Arguments: string(arguments)},
+			})
+		}
+
+		// Assistant messages that only call tools carry no content
+		if m.Role == llm.RoleAssistant && m.Content == "" && len(msg.ToolCalls) > 0 {
+			msg.Content = nil
+		}
+
+		out = append(out, msg)
+	}
+	return out
+}
+
+// dataURL encodes an image as a data URL
+func dataURL(image []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(image), base64.StdEncoding.EncodeToString(image))
+}
+
+// fromResponseMessage converts an assistant message from the wire format
+func fromResponseMessage(m responseMessage) llm.Message {
+	msg := llm.Message{
+		Role:     llm.RoleAssistant,
+		Content:  m.Content,
+		Thinking: m.ReasoningContent + m.Reasoning,
+	}
+	for _, call := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, fromToolCall(call))
+	}
+	return msg
+}
+
+// fromToolCall decodes a tool call and its string-encoded arguments. Arguments
+// that are not valid JSON are passed on as an empty object; the tool's
+// validation then reports what is missing.
+func fromToolCall(call toolCall) llm.ToolCall {
+	arguments := map[string]any{}
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+			slog.Warn("OpenAI chat: Ignoring malformed arguments", "tool", call.Function.Name, "error", err)
+			arguments = map[string]any{}
+		}
+	}
+	return llm.ToolCall{
+		ID:        call.ID,
+		Name:      call.Function.Name,
+		Arguments: arguments,
+	}
+}
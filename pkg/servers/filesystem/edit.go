@@ -0,0 +1,180 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxEditHunks caps the changes edit_file lists in its result
+const maxEditHunks = 10
+
+// EditFileParams represents parameters for replacing text in a file
+type EditFileParams struct {
+	Path       string `json:"path" mcp:"path of the file to edit"`
+	OldString  string `json:"old_string" mcp:"exact text to replace, including whitespace and indentation"`
+	NewString  string `json:"new_string" mcp:"text to replace it with"`
+	ReplaceAll bool   `json:"replace_all,omitempty" mcp:"whether to replace every occurrence; otherwise old_string must occur exactly once (default: false)"`
+}
+
+// EditFile replaces exact text in a file and returns a diff of the lines
+// it changed
+func (s *toolset) EditFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[EditFileParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	oldString, newString := params.Arguments.OldString, params.Arguments.NewString
+	if oldString == "" {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "old_string is empty; use write_file or append_file to add text"}},
+			IsError: true,
+		}, nil
+	}
+	if oldString == newString {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "old_string and new_string are the same; nothing to change"}},
+			IsError: true,
+		}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	if info.Size() > MaxReadBytesLimit {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("%s is %d bytes, too large to edit", params.Arguments.Path, info.Size())}},
+			IsError: true,
+		}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	content := string(data)
+
+	// Models write \n; in a file with CRLF line endings the text to
+	// replace and its replacement use them too
+	if strings.Contains(content, "\r\n") && !strings.Contains(content, oldString) {
+		oldString = toCRLF(oldString)
+		newString = toCRLF(newString)
+	}
+
+	count := strings.Count(content, oldString)
+	switch {
+	case count == 0:
+		message := fmt.Sprintf("old_string was not found in %s", params.Arguments.Path)
+		if trimmed := strings.TrimSpace(oldString); trimmed != "" && strings.Contains(content, trimmed) {
+			message += "; it matches when leading and trailing whitespace is ignored, so check the indentation and line breaks"
+		} else {
+			message += "; read the file again to copy the exact text"
+		}
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: message}},
+			IsError: true,
+		}, nil
+	case count > 1 && !params.Arguments.ReplaceAll:
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("old_string occurs %d times in %s, at lines %s; include more surrounding text to pick one, or set replace_all", count, params.Arguments.Path, matchLines(content, oldString))}},
+			IsError: true,
+		}, nil
+	}
+
+	edited := strings.ReplaceAll(content, oldString, newString)
+	if err := os.WriteFile(path, []byte(edited), info.Mode().Perm()); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error writing to file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var b strings.Builder
+	if count == 1 {
+		fmt.Fprintf(&b, "Successfully edited %s: 1 replacement\n", params.Arguments.Path)
+	} else {
+		fmt.Fprintf(&b, "Successfully edited %s: %d replacements\n", params.Arguments.Path, count)
+	}
+	b.WriteString(editDiff(content, oldString, newString, maxEditHunks))
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, nil
+}
+
+// toCRLF turns the bare \n line endings of s into \r\n
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// matchLines lists the lines where old occurs in content, such as 3, 17
+func matchLines(content, old string) string {
+	var lines []string
+	offset := 0
+	for {
+		i := strings.Index(content[offset:], old)
+		if i < 0 {
+			break
+		}
+		lines = append(lines, fmt.Sprint(strings.Count(content[:offset+i], "\n")+1))
+		offset += i + len(old)
+	}
+	return strings.Join(lines, ", ")
+}
+
+// editDiff describes replacing old with new in content as unified diff
+// hunks of the whole lines each replacement touches, up to limit hunks
+func editDiff(content, old, new string, limit int) string {
+	var b strings.Builder
+	offset, shift, hunks := 0, 0, 0
+	for {
+		i := strings.Index(content[offset:], old)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(old)
+		offset = end
+
+		hunks++
+		if hunks > limit {
+			continue
+		}
+		lineStart := strings.LastIndex(content[:start], "\n") + 1
+		lineEnd := len(content)
+		if j := strings.Index(content[end:], "\n"); j >= 0 {
+			lineEnd = end + j
+		}
+		before := content[lineStart:lineEnd]
+		after := content[lineStart:start] + new + content[end:lineEnd]
+
+		line := strings.Count(content[:start], "\n") + 1
+		oldCount, newCount := strings.Count(before, "\n")+1, strings.Count(after, "\n")+1
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", line, oldCount, line+shift, newCount)
+		writeDiffLines(&b, "-", before)
+		writeDiffLines(&b, "+", after)
+		shift += newCount - oldCount
+	}
+	if hunks > limit {
+		fmt.Fprintf(&b, "… %d more replacements\n", hunks-limit)
+	}
+	return b.String()
+}
+
+// writeDiffLines writes each line of text with a diff prefix
+func writeDiffLines(b *strings.Builder, prefix, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(prefix + strings.TrimSuffix(line, "\r") + "\n")
+	}
+}
@@ -0,0 +1,827 @@
+// Package filesystem implements the filesystem MCP server: finding,
+// searching, reading and writing files
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// GetCurrentDirParams represents parameters for getting current directory
+type GetCurrentDirParams struct{}
+
+// FindFilesParams represents parameters for finding files
+type FindFilesParams struct {
+	Pattern     string   `json:"pattern" mcp:"pattern to match: a regular expression matched against file names, or with pattern_type glob, a glob such as **/*.go or cmd/*/main.go matched against the path relative to directory"`
+	PatternType string   `json:"pattern_type,omitempty" mcp:"syntax of pattern: regex or glob; in a glob ** matches any number of directories (default: regex)"`
+	Directory   string   `json:"directory,omitempty" mcp:"directory to search in (default: current directory)"`
+	Recursive   bool     `json:"recursive,omitempty" mcp:"whether to search recursively; a glob with / or ** always searches subdirectories (default: false)"`
+	MaxResults  int      `json:"max_results,omitempty" mcp:"most paths to return (default: 200)"`
+	Offset      int      `json:"offset,omitempty" mcp:"matches to skip, to get the next page (default: 0)"`
+	Exclude     []string `json:"exclude,omitempty" mcp:"patterns of paths to skip, such as vendor, dist or *_gen.go; a pattern with / matches the path relative to directory, with ** for any number of directories (default: none)"`
+}
+
+// defaultMaxResults is how many paths find_files returns unless a call sets
+// max_results
+const defaultMaxResults = 200
+
+// CreateFileParams represents parameters for creating a file
+type CreateFileParams struct {
+	Path    string `json:"path" mcp:"path of the file to create"`
+	Content string `json:"content,omitempty" mcp:"content to write to the file (default: empty)"`
+}
+
+// CreateDirParams represents parameters for creating a directory
+type CreateDirParams struct {
+	Path string `json:"path" mcp:"path of the directory to create"`
+}
+
+// RemoveParams represents parameters for removing files/directories
+type RemoveParams struct {
+	Path string `json:"path" mcp:"path of the file or directory to remove"`
+}
+
+// WriteFileParams represents parameters for writing to a file
+type WriteFileParams struct {
+	Path    string `json:"path" mcp:"path of the file to write to"`
+	Content string `json:"content" mcp:"content to write to the file"`
+}
+
+// AppendFileParams represents parameters for appending to a file
+type AppendFileParams struct {
+	Path    string `json:"path" mcp:"path of the file to append to; it is created if missing"`
+	Content string `json:"content" mcp:"content to add at the end of the file"`
+}
+
+// ReadFileParams represents parameters for reading a file
+type ReadFileParams struct {
+	Path      string `json:"path" mcp:"path of the file to read"`
+	StartLine int    `json:"start_line,omitempty" mcp:"first line to read, counting from 1 (default: 1)"`
+	EndLine   int    `json:"end_line,omitempty" mcp:"last line to read, inclusive (default: the last line of the file)"`
+	MaxBytes  int64  `json:"max_bytes,omitempty" mcp:"most bytes to return; larger files are truncated (default: 262144, at most 16777216)"`
+}
+
+// GetCurrentDir returns the root, which relative paths are resolved against
+func (s *toolset) GetCurrentDir(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GetCurrentDirParams]) (*mcp.CallToolResultFor[any], error) {
+	readOnlyNote := ""
+	if s.readOnly {
+		readOnlyNote = " (the server is in read-only mode: files cannot be created, changed or removed)"
+	}
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Current directory: %s%s", s.root, readOnlyNote)}},
+	}, nil
+}
+
+// FindFiles finds files matching a regular expression pattern, a page at a
+// time
+func (s *toolset) FindFiles(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[FindFilesParams]) (*mcp.CallToolResultFor[any], error) {
+	directory, err := s.resolve(params.Arguments.Directory)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	pattern := params.Arguments.Pattern
+	recursive := params.Arguments.Recursive
+	var match func(path string, d fs.DirEntry) bool
+	switch params.Arguments.PatternType {
+	case "", "regex":
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid regex pattern: %v; set pattern_type to glob for patterns such as **/*.go", err)}},
+				IsError: true,
+			}, nil
+		}
+		match = func(path string, d fs.DirEntry) bool {
+			return regex.MatchString(d.Name())
+		}
+	case "glob":
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+		if err := checkGlob(pattern); err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid glob pattern: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+		recursive = recursive || strings.Contains(pattern, "/") || strings.Contains(pattern, "**")
+		match = func(path string, d fs.DirEntry) bool {
+			rel, err := filepath.Rel(directory, path)
+			return err == nil && matchGlob(pattern, filepath.ToSlash(rel))
+		}
+	default:
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid pattern_type %q: use regex or glob", params.Arguments.PatternType)}},
+			IsError: true,
+		}, nil
+	}
+	if params.Arguments.MaxResults < 0 || params.Arguments.Offset < 0 {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "max_results and offset must not be negative"}},
+			IsError: true,
+		}, nil
+	}
+	exclude, err := newExcludes(params.Arguments.Exclude)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid exclude: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	maxResults := params.Arguments.MaxResults
+	if maxResults == 0 {
+		maxResults = defaultMaxResults
+	}
+	offset := params.Arguments.Offset
+
+	// The walk stops at the first match past the page, which tells that
+	// there are more without visiting the rest of the tree
+	var matches []string
+	found, more := 0, false
+	add := func(path string) bool {
+		if found == offset+maxResults {
+			more = true
+			return false
+		}
+		if found >= offset {
+			matches = append(matches, path)
+		}
+		found++
+		return true
+	}
+
+	err = walkFiles(directory, recursive, exclude, func(path string, d fs.DirEntry) error {
+		if match(path, d) && !add(path) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error searching files: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var result string
+	if more {
+		result = fmt.Sprintf("Found more than %d files matching pattern '%s', showing %d from offset %d; pass offset %d for the next page:\n",
+			found, params.Arguments.Pattern, len(matches), offset, offset+len(matches))
+	} else {
+		result = fmt.Sprintf("Found %d files matching pattern '%s', showing %d of %d total matches:\n",
+			found, params.Arguments.Pattern, len(matches), found)
+	}
+	for _, match := range matches {
+		result += fmt.Sprintf("- %s\n", match)
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// CreateFile creates a new file
+func (s *toolset) CreateFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[CreateFileParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	// Create parent directories if they don't exist
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating parent directories: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	defer file.Close()
+
+	if params.Arguments.Content != "" {
+		_, err = file.WriteString(params.Arguments.Content)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error writing to file: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully created file: %s", params.Arguments.Path)}},
+	}, nil
+}
+
+// CreateDir creates a new directory
+func (s *toolset) CreateDir(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[CreateDirParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating directory: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully created directory: %s", params.Arguments.Path)}},
+	}, nil
+}
+
+// RemoveFileOrDir removes a file or directory
+func (s *toolset) RemoveFileOrDir(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[RemoveParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolveBelowRoot(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	err = os.RemoveAll(path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error removing: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully removed: %s", params.Arguments.Path)}},
+	}, nil
+}
+
+// WriteFile writes content to a file
+func (s *toolset) WriteFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[WriteFileParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	// Create parent directories if they don't exist
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating parent directories: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	err = os.WriteFile(path, []byte(params.Arguments.Content), 0644)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error writing to file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully wrote to file: %s", params.Arguments.Path)}},
+	}, nil
+}
+
+// AppendFile adds content at the end of a file, creating it if missing
+func (s *toolset) AppendFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[AppendFileParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	// Create parent directories if they don't exist
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating parent directories: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error opening file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	defer file.Close()
+
+	n, err := file.WriteString(params.Arguments.Content)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error appending to file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Appended %d bytes to %s, but could not read its size: %v", n, params.Arguments.Path, err)}},
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully appended %d bytes to %s, which is now %d bytes", n, params.Arguments.Path, info.Size())}},
+	}, nil
+}
+
+// ReadFile reads content from a file, or only the lines from start_line to
+// end_line when either is set. At most max_bytes are returned, with a
+// notice when the file was truncated.
+func (s *toolset) ReadFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ReadFileParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	defer file.Close()
+
+	maxBytes := s.maxReadBytes
+	if params.Arguments.MaxBytes > 0 {
+		maxBytes = min(params.Arguments.MaxBytes, MaxReadBytesLimit)
+	}
+
+	start, end := params.Arguments.StartLine, params.Arguments.EndLine
+	if start == 0 && end == 0 {
+		text, err := readHead(file, maxBytes)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading file: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: text}},
+		}, nil
+	}
+
+	text, err := lineRange(file, start, end, maxBytes)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading %s: %v", params.Arguments.Path, err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, nil
+}
+
+// readHead reads up to maxBytes of a file. The text of a larger file ends
+// with a notice giving its size.
+func readHead(file *os.File, maxBytes int64) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) <= maxBytes {
+		return string(data), nil
+	}
+
+	size := int64(len(data))
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	data = data[:maxBytes]
+	// Do not end on a rune cut in half
+	for i := 1; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	return string(data) + fmt.Sprintf("[truncated: the file is %d bytes in total and only the first %d are shown; read further with start_line and end_line]", size, maxBytes), nil
+}
+
+// lineRange returns lines start to end of r, inclusive and counting from
+// 1, after a header naming the lines returned and the total. A zero start
+// is the first line and a zero end, or one past the end of the file, the
+// last. Lines past maxBytes are left out and the header says so.
+func lineRange(r io.Reader, start, end int, maxBytes int64) (string, error) {
+	if start < 0 || end < 0 {
+		return "", fmt.Errorf("start_line and end_line must be positive")
+	}
+	if start == 0 {
+		start = 1
+	}
+	if end != 0 && end < start {
+		return "", fmt.Errorf("end_line %d is before start_line %d", end, start)
+	}
+
+	// Lines are read in chunks, so a huge line is not held whole unless it
+	// is returned
+	reader := bufio.NewReader(r)
+	var out bytes.Buffer
+	total, last, lineStart := 0, 0, 0
+	inLine, full := false, false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if !inLine {
+				total++
+				inLine = true
+				lineStart = out.Len()
+			}
+			wanted := total >= start && (end == 0 || total <= end) && !full
+			if wanted {
+				if int64(out.Len()+len(chunk)) > maxBytes {
+					full = true
+					out.Truncate(lineStart)
+				} else {
+					out.Write(chunk)
+				}
+			}
+			if chunk[len(chunk)-1] == '\n' {
+				inLine = false
+				if wanted && !full {
+					last = total
+				}
+			} else if err == io.EOF && wanted && !full {
+				last = total
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return "", err
+		}
+	}
+
+	if end == 0 || end > total {
+		end = total
+	}
+	switch {
+	case total == 0:
+		return "", fmt.Errorf("the file is empty")
+	case start > total:
+		return "", fmt.Errorf("start_line %d is past the end of the file, which has %d lines", start, total)
+	case last < start:
+		return "", fmt.Errorf("line %d alone is longer than max_bytes (%d)", start, maxBytes)
+	}
+
+	header := fmt.Sprintf("Lines %d-%d of %d:\n", start, last, total)
+	if last < end {
+		header = fmt.Sprintf("Lines %d-%d of %d (truncated at %d bytes; continue with start_line %d):\n", start, last, total, maxBytes, last+1)
+	}
+	return header + out.String(), nil
+}
+
+// CopyFile copies a file from source to destination
+func (s *toolset) CopyFile(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[struct {
+	Source string `json:"source" mcp:"source file path"`
+	Dest   string `json:"dest" mcp:"destination file path"`
+}]) (*mcp.CallToolResultFor[any], error) {
+	source, err := s.resolve(params.Arguments.Source)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	dest, err := s.resolve(params.Arguments.Dest)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error opening source file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	defer sourceFile.Close()
+
+	// Create parent directories if they don't exist
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating parent directories: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating destination file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, sourceFile)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error copying file: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully copied file from %s to %s", params.Arguments.Source, params.Arguments.Dest)}},
+	}, nil
+}
+
+// Limits of the bytes read_file returns
+const (
+	// DefaultMaxReadBytes applies when neither the options nor the call
+	// set a limit
+	DefaultMaxReadBytes = 256 << 10
+
+	// MaxReadBytesLimit caps any limit, so no argument can have a huge
+	// file returned whole
+	MaxReadBytesLimit = 16 << 20
+)
+
+// Options configures the filesystem server
+type Options struct {
+	// Root is the only directory the tools may access, directly or through
+	// symlinks. Relative paths are resolved against it, and it is the
+	// current directory reported to the model (empty: the working
+	// directory).
+	Root string
+
+	// MaxReadBytes is the most bytes read_file returns unless a call sets
+	// max_bytes (zero: DefaultMaxReadBytes; at most MaxReadBytesLimit)
+	MaxReadBytes int64
+
+	// MaxSearchFileBytes is the size of the largest file search_in_files
+	// reads; larger files are skipped (zero: DefaultMaxSearchFileBytes)
+	MaxSearchFileBytes int64
+
+	// ReadOnly leaves out the tools that create, change or remove files,
+	// so the model can only explore
+	ReadOnly bool
+}
+
+// toolset holds the state shared by the tools
+type toolset struct {
+	root               string
+	maxReadBytes       int64
+	maxSearchFileBytes int64
+	readOnly           bool
+}
+
+// newToolset resolves the root and applies the defaults of the options
+func newToolset(opts Options) (*toolset, error) {
+	root := opts.Root
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root %s: %w", opts.Root, err)
+	}
+	// Paths are checked against the root with its symlinks resolved
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, fmt.Errorf("failed to resolve root %s: %w", opts.Root, err)
+	}
+	s := &toolset{
+		root:               root,
+		maxReadBytes:       DefaultMaxReadBytes,
+		maxSearchFileBytes: DefaultMaxSearchFileBytes,
+		readOnly:           opts.ReadOnly,
+	}
+	if opts.MaxReadBytes > 0 {
+		s.maxReadBytes = min(opts.MaxReadBytes, MaxReadBytesLimit)
+	}
+	if opts.MaxSearchFileBytes > 0 {
+		s.maxSearchFileBytes = opts.MaxSearchFileBytes
+	}
+	return s, nil
+}
+
+// New returns the filesystem server with its tools registered
+func New(opts Options) (*mcp.Server, error) {
+	s, err := newToolset(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a server for file system operations
+	var serverOpts *mcp.ServerOptions
+	if opts.ReadOnly {
+		serverOpts = &mcp.ServerOptions{
+			Instructions: "The server is in read-only mode: files can be found, searched and read, but not created, changed or removed.",
+		}
+	}
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "filesystem",
+		Version: "v1.0.0",
+	}, serverOpts)
+
+	// Register tools
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_current_dir",
+		Description: "Get the current working directory",
+	}, s.GetCurrentDir)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "find_files",
+		Description: "Find files by name. pattern is a regular expression matched against file names, such as \\.go$; with pattern_type glob it is a glob matched against the path relative to directory, such as **/*.go",
+	}, s.FindFiles)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_dir",
+		Description: "List the entries of a directory with their type, size, permissions and modification time",
+	}, s.ListDir)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "stat",
+		Description: "Check whether a path exists and get its type, size, permissions, modification time and, for symlinks, target",
+	}, s.Stat)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_in_files",
+		Description: "Search for text within files",
+	}, s.SearchInFiles)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "read_file",
+		Description: "Read content from a file, optionally only a range of lines; large files are truncated to max_bytes",
+	}, s.ReadFile)
+
+	// The tools below modify files; a read-only server does not offer them
+	if opts.ReadOnly {
+		return server, nil
+	}
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "create_file",
+		Description: "Create a new file with optional content",
+	}, s.CreateFile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "create_dir",
+		Description: "Create a new directory",
+	}, s.CreateDir)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "remove",
+		Description: "Remove a file or directory",
+	}, s.RemoveFileOrDir)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "write_file",
+		Description: "Write content to a file, replacing what it holds",
+	}, s.WriteFile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "append_file",
+		Description: "Add content at the end of a file, keeping what it holds; the file is created if missing",
+	}, s.AppendFile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "edit_file",
+		Description: "Replace exact text in a file; old_string must occur once unless replace_all is set. Returns a diff of the changed lines",
+	}, s.EditFile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "apply_patch",
+		Description: "Apply a unified diff to one or more files; nothing is changed unless every hunk applies. dry_run reports which hunks apply",
+	}, s.ApplyPatch)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "copy_file",
+		Description: "Copy a file from source to destination",
+	}, s.CopyFile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "move",
+		Description: "Move or rename a file or directory; an existing destination is replaced only with overwrite",
+	}, s.Move)
+
+	return server, nil
+}
+
+// maxSymlinks bounds the symlinks followed to resolve a path, which also
+// stops symlink loops
+const maxSymlinks = 40
+
+// resolve makes a relative path absolute against the root (empty: the root)
+// and fails when it is outside the root, through .. or a symlink. A path
+// that does not exist yet is checked through its nearest existing parent.
+func (s *toolset) resolve(path string) (string, error) {
+	if path == "" {
+		return s.root, nil
+	}
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(s.root, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+	if err := s.checkWithinRoot(resolved, 0); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return resolved, nil
+}
+
+// resolveBelowRoot resolves a path like resolve, and also fails for the
+// root itself, which tools that remove or move paths must not touch
+func (s *toolset) resolveBelowRoot(path string) (string, error) {
+	resolved, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved == s.root {
+		return "", fmt.Errorf("%q is the root %s, which cannot be removed or moved", path, s.root)
+	}
+	return resolved, nil
+}
+
+// checkWithinRoot fails when an absolute, clean path is outside the root,
+// with the symlinks along it followed
+func (s *toolset) checkWithinRoot(path string, links int) error {
+	if !isWithin(s.root, path) {
+		return fmt.Errorf("outside the root %s", s.root)
+	}
+	for existing := path; ; existing = filepath.Dir(existing) {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !isWithin(s.root, real) {
+				return fmt.Errorf("a symlink leads outside the root %s", s.root)
+			}
+			return nil
+		}
+
+		// A dangling symlink would be followed by a write: check where it
+		// points
+		if info, err := os.Lstat(existing); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if links >= maxSymlinks {
+				return errors.New("too many levels of symlinks")
+			}
+			target, err := os.Readlink(existing)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink: %w", err)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			if target = filepath.Clean(target); !isWithin(s.root, target) {
+				return fmt.Errorf("a symlink leads outside the root %s", s.root)
+			}
+			return s.checkWithinRoot(target, links+1)
+		}
+		if filepath.Dir(existing) == existing {
+			return nil
+		}
+	}
+}
+
+// isWithin reports whether path is dir or inside it; both are absolute
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
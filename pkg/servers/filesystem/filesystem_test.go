@@ -0,0 +1,218 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// sandbox creates a root holding sub/a.txt, with outside/secret.txt next to
+// it and symlinks in the root that lead out of it, around and in circles
+func sandbox(t *testing.T) (*toolset, string) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(root, "sub", "a.txt"), "inside\n")
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret\n")
+
+	links := map[string]string{
+		"dirlink":  "../outside",
+		"abslink":  outside,
+		"filelink": "../outside/secret.txt",
+		"dangling": "../outside/new.txt",
+		"loop1":    "loop2",
+		"loop2":    "loop1",
+		"inside":   "sub",
+		"chain":    "dirlink",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := newToolset(Options{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, outside
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resultText returns the text of a tool result
+func resultText(t *testing.T, result *mcp.CallToolResultFor[any]) string {
+	t.Helper()
+	if len(result.Content) != 1 {
+		t.Fatalf("got %d contents, want 1", len(result.Content))
+	}
+	text, ok := result.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("got content %T, want text", result.Content[0])
+	}
+	return text.Text
+}
+
+func TestResolve(t *testing.T) {
+	s, outside := sandbox(t)
+
+	tests := []struct {
+		name string
+		path string
+		want string // path relative to the root, or empty for an error
+		err  string
+	}{
+		{name: "empty", path: "", want: "."},
+		{name: "dot", path: ".", want: "."},
+		{name: "relative", path: "sub/a.txt", want: "sub/a.txt"},
+		{name: "missing", path: "new/dir/b.txt", want: "new/dir/b.txt"},
+		{name: "absolute inside", path: filepath.Join(s.root, "sub"), want: "sub"},
+		{name: "dotdot inside", path: "sub/../sub/a.txt", want: "sub/a.txt"},
+		{name: "symlink inside", path: "inside/a.txt", want: "inside/a.txt"},
+		{name: "dotdot", path: "../outside/secret.txt", err: "outside the root"},
+		{name: "dotdot through sub", path: "sub/../../outside", err: "outside the root"},
+		{name: "parent", path: "..", err: "outside the root"},
+		{name: "absolute", path: filepath.Join(outside, "secret.txt"), err: "outside the root"},
+		{name: "system file", path: "/etc/passwd", err: "outside the root"},
+		{name: "symlinked directory", path: "dirlink/secret.txt", err: "symlink leads outside"},
+		{name: "symlinked directory itself", path: "dirlink", err: "symlink leads outside"},
+		{name: "absolute symlink", path: "abslink/secret.txt", err: "symlink leads outside"},
+		{name: "new file in symlinked directory", path: "dirlink/new.txt", err: "symlink leads outside"},
+		{name: "symlinked file", path: "filelink", err: "symlink leads outside"},
+		{name: "dangling symlink", path: "dangling", err: "symlink leads outside"},
+		{name: "chained symlinks", path: "chain/secret.txt", err: "symlink leads outside"},
+		{name: "symlink loop", path: "loop1", err: "too many levels of symlinks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.resolve(tt.path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("resolve(%q) = %q, %v; want error containing %q", tt.path, got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q): %v", tt.path, err)
+			}
+			if want := filepath.Join(s.root, tt.want); got != want {
+				t.Fatalf("resolve(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestCheckWithinRootDanglingInside(t *testing.T) {
+	s, _ := sandbox(t)
+	// A dangling symlink to a missing path inside the root may be written
+	if err := os.Symlink("sub/later.txt", filepath.Join(s.root, "later")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkWithinRoot(filepath.Join(s.root, "later"), 0); err != nil {
+		t.Fatalf("checkWithinRoot: %v", err)
+	}
+}
+
+func TestToolsRefuseEscapes(t *testing.T) {
+	ctx := context.Background()
+	escapes := []string{
+		"../outside/secret.txt",
+		"dirlink/secret.txt",
+		"abslink/secret.txt",
+		"filelink",
+		"dangling",
+		"chain/secret.txt",
+		"loop1",
+	}
+
+	for _, path := range escapes {
+		t.Run(path, func(t *testing.T) {
+			s, outside := sandbox(t)
+			results := map[string]*mcp.CallToolResultFor[any]{}
+			results["read_file"], _ = s.ReadFile(ctx, nil, &mcp.CallToolParamsFor[ReadFileParams]{Arguments: ReadFileParams{Path: path}})
+			results["write_file"], _ = s.WriteFile(ctx, nil, &mcp.CallToolParamsFor[WriteFileParams]{Arguments: WriteFileParams{Path: path, Content: "pwned"}})
+			results["append_file"], _ = s.AppendFile(ctx, nil, &mcp.CallToolParamsFor[AppendFileParams]{Arguments: AppendFileParams{Path: path, Content: "pwned"}})
+			results["edit_file"], _ = s.EditFile(ctx, nil, &mcp.CallToolParamsFor[EditFileParams]{Arguments: EditFileParams{Path: path, OldString: "secret", NewString: "pwned"}})
+			results["remove"], _ = s.RemoveFileOrDir(ctx, nil, &mcp.CallToolParamsFor[RemoveParams]{Arguments: RemoveParams{Path: path}})
+			results["move source"], _ = s.Move(ctx, nil, &mcp.CallToolParamsFor[MoveParams]{Arguments: MoveParams{Source: path, Dest: "stolen.txt"}})
+			results["move dest"], _ = s.Move(ctx, nil, &mcp.CallToolParamsFor[MoveParams]{Arguments: MoveParams{Source: "sub/a.txt", Dest: path, Overwrite: true}})
+			results["stat"], _ = s.Stat(ctx, nil, &mcp.CallToolParamsFor[StatParams]{Arguments: StatParams{Path: path}})
+			for tool, result := range results {
+				if !result.IsError {
+					t.Errorf("%s %s succeeded: %s", tool, path, resultText(t, result))
+				} else if text := resultText(t, result); !strings.HasPrefix(text, "Invalid path") {
+					t.Errorf("%s %s: got %q, want an invalid path error", tool, path, text)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(outside, "secret.txt"))
+			if err != nil || string(data) != "secret\n" {
+				t.Errorf("secret.txt = %q, %v; want it unchanged", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+				t.Errorf("a file was created outside the root through %s", path)
+			}
+		})
+	}
+}
+
+func TestSearchSkipsSymlinkEscapes(t *testing.T) {
+	s, _ := sandbox(t)
+	result, _ := s.SearchInFiles(context.Background(), nil, &mcp.CallToolParamsFor[SearchInFilesParams]{Arguments: SearchInFilesParams{SearchText: "secret", Recursive: true}})
+	if text := resultText(t, result); !strings.Contains(text, "in 0 files") {
+		t.Fatalf("search found text outside the root:\n%s", text)
+	}
+}
+
+func TestRootCannotBeRemovedOrMoved(t *testing.T) {
+	ctx := context.Background()
+	for _, path := range []string{"", ".", "sub/..", "./"} {
+		t.Run(path, func(t *testing.T) {
+			s, _ := sandbox(t)
+			results := map[string]*mcp.CallToolResultFor[any]{}
+			results["remove"], _ = s.RemoveFileOrDir(ctx, nil, &mcp.CallToolParamsFor[RemoveParams]{Arguments: RemoveParams{Path: path}})
+			results["move source"], _ = s.Move(ctx, nil, &mcp.CallToolParamsFor[MoveParams]{Arguments: MoveParams{Source: path, Dest: "moved"}})
+			results["move dest"], _ = s.Move(ctx, nil, &mcp.CallToolParamsFor[MoveParams]{Arguments: MoveParams{Source: "sub", Dest: path, Overwrite: true}})
+			for tool, result := range results {
+				if !result.IsError {
+					t.Errorf("%s %q succeeded: %s", tool, path, resultText(t, result))
+				}
+			}
+			if _, err := os.Stat(filepath.Join(s.root, "sub", "a.txt")); err != nil {
+				t.Fatalf("the root was changed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveInsideRoot(t *testing.T) {
+	s, _ := sandbox(t)
+	result, _ := s.RemoveFileOrDir(context.Background(), nil, &mcp.CallToolParamsFor[RemoveParams]{Arguments: RemoveParams{Path: "sub"}})
+	if result.IsError {
+		t.Fatalf("remove sub: %s", resultText(t, result))
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("sub was not removed: %v", err)
+	}
+	if _, err := os.Stat(s.root); err != nil {
+		t.Fatalf("the root is gone: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ListDirParams represents parameters for listing a directory
+type ListDirParams struct {
+	Path          string `json:"path,omitempty" mcp:"directory to list (default: current directory)"`
+	SortBy        string `json:"sort_by,omitempty" mcp:"order of the entries: name, size (largest first) or mtime (newest first) (default: name)"`
+	IncludeHidden bool   `json:"include_hidden,omitempty" mcp:"whether to list entries whose names start with a dot (default: false)"`
+	JSON          bool   `json:"json,omitempty" mcp:"whether to return the entries as JSON instead of aligned text (default: false)"`
+}
+
+// dirEntry is an entry of a directory listing
+type dirEntry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+
+	// Target is where a symlink points
+	Target string `json:"target,omitempty"`
+}
+
+// entryType names the type of a file: file, dir, symlink or other
+func entryType(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsDir():
+		return "dir"
+	case mode.IsRegular():
+		return "file"
+	}
+	return "other"
+}
+
+// ListDir lists the entries of a directory with their metadata
+func (s *toolset) ListDir(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ListDirParams]) (*mcp.CallToolResultFor[any], error) {
+	directory, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var less func(a, b dirEntry) bool
+	switch params.Arguments.SortBy {
+	case "", "name":
+		less = func(a, b dirEntry) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b dirEntry) bool { return a.Size > b.Size }
+	case "mtime":
+		less = func(a, b dirEntry) bool { return a.ModTime.After(b.ModTime) }
+	default:
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid sort_by %q: use name, size or mtime", params.Arguments.SortBy)}},
+			IsError: true,
+		}, nil
+	}
+
+	info, err := os.Stat(directory)
+	switch {
+	case os.IsNotExist(err):
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Directory %s does not exist", directory)}},
+			IsError: true,
+		}, nil
+	case err != nil:
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading directory: %v", err)}},
+			IsError: true,
+		}, nil
+	case !info.IsDir():
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("%s is not a directory", directory)}},
+			IsError: true,
+		}, nil
+	}
+
+	dirEntries, err := os.ReadDir(directory)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading directory: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	entries := make([]dirEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		if !params.Arguments.IncludeHidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// The entry was removed since the directory was read
+			continue
+		}
+		entry := dirEntry{
+			Name:    e.Name(),
+			Type:    entryType(info.Mode()),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+		}
+		if entry.Type == "symlink" {
+			entry.Target, _ = os.Readlink(filepath.Join(directory, e.Name()))
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	if params.Arguments.JSON {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error encoding the listing: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: string(data)}},
+		}, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Directory %s (%d entries):\n", directory, len(entries))
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		name := entry.Name
+		switch entry.Type {
+		case "dir":
+			name += "/"
+		case "symlink":
+			name += " -> " + entry.Target
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", entry.Type, entry.Size, entry.Mode, entry.ModTime.Format("2006-01-02 15:04"), name)
+	}
+	w.Flush()
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, nil
+}
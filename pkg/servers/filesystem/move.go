@@ -0,0 +1,132 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MoveParams represents parameters for moving or renaming a file or directory
+type MoveParams struct {
+	Source    string `json:"source" mcp:"path of the file or directory to move"`
+	Dest      string `json:"dest" mcp:"path to move it to"`
+	Overwrite bool   `json:"overwrite,omitempty" mcp:"whether to replace an existing destination (default: false)"`
+}
+
+// Move moves or renames a file or directory, copying it and removing the
+// original when the destination is on another filesystem
+func (s *toolset) Move(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[MoveParams]) (*mcp.CallToolResultFor[any], error) {
+	source, err := s.resolveBelowRoot(params.Arguments.Source)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	dest, err := s.resolveBelowRoot(params.Arguments.Dest)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	if _, err := os.Lstat(source); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error reading source: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	if _, err := os.Lstat(dest); err == nil && !params.Arguments.Overwrite {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Destination %s exists; set overwrite to replace it", params.Arguments.Dest)}},
+			IsError: true,
+		}, nil
+	}
+
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error creating parent directories: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	err = os.Rename(source, dest)
+	if errors.Is(err, syscall.EXDEV) {
+		// Rename cannot cross filesystems: copy, then remove the original
+		// only once the copy is complete
+		if err = copyPath(source, dest); err == nil {
+			err = os.RemoveAll(source)
+		}
+	}
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error moving: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Successfully moved %s to %s", params.Arguments.Source, params.Arguments.Dest)}},
+	}, nil
+}
+
+// copyPath copies a file, symlink or directory tree to dest, keeping
+// permissions
+func copyPath(source, dest string) error {
+	return filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		}
+		return copyFileMode(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFileMode copies the contents of a regular file, creating or
+// truncating dest with mode
+func copyFileMode(source, dest string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
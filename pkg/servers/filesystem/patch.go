@@ -0,0 +1,541 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxPatchFuzz is how many lines of context at each end of a hunk may be
+// ignored when the hunk does not match with all of them
+const maxPatchFuzz = 2
+
+// hunkHeader matches the header of a hunk, such as @@ -12,5 +12,6 @@
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// ApplyPatchParams represents parameters for applying a unified diff
+type ApplyPatchParams struct {
+	Patch  string `json:"patch" mcp:"unified diff to apply, with --- and +++ headers for each file; /dev/null creates or deletes a file"`
+	DryRun bool   `json:"dry_run,omitempty" mcp:"whether to only report which hunks apply, without changing any file (default: false)"`
+}
+
+// filePatch is the part of a patch that changes one file
+type filePatch struct {
+	// oldPath and newPath are the paths of the --- and +++ headers; empty
+	// for /dev/null, when the file is created or deleted
+	oldPath string
+	newPath string
+	hunks   []*hunk
+}
+
+// path names the file the patch changes
+func (p *filePatch) path() string {
+	if p.newPath == "" {
+		return p.oldPath
+	}
+	return p.newPath
+}
+
+// hunk is a change to consecutive lines of a file
+type hunk struct {
+	header   string
+	oldStart int
+
+	// lines are the lines of the hunk without their prefix; kinds holds
+	// the prefixes: ' ' for context, '-' for removed and '+' for added
+	lines []string
+	kinds []byte
+
+	// oldNoEOL and newNoEOL report that the old or new lines end the file
+	// without a line break
+	oldNoEOL bool
+	newNoEOL bool
+}
+
+// side returns the lines of the hunk before (old) or after the change,
+// without the first lead and last trail lines of context
+func (h *hunk) side(old bool, lead, trail int) []string {
+	var lines []string
+	for i, kind := range h.kinds[lead : len(h.kinds)-trail] {
+		if kind == ' ' || (kind == '-') == old {
+			lines = append(lines, h.lines[lead+i])
+		}
+	}
+	return lines
+}
+
+// start is the index of the line the hunk's header names as its first
+// old line, after lead lines of context are dropped; a hunk without old
+// lines names the line its new lines follow
+func (h *hunk) start(lead int) int {
+	if len(h.side(true, 0, 0)) == 0 {
+		return h.oldStart
+	}
+	return max(h.oldStart-1, 0) + lead
+}
+
+// context counts the lines of context that start and end the hunk
+func (h *hunk) context() (lead, trail int) {
+	for lead < len(h.kinds) && h.kinds[lead] == ' ' {
+		lead++
+	}
+	for trail < len(h.kinds)-lead && h.kinds[len(h.kinds)-1-trail] == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// parsePatch splits a unified diff into the changes to each file. The line
+// counts of hunk headers are ignored, as models often get them wrong; a
+// hunk ends at the first line that is not part of one.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	isFileHeader := func(i int) bool {
+		return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	}
+
+	var files []*filePatch
+	var current *filePatch
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isFileHeader(i):
+			current = &filePatch{}
+			current.oldPath, current.newPath = patchPaths(line[4:], lines[i+1][4:])
+			if current.oldPath == "" && current.newPath == "" {
+				return nil, fmt.Errorf("line %d: both files are /dev/null", i+1)
+			}
+			files = append(files, current)
+			i += 2
+			continue
+		case !strings.HasPrefix(line, "@@"):
+			// diff --git, index and other lines between files
+			i++
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: hunk before any --- and +++ file header", i+1)
+		}
+		m := hunkHeader.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: invalid hunk header %q; expected @@ -start,count +start,count @@", i+1, line)
+		}
+		h := &hunk{header: line}
+		h.oldStart, _ = strconv.Atoi(m[1])
+
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ") || isFileHeader(i) {
+				break
+			}
+			if line == "" {
+				// Blank context lines often lose their leading space
+				line = " "
+			}
+			kind := line[0]
+			switch kind {
+			case ' ', '-', '+':
+				h.kinds = append(h.kinds, kind)
+				h.lines = append(h.lines, line[1:])
+				continue
+			case '\\':
+				// \ No newline at end of file, about the line before
+				if len(h.kinds) > 0 {
+					switch h.kinds[len(h.kinds)-1] {
+					case '-':
+						h.oldNoEOL = true
+					case '+':
+						h.newNoEOL = true
+					default:
+						h.oldNoEOL, h.newNoEOL = true, true
+					}
+				}
+				continue
+			}
+			break
+		}
+
+		// Trailing blank lines are usually the end of the patch, not context
+		for len(h.kinds) > 0 && h.kinds[len(h.kinds)-1] == ' ' && strings.TrimSpace(h.lines[len(h.lines)-1]) == "" {
+			h.kinds = h.kinds[:len(h.kinds)-1]
+			h.lines = h.lines[:len(h.lines)-1]
+		}
+		if !strings.ContainsAny(string(h.kinds), "+-") {
+			return nil, fmt.Errorf("hunk %q of %s changes no lines", h.header, current.path())
+		}
+		current.hunks = append(current.hunks, h)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no --- and +++ file headers found; the patch must be a unified diff")
+	}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if len(file.hunks) == 0 {
+			return nil, fmt.Errorf("%s has no hunks", file.path())
+		}
+		if seen[file.path()] {
+			return nil, fmt.Errorf("%s is patched more than once; merge its hunks under one header", file.path())
+		}
+		seen[file.path()] = true
+	}
+	return files, nil
+}
+
+// patchPaths returns the paths of a pair of --- and +++ headers, empty for
+// /dev/null, dropping timestamps and the a/ and b/ prefixes of git
+func patchPaths(oldHeader, newHeader string) (string, string) {
+	clean := func(header string) string {
+		path, _, _ := strings.Cut(header, "\t")
+		if path = strings.TrimSpace(path); path == "/dev/null" {
+			return ""
+		}
+		return path
+	}
+	oldPath, newPath := clean(oldHeader), clean(newHeader)
+	oldGit := oldPath == "" || strings.HasPrefix(oldPath, "a/")
+	newGit := newPath == "" || strings.HasPrefix(newPath, "b/")
+	if oldGit && newGit {
+		oldPath = strings.TrimPrefix(oldPath, "a/")
+		newPath = strings.TrimPrefix(newPath, "b/")
+	}
+	return oldPath, newPath
+}
+
+// hunkResult reports where a hunk applies, or why it does not
+type hunkResult struct {
+	header string
+	line   int
+	offset int
+	fuzz   int
+	failed string
+}
+
+// String describes the result, such as "applies at line 12 (offset 2)"
+func (r hunkResult) String() string {
+	if r.failed != "" {
+		return fmt.Sprintf("%s FAILED: %s", r.header, r.failed)
+	}
+	description := fmt.Sprintf("%s applies at line %d", r.header, r.line)
+	var notes []string
+	if r.offset != 0 {
+		notes = append(notes, fmt.Sprintf("offset %+d", r.offset))
+	}
+	if r.fuzz > 0 {
+		notes = append(notes, fmt.Sprintf("fuzz %d", r.fuzz))
+	}
+	if len(notes) > 0 {
+		description += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return description
+}
+
+// patchedFile is a file with a patch applied in memory
+type patchedFile struct {
+	patch   *filePatch
+	source  string
+	target  string
+	content string
+	mode    os.FileMode
+	hunks   []hunkResult
+
+	// err is why the file cannot be patched at all, such as a missing file
+	err error
+}
+
+// failed reports whether the file or any of its hunks cannot be applied
+func (f *patchedFile) failed() bool {
+	if f.err != nil {
+		return true
+	}
+	for _, r := range f.hunks {
+		if r.failed != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPatch applies a unified diff to the files under the root. Nothing
+// is written unless every hunk applies; each file is then replaced
+// atomically.
+func (s *toolset) ApplyPatch(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ApplyPatchParams]) (*mcp.CallToolResultFor[any], error) {
+	patches, err := parsePatch(params.Arguments.Patch)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error parsing patch: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	files := make([]*patchedFile, len(patches))
+	failed := false
+	for i, p := range patches {
+		files[i] = s.patchFile(p)
+		failed = failed || files[i].failed()
+	}
+
+	var b strings.Builder
+	hunks := 0
+	for _, f := range files {
+		hunks += len(f.patch.hunks)
+	}
+	switch {
+	case params.Arguments.DryRun && failed:
+		fmt.Fprintf(&b, "Dry run: the patch does not apply cleanly to %d files, %d hunks\n", len(files), hunks)
+	case params.Arguments.DryRun:
+		fmt.Fprintf(&b, "Dry run: the patch applies to %d files, %d hunks\n", len(files), hunks)
+	case failed:
+		fmt.Fprintf(&b, "The patch does not apply; no files were changed\n")
+	}
+	if params.Arguments.DryRun || failed {
+		writePatchReport(&b, files)
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+			IsError: failed && !params.Arguments.DryRun,
+		}, nil
+	}
+
+	for i, f := range files {
+		if err := f.write(); err != nil {
+			var written []string
+			for _, f := range files[:i] {
+				written = append(written, f.patch.path())
+			}
+			message := fmt.Sprintf("Error writing %s: %v", f.patch.path(), err)
+			if len(written) > 0 {
+				message += fmt.Sprintf("; already patched: %s", strings.Join(written, ", "))
+			}
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: message}},
+				IsError: true,
+			}, nil
+		}
+	}
+
+	fmt.Fprintf(&b, "Successfully applied the patch to %d files, %d hunks\n", len(files), hunks)
+	writePatchReport(&b, files)
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, nil
+}
+
+// writePatchReport lists each file of a patch with the result of each hunk
+func writePatchReport(b *strings.Builder, files []*patchedFile) {
+	for _, f := range files {
+		switch {
+		case f.patch.oldPath == "":
+			fmt.Fprintf(b, "%s (new file):\n", f.patch.newPath)
+		case f.patch.newPath == "":
+			fmt.Fprintf(b, "%s (deleted):\n", f.patch.oldPath)
+		case f.patch.oldPath != f.patch.newPath:
+			fmt.Fprintf(b, "%s (renamed from %s):\n", f.patch.newPath, f.patch.oldPath)
+		default:
+			fmt.Fprintf(b, "%s:\n", f.patch.path())
+		}
+		if f.err != nil {
+			fmt.Fprintf(b, "  FAILED: %v\n", f.err)
+			continue
+		}
+		for _, r := range f.hunks {
+			fmt.Fprintf(b, "  %s\n", r)
+		}
+	}
+}
+
+// patchFile applies the hunks of a file patch to its contents in memory
+func (s *toolset) patchFile(p *filePatch) *patchedFile {
+	f := &patchedFile{patch: p, mode: 0644}
+	var err error
+	if p.oldPath != "" {
+		if f.source, err = s.resolve(p.oldPath); err != nil {
+			f.err = err
+			return f
+		}
+	}
+	if p.newPath != "" {
+		if f.target, err = s.resolve(p.newPath); err != nil {
+			f.err = err
+			return f
+		}
+	}
+
+	var content string
+	if p.oldPath == "" || (p.newPath != "" && f.target != f.source) {
+		if _, err := os.Lstat(f.target); err == nil {
+			f.err = fmt.Errorf("%s exists already", p.newPath)
+			return f
+		}
+	}
+	if p.oldPath != "" {
+		info, err := os.Stat(f.source)
+		switch {
+		case err != nil:
+			f.err = fmt.Errorf("failed to read file: %w", err)
+			return f
+		case !info.Mode().IsRegular():
+			f.err = fmt.Errorf("%s is not a regular file", p.oldPath)
+			return f
+		case info.Size() > MaxReadBytesLimit:
+			f.err = fmt.Errorf("%s is %d bytes, too large to patch", p.oldPath, info.Size())
+			return f
+		}
+		data, err := os.ReadFile(f.source)
+		if err != nil {
+			f.err = fmt.Errorf("failed to read file: %w", err)
+			return f
+		}
+		content = string(data)
+		f.mode = info.Mode().Perm()
+	}
+
+	// Lines are matched without their line endings, which the result keeps
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	eol := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		for i := range lines {
+			lines[i] = strings.TrimSuffix(lines[i], "\r")
+		}
+	}
+
+	var out []string
+	cursor, offset := 0, 0
+	for _, h := range p.hunks {
+		r := hunkResult{header: h.header}
+		pos, lead, trail, ok := locateHunk(lines, cursor, h, offset)
+		if !ok {
+			r.failed = "its context and removed lines do not match the file"
+			if h.oldStart > len(lines)+1 {
+				r.failed = fmt.Sprintf("it starts at line %d, past the end of the file (%d lines)", h.oldStart, len(lines))
+			}
+			f.hunks = append(f.hunks, r)
+			continue
+		}
+		expected := h.start(lead)
+		offset = pos - expected
+		r.line, r.offset, r.fuzz = pos+1, offset, max(lead, trail)
+		f.hunks = append(f.hunks, r)
+
+		out = append(out, lines[cursor:pos]...)
+		out = append(out, h.side(false, lead, trail)...)
+		cursor = pos + len(h.side(true, lead, trail))
+		if cursor == len(lines) && trail == 0 && (h.oldNoEOL || h.newNoEOL) {
+			eol = !h.newNoEOL
+		}
+	}
+	out = append(out, lines[cursor:]...)
+
+	if p.newPath == "" && len(out) > 0 && !f.failed() {
+		f.err = fmt.Errorf("the patch deletes %s but leaves %d of its lines", p.oldPath, len(out))
+		return f
+	}
+	f.content = strings.Join(out, newline)
+	if eol && len(out) > 0 {
+		f.content += newline
+	}
+	return f
+}
+
+// locateHunk finds where the old lines of a hunk are in lines, at or after
+// cursor and as close as possible to the line its header names. Failing
+// that, up to maxPatchFuzz lines of context at either end are ignored.
+// It returns the position and the lines of context dropped at each end.
+func locateHunk(lines []string, cursor int, h *hunk, offset int) (pos, lead, trail int, ok bool) {
+	leadContext, trailContext := h.context()
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		lead, trail = min(fuzz, leadContext), min(fuzz, trailContext)
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		old := h.side(true, lead, trail)
+		if len(old) == 0 {
+			// A hunk without context that only adds lines: its header
+			// names the line they follow
+			if fuzz > 0 {
+				break
+			}
+			return min(max(h.start(0)+offset, cursor), len(lines)), 0, 0, true
+		}
+		expected := h.start(lead) + offset
+		for d := 0; ; d++ {
+			before, after := expected-d, expected+d
+			if before < cursor && after > len(lines)-len(old) {
+				break
+			}
+			if after >= cursor && after <= len(lines)-len(old) && linesMatch(lines[after:], old) {
+				return after, lead, trail, true
+			}
+			if d > 0 && before >= cursor && before <= len(lines)-len(old) && linesMatch(lines[before:], old) {
+				return before, lead, trail, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// linesMatch reports whether lines starts with want, ignoring trailing
+// whitespace
+func linesMatch(lines, want []string) bool {
+	for i, line := range want {
+		if strings.TrimRight(lines[i], " \t") != strings.TrimRight(line, " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+// write replaces the target file with the patched contents through a
+// temporary file, and removes the source of a rename or deletion
+func (f *patchedFile) write() error {
+	if f.patch.newPath == "" {
+		return os.Remove(f.source)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.target), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories: %w", err)
+	}
+	if err := writeFileAtomic(f.target, []byte(f.content), f.mode); err != nil {
+		return err
+	}
+	if f.source != "" && f.source != f.target {
+		return os.Remove(f.source)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so readers see either the old or the new contents
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
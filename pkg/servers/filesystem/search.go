@@ -0,0 +1,400 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Limits of search_in_files and its output
+const (
+	// defaultMaxMatchesPerFile is how many matches of a file are shown
+	// unless a call sets max_matches_per_file
+	defaultMaxMatchesPerFile = 20
+
+	// maxContextLines caps context_lines
+	maxContextLines = 10
+
+	// maxSearchLineBytes is the most of a line shown; longer lines, such as
+	// minified code, are cut around the match
+	maxSearchLineBytes = 200
+
+	// maxSearchOutputBytes caps the whole result; the files past it are
+	// counted but not shown
+	maxSearchOutputBytes = 64 << 10
+
+	// DefaultMaxSearchFileBytes is the size of the largest file searched
+	// unless the options set another
+	DefaultMaxSearchFileBytes = 4 << 20
+
+	// maxSearchConcurrency caps the files searched at once
+	maxSearchConcurrency = 64
+
+	// binarySniffBytes is how much of the start of a file decides whether
+	// it is binary
+	binarySniffBytes = 8 << 10
+)
+
+// Reasons search_in_files skips a file
+const (
+	skipNone = iota
+	skipBinary
+	skipLarge
+)
+
+// SearchInFilesParams represents parameters for searching text in files
+type SearchInFilesParams struct {
+	SearchText        string   `json:"search_text" mcp:"text to search for in files, or a regular expression with regex"`
+	Regex             bool     `json:"regex,omitempty" mcp:"whether search_text is a Go regular expression matched against each line (default: false)"`
+	Directory         string   `json:"directory,omitempty" mcp:"directory to search in (default: current directory)"`
+	FileFilter        string   `json:"file_filter,omitempty" mcp:"regex pattern to filter files (default: match all files)"`
+	Recursive         bool     `json:"recursive,omitempty" mcp:"whether to search recursively (default: true)"`
+	Exclude           []string `json:"exclude,omitempty" mcp:"patterns of paths to skip, such as vendor, dist or *_gen.go; a pattern with / matches the path relative to directory, with ** for any number of directories (default: none)"`
+	ContextLines      int      `json:"context_lines,omitempty" mcp:"lines to show before and after each match, like grep -C (default: 0, at most 10)"`
+	MaxMatchesPerFile int      `json:"max_matches_per_file,omitempty" mcp:"most matches to show for each file (default: 20)"`
+	IncludeBinary     bool     `json:"include_binary,omitempty" mcp:"whether to also search files that look binary, such as images and executables (default: false)"`
+	Concurrency       int      `json:"concurrency,omitempty" mcp:"files to read and search at once (default: the number of CPUs, at most 64)"`
+}
+
+// SearchInFiles searches for text within files and returns each matching
+// line with its line number. Files are read and scanned by a pool of
+// workers.
+func (s *toolset) SearchInFiles(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[SearchInFilesParams]) (*mcp.CallToolResultFor[any], error) {
+	directory, err := s.resolve(params.Arguments.Directory)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var fileFilter *regexp.Regexp
+	if params.Arguments.FileFilter != "" {
+		var err error
+		fileFilter, err = regexp.Compile(params.Arguments.FileFilter)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid file filter regex: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+	}
+	if params.Arguments.ContextLines < 0 || params.Arguments.MaxMatchesPerFile < 0 {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "context_lines and max_matches_per_file must not be negative"}},
+			IsError: true,
+		}, nil
+	}
+	exclude, err := newExcludes(params.Arguments.Exclude)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid exclude: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	contextLines := min(params.Arguments.ContextLines, maxContextLines)
+	maxMatches := params.Arguments.MaxMatchesPerFile
+	if maxMatches == 0 {
+		maxMatches = defaultMaxMatchesPerFile
+	}
+
+	search := params.Arguments.SearchText
+	match := func(line string) []int {
+		if i := strings.Index(line, search); i >= 0 {
+			return []int{i, i + len(search)}
+		}
+		return nil
+	}
+	if params.Arguments.Regex {
+		regex, err := regexp.Compile(search)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid search regex: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+		match = regex.FindStringIndex
+	}
+
+	concurrency := params.Arguments.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, maxSearchConcurrency)
+
+	// The walk hands the files to a pool of workers that read and scan
+	// them; each result is stored at the index of its file, so the output
+	// keeps the order of the walk
+	var (
+		results []searchResult
+		lock    sync.Mutex
+		workers sync.WaitGroup
+	)
+	jobs := make(chan searchJob)
+	for range concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				result := searchFile(job.path, s.maxSearchFileBytes, params.Arguments.IncludeBinary, match, contextLines, maxMatches)
+				lock.Lock()
+				results[job.index] = result
+				lock.Unlock()
+			}
+		}()
+	}
+
+	err = walkFiles(directory, params.Arguments.Recursive, exclude, func(path string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Apply file filter if specified
+		if fileFilter != nil && !fileFilter.MatchString(filepath.Base(path)) {
+			return nil
+		}
+
+		// Do not read through symlinks that lead outside the root
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, err := s.resolve(path); err != nil {
+				return nil
+			}
+		}
+
+		lock.Lock()
+		job := searchJob{index: len(results), path: path}
+		results = append(results, searchResult{path: path})
+		lock.Unlock()
+		select {
+		case jobs <- job:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	workers.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error searching in files: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var body strings.Builder
+	files, matches, hidden, binary, large := 0, 0, 0, 0, 0
+	for _, r := range results {
+		switch r.skip {
+		case skipBinary:
+			binary++
+			continue
+		case skipLarge:
+			large++
+			continue
+		}
+		if r.matches == 0 {
+			continue
+		}
+		files++
+		matches += r.matches
+		if body.Len()+len(r.path)+len(r.block) > maxSearchOutputBytes {
+			hidden++
+			continue
+		}
+		body.WriteString(r.path + ":\n" + r.block)
+	}
+
+	kind := "text"
+	if params.Arguments.Regex {
+		kind = "pattern"
+	}
+	result := fmt.Sprintf("Found %s '%s' in %d files (%d matching lines):\n", kind, params.Arguments.SearchText, files, matches)
+	result += body.String()
+	if hidden > 0 {
+		result += fmt.Sprintf("[truncated: %d more files with matches are not shown; narrow the search with directory or file_filter]\n", hidden)
+	}
+	if binary > 0 {
+		result += fmt.Sprintf("[skipped %d binary files; set include_binary to search them]\n", binary)
+	}
+	if large > 0 {
+		result += fmt.Sprintf("[skipped %d files larger than %d bytes]\n", large, s.maxSearchFileBytes)
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// searchJob is a file for a search worker to scan
+type searchJob struct {
+	index int
+	path  string
+}
+
+// searchResult is what a search worker found in a file
+type searchResult struct {
+	path    string
+	block   string
+	matches int
+	skip    int
+}
+
+// searchFile reads and scans a file for search_in_files. A file that cannot
+// be read has no matches.
+func searchFile(path string, maxBytes int64, includeBinary bool, match func(line string) []int, contextLines, maxMatches int) searchResult {
+	result := searchResult{path: path}
+	content, skip, err := readSearchFile(path, maxBytes, includeBinary)
+	if err != nil {
+		// Skip files that can't be read
+		return result
+	}
+	if result.skip = skip; skip != skipNone {
+		return result
+	}
+	result.block, result.matches = searchContent(string(content), match, contextLines, maxMatches)
+	return result
+}
+
+// readSearchFile reads a file to search, unless it is larger than maxBytes
+// or, without includeBinary, its start looks binary; skip tells which
+func readSearchFile(path string, maxBytes int64, includeBinary bool) ([]byte, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, skipNone, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, skipNone, err
+	}
+	if info.Size() > maxBytes {
+		return nil, skipLarge, nil
+	}
+
+	head := make([]byte, binarySniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, skipNone, err
+	}
+	head = head[:n]
+	if !includeBinary && looksBinary(head) {
+		return nil, skipBinary, nil
+	}
+	rest, err := io.ReadAll(io.LimitReader(f, maxBytes-int64(n)))
+	if err != nil {
+		return nil, skipNone, err
+	}
+	return append(head, rest...), skipNone, nil
+}
+
+// looksBinary reports whether data, the start of a file, is binary: it
+// holds a NUL byte, or more than 30% of it is not valid UTF-8
+func looksBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	invalid := 0
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			if !utf8.FullRune(data[i:]) {
+				// A rune cut off at the end of the sniffed bytes
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	return invalid*10 > len(data)*3
+}
+
+// searchContent finds the lines of content that match, returning them
+// numbered like grep -n, "12: line", with contextLines lines around each
+// as "11- line" and "--" between separate groups. Only the first
+// maxMatches matches are shown; it also returns how many lines match.
+func searchContent(content string, match func(line string) []int, contextLines, maxMatches int) (string, int) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var hits []int
+	locs := make(map[int][]int)
+	for i, line := range lines {
+		// Match $ at the end of lines with CRLF endings too
+		line = strings.TrimSuffix(line, "\r")
+		lines[i] = line
+		if loc := match(line); loc != nil {
+			hits = append(hits, i)
+			if len(locs) < maxMatches {
+				locs[i] = loc
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return "", 0
+	}
+
+	var b strings.Builder
+	last := -1
+	for _, hit := range hits[:min(len(hits), maxMatches)] {
+		from := max(hit-contextLines, last+1)
+		to := min(hit+contextLines, len(lines)-1)
+		if last >= 0 && from > last+1 {
+			b.WriteString("  --\n")
+		}
+		for i := from; i <= to; i++ {
+			if loc, ok := locs[i]; ok {
+				fmt.Fprintf(&b, "  %d: %s\n", i+1, clipLine(lines[i], loc))
+			} else {
+				fmt.Fprintf(&b, "  %d- %s\n", i+1, clipLine(lines[i], nil))
+			}
+		}
+		last = max(last, to)
+	}
+	if len(hits) > maxMatches {
+		fmt.Fprintf(&b, "  … %d more matching lines in this file\n", len(hits)-maxMatches)
+	}
+	return b.String(), len(hits)
+}
+
+// clipLine cuts a line longer than maxSearchLineBytes to a window around
+// the match at loc (nil: the start), marking the cuts with …
+func clipLine(line string, loc []int) string {
+	if len(line) <= maxSearchLineBytes {
+		return line
+	}
+	start := 0
+	if loc != nil && loc[1] > maxSearchLineBytes {
+		start = max(0, loc[0]-maxSearchLineBytes/4)
+	}
+	end := min(len(line), start+maxSearchLineBytes)
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start++
+	}
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end--
+	}
+
+	clipped := line[start:end]
+	if start > 0 {
+		clipped = "…" + clipped
+	}
+	if end < len(line) {
+		clipped += "…"
+	}
+	return clipped
+}
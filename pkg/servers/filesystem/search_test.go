@@ -0,0 +1,107 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// makeSearchTree writes dirs directories of files files each under root.
+// Every third file mentions the needle on a few of its lines, and each
+// directory also holds a binary file that mentions it.
+func makeSearchTree(tb testing.TB, root string, dirs, files, lines int) {
+	tb.Helper()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%02d", d), "sub")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < files; f++ {
+			var sb strings.Builder
+			for l := 0; l < lines; l++ {
+				if f%3 == 0 && l%25 == 7 {
+					fmt.Fprintf(&sb, "line %d of file %d calls needle(%d)\n", l, f, d)
+				} else {
+					fmt.Fprintf(&sb, "line %d of file %d is filler text\n", l, f)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d.go", f)), []byte(sb.String()), 0644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+		binary := append([]byte("needle\x00"), make([]byte, 64)...)
+		if err := os.WriteFile(filepath.Join(dir, "blob.bin"), binary, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// search runs search_in_files over the whole root and returns its text
+func search(tb testing.TB, s *toolset, args SearchInFilesParams) string {
+	tb.Helper()
+	result, err := s.SearchInFiles(context.Background(), nil, &mcp.CallToolParamsFor[SearchInFilesParams]{Arguments: args})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	text := result.Content[0].(*mcp.TextContent).Text
+	if result.IsError {
+		tb.Fatalf("search failed: %s", text)
+	}
+	return text
+}
+
+func TestSearchInFilesOrderIsStable(t *testing.T) {
+	root := t.TempDir()
+	makeSearchTree(t, root, 6, 30, 60)
+	s, err := newToolset(Options{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := SearchInFilesParams{SearchText: "needle", Recursive: true, ContextLines: 1, Concurrency: 1}
+	want := search(t, s, args)
+	if !strings.Contains(want, "Found text 'needle' in 60 files") {
+		t.Fatalf("unexpected result with one worker:\n%.500s", want)
+	}
+	if strings.Contains(want, "blob.bin") {
+		t.Fatalf("binary files were searched:\n%.500s", want)
+	}
+
+	for _, concurrency := range []int{0, 2, 7, 64, 1000} {
+		for run := 0; run < 3; run++ {
+			args.Concurrency = concurrency
+			if got := search(t, s, args); got != want {
+				t.Fatalf("concurrency %d, run %d: output differs from one worker\ngot:\n%.500s\nwant:\n%.500s", concurrency, run, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkSearchInFiles(b *testing.B) {
+	root := b.TempDir()
+	makeSearchTree(b, root, 20, 50, 200)
+	s, err := newToolset(Options{Root: root})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			args := SearchInFilesParams{SearchText: "needle", Recursive: true, Concurrency: concurrency}
+			for b.Loop() {
+				search(b, s, args)
+			}
+		})
+	}
+	b.Run("regex", func(b *testing.B) {
+		args := SearchInFilesParams{SearchText: `needle\(\d+\)`, Regex: true, Recursive: true}
+		for b.Loop() {
+			search(b, s, args)
+		}
+	})
+}
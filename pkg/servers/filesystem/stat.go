@@ -0,0 +1,89 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// StatParams represents parameters for inspecting a path
+type StatParams struct {
+	Path string `json:"path" mcp:"path of the file or directory to inspect"`
+}
+
+// pathInfo is the answer of stat
+type pathInfo struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+
+	Type        string     `json:"type,omitempty"`
+	Size        *int64     `json:"size,omitempty"`
+	Mode        string     `json:"mode,omitempty"`
+	Permissions string     `json:"permissions,omitempty"`
+	ModTime     *time.Time `json:"mod_time,omitempty"`
+
+	// For symlinks: the link as written, the path it resolves to, and the
+	// type of what it points at (empty: the link is broken)
+	Target       string `json:"target,omitempty"`
+	ResolvedPath string `json:"resolved_path,omitempty"`
+	TargetType   string `json:"target_type,omitempty"`
+}
+
+// Stat reports whether a path exists and, if so, its type, size,
+// permissions and modification time. A missing path is an answer, not an
+// error.
+func (s *toolset) Stat(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[StatParams]) (*mcp.CallToolResultFor[any], error) {
+	path, err := s.resolve(params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid path: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	answer := pathInfo{Path: path}
+
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		// Exists stays false
+	case err != nil:
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error inspecting path: %v", err)}},
+			IsError: true,
+		}, nil
+	default:
+		modTime := info.ModTime()
+		answer.Exists = true
+		answer.Type = entryType(info.Mode())
+		size := info.Size()
+		answer.Size = &size
+		answer.Mode = info.Mode().String()
+		answer.Permissions = fmt.Sprintf("%04o", info.Mode().Perm())
+		answer.ModTime = &modTime
+		if answer.Type == "symlink" {
+			answer.Target, _ = os.Readlink(path)
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				answer.ResolvedPath = resolved
+				if target, err := os.Stat(resolved); err == nil {
+					answer.TargetType = entryType(target.Mode())
+				}
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(answer, "", "  ")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error encoding the answer: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(data)}},
+	}, nil
+}
@@ -0,0 +1,133 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// excludes are the patterns of paths that file discovery skips
+type excludes []string
+
+// newExcludes checks and cleans exclude patterns
+func newExcludes(patterns []string) (excludes, error) {
+	var e excludes
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(pattern), "./"), "/")
+		if pattern == "" {
+			continue
+		}
+		if err := checkGlob(pattern); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		e = append(e, pattern)
+	}
+	return e, nil
+}
+
+// match reports whether a path relative to the walked directory is
+// excluded. A pattern without / is matched against the name, at any
+// depth; one with / is a glob matched against the whole relative path.
+func (e excludes) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	name := path.Base(rel)
+	for _, pattern := range e {
+		if strings.Contains(pattern, "/") {
+			if matchGlob(pattern, rel) {
+				return true
+			}
+		} else if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGlob reports a syntax error in a glob pattern
+func checkGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether a slash-separated path matches a glob, where
+// ** matches any number of directories and the other elements match one
+// each, as with path.Match: cmd/*/main.go, **/*.go or docs/**
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the elements of a path against those of a glob
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// walkFiles calls fn for each file in directory, and in its subdirectories
+// when recursive, skipping the excluded paths without descending into
+// excluded directories. fn may return fs.SkipAll to stop early.
+func walkFiles(directory string, recursive bool, exclude excludes, fn func(path string, d fs.DirEntry) error) error {
+	if !recursive {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || exclude.match(entry.Name()) {
+				continue
+			}
+			if err := fn(filepath.Join(directory, entry.Name()), entry); err != nil {
+				if err == fs.SkipAll {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	return filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == directory {
+			return nil
+		}
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		if exclude.match(rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return fn(path, d)
+	})
+}
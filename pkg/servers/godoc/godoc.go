@@ -0,0 +1,419 @@
+// Package godoc implements the godoc MCP server: the go command's doc,
+// fmt, vet, test, build, mod, version, env and list
+package godoc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// GodocParams represents parameters for godoc tool
+type GodocParams struct {
+	PackagePath string `json:"package_path" mcp:"package path to generate documentation for"`
+	Output      string `json:"output,omitempty" mcp:"output format (html, text) - default: text"`
+}
+
+// GoFmtParams represents parameters for go fmt
+type GoFmtParams struct {
+	FilePath string `json:"file_path" mcp:"path to Go file to format"`
+	Write    bool   `json:"write,omitempty" mcp:"write result to file instead of stdout"`
+}
+
+// GoVetParams represents parameters for go vet
+type GoVetParams struct {
+	PackagePath string `json:"package_path,omitempty" mcp:"package path to vet (default: current directory)"`
+}
+
+// GoTestParams represents parameters for go test
+type GoTestParams struct {
+	PackagePath string `json:"package_path,omitempty" mcp:"package path to test (default: current directory)"`
+	Verbose     bool   `json:"verbose,omitempty" mcp:"verbose output"`
+	Cover       bool   `json:"cover,omitempty" mcp:"enable coverage analysis"`
+}
+
+// GoBuildParams represents parameters for go build
+type GoBuildParams struct {
+	PackagePath string `json:"package_path,omitempty" mcp:"package path to build (default: current directory)"`
+	Output      string `json:"output,omitempty" mcp:"output binary name"`
+	Tags        string `json:"tags,omitempty" mcp:"build tags"`
+}
+
+// GoModParams represents parameters for go mod commands
+type GoModParams struct {
+	Command string `json:"command" mcp:"mod command (init, tidy, download, verify, why, graph)"`
+	Args    string `json:"args,omitempty" mcp:"additional arguments"`
+}
+
+// GoDocTool generates documentation for Go packages
+func (s *toolset) GoDocTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GodocParams]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"doc"}
+
+	if params.Arguments.PackagePath != "" {
+		args = append(args, params.Arguments.PackagePath)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error running go doc: %v\nOutput: %s", err, string(output))}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(output)}},
+	}, nil
+}
+
+// GoFmtTool formats Go source code
+func (s *toolset) GoFmtTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GoFmtParams]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"fmt"}
+
+	if params.Arguments.FilePath != "" {
+		// Check if file exists
+		if _, err := os.Stat(s.resolve(params.Arguments.FilePath)); os.IsNotExist(err) {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("File does not exist: %s", params.Arguments.FilePath)}},
+				IsError: true,
+			}, nil
+		}
+		args = append(args, params.Arguments.FilePath)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error running go fmt: %v\nOutput: %s", err, string(output))}},
+			IsError: true,
+		}, nil
+	}
+
+	result := "Go fmt completed successfully"
+	if len(output) > 0 {
+		result += "\nOutput: " + string(output)
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// GoVetTool examines Go source code and reports suspicious constructs
+func (s *toolset) GoVetTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GoVetParams]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"vet"}
+
+	if params.Arguments.PackagePath != "" {
+		args = append(args, params.Arguments.PackagePath)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Go vet found issues:\n%s", string(output))}},
+			IsError: false, // vet finding issues is not an error in tool execution
+		}, nil
+	}
+
+	result := "Go vet completed successfully - no issues found"
+	if len(output) > 0 {
+		result = fmt.Sprintf("Go vet output:\n%s", string(output))
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// GoTestTool runs Go tests
+func (s *toolset) GoTestTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GoTestParams]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"test"}
+
+	if params.Arguments.Verbose {
+		args = append(args, "-v")
+	}
+
+	if params.Arguments.Cover {
+		args = append(args, "-cover")
+	}
+
+	if params.Arguments.PackagePath != "" {
+		args = append(args, params.Arguments.PackagePath)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Go test failed:\n%s", string(output))}},
+			IsError: false, // test failures are not tool execution errors
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(output)}},
+	}, nil
+}
+
+// GoBuildTool builds Go packages
+func (s *toolset) GoBuildTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GoBuildParams]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"build"}
+
+	if params.Arguments.Output != "" {
+		args = append(args, "-o", params.Arguments.Output)
+	}
+
+	if params.Arguments.Tags != "" {
+		args = append(args, "-tags", params.Arguments.Tags)
+	}
+
+	if params.Arguments.PackagePath != "" {
+		args = append(args, params.Arguments.PackagePath)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Go build failed: %v\nOutput: %s", err, string(output))}},
+			IsError: true,
+		}, nil
+	}
+
+	result := "Go build completed successfully"
+	if len(output) > 0 {
+		result += "\nOutput: " + string(output)
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// GoModTool handles go mod commands
+func (s *toolset) GoModTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GoModParams]) (*mcp.CallToolResultFor[any], error) {
+	validCommands := map[string]bool{
+		"init":     true,
+		"tidy":     true,
+		"download": true,
+		"verify":   true,
+		"why":      true,
+		"graph":    true,
+	}
+
+	if !validCommands[params.Arguments.Command] {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Invalid mod command: %s. Valid commands: init, tidy, download, verify, why, graph", params.Arguments.Command)}},
+			IsError: true,
+		}, nil
+	}
+
+	args := []string{"mod", params.Arguments.Command}
+
+	if params.Arguments.Args != "" {
+		// Split additional arguments
+		additionalArgs := strings.Fields(params.Arguments.Args)
+		args = append(args, additionalArgs...)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Go mod %s failed: %v\nOutput: %s", params.Arguments.Command, err, string(output))}},
+			IsError: true,
+		}, nil
+	}
+
+	result := fmt.Sprintf("Go mod %s completed successfully", params.Arguments.Command)
+	if len(output) > 0 {
+		result += "\nOutput: " + string(output)
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: result}},
+	}, nil
+}
+
+// GoVersionTool gets Go version information
+func (s *toolset) GoVersionTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[struct{}]) (*mcp.CallToolResultFor[any], error) {
+	cmd := s.command(ctx, "version")
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error getting Go version: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(output)}},
+	}, nil
+}
+
+// GoEnvTool gets Go environment information
+func (s *toolset) GoEnvTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[struct {
+	Variable string `json:"variable,omitempty" mcp:"specific environment variable to get (optional)"`
+}]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"env"}
+
+	if params.Arguments.Variable != "" {
+		args = append(args, params.Arguments.Variable)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error getting Go environment: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(output)}},
+	}, nil
+}
+
+// GoListTool lists Go packages
+func (s *toolset) GoListTool(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[struct {
+	Pattern string `json:"pattern,omitempty" mcp:"package pattern to list (default: all packages in current module)"`
+	Json    bool   `json:"json,omitempty" mcp:"output in JSON format"`
+}]) (*mcp.CallToolResultFor[any], error) {
+	args := []string{"list"}
+
+	if params.Arguments.Json {
+		args = append(args, "-json")
+	}
+
+	if params.Arguments.Pattern != "" {
+		args = append(args, params.Arguments.Pattern)
+	}
+
+	cmd := s.command(ctx, args...)
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Error listing Go packages: %v\nOutput: %s", err, string(output))}},
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(output)}},
+	}, nil
+}
+
+// Options configures the godoc server
+type Options struct {
+	// Root is the directory the go command runs in (empty: the working
+	// directory)
+	Root string
+}
+
+// toolset holds the state shared by the tools
+type toolset struct {
+	root string
+}
+
+// New returns the godoc server with its tools registered
+func New(opts Options) (*mcp.Server, error) {
+	root := opts.Root
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root %s: %w", opts.Root, err)
+	}
+	s := &toolset{root: root}
+
+	// Create a server for Go development tools
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "godoc",
+		Version: "v1.0.0",
+	}, nil)
+
+	// Register tools
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_doc",
+		Description: "Generate documentation for Go packages using 'go doc'",
+	}, s.GoDocTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_fmt",
+		Description: "Format Go source code using 'go fmt'",
+	}, s.GoFmtTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_vet",
+		Description: "Examine Go source code and report suspicious constructs using 'go vet'",
+	}, s.GoVetTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_test",
+		Description: "Run Go tests using 'go test'",
+	}, s.GoTestTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_build",
+		Description: "Build Go packages using 'go build'",
+	}, s.GoBuildTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_mod",
+		Description: "Handle Go module operations using 'go mod'",
+	}, s.GoModTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_version",
+		Description: "Get Go version information",
+	}, s.GoVersionTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_env",
+		Description: "Get Go environment information",
+	}, s.GoEnvTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "go_mod_list",
+		Description: "List Go packages",
+	}, s.GoListTool)
+
+	return server, nil
+}
+
+// command returns the go command with args, run in the root
+func (s *toolset) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = s.root
+	return cmd
+}
+
+// resolve makes a relative path absolute against the root
+func (s *toolset) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.root, path)
+}
@@ -0,0 +1,24 @@
+// Package servers creates the MCP servers bundled with ttobot, to run them
+// in-process
+package servers
+
+import (
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/pkg/servers/filesystem"
+	"github.com/snowmerak/ttobot/pkg/servers/godoc"
+)
+
+// New returns the builtin server with the given name, working in root
+// (empty: the current directory)
+func New(name, root string) (*mcp.Server, error) {
+	switch name {
+	case mcpConfig.BuiltinFilesystem:
+		return filesystem.New(filesystem.Options{Root: root})
+	case mcpConfig.BuiltinGodoc:
+		return godoc.New(godoc.Options{Root: root})
+	}
+	return nil, fmt.Errorf("unknown builtin server %q", name)
+}
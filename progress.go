@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snowmerak/ttobot/pkg/mcp"
+)
+
+// progressInterval is how often the spinners of a live progress block turn
+const progressInterval = 100 * time.Millisecond
+
+// spinnerFrames animate the steps that are still running
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Step states of a startup progress block
+const (
+	stepRunning = iota
+	stepDone
+	stepWarning
+	stepFailed
+)
+
+// progressStep is one line of a startup progress block, such as a server
+// being connected
+type progressStep struct {
+	name    string
+	state   int
+	detail  string
+	started time.Time
+	elapsed time.Duration
+}
+
+// startupProgress shows what a starting session is waiting for, a line per
+// step. On a terminal the block is redrawn in place with spinners;
+// otherwise each step is printed once it settles. A nil startupProgress
+// shows nothing.
+type startupProgress struct {
+	out  io.Writer
+	live bool
+
+	lock   sync.Mutex
+	steps  []*progressStep
+	frame  int
+	drawn  int
+	closed bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newStartupProgress returns a progress block writing to out, redrawn in
+// place when out is a terminal (nil: quiet)
+func newStartupProgress(out io.Writer, quiet bool) *startupProgress {
+	if quiet {
+		return nil
+	}
+	p := &startupProgress{out: out}
+	if f, ok := out.(*os.File); ok && isTerminal(f) && os.Getenv("TERM") != "dumb" {
+		p.live = true
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.spin()
+	}
+	return p
+}
+
+// spin redraws the block until close, turning the spinners
+func (p *startupProgress) spin() {
+	defer close(p.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.lock.Lock()
+			p.frame++
+			p.drawLocked()
+			p.lock.Unlock()
+		}
+	}
+}
+
+// begin adds a running step
+func (p *startupProgress) begin(name string) *progressStep {
+	step := &progressStep{name: name, started: time.Now()}
+	if p == nil {
+		return step
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.steps = append(p.steps, step)
+	p.drawLocked()
+	return step
+}
+
+// finish settles a step with its state and a detail such as the number of
+// tools or the error
+func (p *startupProgress) finish(step *progressStep, state int, detail string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	step.state = state
+	step.detail = detail
+	step.elapsed = time.Since(step.started)
+	if p.live {
+		p.drawLocked()
+	} else {
+		fmt.Fprintln(p.out, p.lineLocked(step))
+	}
+}
+
+// close stops the spinners and leaves the block as it is; the steps still
+// running are not shown again. It may be called more than once.
+func (p *startupProgress) close() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return
+	}
+	p.drawLocked()
+	p.closed = true
+	p.lock.Unlock()
+	if p.live {
+		close(p.stop)
+		<-p.done
+	}
+}
+
+// drawLocked redraws the live block over the one drawn before
+func (p *startupProgress) drawLocked() {
+	if !p.live || p.closed || len(p.steps) == 0 {
+		return
+	}
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dF", p.drawn)
+	}
+	// Lines wider than the terminal wrap and break the redraw
+	width := terminalColumns(p.out.(*os.File)) - 4
+	for _, step := range p.steps {
+		line := p.lineLocked(step)
+		if width > 0 {
+			line = truncateRunes(line, width)
+		}
+		b.WriteString("\x1b[2K" + line + "\n")
+	}
+	p.drawn = len(p.steps)
+	io.WriteString(p.out, b.String())
+}
+
+// lineLocked formats a step, its name padded to the longest one
+func (p *startupProgress) lineLocked(step *progressStep) string {
+	width := 0
+	for _, s := range p.steps {
+		width = max(width, len(s.name))
+	}
+	switch step.state {
+	case stepDone:
+		return fmt.Sprintf("✅ %-*s  %s (%s)", width, step.name, step.detail, formatElapsed(step.elapsed))
+	case stepWarning:
+		return fmt.Sprintf("⚠️  %-*s  %s (%s)", width, step.name, step.detail, formatElapsed(step.elapsed))
+	case stepFailed:
+		return fmt.Sprintf("❌ %-*s  %s (%s)", width, step.name, step.detail, formatElapsed(step.elapsed))
+	}
+	frame := spinnerFrames[p.frame%len(spinnerFrames)]
+	return fmt.Sprintf("%s  %-*s  starting… %s", frame, width, step.name, formatElapsed(time.Since(step.started)))
+}
+
+// formatElapsed formats a duration of a startup step, such as 1.2s
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// connectFailure summarizes why a server could not be connected in one
+// line: the first line it wrote to stderr, or else the error
+func connectFailure(err error) string {
+	var connectErr *mcp.ConnectError
+	if errors.As(err, &connectErr) {
+		for _, line := range strings.Split(connectErr.Stderr, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				return line
+			}
+		}
+		err = connectErr.Err
+	}
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return line
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runREPL reads questions from standard input, one per line, and answers
+// each with the agent loop, streaming the answer, until /exit, the end of
+// input, or a shutdown. A failed question is reported and the session goes
+// on.
+func runREPL(ctx context.Context, a *app) error {
+	fmt.Fprintln(a.out, "💬 Ask a question, or /help for commands")
+	a.signals.setInteractive(true)
+	defer a.signals.setInteractive(false)
+
+	for {
+		fmt.Fprint(a.out, a.prompt())
+		line, err := a.readLine(ctx)
+		if err != nil && line == "" {
+			fmt.Fprintln(a.out)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		// The line answers the exit question asked on Ctrl-C
+		if a.signals.takeConfirmation() {
+			if answer := strings.ToLower(strings.TrimSpace(line)); answer == "y" || answer == "yes" {
+				return nil
+			}
+			continue
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Only input starting with a slash is a command; anything else,
+		// even if it contains one, is a question
+		if strings.HasPrefix(line, "/") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+			if name == "/exit" || name == "/quit" {
+				return nil
+			}
+			command, ok := findCommand(name)
+			if !ok {
+				fmt.Fprintf(a.out, "Unknown command %s. Available: %s\n", name, commandNames())
+				continue
+			}
+			if err := command.run(a, ctx, strings.TrimSpace(arg)); err != nil {
+				fmt.Fprintf(a.out, "❌ %v\n", err)
+			}
+			continue
+		}
+
+		question, err := a.readQuestion(ctx, line)
+		if err != nil {
+			fmt.Fprintln(a.out)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := a.askStreaming(ctx, a.withAttachments(question)); err != nil {
+			fmt.Fprintf(a.out, "❌ %v\n", err)
+		}
+	}
+}
+
+// continuationPrompt is shown for the lines after the first of a question
+const continuationPrompt = "… "
+
+// prompt returns the prompt of a question, counting the pending
+// attachments
+func (a *app) prompt() string {
+	if len(a.attachments) > 0 {
+		return fmt.Sprintf("📎%d > ", len(a.attachments))
+	}
+	return "> "
+}
+
+// readQuestion reads the rest of a question that starts with line and
+// spans several lines: one opened with """ runs to a line ending with """,
+// and a line ended with Alt-Enter continues on the next
+func (a *app) readQuestion(ctx context.Context, line string) (string, error) {
+	if rest, ok := strings.CutPrefix(line, `"""`); ok {
+		var lines []string
+		for {
+			if text, ok := strings.CutSuffix(strings.TrimRight(rest, " \t"), `"""`); ok {
+				lines = append(lines, text)
+				return strings.Trim(strings.Join(lines, "\n"), "\n"), nil
+			}
+			lines = append(lines, rest)
+			next, err := a.readContinuation(ctx)
+			if err != nil {
+				return "", err
+			}
+			rest = next
+		}
+	}
+
+	// Alt-Enter sends an escape before the newline
+	for strings.HasSuffix(line, "\x1b") {
+		next, err := a.readContinuation(ctx)
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSuffix(line, "\x1b") + "\n" + next
+	}
+	return line, nil
+}
+
+// readContinuation reads the next line of a question spanning several
+// lines, without its line ending
+func (a *app) readContinuation(ctx context.Context) (string, error) {
+	fmt.Fprint(a.out, continuationPrompt)
+	line, err := a.readLine(ctx)
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// pasteCommand reads a question over several lines, up to a line holding
+// only a dot, and asks it
+func (a *app) pasteCommand(ctx context.Context, arg string) error {
+	fmt.Fprintln(a.out, "📋 Paste the question, then a line with only . to send it")
+	var lines []string
+	for {
+		line, err := a.readContinuation(ctx)
+		if err != nil {
+			return fmt.Errorf("input ended before the line with only .: %w", err)
+		}
+		if line == "." {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	question := strings.Trim(strings.Join(lines, "\n"), "\n")
+	if strings.TrimSpace(question) == "" {
+		fmt.Fprintln(a.out, "Nothing was pasted")
+		return nil
+	}
+	return a.askStreaming(ctx, a.withAttachments(question))
+}
+
+// resumeCommand lists the recent sessions or, given a number from that
+// list, resumes that session
+func (a *app) resumeCommand(choice string) error {
+	sessions, err := a.recentSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(a.out, "No saved sessions")
+		return nil
+	}
+	if choice == "" {
+		a.printSessions(sessions)
+		return nil
+	}
+	// Keep what was said so far before switching
+	a.saveSession()
+	return a.resumeChoice(sessions, choice)
+}
+
+// lineRead is the outcome of reading a line from standard input
+type lineRead struct {
+	line string
+	err  error
+}
+
+// readLine reads a line from standard input, giving up when ctx ends. A
+// read given up on is picked up by the next call, so the line typed for it
+// is not lost.
+func (a *app) readLine(ctx context.Context) (string, error) {
+	a.readLock.Lock()
+	defer a.readLock.Unlock()
+
+	if a.pendingRead == nil {
+		lines := make(chan lineRead, 1)
+		go func() {
+			line, err := a.in.ReadString('\n')
+			lines <- lineRead{line, err}
+		}()
+		a.pendingRead = lines
+	}
+
+	select {
+	case result := <-a.pendingRead:
+		a.pendingRead = nil
+		return result.line, result.err
+	case <-ctx.Done():
+		return "", context.Cause(ctx)
+	}
+}
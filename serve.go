@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/pkg/api"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+)
+
+// runServe serves the agent over HTTP until SIGINT or SIGTERM:
+// ttobot serve [-listen addr] [flags]
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFlag := flags.String("config", "", "config file to use instead of "+mcpConfig.ConfigEnvVar+" and the default paths")
+	listen := flags.String("listen", "", "address to listen on (default: serve.listen, or "+mcpConfig.DefaultServeListen+")")
+	dryRun := flags.Bool("dry-run", false, "record the calls of tools that are not read-only instead of running them")
+	var flagOverrides mcpConfig.Overrides
+	overrideFlags(flags, &flagOverrides)
+	var logOpts logOptions
+	logFlags(flags, &logOpts)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ./ttobot serve [-listen 127.0.0.1:8080] [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// A server logs its requests by default
+	closeLog, err := setupLogging(logOpts, false, slog.LevelInfo)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	configFile, err := loadConfig(*configFlag, flagOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	serve := configFile.Serve
+	addr := serve.ListenAddress()
+	if *listen != "" {
+		addr = *listen
+	}
+	token := serve.AuthToken()
+	if serve.AuthTokenEnv != "" && token == "" {
+		return fmt.Errorf("serve.auth_token_env names %s, which is not set", serve.AuthTokenEnv)
+	}
+	if token == "" {
+		// The API runs tools, so only this machine may reach it unauthenticated
+		if !mcpConfig.IsLoopbackAddress(addr) {
+			return fmt.Errorf("refusing to listen on %s without authentication: set serve.auth_token_env or listen on a loopback address", addr)
+		}
+		slog.Warn("API: No serve.auth_token_env set, requests are not authenticated")
+	}
+
+	ctx, signals := handleSignals(context.Background(), os.Stderr)
+	a, err := newApp(ctx, configFile, appOptions{dryRun: *dryRun, serving: true, signals: signals, quiet: logOpts.quiet})
+	if err != nil {
+		if code := exitCode(context.Cause(ctx)); code != 0 {
+			os.Exit(code)
+		}
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	defer a.close()
+
+	server := api.New(api.Options{
+		Provider:     a.provider,
+		ToolHandler:  a.toolHandler,
+		Tools:        a.tools,
+		SystemPrompt: a.conversation.SystemPrompt(),
+		Opts:         a.opts,
+		SelectTools:  a.selectTools,
+		ServerStatus: func(ctx context.Context) ([]mcp.ServerStatus, error) {
+			return a.mcpClient.Status(ctx, configFile.Servers, serverPingTimeout)
+		},
+		AuthToken:  token,
+		SessionTTL: serve.SessionTTLDuration(),
+	})
+	if err := server.ListenAndServe(ctx, addr); err != nil {
+		return err
+	}
+	a.finish()
+	slog.Info("Shutdown", "cause", context.Cause(ctx))
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// Session persistence limits
+const (
+	// keptSessions is how many saved sessions are kept; older ones are
+	// deleted at startup
+	keptSessions = 100
+
+	// listedSessions is how many sessions /resume lists
+	listedSessions = 10
+)
+
+// startSession picks the file the conversation is saved to and prunes old
+// sessions. Without a sessions directory, nothing is saved.
+func (a *app) startSession() {
+	dir, err := llm.DefaultSessionsDir()
+	if err != nil {
+		slog.Warn("Session: Not saving the conversation", "error", err)
+		return
+	}
+	a.sessionsDir = dir
+	a.sessionPath = llm.SessionPath(dir, time.Now().Format("2006-01-02T15-04-05"))
+
+	if removed, err := llm.PruneSessions(dir, keptSessions, 0); err != nil {
+		slog.Warn("Session: Failed to prune old sessions", "error", err)
+	} else if len(removed) > 0 {
+		slog.Info("Session: Removed old sessions", "sessions", len(removed))
+	}
+}
+
+// saveSession writes the conversation to its session file, if it has
+// anything to save; failures are logged, not returned
+func (a *app) saveSession() {
+	if a.sessionPath == "" || len(a.conversation.History()) == 0 {
+		return
+	}
+	if err := a.conversation.Save(a.sessionPath); err != nil {
+		slog.Warn("Session: Failed to save the conversation", "error", err)
+	}
+}
+
+// recentSessions returns the most recently saved sessions, newest first
+func (a *app) recentSessions() ([]llm.SessionInfo, error) {
+	if a.sessionsDir == "" {
+		return nil, fmt.Errorf("sessions are not saved on this system")
+	}
+	sessions, err := llm.ListSessions(a.sessionsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) > listedSessions {
+		sessions = sessions[:listedSessions]
+	}
+	return sessions, nil
+}
+
+// printSessions lists sessions, numbered from 1
+func (a *app) printSessions(sessions []llm.SessionInfo) {
+	for i, session := range sessions {
+		first := session.FirstMessage
+		if runes := []rune(first); len(runes) > 60 {
+			first = string(runes[:59]) + "…"
+		}
+		fmt.Fprintf(a.out, "%2d. %s  %3d messages  %s\n", i+1, session.SavedAt.Local().Format("2006-01-02 15:04"), session.Messages, first)
+	}
+}
+
+// resumeSession replaces the conversation with a saved session and keeps
+// saving to its file. The current system prompt is kept, since the tools
+// may have changed since the session was saved.
+func (a *app) resumeSession(session llm.SessionInfo) error {
+	systemPrompt := a.conversation.SystemPrompt()
+	if err := a.conversation.Load(session.Path); err != nil {
+		return err
+	}
+	a.conversation.SetSystemPrompt(systemPrompt)
+	a.sessionPath = session.Path
+
+	fmt.Fprintf(a.out, "↩️  Resumed the session of %s (%d messages)\n", session.SavedAt.Local().Format("2006-01-02 15:04"), session.Messages)
+	if missing := a.conversation.MissingTools(a.tools); len(missing) > 0 {
+		fmt.Fprintf(a.out, "⚠️  Tools used in this session are no longer available: %s\n", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// pickSession lists the recent sessions and resumes the one chosen on
+// standard input; an empty answer picks the newest
+func (a *app) pickSession() error {
+	sessions, err := a.recentSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(a.out, "No saved sessions")
+		return nil
+	}
+
+	a.printSessions(sessions)
+	fmt.Fprintf(a.out, "Resume which session? [1] ")
+	line, err := a.in.ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("no session chosen")
+	}
+	return a.resumeChoice(sessions, line)
+}
+
+// resumeChoice resumes the session numbered by choice in the list shown to
+// the user (empty: the first)
+func (a *app) resumeChoice(sessions []llm.SessionInfo, choice string) error {
+	choice = strings.TrimSpace(choice)
+	if choice == "" {
+		choice = "1"
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(sessions) {
+		return fmt.Errorf("expected a session number between 1 and %d, got %q", len(sessions), choice)
+	}
+	return a.resumeSession(sessions[n-1])
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds how long the servers get to exit before they are killed
+const shutdownTimeout = 5 * time.Second
+
+// Causes of a shutdown by signal
+var (
+	errInterrupted = errors.New("interrupted")
+	errTerminated  = errors.New("terminated")
+)
+
+// signalHandler decides what SIGINT and SIGTERM do. SIGTERM shuts down.
+// SIGINT stops the running turn of an interactive session; at the prompt it
+// asks whether to exit, and a second SIGINT exits without asking. Outside
+// an interactive session SIGINT shuts down. A signal received while
+// shutting down exits at once.
+type signalHandler struct {
+	cancel context.CancelCauseFunc
+	out    io.Writer
+
+	lock sync.Mutex
+
+	// interactive is set while the interactive session reads questions
+	interactive bool
+
+	// cancelTurn stops the running turn (nil: at the prompt)
+	cancelTurn context.CancelFunc
+
+	// confirming is set while the exit question is unanswered
+	confirming bool
+
+	// shuttingDown is set once cancel was called
+	shuttingDown bool
+}
+
+// handleSignals returns a context that ends with the shutdown cause when a
+// signal asks for a shutdown, and the handler deciding that
+func handleSignals(parent context.Context, out io.Writer) (context.Context, *signalHandler) {
+	ctx, cancel := context.WithCancelCause(parent)
+	h := &signalHandler{cancel: cancel, out: out}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			h.handle(sig)
+		}
+	}()
+	return ctx, h
+}
+
+// handle acts on one signal
+func (h *signalHandler) handle(sig os.Signal) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	switch {
+	case h.shuttingDown:
+		slog.Warn("Shutdown: Received the signal again, exiting without cleanup", "signal", sig)
+		os.Exit(exitCode(errInterrupted))
+	case sig == syscall.SIGTERM:
+		h.shutdown(errTerminated)
+	case !h.interactive || h.confirming:
+		h.shutdown(errInterrupted)
+	case h.cancelTurn != nil:
+		h.cancelTurn()
+		h.cancelTurn = nil
+	default:
+		h.confirming = true
+		fmt.Fprint(h.out, "\nExit ttobot? [y/N] ")
+	}
+}
+
+// shutdown cancels the context of the whole run; the caller holds lock
+func (h *signalHandler) shutdown(cause error) {
+	h.shuttingDown = true
+	h.cancel(cause)
+}
+
+// setInteractive marks whether an interactive session is reading questions
+func (h *signalHandler) setInteractive(interactive bool) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.interactive = interactive
+}
+
+// turn returns the context of one turn of the interactive session, which
+// SIGINT cancels, and the function ending the turn
+func (h *signalHandler) turn(ctx context.Context) (context.Context, context.CancelFunc) {
+	turnCtx, cancel := context.WithCancel(ctx)
+	if h == nil {
+		return turnCtx, cancel
+	}
+
+	h.lock.Lock()
+	h.cancelTurn = cancel
+	h.lock.Unlock()
+	return turnCtx, func() {
+		h.lock.Lock()
+		h.cancelTurn = nil
+		h.lock.Unlock()
+		cancel()
+	}
+}
+
+// takeConfirmation reports whether the exit question is waiting for the
+// next line of input, and clears it
+func (h *signalHandler) takeConfirmation() bool {
+	if h == nil {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	confirming := h.confirming
+	h.confirming = false
+	return confirming
+}
+
+// exitCode is the conventional exit code of a shutdown by signal, 128 plus
+// the signal number (zero: not a signal)
+func exitCode(cause error) int {
+	switch {
+	case errors.Is(cause, errTerminated):
+		return 128 + int(syscall.SIGTERM)
+	case errors.Is(cause, errInterrupted):
+		return 128 + int(syscall.SIGINT)
+	}
+	return 0
+}
+
+// close disconnects the servers, killing those that do not exit within
+// shutdownTimeout
+func (a *app) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.mcpClient.Close(ctx); err != nil {
+		slog.Warn("Shutdown: Failed to disconnect the servers", "error", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+)
+
+// sessionStats totals the turns of a session, for /stats and the line
+// printed when the session ends
+type sessionStats struct {
+	turns      int
+	iterations int
+	usage      llm.Usage
+
+	// elapsed is the wall-clock time of the turns
+	elapsed time.Duration
+}
+
+// record adds a turn that took elapsed; runs that failed before any
+// request are not counted
+func (s *sessionStats) record(result *llm.AgentResult, elapsed time.Duration) {
+	if result == nil || result.Usage.Requests == 0 {
+		return
+	}
+	s.turns++
+	s.iterations += result.Iterations
+	s.usage.Add(result.Usage)
+	s.elapsed += elapsed
+}
+
+// turnStats formats the line printed after an answer, such as
+// "prompt 1.2k tok, completion 345 tok · 9.6s: model 8.3s, tools 1.2s ·
+// 3 tool calls, slowest fs:read_file 0.9s · 2 iterations"
+func turnStats(result *llm.AgentResult, elapsed time.Duration) string {
+	parts := []string{tokenStats(result.Usage), timeStats(result.Usage, elapsed)}
+	if calls := result.Usage.ToolCalls; calls > 0 {
+		part := fmt.Sprintf("%d tool calls", calls)
+		if name, duration := slowestCall(result.Messages); name != "" {
+			part += fmt.Sprintf(", slowest %s %s", name, formatElapsed(duration))
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, fmt.Sprintf("%d iterations", result.Iterations))
+	return strings.Join(parts, " · ")
+}
+
+// tokenStats formats the tokens of usage
+func tokenStats(usage llm.Usage) string {
+	return fmt.Sprintf("prompt %s tok, completion %s tok", llm.FormatTokens(usage.PromptTokens), llm.FormatTokens(usage.CompletionTokens))
+}
+
+// timeStats splits the wall-clock time elapsed between the model and the
+// tools
+func timeStats(usage llm.Usage, elapsed time.Duration) string {
+	part := fmt.Sprintf("%s: model %s", formatElapsed(elapsed), formatElapsed(usage.TotalDuration))
+	if usage.LoadDuration >= 100*time.Millisecond {
+		part += fmt.Sprintf(" (load %s)", formatElapsed(usage.LoadDuration))
+	}
+	if usage.ToolCalls > 0 {
+		part += ", tools " + formatElapsed(usage.ToolDuration)
+	}
+	return part
+}
+
+// slowestCall returns the tool whose call took longest among the tool
+// messages
+func slowestCall(messages []llm.Message) (string, time.Duration) {
+	var name string
+	var slowest time.Duration
+	for _, message := range messages {
+		if message.Role == llm.RoleTool && message.Duration > slowest {
+			name, slowest = message.ToolName, message.Duration
+		}
+	}
+	return name, slowest
+}
+
+// printTurnStats prints the stats line of a turn and adds it to the
+// session totals; -no-stats hides the line
+func (a *app) printTurnStats(result *llm.AgentResult, elapsed time.Duration) {
+	a.stats.record(result, elapsed)
+	if !a.options.noStats {
+		fmt.Fprintf(a.out, "📊 %s\n", turnStats(result, elapsed))
+	}
+}
+
+// printSessionStats prints the session totals on one line
+func (a *app) printSessionStats() {
+	s := a.stats
+	fmt.Fprintf(a.out, "📊 Session: %d turns · %s · %s · %d tool calls · %d iterations\n",
+		s.turns, tokenStats(s.usage), timeStats(s.usage, s.elapsed), s.usage.ToolCalls, s.iterations)
+}
+
+// statsCommand shows the session totals and the calls made to each tool
+func (a *app) statsCommand() {
+	if a.stats.turns == 0 {
+		fmt.Fprintln(a.out, "Nothing has run yet")
+		return
+	}
+	a.printSessionStats()
+
+	tools := a.mcpClient.Stats()
+	if len(tools) == 0 {
+		return
+	}
+	width := 0
+	for _, t := range tools {
+		width = max(width, len(t.Name()))
+	}
+	fmt.Fprintln(a.out, "🔧 Tool calls:")
+	for _, t := range tools {
+		line := fmt.Sprintf("   %-*s  %d calls, %s total, slowest %s", width, t.Name(), t.Calls, formatElapsed(t.Duration), formatElapsed(t.Slowest))
+		if t.Errors > 0 {
+			line += fmt.Sprintf(", %d failed", t.Errors)
+		}
+		fmt.Fprintln(a.out, line)
+	}
+}
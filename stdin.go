@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// maxStdinBytes caps the piped input attached to a question; the rest is
+// dropped with a notice
+const maxStdinBytes = 256 << 10
+
+// errBinaryInput is returned for piped input that is not text
+var errBinaryInput = errors.New("standard input looks like binary data, not text; pipe text, such as the output of git diff or cat, or name the file in the question so a tool can read it")
+
+// pipedInput is text piped to ttobot alongside the question
+type pipedInput struct {
+	text string
+
+	// size is the full size of the input; text holds less when it exceeds
+	// maxStdinBytes
+	size int64
+}
+
+// truncated reports whether text holds only part of the input
+func (p pipedInput) truncated() bool {
+	return int64(len(p.text)) < p.size
+}
+
+// readPipedInput reads r to the end, keeping the first limit bytes
+func readPipedInput(r io.Reader, limit int) (pipedInput, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)))
+	if err != nil {
+		return pipedInput{}, fmt.Errorf("failed to read standard input: %w", err)
+	}
+	// Drain the rest so the writer is not cut off, counting what is dropped
+	rest, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return pipedInput{}, fmt.Errorf("failed to read standard input: %w", err)
+	}
+	input := pipedInput{size: int64(len(data)) + rest}
+
+	if rest > 0 {
+		// Do not end on a rune cut in half
+		for i := 1; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return pipedInput{}, errBinaryInput
+	}
+	input.text = string(data)
+	return input, nil
+}
+
+// attachTo appends the input to the question as a delimited block; without
+// input it returns the question unchanged
+func (p pipedInput) attachTo(question string) string {
+	if p.size == 0 {
+		return question
+	}
+
+	var b bytes.Buffer
+	b.WriteString(question)
+	writeAttached(&b, "input", "standard input", p.text, int64(len(p.text)), p.size)
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snowmerak/ttobot/lib/llm"
+	"github.com/snowmerak/ttobot/pkg/markdown"
+)
+
+// ANSI sequences for dimmed text
+const (
+	dimStart = "\x1b[2m"
+	dimEnd   = "\x1b[0m"
+)
+
+// streamPrinter writes the events of a streamed agent run to the terminal
+type streamPrinter struct {
+	a *app
+
+	// answer renders the answer's markdown a line at a time (nil: the
+	// answer is printed as generated)
+	answer *markdown.Renderer
+
+	// atLineStart is false while a line of generated text is open
+	atLineStart bool
+
+	// thinking is true while dimmed reasoning is being printed
+	thinking bool
+}
+
+// newStreamPrinter creates a printer for one turn
+func newStreamPrinter(a *app) *streamPrinter {
+	p := &streamPrinter{a: a, atLineStart: true}
+	if a.renderMarkdown {
+		p.answer = markdown.NewRenderer(a.out, a.markdownOptions())
+	}
+	return p
+}
+
+// newline ends an open line of generated text
+func (p *streamPrinter) newline() {
+	p.endThinking()
+	if p.answer != nil {
+		p.answer.Flush()
+	}
+	if !p.atLineStart {
+		fmt.Fprintln(p.a.out)
+		p.atLineStart = true
+	}
+}
+
+// endThinking closes dimmed reasoning text
+func (p *streamPrinter) endThinking() {
+	if p.thinking {
+		fmt.Fprint(p.a.out, dimEnd)
+		p.thinking = false
+	}
+}
+
+// write prints generated text
+func (p *streamPrinter) write(text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprint(p.a.out, text)
+	p.atLineStart = strings.HasSuffix(text, "\n")
+}
+
+// handle prints one event; it implements the StreamWithTools callback
+func (p *streamPrinter) handle(event llm.StreamEvent) error {
+	switch event.Kind {
+	case llm.EventToken:
+		if event.Thinking != "" && p.a.showThinking {
+			if !p.thinking && p.a.color {
+				fmt.Fprint(p.a.out, dimStart)
+				p.thinking = true
+			}
+			p.write(event.Thinking)
+		}
+		if event.Content != "" {
+			p.endThinking()
+			if p.answer == nil {
+				p.write(event.Content)
+				break
+			}
+			// The renderer prints whole lines only
+			if !p.atLineStart {
+				fmt.Fprintln(p.a.out)
+				p.atLineStart = true
+			}
+			p.answer.Write([]byte(event.Content))
+		}
+	case llm.EventToolCall:
+		p.newline()
+		if p.a.verbose {
+			fmt.Fprintf(p.a.out, "🔧 %s %v\n", event.ToolCall.Name, event.ToolCall.Arguments)
+		} else {
+			fmt.Fprintf(p.a.out, "🔧 %s\n", event.ToolCall.Name)
+		}
+	case llm.EventToolResult:
+		if p.a.verbose {
+			fmt.Fprintf(p.a.out, "📄 %s\n%s\n", event.ToolCall.Name, indent(truncateRunes(strings.TrimSpace(event.Content), traceResultChars), "   "))
+		}
+	case llm.EventDone:
+		p.newline()
+	}
+	return nil
+}
+
+// truncateRunes shortens s to limit runes, marking the cut
+func truncateRunes(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return s
+}
+
+// askStreaming runs the agent loop on a question like ask, printing the
+// answer as it is generated and each tool call as it is made. Ctrl-C stops
+// only this turn: the text generated so far stays in the conversation,
+// marked as interrupted.
+func (a *app) askStreaming(ctx context.Context, question string) error {
+	a.conversation.AddUser(question)
+
+	turnCtx, endTurn := a.signals.turn(ctx)
+	defer endTurn()
+
+	printer := newStreamPrinter(a)
+	started := time.Now()
+	result, err := llm.StreamWithTools(turnCtx, a.provider, a.toolHandler, a.conversation, printer.handle, a.turnOpts(question))
+	printer.newline()
+	a.lastRun = result
+	a.saveSession()
+
+	if err != nil {
+		a.stats.record(result, time.Since(started))
+		// Only our own interrupt is a normal end of the turn
+		if turnCtx.Err() != nil && ctx.Err() == nil {
+			fmt.Fprintln(a.out, "⏹️  Interrupted")
+			return nil
+		}
+		if advice := errorAdvice(err, a.config); advice != "" {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", advice)
+		}
+		return err
+	}
+
+	if result.Incomplete {
+		fmt.Fprintf(a.out, "⚠️  Stopped after %d model calls; this summarizes the findings so far.\n", result.Iterations)
+	}
+	a.printTurnStats(result, time.Since(started))
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os"
+
+// terminalColumns reports an unknown width: the size of the terminal is
+// only queried on Linux, macOS and the BSDs
+func terminalColumns(f *os.File) int {
+	return 0
+}
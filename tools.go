@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	mcpConfig "github.com/snowmerak/ttobot/lib/mcp"
+	"github.com/snowmerak/ttobot/lib/tool"
+	"github.com/snowmerak/ttobot/pkg/mcp"
+)
+
+// errToolError is returned when a tool called with ttobot tools call
+// reports an error; its result is printed already
+var errToolError = errors.New("the tool reported an error")
+
+// runTools inspects and calls the tools of the configured servers without
+// a model: ttobot tools list | describe <name> | call <name> [-args json]
+func runTools(args []string) error {
+	flags := flag.NewFlagSet("tools", flag.ExitOnError)
+	configFlag := flags.String("config", "", "config file to use instead of "+mcpConfig.ConfigEnvVar+" and the default paths")
+	argsFlag := flags.String("args", "{}", "with call, the arguments of the tool as a JSON object")
+	var flagOverrides mcpConfig.Overrides
+	overrideFlags(flags, &flagOverrides)
+	var logOpts logOptions
+	logFlags(flags, &logOpts)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ./ttobot tools list [flags]")
+		fmt.Fprintln(flags.Output(), "       ./ttobot tools describe <name> [flags]")
+		fmt.Fprintln(flags.Output(), "       ./ttobot tools call <name> [-args '{\"path\": \"go.mod\"}'] [flags]")
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before and after the action and tool name
+	flags.Parse(args)
+	var positional []string
+	for flags.NArg() > 0 {
+		positional = append(positional, flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	action, names := positional[0], positional[1:]
+	switch {
+	case action != "list" && action != "describe" && action != "call":
+		fmt.Fprintf(flags.Output(), "Unknown action %q\n", action)
+		flags.Usage()
+		os.Exit(2)
+	case action == "list" && len(names) > 0, action != "list" && len(names) != 1:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	closeLog, err := setupLogging(logOpts, false, slog.LevelWarn)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	configFile, err := loadConfig(*configFlag, flagOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ctx, _ := handleSignals(context.Background(), os.Stderr)
+	client := mcp.NewClient("ttobot", "1.0.0")
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := client.Close(closeCtx); err != nil {
+			slog.Warn("Shutdown: Failed to disconnect the servers", "error", err)
+		}
+	}()
+	if err := client.ConnectFromConfigs(context.WithoutCancel(ctx), configFile.Servers); err != nil {
+		return fmt.Errorf("failed to connect to MCP servers: %w", err)
+	}
+	if err := connectBuiltins(context.WithoutCancel(ctx), client, configFile, nil); err != nil {
+		return err
+	}
+	registry, err := client.Registry(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tools: %w", err)
+	}
+
+	if action == "list" {
+		printToolList(os.Stdout, registry.Snapshot())
+		return nil
+	}
+	t, ok := registry.Get(names[0])
+	if !ok {
+		return fmt.Errorf("tool %s not found; ttobot tools list shows the tools", names[0])
+	}
+	if action == "describe" {
+		return describeTool(os.Stdout, t)
+	}
+
+	return callTool(ctx, t, *argsFlag)
+}
+
+// printToolList lists the tools grouped by server, each with its
+// description and parameters; optional parameters are marked with ?
+func printToolList(w io.Writer, tools []tool.Tool) {
+	if len(tools) == 0 {
+		fmt.Fprintln(w, "No tools are offered")
+		return
+	}
+
+	byServer := make(map[string][]tool.Tool)
+	var servers []string
+	for _, t := range tools {
+		if _, ok := byServer[t.Server]; !ok {
+			servers = append(servers, t.Server)
+		}
+		byServer[t.Server] = append(byServer[t.Server], t)
+	}
+	sort.Strings(servers)
+	width := 0
+	for _, t := range tools {
+		width = max(width, len(t.Name))
+	}
+
+	for _, server := range servers {
+		fmt.Fprintf(w, "📦 %s (%d tools)\n", server, len(byServer[server]))
+		for _, t := range byServer[server] {
+			description, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+			fmt.Fprintf(w, "   %-*s  %s\n", width, t.Name, description)
+			if parameters := parameterSummary(t.Function.Parameters); parameters != "" {
+				fmt.Fprintf(w, "   %-*s  (%s)\n", width, "", parameters)
+			}
+		}
+	}
+}
+
+// parameterSummary lists the parameters of a schema with their types, the
+// required ones first
+func parameterSummary(schema tool.ParameterSchema) string {
+	required := make(map[string]bool, len(schema.Required))
+	var names []string
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; ok && !required[name] {
+			required[name] = true
+			names = append(names, name)
+		}
+	}
+	var optional []string
+	for name := range schema.Properties {
+		if !required[name] {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+
+	parameters := make([]string, 0, len(schema.Properties))
+	for _, name := range append(names, optional...) {
+		marker := ""
+		if !required[name] {
+			marker = "?"
+		}
+		parameters = append(parameters, fmt.Sprintf("%s%s: %s", name, marker, parameterType(schema.Properties[name])))
+	}
+	return strings.Join(parameters, ", ")
+}
+
+// parameterType names the type of a parameter briefly, such as string,
+// string|null or string[]
+func parameterType(p tool.PropertyDefinition) string {
+	switch {
+	case len(p.Types) > 0:
+		return strings.Join(p.Types, "|")
+	case p.PrimaryType() == "array" && p.Items != nil && p.Items.PrimaryType() != "":
+		return p.Items.PrimaryType() + "[]"
+	case p.PrimaryType() != "":
+		return p.PrimaryType()
+	}
+	return "any"
+}
+
+// toolDescription is the JSON written by ttobot tools describe
+type toolDescription struct {
+	Name         string               `json:"name"`
+	Server       string               `json:"server,omitempty"`
+	OriginalName string               `json:"original_name,omitempty"`
+	Title        string               `json:"title,omitempty"`
+	Description  string               `json:"description"`
+	ReadOnly     bool                 `json:"read_only"`
+	Destructive  bool                 `json:"destructive"`
+	Tags         []string             `json:"tags,omitempty"`
+	Parameters   tool.ParameterSchema `json:"parameters"`
+}
+
+// describeTool writes a tool with its full parameter schema as JSON
+func describeTool(w io.Writer, t tool.Tool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toolDescription{
+		Name:         t.Name,
+		Server:       t.Server,
+		OriginalName: t.OriginalName,
+		Title:        t.Title,
+		Description:  t.Description,
+		ReadOnly:     t.ReadOnly,
+		Destructive:  t.Destructive,
+		Tags:         t.Tags,
+		Parameters:   t.Function.Parameters,
+	})
+}
+
+// callTool validates the arguments, calls the tool and writes its result
+// to standard output. It returns errToolError if the tool reported an error.
+func callTool(ctx context.Context, t tool.Tool, argsJSON string) error {
+	var arguments map[string]any
+	if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
+		return fmt.Errorf("invalid -args: expected a JSON object: %w", err)
+	}
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+
+	problems := t.Function.Parameters.ValidateArguments(arguments)
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", problem.Error())
+		} else {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", problem.Error())
+		}
+	}
+	if tool.HasErrors(problems) {
+		return fmt.Errorf("invalid arguments for %s", t.Name)
+	}
+
+	result, err := t.Execute(ctx, arguments)
+	if err != nil {
+		return fmt.Errorf("tool call failed: %w", err)
+	}
+	text := result.Text()
+	fmt.Fprint(os.Stdout, text)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		fmt.Fprintln(os.Stdout)
+	}
+	if result.IsError {
+		return errToolError
+	}
+	return nil
+}